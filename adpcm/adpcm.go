@@ -0,0 +1,39 @@
+/*
+Package adpcm implements the IMA and Microsoft ADPCM block codecs used in WAV
+files. Both code 16bit samples with 4 bits each, in blocks that start with a
+header holding the decoder state, so every block can be decoded on its own.
+
+Blocks are blockSize bytes, the block align of the WAV file. Multi-channel
+blocks hold interleaved frames.
+*/
+package adpcm
+
+import (
+	"errors"
+)
+
+var (
+	ErrBlockSize = errors.New("invalid block size")
+	ErrChannels  = errors.New("invalid number of channels")
+	ErrFrameSize = errors.New("frame doesn't fit the block")
+	ErrShort     = errors.New("buffer is too small")
+)
+
+func clamp16(v int) int {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return v
+}
+
+func putInt16(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+}
+
+func getInt16(b []byte) int {
+	return int(int16(uint16(b[0]) | uint16(b[1])<<8))
+}
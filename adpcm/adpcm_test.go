@@ -0,0 +1,145 @@
+package adpcm
+
+import (
+	"math"
+	"testing"
+)
+
+// tones returns interleaved tones of different frequencies per channel.
+func tones(n, channels int) []int16 {
+	out := make([]int16, n*channels)
+	for i := 0; i < n; i++ {
+		for c := 0; c < channels; c++ {
+			freq := 440 * float64(c+1)
+			out[i*channels+c] = int16(10000 * math.Sin(2*math.Pi*freq*float64(i)/8000))
+		}
+	}
+	return out
+}
+
+func snr(input, output []int16) float64 {
+	var signal, noise float64
+	for i := range input {
+		s := float64(input[i])
+		e := float64(output[i]) - s
+		signal += s * s
+		noise += e * e
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+type encoder interface {
+	SamplesPerBlock() int
+	Encode(frame []int16, block []byte) (int, error)
+}
+
+type decoder interface {
+	Decode(block []byte, frame []int16) (int, error)
+}
+
+func roundTrip(t *testing.T, enc encoder, dec decoder, input []int16, channels, blockSize int) []int16 {
+	var output []int16
+	block := make([]byte, blockSize)
+	frame := make([]int16, enc.SamplesPerBlock()*channels)
+	step := enc.SamplesPerBlock() * channels
+	for i := 0; i < len(input); i += step {
+		end := i + step
+		if end > len(input) {
+			end = len(input)
+		}
+		n, err := enc.Encode(input[i:end], block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if end-i == step && n != blockSize {
+			t.Fatalf("block is %d bytes", n)
+		}
+		if n, err = dec.Decode(block[:n], frame); err != nil {
+			t.Fatal(err)
+		}
+		if n < end-i {
+			t.Fatalf("decoded %d of %d samples", n, end-i)
+		}
+		output = append(output, frame[:end-i]...)
+	}
+	return output
+}
+
+func TestIMA_RoundTrip(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		blockSize := 256 * channels
+		enc, err := NewIMAEncoder(channels, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := NewIMADecoder(channels, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := dec.SamplesPerBlock(); n != 505 {
+			t.Fatal(n)
+		}
+		input := tones(8000, channels)
+		output := roundTrip(t, enc, dec, input, channels, blockSize)
+		if s := snr(input, output); s < 20 {
+			t.Fatalf("%d channels: SNR %.1fdB", channels, s)
+		}
+	}
+}
+
+func TestMS_RoundTrip(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		blockSize := 256 * channels
+		enc, err := NewMSEncoder(channels, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := NewMSDecoder(channels, blockSize, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := dec.SamplesPerBlock(); n != 500 {
+			t.Fatal(n)
+		}
+		input := tones(8000, channels)
+		output := roundTrip(t, enc, dec, input, channels, blockSize)
+		if s := snr(input, output); s < 20 {
+			t.Fatalf("%d channels: SNR %.1fdB", channels, s)
+		}
+	}
+}
+
+func TestIMA_Decode(t *testing.T) {
+	dec, _ := NewIMADecoder(1, 8)
+	block := []byte{100, 0, 0, 0, 0x07, 0x08, 0, 0}
+	frame := make([]int16, 9)
+	n, err := dec.Decode(block, frame)
+	if err != nil || n != 9 {
+		t.Fatal(n, err)
+	}
+	// Code 7 at step 7 adds 11 and moves to step 16. Code 0 adds 16/8 and
+	// moves to step 14, then code 8 subtracts 14/8.
+	if frame[0] != 100 || frame[1] != 111 || frame[2] != 113 || frame[3] != 112 {
+		t.Fatal(frame)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := NewIMADecoder(0, 256); err != ErrChannels {
+		t.Fatal(err)
+	}
+	if _, err := NewIMAEncoder(2, 260); err != ErrBlockSize {
+		t.Fatal(err)
+	}
+	if _, err := NewMSDecoder(3, 256, nil); err != ErrChannels {
+		t.Fatal(err)
+	}
+	enc, _ := NewMSEncoder(2, 256)
+	if _, err := enc.Encode(make([]int16, 3), make([]byte, 256)); err != ErrFrameSize {
+		t.Fatal(err)
+	}
+	dec, _ := NewMSDecoder(1, 256, nil)
+	if _, err := dec.Decode(make([]byte, 100), make([]int16, 10)); err != ErrShort {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,209 @@
+package adpcm
+
+// IMA ADPCM, WAV format 0x11. Each channel has a 4 byte header with the first
+// sample and the step index, followed by groups of 4 bytes per channel that
+// hold 8 samples, the first in the low nibble.
+
+var (
+	imaIndexTable = [8]int{-1, -1, -1, -1, 2, 4, 6, 8}
+	imaStepTable  = [89]int{
+		7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+		50, 55, 60, 66, 73, 80, 88, 97, 107, 118, 130, 143, 157, 173, 190, 209, 230,
+		253, 279, 307, 337, 371, 408, 449, 494, 544, 598, 658, 724, 796, 876, 963,
+		1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066, 2272, 2499, 2749, 3024, 3327,
+		3660, 4026, 4428, 4871, 5358, 5894, 6484, 7132, 7845, 8630, 9493, 10442,
+		11487, 12635, 13899, 15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794,
+		32767,
+	}
+)
+
+type imaState struct {
+	predictor int
+	index     int
+}
+
+func (s *imaState) decode(nibble byte) int16 {
+	step := imaStepTable[s.index]
+	diff := step >> 3
+	if nibble&1 != 0 {
+		diff += step >> 2
+	}
+	if nibble&2 != 0 {
+		diff += step >> 1
+	}
+	if nibble&4 != 0 {
+		diff += step
+	}
+	if nibble&8 != 0 {
+		diff = -diff
+	}
+	s.predictor = clamp16(s.predictor + diff)
+	s.index += imaIndexTable[nibble&7]
+	if s.index < 0 {
+		s.index = 0
+	} else if s.index > 88 {
+		s.index = 88
+	}
+	return int16(s.predictor)
+}
+
+func (s *imaState) encode(sample int16) byte {
+	diff := int(sample) - s.predictor
+	var nibble byte
+	if diff < 0 {
+		nibble = 8
+		diff = -diff
+	}
+	step := imaStepTable[s.index]
+	for mask := byte(4); mask > 0; mask >>= 1 {
+		if diff >= step {
+			nibble |= mask
+			diff -= step
+		}
+		step >>= 1
+	}
+	s.decode(nibble)
+	return nibble
+}
+
+// IMASamplesPerBlock returns the number of samples per channel in a block.
+func IMASamplesPerBlock(channels, blockSize int) int {
+	return (blockSize-4*channels)*2/channels + 1
+}
+
+func checkIMA(channels, blockSize int) error {
+	if channels <= 0 {
+		return ErrChannels
+	}
+	if blockSize <= 4*channels || blockSize%(4*channels) != 0 {
+		return ErrBlockSize
+	}
+	return nil
+}
+
+// IMADecoder decodes IMA ADPCM blocks.
+type IMADecoder struct {
+	channels  int
+	blockSize int
+	state     []imaState
+}
+
+// NewIMADecoder returns an IMADecoder for blocks of blockSize bytes.
+func NewIMADecoder(channels, blockSize int) (*IMADecoder, error) {
+	if err := checkIMA(channels, blockSize); err != nil {
+		return nil, err
+	}
+	return &IMADecoder{
+		channels:  channels,
+		blockSize: blockSize,
+		state:     make([]imaState, channels),
+	}, nil
+}
+
+// SamplesPerBlock returns the number of samples per channel in a block.
+func (d *IMADecoder) SamplesPerBlock() int {
+	return IMASamplesPerBlock(d.channels, d.blockSize)
+}
+
+// Decode decodes a block into frame and returns the number of samples. The
+// last block of a file may be short.
+func (d *IMADecoder) Decode(block []byte, frame []int16) (int, error) {
+	ch := d.channels
+	if len(block) > d.blockSize || len(block) < 4*ch {
+		return 0, ErrBlockSize
+	}
+	groups := (len(block) - 4*ch) / (4 * ch)
+	n := (groups*8 + 1) * ch
+	if len(frame) < n {
+		return 0, ErrShort
+	}
+	for c := 0; c < ch; c++ {
+		s := &d.state[c]
+		s.predictor = getInt16(block[4*c:])
+		s.index = int(block[4*c+2])
+		if s.index > 88 {
+			s.index = 88
+		}
+		frame[c] = int16(s.predictor)
+	}
+	data := block[4*ch:]
+	for g := 0; g < groups; g++ {
+		for c := 0; c < ch; c++ {
+			s := &d.state[c]
+			out := frame[(1+g*8)*ch+c:]
+			for i, b := range data[:4] {
+				out[2*i*ch] = s.decode(b & 0x0F)
+				out[(2*i+1)*ch] = s.decode(b >> 4)
+			}
+			data = data[4:]
+		}
+	}
+	return n, nil
+}
+
+// IMAEncoder encodes IMA ADPCM blocks.
+type IMAEncoder struct {
+	channels  int
+	blockSize int
+	state     []imaState
+}
+
+// NewIMAEncoder returns an IMAEncoder for blocks of blockSize bytes.
+func NewIMAEncoder(channels, blockSize int) (*IMAEncoder, error) {
+	if err := checkIMA(channels, blockSize); err != nil {
+		return nil, err
+	}
+	return &IMAEncoder{
+		channels:  channels,
+		blockSize: blockSize,
+		state:     make([]imaState, channels),
+	}, nil
+}
+
+// SamplesPerBlock returns the number of samples per channel in a block.
+func (e *IMAEncoder) SamplesPerBlock() int {
+	return IMASamplesPerBlock(e.channels, e.blockSize)
+}
+
+// Encode encodes an interleaved frame of up to SamplesPerBlock samples per
+// channel into block and returns the block length. A short frame makes a
+// short block, padded to a group of 8 samples with its last sample.
+func (e *IMAEncoder) Encode(frame []int16, block []byte) (int, error) {
+	ch := e.channels
+	if len(frame) == 0 || len(frame)%ch != 0 || len(frame) > e.SamplesPerBlock()*ch {
+		return 0, ErrFrameSize
+	}
+	samples := len(frame) / ch
+	groups := (samples - 1 + 7) / 8
+	n := 4*ch + groups*4*ch
+	if len(block) < n {
+		return 0, ErrShort
+	}
+	for c := 0; c < ch; c++ {
+		s := &e.state[c]
+		s.predictor = int(frame[c])
+		putInt16(block[4*c:], s.predictor)
+		block[4*c+2] = byte(s.index)
+		block[4*c+3] = 0
+	}
+	sample := func(i, c int) int16 {
+		if i >= samples {
+			i = samples - 1
+		}
+		return frame[i*ch+c]
+	}
+	data := block[4*ch:]
+	for g := 0; g < groups; g++ {
+		for c := 0; c < ch; c++ {
+			s := &e.state[c]
+			for i := 0; i < 4; i++ {
+				first := 1 + g*8 + 2*i
+				lo := s.encode(sample(first, c))
+				hi := s.encode(sample(first+1, c))
+				data[i] = lo | hi<<4
+			}
+			data = data[4:]
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,254 @@
+package adpcm
+
+// Microsoft ADPCM, WAV format 0x2. The block header holds, for each channel,
+// a predictor index, the step size and the first two samples, the second one
+// first. The nibbles follow interleaved by channel, the first in the high
+// nibble. Each sample is predicted from the previous two with the
+// coefficient pair of the block.
+
+// MSCoefficients are the standard predictor coefficient pairs, which every
+// MS ADPCM WAV file lists in its fmt chunk.
+var MSCoefficients = [][2]int{
+	{256, 0},
+	{512, -256},
+	{0, 0},
+	{192, 64},
+	{240, 0},
+	{460, -208},
+	{392, -232},
+}
+
+var msAdaptationTable = [16]int{
+	230, 230, 230, 230, 307, 409, 512, 614,
+	768, 614, 512, 409, 307, 230, 230, 230,
+}
+
+type msState struct {
+	coef1, coef2     int
+	delta            int
+	sample1, sample2 int
+}
+
+func (s *msState) predict() int {
+	return (s.sample1*s.coef1 + s.sample2*s.coef2) / 256
+}
+
+func (s *msState) update(nibble byte, sample int) {
+	s.sample2 = s.sample1
+	s.sample1 = sample
+	s.delta = msAdaptationTable[nibble] * s.delta >> 8
+	if s.delta < 16 {
+		s.delta = 16
+	}
+}
+
+func (s *msState) decode(nibble byte) int16 {
+	signed := int(nibble)
+	if signed >= 8 {
+		signed -= 16
+	}
+	sample := clamp16(s.predict() + signed*s.delta)
+	s.update(nibble, sample)
+	return int16(sample)
+}
+
+func (s *msState) encode(sample int16) (byte, int) {
+	predictor := s.predict()
+	diff := int(sample) - predictor
+	bias := s.delta / 2
+	if diff < 0 {
+		bias = -bias
+	}
+	code := (diff + bias) / s.delta
+	if code > 7 {
+		code = 7
+	} else if code < -8 {
+		code = -8
+	}
+	decoded := clamp16(predictor + code*s.delta)
+	nibble := byte(code) & 0x0F
+	s.update(nibble, decoded)
+	return nibble, decoded - int(sample)
+}
+
+// MSSamplesPerBlock returns the number of samples per channel in a block.
+func MSSamplesPerBlock(channels, blockSize int) int {
+	return (blockSize-7*channels)*2/channels + 2
+}
+
+func checkMS(channels, blockSize int) error {
+	if channels <= 0 || channels > 2 {
+		return ErrChannels
+	}
+	if blockSize <= 7*channels {
+		return ErrBlockSize
+	}
+	return nil
+}
+
+// MSDecoder decodes Microsoft ADPCM blocks.
+type MSDecoder struct {
+	channels  int
+	blockSize int
+	coefs     [][2]int
+	state     []msState
+}
+
+// NewMSDecoder returns an MSDecoder for blocks of blockSize bytes. coefs are
+// the coefficients from the fmt chunk, nil for MSCoefficients. Mono and
+// stereo are supported.
+func NewMSDecoder(channels, blockSize int, coefs [][2]int) (*MSDecoder, error) {
+	if err := checkMS(channels, blockSize); err != nil {
+		return nil, err
+	}
+	if coefs == nil {
+		coefs = MSCoefficients
+	}
+	return &MSDecoder{
+		channels:  channels,
+		blockSize: blockSize,
+		coefs:     coefs,
+		state:     make([]msState, channels),
+	}, nil
+}
+
+// SamplesPerBlock returns the number of samples per channel in a block.
+func (d *MSDecoder) SamplesPerBlock() int {
+	return MSSamplesPerBlock(d.channels, d.blockSize)
+}
+
+// Decode decodes a block into frame and returns the number of samples. The
+// last block of a file may be short.
+func (d *MSDecoder) Decode(block []byte, frame []int16) (int, error) {
+	ch := d.channels
+	if len(block) > d.blockSize || len(block) < 7*ch {
+		return 0, ErrBlockSize
+	}
+	data := block[7*ch:]
+	n := 2*ch + len(data)*2/ch*ch
+	if len(frame) < n {
+		return 0, ErrShort
+	}
+	for c := 0; c < ch; c++ {
+		s := &d.state[c]
+		predictor := int(block[c])
+		if predictor >= len(d.coefs) {
+			return 0, ErrBlockSize
+		}
+		s.coef1 = d.coefs[predictor][0]
+		s.coef2 = d.coefs[predictor][1]
+		s.delta = getInt16(block[ch+2*c:])
+		s.sample1 = getInt16(block[3*ch+2*c:])
+		s.sample2 = getInt16(block[5*ch+2*c:])
+		frame[c] = int16(s.sample2)
+		frame[ch+c] = int16(s.sample1)
+	}
+	out := frame[2*ch : n]
+	for i := range out {
+		b := data[i/2]
+		if i%2 == 0 {
+			b >>= 4
+		}
+		out[i] = d.state[i%ch].decode(b & 0x0F)
+	}
+	return n, nil
+}
+
+// MSEncoder encodes Microsoft ADPCM blocks with the standard coefficients.
+// The coefficient pair with the least error is chosen for each block.
+type MSEncoder struct {
+	channels  int
+	blockSize int
+	delta     []int
+}
+
+// NewMSEncoder returns an MSEncoder for blocks of blockSize bytes.
+func NewMSEncoder(channels, blockSize int) (*MSEncoder, error) {
+	if err := checkMS(channels, blockSize); err != nil {
+		return nil, err
+	}
+	delta := make([]int, channels)
+	for c := range delta {
+		delta[c] = 16
+	}
+	return &MSEncoder{
+		channels:  channels,
+		blockSize: blockSize,
+		delta:     delta,
+	}, nil
+}
+
+// SamplesPerBlock returns the number of samples per channel in a block.
+func (e *MSEncoder) SamplesPerBlock() int {
+	return MSSamplesPerBlock(e.channels, e.blockSize)
+}
+
+// Encode encodes an interleaved frame of up to SamplesPerBlock samples per
+// channel into block and returns the block length. A short frame makes a
+// short block. A block holds at least 2 samples, so a single one is repeated.
+func (e *MSEncoder) Encode(frame []int16, block []byte) (int, error) {
+	ch := e.channels
+	samples := len(frame) / ch
+	if len(frame) == 0 || len(frame)%ch != 0 || samples > e.SamplesPerBlock() {
+		return 0, ErrFrameSize
+	}
+	if samples == 1 {
+		frame = append(frame[:ch:ch], frame...)
+		samples = 2
+	}
+	// Stereo nibbles pair up, mono ones are padded to a whole byte.
+	nibbles := (samples - 2) * ch
+	n := 7*ch + (nibbles+1)/2
+	if len(block) < n {
+		return 0, ErrShort
+	}
+	data := block[7*ch : n]
+	for i := range data {
+		data[i] = 0
+	}
+	for c := 0; c < ch; c++ {
+		best, bestErr := 0, -1
+		for p := range MSCoefficients {
+			if err := e.encodeChannel(c, p, frame, nil); bestErr < 0 || err < bestErr {
+				best, bestErr = p, err
+			}
+		}
+		block[c] = byte(best)
+		putInt16(block[ch+2*c:], e.delta[c])
+		putInt16(block[3*ch+2*c:], int(frame[ch+c]))
+		putInt16(block[5*ch+2*c:], int(frame[c]))
+		e.encodeChannel(c, best, frame, data)
+	}
+	return n, nil
+}
+
+// encodeChannel encodes a channel with predictor p and returns the squared
+// error. Unless data is nil, the nibbles are stored in it and the step size
+// is kept for the next block.
+func (e *MSEncoder) encodeChannel(c, p int, frame []int16, data []byte) int {
+	ch := e.channels
+	s := msState{
+		coef1:   MSCoefficients[p][0],
+		coef2:   MSCoefficients[p][1],
+		delta:   e.delta[c],
+		sample1: int(frame[ch+c]),
+		sample2: int(frame[c]),
+	}
+	sum := 0
+	for i := 2*ch + c; i < len(frame); i += ch {
+		nibble, err := s.encode(frame[i])
+		sum += err * err
+		if data != nil {
+			k := i - 2*ch
+			if k%2 == 0 {
+				data[k/2] |= nibble << 4
+			} else {
+				data[k/2] |= nibble
+			}
+		}
+	}
+	if data != nil {
+		e.delta[c] = s.delta
+	}
+	return sum
+}
@@ -0,0 +1,143 @@
+/*
+Package capture reads captured UDP packets from pcap, pcapng and rtpdump
+(rtptools) files, extracts the RTP streams in them by SSRC and replays a
+stream through a jitter buffer and decoder, as our media servers would have
+played it. A Recorder writes live RTP to such files, per SSRC and with receive
+times, so that problems can be reproduced later with the same timing.
+*/
+package capture
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+var (
+	ErrFormat   = errors.New("unknown capture format")
+	ErrLinkType = errors.New("unsupported link type")
+	ErrCorrupt  = errors.New("corrupt capture")
+)
+
+// Packet is a UDP datagram and the time it was captured.
+type Packet struct {
+	Time    time.Time
+	Src     *net.UDPAddr
+	Dst     *net.UDPAddr
+	Payload []byte
+}
+
+// Reader reads the UDP packets of a capture. ReadPacket returns io.EOF after
+// the last packet. Packets that aren't UDP over IPv4 or IPv6 are skipped.
+type Reader interface {
+	ReadPacket() (*Packet, error)
+}
+
+// NewReader detects the format of a capture and returns a Reader for it.
+func NewReader(r io.Reader) (Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, ErrFormat
+	}
+	switch {
+	case string(magic) == "#!rt":
+		return newRTPDumpReader(br)
+	case string(magic) == "\x0a\x0d\x0d\x0a":
+		return newPcapngReader(br)
+	}
+	if _, _, ok := pcapByteOrder(magic); ok {
+		return newPcapReader(br)
+	}
+	return nil, ErrFormat
+}
+
+type file struct {
+	Reader
+	*os.File
+}
+
+// OpenFile opens a capture file.
+func OpenFile(name string) (interface {
+	Reader
+	io.Closer
+}, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return file{Reader: r, File: f}, nil
+}
+
+// RTPPacket is an RTP packet and the time it arrived.
+type RTPPacket struct {
+	Arrival time.Time
+	*rtp.Packet
+}
+
+// Stream holds the RTP packets of an SSRC in the order they arrived.
+type Stream struct {
+	SSRC        uint32
+	PayloadType uint8 // Of the first packet.
+	Src         *net.UDPAddr
+	Dst         *net.UDPAddr
+	Packets     []RTPPacket
+}
+
+// ReadStreams reads the RTP streams of a capture in order of their first
+// packet. RTCP and packets that aren't RTP are skipped.
+func ReadStreams(r Reader) ([]*Stream, error) {
+	var streams []*Stream
+	bySSRC := make(map[uint32]*Stream)
+	for {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			return streams, nil
+		}
+		if err != nil {
+			return streams, err
+		}
+		packet, ok := parseRTP(p.Payload)
+		if !ok {
+			continue
+		}
+		s := bySSRC[packet.SSRC]
+		if s == nil {
+			s = &Stream{
+				SSRC:        packet.SSRC,
+				PayloadType: packet.PayloadType,
+				Src:         p.Src,
+				Dst:         p.Dst,
+			}
+			bySSRC[packet.SSRC] = s
+			streams = append(streams, s)
+		}
+		s.Packets = append(s.Packets, RTPPacket{Arrival: p.Time, Packet: packet})
+	}
+}
+
+// parseRTP returns the RTP packet in a UDP payload. Packet types 192 to 223
+// are RTCP (RFC 5761).
+func parseRTP(payload []byte) (*rtp.Packet, bool) {
+	if len(payload) < 12 || payload[0]>>6 != 2 {
+		return nil, false
+	}
+	if isRTCP(payload) {
+		return nil, false
+	}
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(payload); err != nil {
+		return nil, false
+	}
+	return packet, true
+}
@@ -0,0 +1,275 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pidato/audio/red"
+	"github.com/pidato/audio/transcode"
+	"github.com/pion/rtp"
+)
+
+var start = time.Unix(1600000000, 0)
+
+// rtpPackets returns 50 PCMU packets of 20ms. Packet 10 is lost and packets
+// 20 and 21 arrive swapped.
+func rtpPackets(t *testing.T) (payloads [][]byte, times []time.Time) {
+	for i := 0; i < 50; i++ {
+		if i == 10 {
+			continue
+		}
+		packet := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    0,
+				SequenceNumber: uint16(65530 + i),
+				Timestamp:      uint32(160 * i),
+				SSRC:           0x1234,
+			},
+			Payload: bytes.Repeat([]byte{0xff}, 160),
+		}
+		buf, err := packet.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, buf)
+		times = append(times, start.Add(time.Duration(i)*20*time.Millisecond+time.Duration(i%3)*time.Millisecond))
+	}
+	payloads[19], payloads[20] = payloads[20], payloads[19]
+	// An RTCP receiver report isn't RTP.
+	payloads = append(payloads, []byte{0x80, 201, 0, 1, 0, 0, 0x12, 0x34})
+	times = append(times, times[len(times)-1])
+	return payloads, times
+}
+
+func udp4(payload []byte) []byte {
+	ip := make([]byte, 28, 28+len(payload))
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(28+len(payload)))
+	ip[9] = 17
+	copy(ip[12:], []byte{192, 0, 2, 1})
+	copy(ip[16:], []byte{192, 0, 2, 2})
+	binary.BigEndian.PutUint16(ip[20:], 4000)
+	binary.BigEndian.PutUint16(ip[22:], 5004)
+	binary.BigEndian.PutUint16(ip[24:], uint16(8+len(payload)))
+	return append(ip, payload...)
+}
+
+func udp6(payload []byte) []byte {
+	ip := make([]byte, 48, 48+len(payload))
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:], uint16(8+len(payload)))
+	ip[6] = 17
+	copy(ip[8:], net.ParseIP("2001:db8::1"))
+	copy(ip[24:], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(ip[40:], 4000)
+	binary.BigEndian.PutUint16(ip[42:], 5004)
+	binary.BigEndian.PutUint16(ip[44:], uint16(8+len(payload)))
+	return append(ip, payload...)
+}
+
+func ethernet(ip []byte) []byte {
+	frame := make([]byte, 18)
+	// A VLAN tag, then IPv4.
+	binary.BigEndian.PutUint16(frame[12:], 0x8100)
+	binary.BigEndian.PutUint16(frame[16:], 0x0800)
+	return append(frame, ip...)
+}
+
+func pcapFile(t *testing.T) []byte {
+	var b bytes.Buffer
+	header := make([]byte, 24)
+	binary.BigEndian.PutUint32(header, 0xa1b23c4d) // Nanoseconds.
+	binary.BigEndian.PutUint16(header[4:], 2)
+	binary.BigEndian.PutUint16(header[6:], 4)
+	binary.BigEndian.PutUint32(header[16:], 65535)
+	binary.BigEndian.PutUint32(header[20:], linkEthernet)
+	b.Write(header)
+	payloads, times := rtpPackets(t)
+	for i, payload := range payloads {
+		frame := ethernet(udp4(payload))
+		record := make([]byte, 16)
+		binary.BigEndian.PutUint32(record, uint32(times[i].Unix()))
+		binary.BigEndian.PutUint32(record[4:], uint32(times[i].Nanosecond()))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(frame)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(frame)))
+		b.Write(record)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	block := make([]byte, 8, 12+len(body))
+	binary.LittleEndian.PutUint32(block, blockType)
+	binary.LittleEndian.PutUint32(block[4:], uint32(12+len(body)))
+	block = append(block, body...)
+	return append(block, block[4:8]...)
+}
+
+func pcapngFile(t *testing.T) []byte {
+	var b bytes.Buffer
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb, 0x1a2b3c4d)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+	b.Write(pcapngBlock(blockSectionHeader, shb))
+	// Raw IP with millisecond timestamps.
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb, linkRaw)
+	idb = append(idb, 9, 0, 1, 0, 3, 0, 0, 0, 0, 0, 0, 0)
+	b.Write(pcapngBlock(blockInterface, idb))
+	payloads, times := rtpPackets(t)
+	for i, payload := range payloads {
+		ip := udp6(payload)
+		epb := make([]byte, 20)
+		ts := uint64(times[i].UnixNano() / int64(time.Millisecond))
+		binary.LittleEndian.PutUint32(epb[4:], uint32(ts>>32))
+		binary.LittleEndian.PutUint32(epb[8:], uint32(ts))
+		binary.LittleEndian.PutUint32(epb[12:], uint32(len(ip)))
+		binary.LittleEndian.PutUint32(epb[16:], uint32(len(ip)))
+		b.Write(pcapngBlock(blockEnhancedPacket, append(epb, ip...)))
+	}
+	return b.Bytes()
+}
+
+func rtpdumpFile(t *testing.T) []byte {
+	var b bytes.Buffer
+	b.WriteString("#!rtpplay1.0 192.0.2.2/5004\n")
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint32(header, uint32(start.Unix()))
+	copy(header[8:], []byte{192, 0, 2, 1})
+	binary.BigEndian.PutUint16(header[12:], 4000)
+	b.Write(header)
+	payloads, times := rtpPackets(t)
+	for i, payload := range payloads {
+		record := make([]byte, 8)
+		binary.BigEndian.PutUint16(record, uint16(8+len(payload)))
+		binary.BigEndian.PutUint16(record[2:], uint16(len(payload)))
+		binary.BigEndian.PutUint32(record[4:], uint32(times[i].Sub(start)/time.Millisecond))
+		b.Write(record)
+		b.Write(payload)
+	}
+	return b.Bytes()
+}
+
+func TestReadStreams(t *testing.T) {
+	for name, file := range map[string][]byte{
+		"pcap":    pcapFile(t),
+		"pcapng":  pcapngFile(t),
+		"rtpdump": rtpdumpFile(t),
+	} {
+		r, err := NewReader(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		streams, err := ReadStreams(r)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(streams) != 1 {
+			t.Fatal(name, len(streams))
+		}
+		s := streams[0]
+		if s.SSRC != 0x1234 || s.PayloadType != 0 || len(s.Packets) != 49 || s.Src.Port != 4000 || s.Dst.Port != 5004 {
+			t.Fatalf("%s: %+v", name, s)
+		}
+		if got, want := s.Packets[1].Arrival, start.Add(21*time.Millisecond); !got.Equal(want) {
+			t.Fatal(name, got, want)
+		}
+		// 65530 + 21 wrapped around.
+		if s.Packets[19].SequenceNumber != 15 {
+			t.Fatal(name, s.Packets[19].SequenceNumber)
+		}
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("RIFF...."))); err != ErrFormat {
+		t.Fatal(err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	r, _ := NewReader(bytes.NewReader(pcapFile(t)))
+	streams, err := ReadStreams(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "replay*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	report, err := Replay(streams[0], f, ReplayConfig{Params: transcode.Params{PLC: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(report)
+	if report.Expected != 50 || report.Received != 49 || report.Lost != 1 || report.Late != 0 {
+		t.Fatalf("%+v", report.Stats)
+	}
+	if report.Concealed != 160 || report.Jitter == 0 {
+		t.Fatalf("%+v", report.Stats)
+	}
+	if report.Decoded != time.Second {
+		t.Fatal(report.Decoded)
+	}
+	info, _ := f.Stat()
+	if info.Size() != 44+16000 {
+		t.Fatal(info.Size())
+	}
+}
+
+func TestReplayRED(t *testing.T) {
+	// PCMU with the 2 previous payloads, packets 10 and 11 lost and packet
+	// 30 lost with what it would have recovered.
+	encoder := red.NewEncoder(0, 2)
+	s := &Stream{SSRC: 0x1234, PayloadType: 63}
+	for i := 0; i < 50; i++ {
+		buf := make([]byte, 1000)
+		n, err := encoder.Encode(bytes.Repeat([]byte{byte(i)}, 160), uint32(160*i), buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 10, 11, 30, 31, 32:
+			continue
+		}
+		s.Packets = append(s.Packets, RTPPacket{
+			Arrival: start.Add(time.Duration(i) * 20 * time.Millisecond),
+			Packet: &rtp.Packet{
+				Header:  rtp.Header{Version: 2, PayloadType: 63, SequenceNumber: uint16(i), Timestamp: uint32(160 * i), SSRC: 0x1234},
+				Payload: buf[:n],
+			},
+		})
+	}
+	f, err := ioutil.TempFile("", "replay*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	report, err := Replay(s, f, ReplayConfig{RED: 63})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Codec.Name != "PCMU" || report.Expected != 50 || report.Received != 45 {
+		t.Fatalf("%s %+v", report.Codec, report.Stats)
+	}
+	if report.Recovered != 4 || report.Lost != 1 || report.Concealed != 160 || report.Errors != 0 {
+		t.Fatalf("%+v", report.Stats)
+	}
+	if report.Decoded != time.Second {
+		t.Fatal(report.Decoded)
+	}
+}
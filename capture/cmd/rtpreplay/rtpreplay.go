@@ -0,0 +1,110 @@
+/*
+	rtpreplay replays the RTP streams of a pcap, pcapng or rtpdump file through
+	the jitter buffer and decoder, writes each to a WAV file named after its
+	SSRC and prints a loss and jitter report.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pidato/audio/capture"
+	"github.com/pidato/audio/transcode"
+)
+
+func main() {
+	var (
+		ssrc   = flag.String("ssrc", "", "only replay this SSRC (hex)")
+		codec  = flag.String("codec", "", "rtpmap encoding of dynamic payload types, such as opus/48000/2")
+		redPT  = flag.Uint("red", 0, "payload type of RED packets carrying the codec")
+		delay  = flag.Duration("delay", 60*time.Millisecond, "jitter buffer delay")
+		speed  = flag.Float64("speed", 0, "speed relative to real time, 0 for as fast as possible")
+		plc    = flag.Bool("plc", true, "conceal lost G711 packets")
+		outDir = flag.String("o", ".", "directory of the WAV files")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] capture\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	config := capture.ReplayConfig{
+		Delay:  *delay,
+		Speed:  *speed,
+		Params: transcode.Params{PLC: *plc},
+		RED:    uint8(*redPT),
+	}
+	if *codec != "" {
+		c, err := transcode.ParseCodec(*codec)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		config.Codec = c
+	}
+	if err := replay(flag.Arg(0), *ssrc, *outDir, config); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func replay(name, ssrc, outDir string, config capture.ReplayConfig) error {
+	r, err := capture.OpenFile(name)
+	if err != nil {
+		return err
+	}
+	streams, err := capture.ReadStreams(r)
+	_ = r.Close()
+	if err != nil {
+		return err
+	}
+	var only uint64
+	if ssrc != "" {
+		if only, err = strconv.ParseUint(ssrc, 16, 32); err != nil {
+			return err
+		}
+	}
+
+	var exitErr error
+	for _, s := range streams {
+		if ssrc != "" && uint64(s.SSRC) != only {
+			continue
+		}
+		fmt.Printf("%s -> %s\n", s.Src, s.Dst)
+		if err := replayStream(s, filepath.Join(outDir, fmt.Sprintf("%08x.wav", s.SSRC)), config); err != nil {
+			fmt.Printf("SSRC %08x: %v\n\n", s.SSRC, err)
+			exitErr = err
+		}
+	}
+	return exitErr
+}
+
+func replayStream(s *capture.Stream, name string, config capture.ReplayConfig) error {
+	if _, ok := transcode.DefaultRegistry.LookupPayloadType(s.PayloadType); ok {
+		config.Codec = transcode.Codec{}
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	report, err := capture.Replay(s, f, config)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	fmt.Println(report)
+	return nil
+}
@@ -0,0 +1,137 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Link types of pcap and pcapng.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLoop     = 108
+	linkLinuxSLL = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+func supportedLink(linkType uint32) bool {
+	switch linkType {
+	case linkNull, linkEthernet, linkRaw, linkLoop, linkLinuxSLL, linkIPv4, linkIPv6, linkSLL2:
+		return true
+	}
+	return false
+}
+
+// decodeLink returns the UDP packet in a frame, or false when it isn't one.
+func decodeLink(linkType uint32, frame []byte) (src, dst *net.UDPAddr, payload []byte, ok bool) {
+	var etherType uint16
+	switch linkType {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return
+		}
+		etherType = binary.BigEndian.Uint16(frame[12:])
+		frame = frame[14:]
+		// VLAN tags.
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= 4 {
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[4:]
+		}
+	case linkNull, linkLoop:
+		// The address family, in the byte order of the capturing host.
+		if len(frame) < 4 {
+			return
+		}
+		frame = frame[4:]
+	case linkLinuxSLL:
+		if len(frame) < 16 {
+			return
+		}
+		etherType = binary.BigEndian.Uint16(frame[14:])
+		frame = frame[16:]
+	case linkSLL2:
+		if len(frame) < 20 {
+			return
+		}
+		etherType = binary.BigEndian.Uint16(frame)
+		frame = frame[20:]
+	case linkRaw, linkIPv4, linkIPv6:
+	default:
+		return
+	}
+	if etherType != 0 && etherType != 0x0800 && etherType != 0x86dd {
+		return
+	}
+	return decodeIP(frame)
+}
+
+// decodeIP returns the UDP packet in an IPv4 or IPv6 packet. Fragmented
+// packets are skipped.
+func decodeIP(packet []byte) (src, dst *net.UDPAddr, payload []byte, ok bool) {
+	if len(packet) < 1 {
+		return
+	}
+	var srcIP, dstIP net.IP
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return
+		}
+		headerLen := int(packet[0]&0xf) * 4
+		total := int(binary.BigEndian.Uint16(packet[2:]))
+		if packet[9] != 17 || headerLen < 20 || total < headerLen || len(packet) < headerLen {
+			return
+		}
+		// More fragments or a fragment offset.
+		if binary.BigEndian.Uint16(packet[6:])&0x3fff != 0 {
+			return
+		}
+		if total < len(packet) {
+			packet = packet[:total]
+		}
+		srcIP, dstIP = net.IP(packet[12:16]), net.IP(packet[16:20])
+		packet = packet[headerLen:]
+	case 6:
+		if len(packet) < 40 {
+			return
+		}
+		next := packet[6]
+		srcIP, dstIP = net.IP(packet[8:24]), net.IP(packet[24:40])
+		if length := 40 + int(binary.BigEndian.Uint16(packet[4:])); length < len(packet) {
+			packet = packet[:length]
+		}
+		packet = packet[40:]
+		// Extension headers.
+		for next != 17 {
+			switch next {
+			case 0, 43, 60:
+				if len(packet) < 8 {
+					return
+				}
+				length := (int(packet[1]) + 1) * 8
+				if len(packet) < length {
+					return
+				}
+				next, packet = packet[0], packet[length:]
+			default:
+				// Fragments and other protocols.
+				return
+			}
+		}
+	default:
+		return
+	}
+	if len(packet) < 8 {
+		return
+	}
+	length := int(binary.BigEndian.Uint16(packet[4:]))
+	if length < 8 || length > len(packet) {
+		return
+	}
+	src = &net.UDPAddr{IP: append(net.IP(nil), srcIP...), Port: int(binary.BigEndian.Uint16(packet))}
+	dst = &net.UDPAddr{IP: append(net.IP(nil), dstIP...), Port: int(binary.BigEndian.Uint16(packet[2:]))}
+	return src, dst, packet[8:length], true
+}
@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// pcapByteOrder returns the byte order of a pcap file and whether timestamps
+// are in nanoseconds rather than microseconds.
+func pcapByteOrder(magic []byte) (order binary.ByteOrder, nano, ok bool) {
+	switch binary.LittleEndian.Uint32(magic) {
+	case 0xa1b2c3d4:
+		return binary.LittleEndian, false, true
+	case 0xa1b23c4d:
+		return binary.LittleEndian, true, true
+	}
+	switch binary.BigEndian.Uint32(magic) {
+	case 0xa1b2c3d4:
+		return binary.BigEndian, false, true
+	case 0xa1b23c4d:
+		return binary.BigEndian, true, true
+	}
+	return nil, false, false
+}
+
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	linkType uint32
+	header   [16]byte
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, ErrCorrupt
+	}
+	order, nano, _ := pcapByteOrder(header[:])
+	p := &pcapReader{
+		r:        r,
+		order:    order,
+		nano:     nano,
+		linkType: order.Uint32(header[20:]) & 0xffff,
+	}
+	if !supportedLink(p.linkType) {
+		return nil, ErrLinkType
+	}
+	return p, nil
+}
+
+func (p *pcapReader) ReadPacket() (*Packet, error) {
+	for {
+		if _, err := io.ReadFull(p.r, p.header[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, ErrCorrupt
+			}
+			return nil, err
+		}
+		sec := p.order.Uint32(p.header[:])
+		frac := p.order.Uint32(p.header[4:])
+		length := p.order.Uint32(p.header[8:])
+		if length > 1<<18 {
+			return nil, ErrCorrupt
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(p.r, frame); err != nil {
+			return nil, ErrCorrupt
+		}
+		src, dst, payload, ok := decodeLink(p.linkType, frame)
+		if !ok {
+			continue
+		}
+		if !p.nano {
+			frac *= 1000
+		}
+		return &Packet{
+			Time:    time.Unix(int64(sec), int64(frac)),
+			Src:     src,
+			Dst:     dst,
+			Payload: payload,
+		}, nil
+	}
+}
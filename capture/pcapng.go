@@ -0,0 +1,154 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Block types of pcapng.
+const (
+	blockSectionHeader     = 0x0a0d0d0a
+	blockInterface         = 1
+	blockEnhancedPacket    = 6
+	optionEndOfOptions     = 0
+	optionInterfaceTSResol = 9
+)
+
+type pcapngInterface struct {
+	linkType uint32
+	// Timestamp units per second.
+	resolution uint64
+}
+
+// pcapngReader reads the enhanced packet blocks of a pcapng file. Simple
+// packet blocks have no timestamp and are skipped.
+type pcapngReader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterface
+}
+
+func newPcapngReader(r io.Reader) (*pcapngReader, error) {
+	return &pcapngReader{r: r, order: binary.LittleEndian}, nil
+}
+
+func (p *pcapngReader) ReadPacket() (*Packet, error) {
+	for {
+		blockType, body, err := p.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		switch blockType {
+		case blockSectionHeader:
+			// Interfaces are numbered per section.
+			p.interfaces = p.interfaces[:0]
+		case blockInterface:
+			if len(body) < 8 {
+				return nil, ErrCorrupt
+			}
+			p.interfaces = append(p.interfaces, pcapngInterface{
+				linkType:   uint32(p.order.Uint16(body)),
+				resolution: p.resolution(body[8:]),
+			})
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return nil, ErrCorrupt
+			}
+			id := p.order.Uint32(body)
+			if int(id) >= len(p.interfaces) {
+				return nil, ErrCorrupt
+			}
+			intf := p.interfaces[id]
+			ts := uint64(p.order.Uint32(body[4:]))<<32 | uint64(p.order.Uint32(body[8:]))
+			length := p.order.Uint32(body[12:])
+			if int(length) > len(body)-20 {
+				return nil, ErrCorrupt
+			}
+			src, dst, payload, ok := decodeLink(intf.linkType, body[20:20+length])
+			if !ok {
+				continue
+			}
+			sec := ts / intf.resolution
+			nsec := (ts % intf.resolution) * uint64(time.Second) / intf.resolution
+			return &Packet{
+				Time:    time.Unix(int64(sec), int64(nsec)),
+				Src:     src,
+				Dst:     dst,
+				Payload: payload,
+			}, nil
+		}
+	}
+}
+
+// readBlock returns the type and body of the next block. A section header
+// sets the byte order of the blocks that follow.
+func (p *pcapngReader) readBlock() (uint32, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(p.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, ErrCorrupt
+		}
+		return 0, nil, err
+	}
+	blockType := p.order.Uint32(header[:])
+	if blockType == blockSectionHeader {
+		var magic [4]byte
+		if _, err := io.ReadFull(p.r, magic[:]); err != nil {
+			return 0, nil, ErrCorrupt
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic[:]) == 0x1a2b3c4d:
+			p.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic[:]) == 0x1a2b3c4d:
+			p.order = binary.BigEndian
+		default:
+			return 0, nil, ErrCorrupt
+		}
+		length := p.order.Uint32(header[4:])
+		if length < 16 || length%4 != 0 || length > 1<<20 {
+			return 0, nil, ErrCorrupt
+		}
+		body := make([]byte, length-12)
+		if _, err := io.ReadFull(p.r, body); err != nil {
+			return 0, nil, ErrCorrupt
+		}
+		return blockType, append(magic[:], body[:len(body)-4]...), nil
+	}
+	length := p.order.Uint32(header[4:])
+	if length < 12 || length%4 != 0 || length > 1<<20 {
+		return 0, nil, ErrCorrupt
+	}
+	body := make([]byte, length-8)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return 0, nil, ErrCorrupt
+	}
+	// Without the trailing length.
+	return blockType, body[:len(body)-4], nil
+}
+
+// resolution returns the timestamp units per second of the if_tsresol option
+// of an interface, microseconds by default.
+func (p *pcapngReader) resolution(options []byte) uint64 {
+	for len(options) >= 4 {
+		code := p.order.Uint16(options)
+		length := int(p.order.Uint16(options[2:]))
+		options = options[4:]
+		if code == optionEndOfOptions || length > len(options) {
+			break
+		}
+		if code == optionInterfaceTSResol && length >= 1 {
+			v := options[0]
+			if v&0x80 != 0 {
+				if v&0x7f < 64 {
+					return 1 << (v & 0x7f)
+				}
+			} else if v <= 19 {
+				return uint64(math.Pow10(int(v)))
+			}
+		}
+		options = options[(length+3)&^3:]
+	}
+	return 1000000
+}
@@ -0,0 +1,217 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Format is the file format a Recorder writes.
+type Format int
+
+const (
+	RTPDump Format = iota
+	Pcap
+)
+
+func (f Format) ext() string {
+	if f == Pcap {
+		return "pcap"
+	}
+	return "rtpdump"
+}
+
+// RecorderConfig configures a Recorder.
+type RecorderConfig struct {
+	// Dir is the directory files are written to.
+	Dir    string
+	Format Format
+	// MaxSize and MaxDuration start a new file once a file reaches them.
+	// Zero means no limit.
+	MaxSize     int64
+	MaxDuration time.Duration
+	// Name returns the name of a file in Dir. By default it's
+	// "<ssrc>-<start time>-<index>.<format>".
+	Name func(ssrc uint32, start time.Time, index int) string
+}
+
+// Recorder writes received RTP and RTCP packets to a file per SSRC, with
+// their receive times. It implements transport.PacketRecorder.
+type Recorder struct {
+	config     RecorderConfig
+	recordings map[uint32]*recording
+	err        error
+
+	closed bool
+	mu     sync.Mutex
+}
+
+type recording struct {
+	ssrc  uint32
+	index int
+	file  *os.File
+	buf   *bufio.Writer
+	w     Writer
+	start time.Time
+}
+
+// NewRecorder creates a Recorder writing to config.Dir, which must exist.
+func NewRecorder(config RecorderConfig) (*Recorder, error) {
+	info, err := os.Stat(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", config.Dir)
+	}
+	if config.Name == nil {
+		config.Name = func(ssrc uint32, start time.Time, index int) string {
+			return fmt.Sprintf("%08x-%s-%d.%s", ssrc, start.UTC().Format("20060102T150405.000"), index, config.Format.ext())
+		}
+	}
+	return &Recorder{
+		config:     config,
+		recordings: map[uint32]*recording{},
+	}, nil
+}
+
+// Record writes a packet to the file of its SSRC: the sender's for RTCP.
+// Packets too short to have one are dropped. After an error, Record and
+// Close return the first one.
+func (r *Recorder) Record(data []byte, src, dst net.Addr, arrival time.Time) error {
+	var ssrc uint32
+	switch {
+	case isRTCP(data) && len(data) >= 8:
+		ssrc = binary.BigEndian.Uint32(data[4:])
+	case len(data) >= 12 && data[0]>>6 == 2:
+		ssrc = binary.BigEndian.Uint32(data[8:])
+	default:
+		return nil
+	}
+	packet := &Packet{
+		Time:    arrival,
+		Src:     udpAddr(src),
+		Dst:     udpAddr(dst),
+		Payload: data,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	if r.err != nil {
+		return r.err
+	}
+	rec := r.recordings[ssrc]
+	if rec != nil && r.full(rec, arrival) {
+		if r.err = rec.close(); r.err != nil {
+			return r.err
+		}
+		rec = &recording{ssrc: ssrc, index: rec.index + 1}
+		r.recordings[ssrc] = rec
+	}
+	if rec == nil {
+		rec = &recording{ssrc: ssrc}
+		r.recordings[ssrc] = rec
+	}
+	if rec.file == nil {
+		if r.err = r.open(rec, packet); r.err != nil {
+			return r.err
+		}
+	}
+	if r.err = rec.w.WritePacket(packet); r.err != nil {
+		return r.err
+	}
+	return nil
+}
+
+func (r *Recorder) full(rec *recording, arrival time.Time) bool {
+	if r.config.MaxSize > 0 && rec.written() >= r.config.MaxSize {
+		return true
+	}
+	return r.config.MaxDuration > 0 && arrival.Sub(rec.start) >= r.config.MaxDuration
+}
+
+func (r *Recorder) open(rec *recording, packet *Packet) error {
+	name := filepath.Join(r.config.Dir, r.config.Name(rec.ssrc, packet.Time, rec.index))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	rec.file = f
+	rec.buf = bufio.NewWriter(f)
+	rec.start = packet.Time
+	if r.config.Format == Pcap {
+		rec.w, err = NewPcapWriter(rec.buf)
+	} else {
+		rec.w, err = NewRTPDumpWriter(rec.buf, packet.Src, packet.Dst, packet.Time)
+	}
+	if err != nil {
+		_ = f.Close()
+		rec.file = nil
+	}
+	return err
+}
+
+// written returns the size of the file including buffered data.
+func (rec *recording) written() int64 {
+	pos, _ := rec.file.Seek(0, io.SeekCurrent)
+	return pos + int64(rec.buf.Buffered())
+}
+
+func (rec *recording) close() error {
+	if rec.file == nil {
+		return nil
+	}
+	err := rec.buf.Flush()
+	if cerr := rec.file.Close(); err == nil {
+		err = cerr
+	}
+	rec.file = nil
+	return err
+}
+
+// Flush writes buffered packets to the files.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.recordings {
+		if rec.file == nil {
+			continue
+		}
+		if err := rec.buf.Flush(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+// Close closes the files.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	for _, rec := range r.recordings {
+		if err := rec.close(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+func udpAddr(addr net.Addr) *net.UDPAddr {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a
+	}
+	return nil
+}
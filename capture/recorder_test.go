@@ -0,0 +1,87 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	// The last packet is an RTCP receiver report of the first stream.
+	payloads, times := rtpPackets(t)
+
+	for _, test := range []struct {
+		format  Format
+		src     *net.UDPAddr
+		maxSize int64
+		files   int
+	}{
+		{RTPDump, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}, 0, 4},
+		{Pcap, &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 4000}, 0, 4},
+		{Pcap, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}, 2000, 10},
+	} {
+		dir, err := ioutil.TempDir("", "recorder")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		dst := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5004}
+		if test.src.IP.To4() == nil {
+			dst.IP = net.ParseIP("2001:db8::2")
+		}
+
+		r, err := NewRecorder(RecorderConfig{Dir: dir, Format: test.format, MaxSize: test.maxSize, MaxDuration: 500 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, payload := range payloads {
+			if err := r.Record(payload, test.src, dst, times[i]); err != nil {
+				t.Fatal(err)
+			}
+			if isRTCP(payload) {
+				continue
+			}
+			other := append([]byte(nil), payload...)
+			binary.BigEndian.PutUint32(other[8:], 0x5678)
+			if err := r.Record(other, test.src, dst, times[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		names, _ := filepath.Glob(filepath.Join(dir, "*"))
+		if len(names) != test.files {
+			t.Fatal(test.format, test.maxSize, len(names))
+		}
+		packets := map[uint32]int{}
+		for _, name := range names {
+			f, err := OpenFile(name)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			streams, err := ReadStreams(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			for _, s := range streams {
+				if !s.Src.IP.Equal(test.src.IP) || s.Src.Port != 4000 || s.Dst.Port != 5004 {
+					t.Fatal(name, s.Src, s.Dst)
+				}
+				if s.SSRC == 0x1234 && packets[s.SSRC] == 0 && !s.Packets[1].Arrival.Equal(times[1]) {
+					t.Fatal(name, s.Packets[1].Arrival)
+				}
+				packets[s.SSRC] += len(s.Packets)
+			}
+		}
+		if packets[0x1234] != 49 || packets[0x5678] != 49 {
+			t.Fatal(test.format, packets)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package capture
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/red"
+	"github.com/pidato/audio/transcode"
+	"github.com/pidato/audio/transport"
+)
+
+// ReplayConfig configures Replay.
+type ReplayConfig struct {
+	// Codecs, DefaultRegistry when nil.
+	Registry *transcode.Registry
+	// Codec of the stream, found by its static payload type when empty.
+	Codec transcode.Codec
+	// Payload type of RED (RFC 2198) packets carrying the codec, 0 for none.
+	RED    uint8
+	Params transcode.Params
+	// Playout delay of the jitter buffer, default 60ms.
+	Delay time.Duration
+	// Speed relative to real time, 0 to replay as fast as possible.
+	Speed float64
+}
+
+// Report describes a replayed stream.
+type Report struct {
+	SSRC        uint32
+	PayloadType uint8
+	Codec       transcode.Codec
+	Packets     int
+	Duration    time.Duration // From the first to the last arrival.
+	Decoded     time.Duration // Audio written to the WAV file.
+	transport.Stats
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SSRC %08x, payload type %d (%s)\n", r.SSRC, r.PayloadType, r.Codec)
+	fmt.Fprintf(&b, "packets: %d over %s, decoded %s\n", r.Packets, r.Duration, r.Decoded)
+	loss := 0.0
+	if r.Expected > 0 {
+		loss = 100 * float64(r.Lost) / float64(r.Expected)
+	}
+	fmt.Fprintf(&b, "expected %d, lost %d (%.1f%%), late %d, duplicates %d\n",
+		r.Expected, r.Lost, loss, r.Late, r.Duplicates)
+	fmt.Fprintf(&b, "recovered %d, concealed %d ticks, decode errors %d\n", r.Recovered, r.Concealed, r.Errors)
+	fmt.Fprintf(&b, "jitter %s, max %s\n", r.Jitter, r.MaxJitter)
+	return b.String()
+}
+
+// Replay plays a stream through a jitter buffer and decoder as if it arrived
+// at the captured times, and writes the decoded audio to wav as 16bit PCM.
+func Replay(s *Stream, wav io.WriteSeeker, config ReplayConfig) (*Report, error) {
+	registry := config.Registry
+	if registry == nil {
+		registry = transcode.DefaultRegistry
+	}
+	payloadType := s.PayloadType
+	isRED := config.RED != 0 && s.PayloadType == config.RED
+	if isRED {
+		// The codec's payload type is in the RED blocks.
+		payloadType = 0
+		for _, p := range s.Packets {
+			if blocks, err := red.Unmarshal(p.Payload); err == nil {
+				payloadType = blocks[len(blocks)-1].PayloadType
+				break
+			}
+		}
+	}
+	codec := config.Codec
+	if codec.Name == "" {
+		reg, ok := registry.LookupPayloadType(payloadType)
+		if !ok {
+			return nil, transcode.ErrUnknownCodec
+		}
+		codec = reg.Codec
+	}
+	delay := config.Delay
+	if delay == 0 {
+		delay = 60 * time.Millisecond
+	}
+	params := config.Params
+	if params.MaxFrames == 0 {
+		// Frames are written after every packet, but concealing a long gap
+		// decodes many at once.
+		params.MaxFrames = 500
+	}
+
+	decoder, err := registry.NewDecoder(codec, params)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	writer, err := pcm.NewWavWriter(wav, decoder.SampleRate(), decoder.Channels(), pcm.WavFormatPCM)
+	if err != nil {
+		return nil, err
+	}
+
+	// Frames are written on this goroutine as they are decoded, so the
+	// decoder never waits for the writer and the output doesn't depend on
+	// scheduling.
+	var decoded int
+	write := func() error {
+		for decoder.Buffered() > 0 {
+			frame, err := decoder.ReadFrame()
+			if err != nil {
+				return err
+			}
+			decoded += len(frame) / decoder.Channels()
+			err = writer.Write(frame)
+			decoder.Release(frame)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	jitter := transport.NewJitterBuffer(decoder, payloadType, codec.ClockRate, delay)
+	if isRED {
+		jitter.SetRED(config.RED)
+	}
+	report := &Report{
+		SSRC:        s.SSRC,
+		PayloadType: s.PayloadType,
+		Codec:       codec,
+		Packets:     len(s.Packets),
+	}
+	if len(s.Packets) > 0 {
+		report.Duration = s.Packets[len(s.Packets)-1].Arrival.Sub(s.Packets[0].Arrival)
+		err = replay(s.Packets, jitter, config.Speed, write)
+	}
+	if err == nil {
+		err = decoder.WriteFinal()
+	}
+	if err == nil {
+		err = write()
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Stats = jitter.Stats()
+	report.Decoded = time.Duration(decoded) * time.Second / time.Duration(decoder.SampleRate())
+	return report, nil
+}
+
+// replay pushes packets to jitter at their arrival times and calls write
+// after each to write the frames decoded.
+func replay(packets []RTPPacket, jitter *transport.JitterBuffer, speed float64, write func() error) error {
+	start, wallStart := packets[0].Arrival, time.Now()
+	for _, p := range packets {
+		if speed > 0 {
+			at := wallStart.Add(time.Duration(float64(p.Arrival.Sub(start)) / speed))
+			time.Sleep(time.Until(at))
+		}
+		if err := jitter.Advance(p.Arrival); err != nil {
+			return err
+		}
+		switch err := jitter.Push(p.Packet, p.Arrival); err {
+		case nil, transport.ErrLate, transport.ErrDuplicate, red.ErrCorrupt:
+		default:
+			return err
+		}
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	if err := jitter.Flush(); err != nil {
+		return err
+	}
+	return write()
+}
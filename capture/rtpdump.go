@@ -0,0 +1,73 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rtpdumpReader reads the binary rtpdump format of rtptools:
+//
+//	#!rtpplay1.0 <address>/<port>\n
+//	start seconds, microseconds, source address (32 bits), port, padding
+//	per packet: length including this header, RTP length (0 for RTCP),
+//	milliseconds since start (32 bits), packet
+type rtpdumpReader struct {
+	r     *bufio.Reader
+	start time.Time
+	src   *net.UDPAddr
+	dst   *net.UDPAddr
+}
+
+func newRTPDumpReader(r *bufio.Reader) (*rtpdumpReader, error) {
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "#!rtpplay1.0 ") {
+		return nil, ErrFormat
+	}
+	d := &rtpdumpReader{r: r}
+	addr := strings.TrimSpace(strings.TrimPrefix(line, "#!rtpplay1.0 "))
+	if i := strings.LastIndexByte(addr, '/'); i >= 0 {
+		port, _ := strconv.Atoi(addr[i+1:])
+		d.dst = &net.UDPAddr{IP: net.ParseIP(addr[:i]), Port: port}
+	}
+
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, ErrCorrupt
+	}
+	d.start = time.Unix(int64(binary.BigEndian.Uint32(header[:])), int64(binary.BigEndian.Uint32(header[4:]))*1000)
+	d.src = &net.UDPAddr{
+		IP:   net.IP(append([]byte(nil), header[8:12]...)),
+		Port: int(binary.BigEndian.Uint16(header[12:])),
+	}
+	return d, nil
+}
+
+func (d *rtpdumpReader) ReadPacket() (*Packet, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrCorrupt
+		}
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[:]))
+	offset := binary.BigEndian.Uint32(header[4:])
+	if length < 8 {
+		return nil, ErrCorrupt
+	}
+	payload := make([]byte, length-8)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		return nil, ErrCorrupt
+	}
+	return &Packet{
+		Time:    d.start.Add(time.Duration(offset) * time.Millisecond),
+		Src:     d.src,
+		Dst:     d.dst,
+		Payload: payload,
+	}, nil
+}
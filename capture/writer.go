@@ -0,0 +1,173 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Writer writes UDP packets to a capture.
+type Writer interface {
+	WritePacket(p *Packet) error
+}
+
+// RTPDumpWriter writes the rtpdump format of rtptools, which rtpplay can
+// send again with the original timing.
+type RTPDumpWriter struct {
+	w     io.Writer
+	start time.Time
+	buf   []byte
+}
+
+// NewRTPDumpWriter writes the header of an rtpdump file for packets from src
+// to dst, with offsets relative to start.
+func NewRTPDumpWriter(w io.Writer, src, dst *net.UDPAddr, start time.Time) (*RTPDumpWriter, error) {
+	if dst == nil {
+		dst = &net.UDPAddr{IP: net.IPv4zero}
+	}
+	if _, err := fmt.Fprintf(w, "#!rtpplay1.0 %s/%d\n", dst.IP, dst.Port); err != nil {
+		return nil, err
+	}
+	var header [16]byte
+	binary.BigEndian.PutUint32(header[:], uint32(start.Unix()))
+	binary.BigEndian.PutUint32(header[4:], uint32(start.Nanosecond()/1000))
+	if src != nil {
+		if ip := src.IP.To4(); ip != nil {
+			copy(header[8:], ip)
+		}
+		binary.BigEndian.PutUint16(header[12:], uint16(src.Port))
+	}
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &RTPDumpWriter{w: w, start: start}, nil
+}
+
+// WritePacket writes a packet, which may be RTP or RTCP. Packets before the
+// start time are written at offset 0.
+func (d *RTPDumpWriter) WritePacket(p *Packet) error {
+	if len(p.Payload) > 0xffff-8 {
+		return ErrCorrupt
+	}
+	offset := p.Time.Sub(d.start) / time.Millisecond
+	if offset < 0 {
+		offset = 0
+	}
+	plen := len(p.Payload)
+	if isRTCP(p.Payload) {
+		plen = 0
+	}
+	d.buf = append(d.buf[:0], 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(d.buf, uint16(8+len(p.Payload)))
+	binary.BigEndian.PutUint16(d.buf[2:], uint16(plen))
+	binary.BigEndian.PutUint32(d.buf[4:], uint32(offset))
+	d.buf = append(d.buf, p.Payload...)
+	_, err := d.w.Write(d.buf)
+	return err
+}
+
+// PcapWriter writes pcap files with nanosecond timestamps. Packets are
+// written as raw IPv4 or IPv6 packets, as the link layer isn't known.
+type PcapWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewPcapWriter writes the header of a pcap file.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	var header [24]byte
+	binary.LittleEndian.PutUint32(header[:], 0xa1b23c4d)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 0xffff)
+	binary.LittleEndian.PutUint32(header[20:], linkRaw)
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// WritePacket writes a packet. Missing addresses are written as 0.0.0.0.
+func (p *PcapWriter) WritePacket(packet *Packet) error {
+	src, dst := packet.Src, packet.Dst
+	if src == nil {
+		src = &net.UDPAddr{IP: net.IPv4zero}
+	}
+	if dst == nil {
+		dst = &net.UDPAddr{IP: net.IPv4zero}
+	}
+	v4 := src.IP.To4() != nil && dst.IP.To4() != nil
+	headerLen := 40
+	if v4 {
+		headerLen = 20
+	}
+	length := headerLen + 8 + len(packet.Payload)
+	if length > 0xffff {
+		return ErrCorrupt
+	}
+
+	p.buf = append(p.buf[:0], make([]byte, 16+headerLen+8)...)
+	binary.LittleEndian.PutUint32(p.buf, uint32(packet.Time.Unix()))
+	binary.LittleEndian.PutUint32(p.buf[4:], uint32(packet.Time.Nanosecond()))
+	binary.LittleEndian.PutUint32(p.buf[8:], uint32(length))
+	binary.LittleEndian.PutUint32(p.buf[12:], uint32(length))
+
+	ip, udp := p.buf[16:16+headerLen], p.buf[16+headerLen:]
+	binary.BigEndian.PutUint16(udp, uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(packet.Payload)))
+	if v4 {
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(length))
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // Don't fragment.
+		ip[8] = 64
+		ip[9] = 17
+		copy(ip[12:], src.IP.To4())
+		copy(ip[16:], dst.IP.To4())
+		binary.BigEndian.PutUint16(ip[10:], ^checksum(0, ip))
+		// A UDP checksum of 0 means none over IPv4.
+	} else {
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(8+len(packet.Payload)))
+		ip[6] = 17
+		ip[7] = 64
+		copy(ip[8:], src.IP.To16())
+		copy(ip[24:], dst.IP.To16())
+		// The UDP checksum is mandatory over IPv6 and covers a pseudo-header.
+		sum := checksum(0, ip[8:40])
+		sum = checksum(sum, []byte{0, 0, udp[4], udp[5], 0, 0, 0, 17})
+		sum = checksum(sum, udp)
+		sum = ^checksum(sum, packet.Payload)
+		if sum == 0 {
+			sum = 0xffff
+		}
+		binary.BigEndian.PutUint16(udp[6:], sum)
+	}
+	p.buf = append(p.buf, packet.Payload...)
+	_, err := p.w.Write(p.buf)
+	return err
+}
+
+// checksum adds data to an internet checksum (RFC 1071) without
+// complementing it. Only the last data may have an odd length.
+func checksum(sum uint16, data []byte) uint16 {
+	s := uint32(sum)
+	for len(data) > 1 {
+		s += uint32(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		s += uint32(data[0]) << 8
+	}
+	for s > 0xffff {
+		s = s&0xffff + s>>16
+	}
+	return uint16(s)
+}
+
+// isRTCP reports whether a packet is RTCP rather than RTP (RFC 5761).
+func isRTCP(packet []byte) bool {
+	return len(packet) >= 2 && packet[1] >= 192 && packet[1] <= 223
+}
@@ -0,0 +1,162 @@
+// Package cn implements RFC 3389 comfort noise. An Encoder estimates the
+// level and spectral envelope of background noise from PCM frames and
+// produces CN payloads. A Decoder synthesizes matching noise from them.
+package cn
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// Static RTP payload type of CN at 8Khz. Other clock rates use a dynamic
+	// payload type.
+	PayloadType = 13
+
+	// Default number of reflection coefficients, the same as G.729 Annex B.
+	DefaultOrder = 10
+	// Maximum number of reflection coefficients.
+	MaxOrder = 20
+
+	// Quietest level a payload can describe in -dBov.
+	MinLevel = 127
+)
+
+var (
+	ErrUnsupported  = errors.New("unsupported sample rate")
+	ErrOrder        = errors.New("invalid filter order")
+	ErrShortPayload = errors.New("payload too short")
+)
+
+func checkSampleRate(sampleRate int) error {
+	switch sampleRate {
+	case 8000, 16000:
+		return nil
+	}
+	return ErrUnsupported
+}
+
+// Level converts a mean square sample value to the RFC 3389 noise level in
+// -dBov, where 0 dBov is the power of a full scale square wave.
+func Level(power float64) int {
+	if power <= 0 {
+		return MinLevel
+	}
+	level := int(math.Round(-10 * math.Log10(power/(32768*32768))))
+	if level < 0 {
+		return 0
+	}
+	if level > MinLevel {
+		return MinLevel
+	}
+	return level
+}
+
+// Power converts an RFC 3389 noise level in -dBov to a mean square sample value.
+func Power(level int) float64 {
+	return 32768 * 32768 * math.Pow(10, -float64(level)/10)
+}
+
+// quantize maps a reflection coefficient in (-1, 1) to a payload byte.
+func quantize(k float64) byte {
+	n := math.Round(k*128) + 127
+	if n < 0 {
+		n = 0
+	} else if n > 254 {
+		n = 254
+	}
+	return byte(n)
+}
+
+func dequantize(n byte) float64 {
+	return (float64(n) - 127) / 128
+}
+
+// Marshal writes a CN payload with the supplied level and reflection
+// coefficients and returns its length.
+func Marshal(level int, k []float64, payload []byte) (int, error) {
+	if len(k) > MaxOrder {
+		return 0, ErrOrder
+	}
+	if len(payload) < len(k)+1 {
+		return 0, ErrShortPayload
+	}
+	if level < 0 {
+		level = 0
+	} else if level > MinLevel {
+		level = MinLevel
+	}
+	payload[0] = byte(level)
+	for i, v := range k {
+		payload[i+1] = quantize(v)
+	}
+	return len(k) + 1, nil
+}
+
+// Unmarshal parses a CN payload. The reflection coefficients are appended to k.
+func Unmarshal(payload []byte, k []float64) (level int, _ []float64, err error) {
+	if len(payload) == 0 {
+		return 0, k, ErrShortPayload
+	}
+	if len(payload)-1 > MaxOrder {
+		return 0, k, ErrOrder
+	}
+	level = int(payload[0] & 0x7f)
+	for _, n := range payload[1:] {
+		k = append(k, dequantize(n))
+	}
+	return level, k, nil
+}
+
+// levinson solves for the reflection coefficients of the autocorrelation r
+// using the Levinson-Durbin recursion. a and k must have len(r)-1 elements.
+// Returns the prediction error.
+func levinson(r, a, k []float64) float64 {
+	order := len(r) - 1
+	for i := range a {
+		a[i] = 0
+	}
+	err := r[0]
+	if err <= 0 {
+		for i := range k {
+			k[i] = 0
+		}
+		return 0
+	}
+	var tmp [MaxOrder]float64
+	for i := 0; i < order; i++ {
+		acc := r[i+1]
+		for j := 0; j < i; j++ {
+			acc += a[j] * r[i-j]
+		}
+		ki := -acc / err
+		if ki >= 1 || ki <= -1 {
+			// Numerically unstable. Keep the filter found so far.
+			for j := i; j < order; j++ {
+				k[j] = 0
+			}
+			return err
+		}
+		k[i] = ki
+		copy(tmp[:i], a[:i])
+		for j := 0; j < i; j++ {
+			a[j] = tmp[j] + ki*tmp[i-1-j]
+		}
+		a[i] = ki
+		err *= 1 - ki*ki
+	}
+	return err
+}
+
+// stepUp converts reflection coefficients to direct form predictor
+// coefficients. a must have len(k) elements.
+func stepUp(k, a []float64) {
+	var tmp [MaxOrder]float64
+	for i, ki := range k {
+		copy(tmp[:i], a[:i])
+		for j := 0; j < i; j++ {
+			a[j] = tmp[j] + ki*tmp[i-1-j]
+		}
+		a[i] = ki
+	}
+}
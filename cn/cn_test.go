@@ -0,0 +1,168 @@
+package cn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// lowpassNoise returns white noise through a one pole lowpass so the encoder
+// has some spectral shape to find.
+func lowpassNoise(n int, rms float64, seed int64) []int16 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]int16, n)
+	var y float64
+	// Unit variance white noise through y = 0.9y + x has a variance of
+	// 1 / (1 - 0.81).
+	scale := rms / math.Sqrt(1/(1-0.81))
+	for i := range out {
+		y = 0.9*y + rng.NormFloat64()
+		out[i] = int16(y * scale)
+	}
+	return out
+}
+
+func meanSquare(frame []int16) float64 {
+	var sum float64
+	for _, s := range frame {
+		sum += float64(s) * float64(s)
+	}
+	return sum / float64(len(frame))
+}
+
+func TestMarshal(t *testing.T) {
+	k := []float64{0.5, -0.25, 0, 0.9921875, -0.9921875}
+	payload := make([]byte, 6)
+	n, err := Marshal(40, k, payload)
+	if err != nil || n != 6 {
+		t.Fatal(n, err)
+	}
+	if payload[0] != 40 || payload[3] != 127 {
+		t.Fatal(payload)
+	}
+	level, got, err := Unmarshal(payload[:n], nil)
+	if err != nil || level != 40 {
+		t.Fatal(level, err)
+	}
+	for i := range k {
+		if math.Abs(got[i]-k[i]) > 1.0/256 {
+			t.Fatalf("k[%d] = %f, want %f", i, got[i], k[i])
+		}
+	}
+
+	if _, err = Marshal(40, k, payload[:3]); err != ErrShortPayload {
+		t.Fatal(err)
+	}
+	if _, _, err = Unmarshal(nil, nil); err != ErrShortPayload {
+		t.Fatal(err)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	if Level(32768*32768) != 0 {
+		t.Fatal(Level(32768 * 32768))
+	}
+	if Level(0) != MinLevel {
+		t.Fatal(Level(0))
+	}
+	for _, level := range []int{0, 30, 60, 90} {
+		if got := Level(Power(level)); got != level {
+			t.Fatalf("Level(Power(%d)) = %d", level, got)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, sampleRate := range []int{8000, 16000} {
+		frameSize := sampleRate / 50
+		input := lowpassNoise(frameSize*50, 1000, 1)
+
+		enc, err := NewEncoder(sampleRate, DefaultOrder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := make([]byte, DefaultOrder+1)
+		var n int
+		for i := 0; i < len(input); i += frameSize {
+			if n, err = enc.Encode(input[i:i+frameSize], payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n != DefaultOrder+1 {
+			t.Fatal(n)
+		}
+		if want := Level(meanSquare(input)); math.Abs(float64(int(payload[0])-want)) > 1 {
+			t.Fatalf("%d: level %d, want %d", sampleRate, payload[0], want)
+		}
+		// Strongly lowpassed noise has a large first reflection coefficient.
+		if k := dequantize(payload[1]); k > -0.5 {
+			t.Fatalf("%d: k1 = %f", sampleRate, k)
+		}
+
+		dec, err := NewDecoder(sampleRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := make([]int16, frameSize)
+		dec.Generate(frame)
+		if meanSquare(frame) != 0 {
+			t.Fatal("noise before the first payload")
+		}
+		if err = dec.Update(payload[:n]); err != nil {
+			t.Fatal(err)
+		}
+		output := make([]int16, 0, len(input))
+		for i := 0; i < 50; i++ {
+			dec.Generate(frame)
+			output = append(output, frame...)
+		}
+
+		ratio := 10 * math.Log10(meanSquare(output)/meanSquare(input))
+		if math.Abs(ratio) > 1.5 {
+			t.Fatalf("%d: output is %.1fdB from the input", sampleRate, ratio)
+		}
+		// The output should have the input's shape, i.e. be correlated with
+		// its neighbouring samples.
+		var r0, r1 float64
+		for i := 1; i < len(output); i++ {
+			r0 += float64(output[i]) * float64(output[i])
+			r1 += float64(output[i]) * float64(output[i-1])
+		}
+		if r1/r0 < 0.5 {
+			t.Fatalf("%d: output isn't lowpassed, r1/r0 = %f", sampleRate, r1/r0)
+		}
+	}
+}
+
+func TestSilence(t *testing.T) {
+	enc, _ := NewEncoder(8000, DefaultOrder)
+	payload := make([]byte, DefaultOrder+1)
+	if _, err := enc.Encode(make([]int16, 160), payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload[0] != MinLevel {
+		t.Fatal(payload[0])
+	}
+
+	dec, _ := NewDecoder(8000)
+	if err := dec.Update(payload); err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]int16, 160)
+	dec.Generate(frame)
+	if meanSquare(frame) != 0 {
+		t.Fatal("noise for silence")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if _, err := NewEncoder(48000, DefaultOrder); err != ErrUnsupported {
+		t.Fatal(err)
+	}
+	if _, err := NewEncoder(8000, MaxOrder+1); err != ErrOrder {
+		t.Fatal(err)
+	}
+	if _, err := NewDecoder(44100); err != ErrUnsupported {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,135 @@
+package cn
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Per sample weight when moving towards a new level, about 10ms at 8Khz.
+const decoderSmoothing = 0.0125
+
+// Decoder synthesizes comfort noise from CN payloads. White noise is shaped
+// by the all-pole filter described by the reflection coefficients and scaled
+// to the payload's level. Level changes are smoothed so updates don't click.
+type Decoder struct {
+	sampleRate int
+
+	k      []float64
+	a      []float64
+	state  []float64
+	target float64 // Excitation gain for the current payload.
+	gain   float64
+	active bool
+
+	rng *rand.Rand
+	mu  sync.Mutex
+}
+
+// NewDecoder creates a Decoder. sampleRate must be 8000 or 16000.
+func NewDecoder(sampleRate int) (*Decoder, error) {
+	if err := checkSampleRate(sampleRate); err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		sampleRate: sampleRate,
+		rng:        rand.New(rand.NewSource(1)),
+	}, nil
+}
+
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// Active reports whether a payload has been received since the last Reset.
+func (d *Decoder) Active() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+// Reset discards the current payload and filter state.
+func (d *Decoder) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.k = d.k[:0]
+	d.a = d.a[:0]
+	d.state = d.state[:0]
+	d.target = 0
+	d.gain = 0
+	d.active = false
+}
+
+// Update applies a CN payload. Subsequent Generate calls produce noise
+// matching it.
+func (d *Decoder) Update(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	level, k, err := Unmarshal(payload, d.k[:0])
+	if err != nil {
+		return err
+	}
+	d.k = k
+	if cap(d.a) < len(k) {
+		d.a = make([]float64, len(k))
+	}
+	d.a = d.a[:len(k)]
+	stepUp(d.k, d.a)
+	if len(d.state) != len(k) {
+		state := make([]float64, len(k))
+		copy(state, d.state)
+		d.state = state
+	}
+
+	// The filter amplifies white noise by 1 / prod(1 - k^2). Scale the
+	// excitation down so the output has the payload's power.
+	power := Power(level)
+	if level >= MinLevel {
+		power = 0
+	}
+	for _, ki := range d.k {
+		power *= 1 - ki*ki
+	}
+	d.target = math.Sqrt(power)
+	if !d.active {
+		d.gain = d.target
+	}
+	d.active = true
+	return nil
+}
+
+// Generate fills frame with comfort noise. The frame is silent until the
+// first payload is applied.
+func (d *Decoder) Generate(frame []int16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.active {
+		for i := range frame {
+			frame[i] = 0
+		}
+		return
+	}
+
+	order := len(d.a)
+	for i := range frame {
+		d.gain += (d.target - d.gain) * decoderSmoothing
+		y := d.rng.NormFloat64() * d.gain
+		for j := 0; j < order; j++ {
+			y -= d.a[j] * d.state[j]
+		}
+		if order > 0 {
+			copy(d.state[1:], d.state[:order-1])
+			d.state[0] = y
+		}
+		switch {
+		case y > 32767:
+			frame[i] = 32767
+		case y < -32768:
+			frame[i] = -32768
+		default:
+			frame[i] = int16(y)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package cn
+
+import (
+	"sync"
+)
+
+// Encoder estimates the background noise of a stream and produces CN
+// payloads describing it. Frames should be written while the far end is
+// hearing silence, e.g. while VAD reports no speech.
+type Encoder struct {
+	sampleRate int
+	order      int
+
+	// Autocorrelation averaged over recent frames.
+	r     []float64
+	frame []float64
+	a     []float64
+	k     []float64
+	hasR  bool
+
+	mu sync.Mutex
+}
+
+// Weight of the newest frame in the averaged autocorrelation.
+const encoderSmoothing = 0.2
+
+// NewEncoder creates an Encoder producing payloads with order reflection
+// coefficients. sampleRate must be 8000 or 16000.
+func NewEncoder(sampleRate, order int) (*Encoder, error) {
+	if err := checkSampleRate(sampleRate); err != nil {
+		return nil, err
+	}
+	if order < 0 || order > MaxOrder {
+		return nil, ErrOrder
+	}
+	return &Encoder{
+		sampleRate: sampleRate,
+		order:      order,
+		r:          make([]float64, order+1),
+		frame:      make([]float64, order+1),
+		a:          make([]float64, order),
+		k:          make([]float64, order),
+	}, nil
+}
+
+func (e *Encoder) SampleRate() int {
+	return e.sampleRate
+}
+
+func (e *Encoder) Order() int {
+	return e.order
+}
+
+// Reset forgets the noise estimate.
+func (e *Encoder) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.r {
+		e.r[i] = 0
+	}
+	e.hasR = false
+}
+
+// Write updates the noise estimate with a frame of background noise.
+func (e *Encoder) Write(frame []int16) {
+	if len(frame) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := len(frame)
+	for lag := range e.frame {
+		var sum float64
+		for i := lag; i < n; i++ {
+			sum += float64(frame[i]) * float64(frame[i-lag])
+		}
+		// Normalized to a mean square so frame size doesn't matter.
+		e.frame[lag] = sum / float64(n)
+	}
+
+	if !e.hasR {
+		copy(e.r, e.frame)
+		e.hasR = true
+		return
+	}
+	for i := range e.r {
+		e.r[i] += (e.frame[i] - e.r[i]) * encoderSmoothing
+	}
+}
+
+// Payload writes a CN payload for the current noise estimate and returns its
+// length, which is Order() + 1.
+func (e *Encoder) Payload(payload []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(payload) < e.order+1 {
+		return 0, ErrShortPayload
+	}
+	if !e.hasR {
+		for i := range e.k {
+			e.k[i] = 0
+		}
+		return Marshal(MinLevel, e.k, payload)
+	}
+
+	// Slight white noise correction keeps the recursion stable for very
+	// tonal or digitally silent input.
+	r0 := e.r[0]
+	e.r[0] *= 1.0001
+	levinson(e.r, e.a, e.k)
+	e.r[0] = r0
+
+	return Marshal(Level(r0), e.k, payload)
+}
+
+// Encode writes a frame and produces a payload for the updated estimate.
+func (e *Encoder) Encode(frame []int16, payload []byte) (int, error) {
+	e.Write(frame)
+	return e.Payload(payload)
+}
@@ -0,0 +1,96 @@
+package g711
+
+import (
+	"errors"
+)
+
+// FrameDecoder decodes G711 payloads to 16bit 8000Hz LPCM frames. Frames the
+// jitter buffer reports missing are concealed with PLC.
+type FrameDecoder struct {
+	decode func(uint8) int16 // decoding function
+	plc    *PLC
+}
+
+// NewFrameDecoder returns a FrameDecoder for Alaw or Ulaw payloads. With plc,
+// missing frames are concealed as in G.711 Appendix I. Output is then delayed
+// by PLCDelay samples and frames must be a multiple of 10ms.
+func NewFrameDecoder(format int, plc bool) (*FrameDecoder, error) {
+	d := &FrameDecoder{}
+	switch format {
+	case Alaw:
+		d.decode = DecodeAlawFrame
+	case Ulaw:
+		d.decode = DecodeUlawFrame
+	default:
+		return nil, errors.New("Invalid input format")
+	}
+	if plc {
+		d.plc = NewPLC()
+	}
+	return d, nil
+}
+
+// Decode decodes payload into frame and returns the number of samples, which
+// is len(payload).
+func (d *FrameDecoder) Decode(payload []byte, frame []int16) (int, error) {
+	if len(frame) < len(payload) {
+		return 0, errors.New("frame is too small")
+	}
+	frame = frame[:len(payload)]
+	for i, b := range payload {
+		frame[i] = d.decode(b)
+	}
+	if d.plc != nil {
+		if err := d.plc.AddToHistory(frame); err != nil {
+			return 0, err
+		}
+	}
+	return len(frame), nil
+}
+
+// Missing fills frame with a replacement for a lost payload. Without PLC the
+// frame is silent.
+func (d *FrameDecoder) Missing(frame []int16) error {
+	if d.plc == nil {
+		for i := range frame {
+			frame[i] = 0
+		}
+		return nil
+	}
+	return d.plc.Conceal(frame)
+}
+
+// Reset discards the PLC history.
+func (d *FrameDecoder) Reset() {
+	if d.plc != nil {
+		d.plc.Reset()
+	}
+}
+
+// FrameEncoder encodes 16bit 8000Hz LPCM frames to G711 payloads.
+type FrameEncoder struct {
+	encode func(int16) uint8 // encoding function
+}
+
+// NewFrameEncoder returns a FrameEncoder producing Alaw or Ulaw payloads.
+func NewFrameEncoder(format int) (*FrameEncoder, error) {
+	switch format {
+	case Alaw:
+		return &FrameEncoder{encode: EncodeAlawFrame}, nil
+	case Ulaw:
+		return &FrameEncoder{encode: EncodeUlawFrame}, nil
+	}
+	return nil, errors.New("Invalid output format")
+}
+
+// Encode encodes frame into payload and returns the payload length, which is
+// len(frame).
+func (e *FrameEncoder) Encode(frame []int16, payload []byte) (int, error) {
+	if len(payload) < len(frame) {
+		return 0, errors.New("payload is too small")
+	}
+	for i, s := range frame {
+		payload[i] = e.encode(s)
+	}
+	return len(frame), nil
+}
@@ -0,0 +1,40 @@
+package g711
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFrameEncoder(t *testing.T) {
+	input := sine(160, 440, 8000)
+	for _, format := range []int{Alaw, Ulaw} {
+		enc, err := NewFrameEncoder(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := NewFrameDecoder(format, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := make([]byte, 160)
+		n, err := enc.Encode(input, payload)
+		if err != nil || n != 160 {
+			t.Fatal(n, err)
+		}
+		output := make([]int16, 160)
+		if n, err = dec.Decode(payload[:n], output); err != nil || n != 160 {
+			t.Fatal(n, err)
+		}
+		for i := range input {
+			if diff := math.Abs(float64(output[i] - input[i])); diff > 256 {
+				t.Fatalf("%d: sample %d is %d, want %d", format, i, output[i], input[i])
+			}
+		}
+		if err = dec.Missing(output); err != nil || output[0] != 0 {
+			t.Fatal(output[0], err)
+		}
+	}
+	if _, err := NewFrameEncoder(Lpcm); err == nil {
+		t.Fatal("expected an error")
+	}
+}
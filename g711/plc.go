@@ -0,0 +1,302 @@
+package g711
+
+import (
+	"errors"
+	"math"
+)
+
+// Packet loss concealment from ITU-T G.711 Appendix I.
+//
+// The last 48.75ms of output are kept as history. When a frame is lost the
+// pitch is estimated from the history and the last pitch period is repeated,
+// with a quarter period overlap-add at every join. Further losses add periods
+// to the repeated segment so it doesn't sound buzzy, and the output is
+// attenuated by 20% per 10ms until it is silent after 60ms. The first good
+// frame after a loss is crossfaded with the continued concealment.
+//
+// The overlap-add at the start of a loss needs audio that was already
+// played, so output is delayed by PLCDelay samples.
+
+const (
+	// Algorithmic delay of PLC in samples.
+	PLCDelay = plcOverlapMax
+
+	plcFrameSize   = 80 // 10ms, the unit concealment is done in.
+	plcPitchMin    = 40 // 200Hz
+	plcPitchMax    = 120
+	plcPitchDiff   = plcPitchMax - plcPitchMin
+	plcOverlapMax  = plcPitchMax >> 2
+	plcHistoryLen  = plcPitchMax*3 + plcOverlapMax
+	plcNDec        = 2 // Decimation of the coarse pitch search.
+	plcCorrLen     = 160
+	plcCorrBufLen  = plcCorrLen + plcPitchMax
+	plcCorrMinPow  = 250.0
+	plcEOverlapInc = 32 // Extra crossfade per 10ms lost.
+	plcAttenFac    = 0.2
+	plcAttenIncr   = plcAttenFac / plcFrameSize
+)
+
+var ErrFrameSize = errors.New("frame size must be a multiple of 10ms")
+
+// PLC conceals lost frames of 8Khz audio. Every frame must be passed through
+// it in order, either to AddToHistory when it was received or to Conceal when
+// it was lost. Frames must be a multiple of 10ms.
+type PLC struct {
+	eraseCnt int // Number of consecutive 10ms blocks concealed.
+
+	pitch     int
+	pOverlap  int // Quarter of the pitch period.
+	pOffset   int // Read position in the repeated segment.
+	pitchBLen int // Length of the repeated segment.
+
+	history  [plcHistoryLen]int16
+	pitchBuf [plcHistoryLen]float64
+	lastQ    [plcOverlapMax]float64
+	overlap  [plcFrameSize]int16
+}
+
+func NewPLC() *PLC {
+	return &PLC{}
+}
+
+// Reset clears the history.
+func (p *PLC) Reset() {
+	*p = PLC{}
+}
+
+// Concealing reports whether the last frame was concealed.
+func (p *PLC) Concealing() bool {
+	return p.eraseCnt > 0
+}
+
+// AddToHistory records a received frame. The frame is replaced with the
+// output, which is delayed by PLCDelay samples and crossfaded with the
+// concealment if frames were lost before it.
+func (p *PLC) AddToHistory(frame []int16) error {
+	if len(frame)%plcFrameSize != 0 {
+		return ErrFrameSize
+	}
+	for i := 0; i < len(frame); i += plcFrameSize {
+		p.addToHistory(frame[i : i+plcFrameSize])
+	}
+	return nil
+}
+
+// Conceal fills frame with a replacement for a lost frame.
+func (p *PLC) Conceal(frame []int16) error {
+	if len(frame)%plcFrameSize != 0 {
+		return ErrFrameSize
+	}
+	for i := 0; i < len(frame); i += plcFrameSize {
+		p.conceal(frame[i : i+plcFrameSize])
+	}
+	return nil
+}
+
+func (p *PLC) addToHistory(s []int16) {
+	if p.eraseCnt > 0 {
+		olen := p.pOverlap + (p.eraseCnt-1)*plcEOverlapInc
+		if olen > plcFrameSize {
+			olen = plcFrameSize
+		}
+		p.getFESpeech(p.overlap[:olen])
+		p.overlapAddAtEnd(s, p.overlap[:olen])
+		p.eraseCnt = 0
+	}
+	p.saveSpeech(s)
+}
+
+func (p *PLC) conceal(out []int16) {
+	end := plcHistoryLen
+	switch {
+	case p.eraseCnt == 0:
+		for i, v := range p.history {
+			p.pitchBuf[i] = float64(v)
+		}
+		p.pitch = p.findPitch()
+		p.pOverlap = p.pitch >> 2
+		// Save the last quarter period and crossfade it with the quarter
+		// period before the repeated segment, so the join is smooth.
+		copy(p.lastQ[:p.pOverlap], p.pitchBuf[end-p.pOverlap:end])
+		p.pOffset = 0
+		p.pitchBLen = p.pitch
+		start := end - p.pitchBLen
+		overlapAdd(p.lastQ[:p.pOverlap], p.pitchBuf[start-p.pOverlap:], p.pitchBuf[end-p.pOverlap:])
+		// The output is delayed, so the crossfaded quarter period has not
+		// been played yet.
+		for i := end - p.pOverlap; i < end; i++ {
+			p.history[i] = saturate(p.pitchBuf[i])
+		}
+		p.getFESpeech(out)
+
+	case p.eraseCnt == 1 || p.eraseCnt == 2:
+		// Add a period to the repeated segment.
+		var tmp [plcOverlapMax]int16
+		saveOffset := p.pOffset
+		p.getFESpeech(tmp[:p.pOverlap])
+		p.pOffset = saveOffset
+		for p.pOffset > p.pitch {
+			p.pOffset -= p.pitch
+		}
+		p.pitchBLen += p.pitch
+		start := end - p.pitchBLen
+		overlapAdd(p.lastQ[:p.pOverlap], p.pitchBuf[start-p.pOverlap:], p.pitchBuf[end-p.pOverlap:])
+		p.getFESpeech(out)
+		overlapAddInt16(tmp[:p.pOverlap], out, out)
+		p.scaleSpeech(out)
+
+	case p.eraseCnt > 5:
+		for i := range out {
+			out[i] = 0
+		}
+
+	default:
+		p.getFESpeech(out)
+		p.scaleSpeech(out)
+	}
+	p.eraseCnt++
+	p.saveSpeech(out)
+}
+
+// saveSpeech appends s to the history and replaces it with the delayed output.
+func (p *PLC) saveSpeech(s []int16) {
+	copy(p.history[:], p.history[plcFrameSize:])
+	copy(p.history[plcHistoryLen-plcFrameSize:], s)
+	copy(s, p.history[plcHistoryLen-plcFrameSize-plcOverlapMax:])
+}
+
+// getFESpeech reads the repeated segment into out.
+func (p *PLC) getFESpeech(out []int16) {
+	start := plcHistoryLen - p.pitchBLen
+	for len(out) > 0 {
+		n := p.pitchBLen - p.pOffset
+		if n > len(out) {
+			n = len(out)
+		}
+		for i := 0; i < n; i++ {
+			out[i] = saturate(p.pitchBuf[start+p.pOffset+i])
+		}
+		p.pOffset += n
+		if p.pOffset == p.pitchBLen {
+			p.pOffset = 0
+		}
+		out = out[n:]
+	}
+}
+
+// scaleSpeech attenuates concealment by 20% per 10ms after the first 10ms.
+func (p *PLC) scaleSpeech(out []int16) {
+	g := 1 - float64(p.eraseCnt-1)*plcAttenFac
+	for i, v := range out {
+		out[i] = saturate(float64(v) * g)
+		g -= plcAttenIncr
+	}
+}
+
+// overlapAddAtEnd crossfades the continued concealment f into the start of
+// the good frame s.
+func (p *PLC) overlapAddAtEnd(s, f []int16) {
+	gain := 1 - float64(p.eraseCnt-1)*plcAttenFac
+	if gain < 0 {
+		gain = 0
+	}
+	incr := 1 / float64(len(f))
+	incrGain := incr * gain
+	lw := (1 - incr) * gain
+	rw := incr
+	for i := range f {
+		s[i] = saturate(lw*float64(f[i]) + rw*float64(s[i]))
+		lw -= incrGain
+		rw += incr
+	}
+}
+
+// findPitch estimates the pitch period of the history by normalized cross
+// correlation of the last 20ms with earlier audio, first at half resolution
+// and then refined around the best match.
+func (p *PLC) findPitch() int {
+	l := p.pitchBuf[plcHistoryLen-plcCorrLen:]
+	r := p.pitchBuf[plcHistoryLen-plcCorrBufLen:]
+
+	var energy, corr float64
+	for i := 0; i < plcCorrLen; i += plcNDec {
+		energy += r[i] * r[i]
+		corr += r[i] * l[i]
+	}
+	bestCorr := corr / math.Sqrt(math.Max(energy, plcCorrMinPow))
+	bestMatch := 0
+	for j := plcNDec; j <= plcPitchDiff; j += plcNDec {
+		energy -= r[j-plcNDec] * r[j-plcNDec]
+		energy += r[j-plcNDec+plcCorrLen] * r[j-plcNDec+plcCorrLen]
+		corr = 0
+		for i := 0; i < plcCorrLen; i += plcNDec {
+			corr += r[j+i] * l[i]
+		}
+		corr /= math.Sqrt(math.Max(energy, plcCorrMinPow))
+		if corr >= bestCorr {
+			bestCorr = corr
+			bestMatch = j
+		}
+	}
+
+	lo := bestMatch - (plcNDec - 1)
+	if lo < 0 {
+		lo = 0
+	}
+	hi := bestMatch + (plcNDec - 1)
+	if hi > plcPitchDiff {
+		hi = plcPitchDiff
+	}
+	energy, corr = 0, 0
+	for i := 0; i < plcCorrLen; i++ {
+		energy += r[lo+i] * r[lo+i]
+		corr += r[lo+i] * l[i]
+	}
+	bestCorr = corr / math.Sqrt(math.Max(energy, plcCorrMinPow))
+	bestMatch = lo
+	for j := lo + 1; j <= hi; j++ {
+		energy -= r[j-1] * r[j-1]
+		energy += r[j-1+plcCorrLen] * r[j-1+plcCorrLen]
+		corr = 0
+		for i := 0; i < plcCorrLen; i++ {
+			corr += r[j+i] * l[i]
+		}
+		corr /= math.Sqrt(math.Max(energy, plcCorrMinPow))
+		if corr > bestCorr {
+			bestCorr = corr
+			bestMatch = j
+		}
+	}
+	return plcPitchMax - bestMatch
+}
+
+// overlapAdd writes a triangular crossfade from l to r into out.
+func overlapAdd(l, r, out []float64) {
+	incr := 1 / float64(len(l))
+	lw, rw := 1-incr, incr
+	for i := range l {
+		out[i] = lw*l[i] + rw*r[i]
+		lw -= incr
+		rw += incr
+	}
+}
+
+func overlapAddInt16(l, r, out []int16) {
+	incr := 1 / float64(len(l))
+	lw, rw := 1-incr, incr
+	for i := range l {
+		out[i] = saturate(lw*float64(l[i]) + rw*float64(r[i]))
+		lw -= incr
+		rw += incr
+	}
+}
+
+func saturate(v float64) int16 {
+	switch {
+	case v > 32767:
+		return 32767
+	case v < -32768:
+		return -32768
+	}
+	return int16(v)
+}
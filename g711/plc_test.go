@@ -0,0 +1,102 @@
+package g711
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(n int, freq, amplitude float64) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/8000))
+	}
+	return out
+}
+
+func TestPLC_Periodic(t *testing.T) {
+	const frameSize = 160
+	input := sine(frameSize*20, 250, 8000)
+	payload := make([]byte, frameSize)
+
+	d, err := NewFrameDecoder(Ulaw, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output []int16
+	frame := make([]int16, frameSize)
+	for i := 0; i < len(input); i += frameSize {
+		// Lose the 11th frame, 20ms.
+		if i/frameSize == 10 {
+			if err = d.Missing(frame); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			for j, s := range input[i : i+frameSize] {
+				payload[j] = EncodeUlawFrame(s)
+			}
+			if _, err = d.Decode(payload, frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		output = append(output, frame...)
+	}
+
+	// A pure tone is continued almost perfectly. The second 10ms is faded by
+	// 20%. Output is delayed, so the loss is heard from PLCDelay on.
+	lossStart := 10*frameSize + PLCDelay
+	lossEnd := lossStart + frameSize
+	for i := lossStart; i < lossEnd; i++ {
+		want := float64(input[i-PLCDelay])
+		if faded := i - lossStart - 80; faded > 0 {
+			want *= 1 - float64(faded)*0.2/80
+		}
+		if diff := math.Abs(float64(output[i]) - want); diff > 300 {
+			t.Fatalf("sample %d is %d, want about %.0f", i, output[i], want)
+		}
+	}
+	// Decoding is back to normal after the crossfade into the next frame.
+	for i := lossEnd + 80; i < len(output); i++ {
+		if diff := math.Abs(float64(output[i] - input[i-PLCDelay])); diff > 300 {
+			t.Fatalf("sample %d is %d, want %d", i, output[i], input[i-PLCDelay])
+		}
+	}
+}
+
+func TestPLC_LongLoss(t *testing.T) {
+	p := NewPLC()
+	input := sine(800, 200, 8000)
+	if err := p.AddToHistory(input); err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]int16, 80)
+	for i := 0; i < 8; i++ {
+		if err := p.Conceal(frame); err != nil {
+			t.Fatal(err)
+		}
+		if !p.Concealing() {
+			t.Fatal("not concealing")
+		}
+	}
+	// Silent after 60ms, apart from the delayed tail of the last block.
+	for i, s := range frame[PLCDelay:] {
+		if s != 0 {
+			t.Fatalf("sample %d is %d after 80ms of loss", i, s)
+		}
+	}
+	if err := p.AddToHistory(make([]int16, 80)); err != nil {
+		t.Fatal(err)
+	}
+	if p.Concealing() {
+		t.Fatal("still concealing")
+	}
+}
+
+func TestPLC_FrameSize(t *testing.T) {
+	p := NewPLC()
+	if err := p.Conceal(make([]int16, 100)); err != ErrFrameSize {
+		t.Fatal(err)
+	}
+	if err := p.AddToHistory(make([]int16, 100)); err != ErrFrameSize {
+		t.Fatal(err)
+	}
+}
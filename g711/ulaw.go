@@ -11,8 +11,6 @@
 
 package g711
 
-import "github.com/pidato/audio/pcm"
-
 const (
 	uLawBias = 0x84
 	uLawClip = 0x7F7B
@@ -94,16 +92,6 @@ var (
 	}
 )
 
-type UlawEncoder struct {
-	pcm *pcm.Buffer
-
-	buf []int16
-}
-
-func NewUlawEncoder(ptime int) (*UlawEncoder, error) {
-
-}
-
 // EncodeUlaw encodes 16bit LPCM data to G711 u-law PCM
 func EncodeUlaw(lpcm []byte) []byte {
 	if len(lpcm) < 2 {
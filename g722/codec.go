@@ -0,0 +1,349 @@
+package g722
+
+// The ITU-T G.722 sub-band ADPCM algorithm. The input is split by a QMF into
+// a 0-4Khz and a 4-8Khz band at 8Khz each. The low band is coded with 6 bits
+// and the high band with 2 bits, so every pair of 16Khz samples becomes one
+// byte. The 56 and 48 kbit modes drop the 1 or 2 least significant bits of the
+// low band, which the decoder then ignores.
+
+var (
+	q6   = [32]int{0, 35, 72, 110, 150, 190, 233, 276, 323, 370, 422, 473, 530, 587, 650, 714, 786, 858, 940, 1023, 1121, 1219, 1339, 1458, 1612, 1765, 1980, 2195, 2557, 2919, 0, 0}
+	iln  = [32]int{0, 63, 62, 31, 30, 29, 28, 27, 26, 25, 24, 23, 22, 21, 20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 0}
+	ilp  = [32]int{0, 61, 60, 59, 58, 57, 56, 55, 54, 53, 52, 51, 50, 49, 48, 47, 46, 45, 44, 43, 42, 41, 40, 39, 38, 37, 36, 35, 34, 33, 32, 0}
+	wl   = [8]int{-60, -30, 58, 172, 334, 538, 1198, 3042}
+	rl42 = [16]int{0, 7, 6, 5, 4, 3, 2, 1, 7, 6, 5, 4, 3, 2, 1, 0}
+	ilb  = [32]int{2048, 2093, 2139, 2186, 2233, 2282, 2332, 2383, 2435, 2489, 2543, 2599, 2656, 2714, 2774, 2834, 2896, 2960, 3025, 3091, 3158, 3228, 3298, 3371, 3444, 3520, 3597, 3676, 3756, 3838, 3922, 4008}
+	qm2  = [4]int{-7408, -1616, 7408, 1616}
+	qm4  = [16]int{0, -20456, -12896, -8968, -6288, -4240, -2584, -1200, 20456, 12896, 8968, 6288, 4240, 2584, 1200, 0}
+	qm5  = [32]int{-280, -280, -23352, -17560, -14120, -11664, -9752, -8184, -6864, -5712, -4696, -3784, -2960, -2208, -1520, -880, 23352, 17560, 14120, 11664, 9752, 8184, 6864, 5712, 4696, 3784, 2960, 2208, 1520, 880, 280, -280}
+	qm6  = [64]int{-136, -136, -136, -136, -24808, -21904, -19008, -16704, -14984, -13512, -12280, -11192, -10232, -9360, -8576, -7856, -7192, -6576, -6000, -5456, -4944, -4464, -4008, -3576, -3168, -2776, -2400, -2032, -1688, -1360, -1040, -728, 24808, 21904, 19008, 16704, 14984, 13512, 12280, 11192, 10232, 9360, 8576, 7856, 7192, 6576, 6000, 5456, 4944, 4464, 4008, 3576, 3168, 2776, 2400, 2032, 1688, 1360, 1040, 728, 432, 136, -432, -136}
+	ihn  = [3]int{0, 1, 0}
+	ihp  = [3]int{0, 3, 2}
+	wh   = [3]int{0, -214, 798}
+	rh2  = [4]int{2, 1, 2, 1}
+
+	qmfCoeffs = [12]int{3, -11, 12, 32, -210, 951, 3876, -805, 362, -156, 53, -11}
+)
+
+// band is the adaptive predictor and quantizer state of a sub-band.
+type band struct {
+	s   int
+	sp  int
+	sz  int
+	r   [3]int
+	a   [3]int
+	ap  [3]int
+	p   [3]int
+	d   [7]int
+	b   [7]int
+	bp  [7]int
+	sg  [7]int
+	nb  int
+	det int
+}
+
+func saturate(amp int) int {
+	if amp > 32767 {
+		return 32767
+	}
+	if amp < -32768 {
+		return -32768
+	}
+	return amp
+}
+
+// scale updates the quantizer scale factor from the log scale factor nb.
+func (b *band) scale(shift int) {
+	wd1 := (b.nb >> 6) & 31
+	wd2 := shift - (b.nb >> 11)
+	var wd3 int
+	if wd2 < 0 {
+		wd3 = ilb[wd1] << uint(-wd2)
+	} else {
+		wd3 = ilb[wd1] >> uint(wd2)
+	}
+	b.det = wd3 << 2
+}
+
+// block4 updates the pole and zero predictors with the quantized difference d.
+func (b *band) block4(d int) {
+	// RECONS
+	b.d[0] = d
+	b.r[0] = saturate(b.s + d)
+
+	// PARREC
+	b.p[0] = saturate(b.sz + d)
+
+	// UPPOL2
+	for i := 0; i < 3; i++ {
+		b.sg[i] = b.p[i] >> 15
+	}
+	wd1 := saturate(b.a[1] << 2)
+	wd2 := wd1
+	if b.sg[0] == b.sg[1] {
+		wd2 = -wd1
+	}
+	if wd2 > 32767 {
+		wd2 = 32767
+	}
+	wd3 := wd2 >> 7
+	if b.sg[0] == b.sg[2] {
+		wd3 += 128
+	} else {
+		wd3 -= 128
+	}
+	wd3 += (b.a[2] * 32512) >> 15
+	if wd3 > 12288 {
+		wd3 = 12288
+	} else if wd3 < -12288 {
+		wd3 = -12288
+	}
+	b.ap[2] = wd3
+
+	// UPPOL1
+	b.sg[0] = b.p[0] >> 15
+	b.sg[1] = b.p[1] >> 15
+	if b.sg[0] == b.sg[1] {
+		wd1 = 192
+	} else {
+		wd1 = -192
+	}
+	wd2 = (b.a[1] * 32640) >> 15
+	b.ap[1] = saturate(wd1 + wd2)
+	wd3 = saturate(15360 - b.ap[2])
+	if b.ap[1] > wd3 {
+		b.ap[1] = wd3
+	} else if b.ap[1] < -wd3 {
+		b.ap[1] = -wd3
+	}
+
+	// UPZERO
+	wd1 = 128
+	if d == 0 {
+		wd1 = 0
+	}
+	b.sg[0] = d >> 15
+	for i := 1; i < 7; i++ {
+		b.sg[i] = b.d[i] >> 15
+		wd2 = -wd1
+		if b.sg[i] == b.sg[0] {
+			wd2 = wd1
+		}
+		wd3 = (b.b[i] * 32640) >> 15
+		b.bp[i] = saturate(wd2 + wd3)
+	}
+
+	// DELAYA
+	for i := 6; i > 0; i-- {
+		b.d[i] = b.d[i-1]
+		b.b[i] = b.bp[i]
+	}
+	for i := 2; i > 0; i-- {
+		b.r[i] = b.r[i-1]
+		b.p[i] = b.p[i-1]
+		b.a[i] = b.ap[i]
+	}
+
+	// FILTEP
+	wd1 = saturate(b.r[1] + b.r[1])
+	wd1 = (b.a[1] * wd1) >> 15
+	wd2 = saturate(b.r[2] + b.r[2])
+	wd2 = (b.a[2] * wd2) >> 15
+	b.sp = saturate(wd1 + wd2)
+
+	// FILTEZ
+	b.sz = 0
+	for i := 6; i > 0; i-- {
+		wd1 = saturate(b.d[i] + b.d[i])
+		b.sz += (b.b[i] * wd1) >> 15
+	}
+	b.sz = saturate(b.sz)
+
+	// PREDIC
+	b.s = saturate(b.sp + b.sz)
+}
+
+type encoderState struct {
+	bits int
+	x    [24]int
+	band [2]band
+}
+
+func (s *encoderState) reset(bits int) {
+	*s = encoderState{bits: bits}
+	s.band[0].det = 32
+	s.band[1].det = 8
+}
+
+// encode codes a pair of 16Khz samples.
+func (s *encoderState) encode(amp0, amp1 int16) byte {
+	// Transmit QMF.
+	copy(s.x[:22], s.x[2:])
+	s.x[22] = int(amp0)
+	s.x[23] = int(amp1)
+	sumEven, sumOdd := 0, 0
+	for i := 0; i < 12; i++ {
+		sumOdd += s.x[2*i] * qmfCoeffs[i]
+		sumEven += s.x[2*i+1] * qmfCoeffs[11-i]
+	}
+	return s.encodeBands((sumEven+sumOdd)>>14, (sumEven-sumOdd)>>14)
+}
+
+// encodeBands codes a sample of each band. The ITU-T test sequences start
+// here, bypassing the QMF.
+func (s *encoderState) encodeBands(xlow, xhigh int) byte {
+	// Low band: SUBTRA, QUANTL.
+	low := &s.band[0]
+	el := saturate(xlow - low.s)
+	wd := el
+	if el < 0 {
+		wd = -(el + 1)
+	}
+	i := 1
+	for ; i < 30; i++ {
+		if wd < (q6[i]*low.det)>>12 {
+			break
+		}
+	}
+	var ilow int
+	if el < 0 {
+		ilow = iln[i]
+	} else {
+		ilow = ilp[i]
+	}
+
+	// INVQAL, LOGSCL, SCALEL.
+	ril := ilow >> 2
+	dlow := (low.det * qm4[ril]) >> 15
+	low.nb = (low.nb*127)>>7 + wl[rl42[ril]]
+	if low.nb < 0 {
+		low.nb = 0
+	} else if low.nb > 18432 {
+		low.nb = 18432
+	}
+	low.scale(8)
+	low.block4(dlow)
+
+	// High band: SUBTRA, QUANTH.
+	high := &s.band[1]
+	eh := saturate(xhigh - high.s)
+	wd = eh
+	if eh < 0 {
+		wd = -(eh + 1)
+	}
+	mih := 1
+	if wd >= (564*high.det)>>12 {
+		mih = 2
+	}
+	var ihigh int
+	if eh < 0 {
+		ihigh = ihn[mih]
+	} else {
+		ihigh = ihp[mih]
+	}
+
+	// INVQAH, LOGSCH, SCALEH.
+	dhigh := (high.det * qm2[ihigh]) >> 15
+	high.nb = (high.nb*127)>>7 + wh[rh2[ihigh]]
+	if high.nb < 0 {
+		high.nb = 0
+	} else if high.nb > 22528 {
+		high.nb = 22528
+	}
+	high.scale(10)
+	high.block4(dhigh)
+
+	// The lower rate modes clear the bits the decoder ignores.
+	code := (ihigh << 6) | ilow
+	return byte(code >> uint(8-s.bits) << uint(8-s.bits))
+}
+
+type decoderState struct {
+	bits int
+	x    [24]int
+	band [2]band
+}
+
+func (s *decoderState) reset(bits int) {
+	*s = decoderState{bits: bits}
+	s.band[0].det = 32
+	s.band[1].det = 8
+}
+
+// decode decodes a byte into a pair of 16Khz samples.
+func (s *decoderState) decode(code byte) (int16, int16) {
+	rlow, rhigh := s.decodeBands(code)
+
+	// Receive QMF.
+	copy(s.x[:22], s.x[2:])
+	s.x[22] = rlow + rhigh
+	s.x[23] = rlow - rhigh
+	xout1, xout2 := 0, 0
+	for i := 0; i < 12; i++ {
+		xout2 += s.x[2*i] * qmfCoeffs[i]
+		xout1 += s.x[2*i+1] * qmfCoeffs[11-i]
+	}
+	return int16(saturate(xout1 >> 11)), int16(saturate(xout2 >> 11))
+}
+
+// decodeBands decodes a byte into a sample of each band, before the QMF.
+func (s *decoderState) decodeBands(code byte) (rlow, rhigh int) {
+	var wd1, wd2, ihigh int
+	switch s.bits {
+	case 7:
+		wd1 = int(code>>1) & 0x1f
+		ihigh = int(code>>6) & 0x03
+		wd2 = qm5[wd1]
+		wd1 >>= 1
+	case 6:
+		wd1 = int(code>>2) & 0x0f
+		ihigh = int(code>>6) & 0x03
+		wd2 = qm4[wd1]
+	default:
+		wd1 = int(code) & 0x3f
+		ihigh = int(code>>6) & 0x03
+		wd2 = qm6[wd1]
+		wd1 >>= 2
+	}
+
+	// Low band: INVQBL, RECONS, LIMIT.
+	low := &s.band[0]
+	rlow = low.s + (low.det*wd2)>>15
+	if rlow > 16383 {
+		rlow = 16383
+	} else if rlow < -16384 {
+		rlow = -16384
+	}
+
+	// INVQAL, LOGSCL, SCALEL.
+	dlow := (low.det * qm4[wd1]) >> 15
+	low.nb = (low.nb*127)>>7 + wl[rl42[wd1]]
+	if low.nb < 0 {
+		low.nb = 0
+	} else if low.nb > 18432 {
+		low.nb = 18432
+	}
+	low.scale(8)
+	low.block4(dlow)
+
+	// High band: INVQAH, RECONS, LIMIT.
+	high := &s.band[1]
+	dhigh := (high.det * qm2[ihigh]) >> 15
+	rhigh = dhigh + high.s
+	if rhigh > 16383 {
+		rhigh = 16383
+	} else if rhigh < -16384 {
+		rhigh = -16384
+	}
+
+	// LOGSCH, SCALEH.
+	high.nb = (high.nb*127)>>7 + wh[rh2[ihigh]]
+	if high.nb < 0 {
+		high.nb = 0
+	} else if high.nb > 22528 {
+		high.nb = 22528
+	}
+	high.scale(10)
+	high.block4(dhigh)
+	return rlow, rhigh
+}
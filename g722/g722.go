@@ -0,0 +1,258 @@
+/*
+Package g722 implements encoding and decoding of G722 wideband audio.
+G.722 is an ITU-T sub-band ADPCM codec for 16Khz audio at 64, 56 or 48 kbit/s.
+
+Every pair of 16Khz samples is coded as one byte, which is why RTP uses a
+clock rate of 8000 for G722 (RFC 3551) even though the audio is 16Khz. A
+payload of n bytes holds 2n samples and advances the RTP timestamp by n.
+*/
+package g722
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// Bit rates
+	Rate64000 = 64000
+	Rate56000 = 56000
+	Rate48000 = 48000
+
+	SampleRate = 16000
+	// RTP clock rate of G722, which is half the sample rate.
+	ClockRate = 8000
+	// Static RTP payload type.
+	PayloadType = 9
+)
+
+var (
+	ErrRate      = errors.New("Invalid bit rate")
+	ErrFrameSize = errors.New("frame must have an even number of samples")
+	ErrShort     = errors.New("buffer is too small")
+)
+
+func bitsOf(rate int) (int, error) {
+	switch rate {
+	case Rate64000:
+		return 8, nil
+	case Rate56000:
+		return 7, nil
+	case Rate48000:
+		return 6, nil
+	}
+	return 0, ErrRate
+}
+
+// Ticks returns the number of RTP timestamp ticks of a payload.
+func Ticks(payloadSize int) int {
+	return payloadSize
+}
+
+// FrameEncoder encodes 16Khz LPCM frames to G722 payloads.
+type FrameEncoder struct {
+	state encoderState
+}
+
+// NewFrameEncoder returns a FrameEncoder for the bit rate.
+func NewFrameEncoder(rate int) (*FrameEncoder, error) {
+	bits, err := bitsOf(rate)
+	if err != nil {
+		return nil, err
+	}
+	e := &FrameEncoder{}
+	e.state.reset(bits)
+	return e, nil
+}
+
+// Encode encodes frame into payload and returns the payload length, which is
+// len(frame)/2.
+func (e *FrameEncoder) Encode(frame []int16, payload []byte) (int, error) {
+	if len(frame)%2 != 0 {
+		return 0, ErrFrameSize
+	}
+	n := len(frame) / 2
+	if len(payload) < n {
+		return 0, ErrShort
+	}
+	for i := 0; i < n; i++ {
+		payload[i] = e.state.encode(frame[2*i], frame[2*i+1])
+	}
+	return n, nil
+}
+
+// Reset discards the encoder state.
+func (e *FrameEncoder) Reset() {
+	e.state.reset(e.state.bits)
+}
+
+// FrameDecoder decodes G722 payloads to 16Khz LPCM frames.
+type FrameDecoder struct {
+	state decoderState
+}
+
+// NewFrameDecoder returns a FrameDecoder for the bit rate.
+func NewFrameDecoder(rate int) (*FrameDecoder, error) {
+	bits, err := bitsOf(rate)
+	if err != nil {
+		return nil, err
+	}
+	d := &FrameDecoder{}
+	d.state.reset(bits)
+	return d, nil
+}
+
+// Decode decodes payload into frame and returns the number of samples, which
+// is len(payload)*2.
+func (d *FrameDecoder) Decode(payload []byte, frame []int16) (int, error) {
+	if len(frame) < len(payload)*2 {
+		return 0, ErrShort
+	}
+	for i, code := range payload {
+		frame[2*i], frame[2*i+1] = d.state.decode(code)
+	}
+	return len(payload) * 2, nil
+}
+
+// Reset discards the decoder state.
+func (d *FrameDecoder) Reset() {
+	d.state.reset(d.state.bits)
+}
+
+// Decoder reads G722 data and decodes it to 16bit 16000Hz LPCM
+type Decoder struct {
+	state  decoderState
+	source io.Reader // source data
+	buf    []byte
+}
+
+// NewDecoder returns a pointer to a Decoder that implements an io.Reader.
+// It takes as input the source data Reader and the bit rate.
+func NewDecoder(reader io.Reader, rate int) (*Decoder, error) {
+	if reader == nil {
+		return nil, errors.New("io.Reader is nil")
+	}
+	bits, err := bitsOf(rate)
+	if err != nil {
+		return nil, err
+	}
+	r := &Decoder{source: reader}
+	r.state.reset(bits)
+	return r, nil
+}
+
+// Reset discards the Decoder state. This permits reusing a Decoder rather than allocating a new one.
+func (r *Decoder) Reset(reader io.Reader) error {
+	if reader == nil {
+		return errors.New("io.Reader is nil")
+	}
+	r.source = reader
+	r.state.reset(r.state.bits)
+	return nil
+}
+
+// Read decodes G722 data. Reads up to len(p) bytes into p, returns the number
+// of bytes read and any error encountered. Every byte of G722 decodes to 4
+// bytes of LPCM, so len(p) should be a multiple of 4.
+func (r *Decoder) Read(p []byte) (i int, err error) {
+	if len(p) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	n := len(p) / 4
+	if cap(r.buf) < n {
+		r.buf = make([]byte, n)
+	}
+	b := r.buf[:n]
+	n, err = r.source.Read(b)
+	for j, code := range b[:n] {
+		s0, s1 := r.state.decode(code)
+		p[4*j] = byte(s0)
+		p[4*j+1] = byte(s0 >> 8)
+		p[4*j+2] = byte(s1)
+		p[4*j+3] = byte(s1 >> 8)
+	}
+	return n * 4, err
+}
+
+// Encoder encodes 16bit 16000Hz LPCM data to G722
+type Encoder struct {
+	state       encoderState
+	destination io.Writer // output data
+	partial     [4]byte   // Bytes of an incomplete sample pair.
+	partialLen  int
+	buf         []byte
+}
+
+// NewEncoder returns a pointer to an Encoder that implements an io.Writer.
+// It takes as input the destination data Writer and the bit rate.
+func NewEncoder(writer io.Writer, rate int) (*Encoder, error) {
+	if writer == nil {
+		return nil, errors.New("io.Writer is nil")
+	}
+	bits, err := bitsOf(rate)
+	if err != nil {
+		return nil, err
+	}
+	w := &Encoder{destination: writer}
+	w.state.reset(bits)
+	return w, nil
+}
+
+// Reset discards the Encoder state. This permits reusing an Encoder rather than allocating a new one.
+func (w *Encoder) Reset(writer io.Writer) error {
+	if writer == nil {
+		return errors.New("io.Writer is nil")
+	}
+	w.destination = writer
+	w.state.reset(w.state.bits)
+	w.partialLen = 0
+	return nil
+}
+
+// Write encodes LPCM data. Writes len(p) bytes from p to the underlying data
+// stream, returns the number of bytes written from p (0 <= n <= len(p)) and
+// any error encountered that caused the write to stop early. An incomplete
+// sample pair is kept until the next Write.
+func (w *Encoder) Write(p []byte) (i int, err error) {
+	total := len(p)
+	if cap(w.buf) < (w.partialLen+len(p))/4 {
+		w.buf = make([]byte, (w.partialLen+len(p))/4)
+	}
+	out := w.buf[:0]
+	// Bytes of the first pair that were written before this call.
+	carried := w.partialLen
+	if w.partialLen > 0 {
+		n := copy(w.partial[w.partialLen:], p)
+		w.partialLen += n
+		p = p[n:]
+		if w.partialLen < 4 {
+			return total, nil
+		}
+		out = append(out, w.encodePair(w.partial[:]))
+		w.partialLen = 0
+	}
+	for len(p) >= 4 {
+		out = append(out, w.encodePair(p))
+		p = p[4:]
+	}
+	w.partialLen = copy(w.partial[:], p)
+
+	n, err := w.destination.Write(out)
+	if n < len(out) {
+		// Report back the bytes of p behind what was written. The trailing
+		// partial pair is reported as not written, so it isn't kept.
+		w.partialLen = 0
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		if n == 0 {
+			return 0, err
+		}
+		return n*4 - carried, err
+	}
+	return total, err
+}
+
+func (w *Encoder) encodePair(p []byte) byte {
+	return w.state.encode(int16(p[0])|int16(p[1])<<8, int16(p[2])|int16(p[3])<<8)
+}
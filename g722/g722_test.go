@@ -0,0 +1,281 @@
+package g722
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func tone(n int, freq, amplitude float64) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/SampleRate))
+	}
+	return out
+}
+
+// snr returns the SNR of output against input in dB, allowing for the codec
+// delay.
+func snr(input, output []int16) (float64, int) {
+	best, bestDelay := math.Inf(-1), 0
+	for delay := 0; delay < 64; delay++ {
+		var signal, noise float64
+		for i := 2000; i < len(input)-delay; i++ {
+			s := float64(input[i])
+			e := float64(output[i+delay]) - s
+			signal += s * s
+			noise += e * e
+		}
+		if v := 10 * math.Log10(signal/noise); v > best {
+			best, bestDelay = v, delay
+		}
+	}
+	return best, bestDelay
+}
+
+// referenceInput returns the input of the spandsp fixtures: half a second of
+// speech, then half a second at twice the level.
+func referenceInput(t *testing.T) []int16 {
+	data, err := ioutil.ReadFile("../pcm/testdata/recording.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]int16, (len(data)-44)/2)
+	if err = binary.Read(bytes.NewReader(data[44:]), binary.LittleEndian, pcm); err != nil {
+		t.Fatal(err)
+	}
+	input := append([]int16(nil), pcm[16000:24000]...)
+	for _, s := range pcm[24000:32000] {
+		input = append(input, 2*s)
+	}
+	return input
+}
+
+// TestReference checks the codec is bit exact with the independent G722 codec
+// of spandsp. testdata/spandsp64.g722 etc. were encoded from referenceInput by
+// github.com/gotranspile/g722@v0.0.0-20240123003956-384a1bb16a19, a
+// translation of it, and shifted to the octets of the lower rates. The .raw
+// files are the payloads decoded by it, 16bit little endian. The input is
+// kept below full scale because that version wraps decoded samples rather
+// than saturating them.
+func TestReference(t *testing.T) {
+	input := referenceInput(t)
+	for _, rate := range []int{Rate64000, Rate56000, Rate48000} {
+		name := fmt.Sprintf("testdata/spandsp%d", rate/1000)
+		payload, err := ioutil.ReadFile(name + ".g722")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ioutil.ReadFile(name + ".raw")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := make([]int16, len(raw)/2)
+		if err = binary.Read(bytes.NewReader(raw), binary.LittleEndian, expected); err != nil {
+			t.Fatal(err)
+		}
+
+		enc, err := NewFrameEncoder(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded := make([]byte, len(input)/2)
+		if _, err = enc.Encode(input, encoded); err != nil {
+			t.Fatal(err)
+		}
+		for i := range payload {
+			if encoded[i] != payload[i] {
+				t.Fatalf("%d: byte %d is %#x, expected %#x", rate, i, encoded[i], payload[i])
+			}
+		}
+
+		dec, err := NewFrameDecoder(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := make([]int16, len(payload)*2)
+		if _, err = dec.Decode(payload, decoded); err != nil {
+			t.Fatal(err)
+		}
+		for i := range expected {
+			if decoded[i] != expected[i] {
+				t.Fatalf("%d: sample %d is %d, expected %d", rate, i, decoded[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		rate   int
+		minSNR float64
+	}{
+		{Rate64000, 40},
+		{Rate56000, 33},
+		{Rate48000, 25},
+	} {
+		for _, freq := range []float64{300, 1000, 3000, 6000} {
+			input := tone(16000, freq, 8000)
+			enc, err := NewFrameEncoder(test.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := NewFrameDecoder(test.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := make([]int16, len(input))
+			payload := make([]byte, 160)
+			for i := 0; i < len(input); i += 320 {
+				n, err := enc.Encode(input[i:i+320], payload)
+				if err != nil || n != 160 {
+					t.Fatal(n, err)
+				}
+				if n, err = dec.Decode(payload, output[i:]); err != nil || n != 320 {
+					t.Fatal(n, err)
+				}
+			}
+			s, delay := snr(input, output)
+			t.Logf("%d %.0fHz: %.1fdB, delay %d", test.rate, freq, s, delay)
+			// The high band only has 2 bits.
+			minSNR := test.minSNR
+			if freq > 4000 {
+				minSNR = 20
+			}
+			if s < minSNR {
+				t.Fatalf("%d %.0fHz: SNR %.1fdB", test.rate, freq, s)
+			}
+		}
+	}
+}
+
+func TestStreamMatchesFrames(t *testing.T) {
+	input := tone(3200, 1000, 8000)
+	lpcm := make([]byte, len(input)*2)
+	for i, s := range input {
+		lpcm[2*i] = byte(s)
+		lpcm[2*i+1] = byte(s >> 8)
+	}
+
+	enc, _ := NewFrameEncoder(Rate64000)
+	want := make([]byte, len(input)/2)
+	if _, err := enc.Encode(input, want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write in chunks that split sample pairs.
+	var encoded bytes.Buffer
+	w, err := NewEncoder(&encoded, Rate64000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(lpcm); i += 7 {
+		end := i + 7
+		if end > len(lpcm) {
+			end = len(lpcm)
+		}
+		n, err := w.Write(lpcm[i:end])
+		if err != nil || n != end-i {
+			t.Fatal(n, err)
+		}
+	}
+	if !bytes.Equal(encoded.Bytes(), want) {
+		t.Fatal("stream encoding differs from frame encoding")
+	}
+
+	r, err := NewDecoder(bytes.NewReader(want), Rate64000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := make([]byte, len(lpcm))
+	n, err := io.ReadFull(r, decoded)
+	if err != nil || n != len(lpcm) {
+		t.Fatal(n, err)
+	}
+	dec, _ := NewFrameDecoder(Rate64000)
+	frame := make([]int16, len(input))
+	if _, err = dec.Decode(want, frame); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range frame {
+		if got := int16(decoded[2*i]) | int16(decoded[2*i+1])<<8; got != s {
+			t.Fatalf("sample %d is %d, want %d", i, got, s)
+		}
+	}
+}
+
+// limitedWriter accepts n bytes and fails after that.
+type limitedWriter struct {
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, io.ErrClosedPipe
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestEncoder_ShortWrite(t *testing.T) {
+	dst := &limitedWriter{n: 1}
+	w, err := NewEncoder(dst, Rate64000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Write(make([]byte, 2)); n != 2 || err != nil {
+		t.Fatal(n, err)
+	}
+	// The first pair takes 2 bytes of each call. Only it is written, the
+	// next pair and the trailing byte aren't.
+	if n, err := w.Write(make([]byte, 7)); n != 2 || err != io.ErrClosedPipe {
+		t.Fatal(n, err)
+	}
+	if w.partialLen != 0 {
+		t.Fatal(w.partialLen)
+	}
+
+	// Nothing of this call is written.
+	if _, err := w.Write(make([]byte, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Write(make([]byte, 5)); n != 0 || err != io.ErrClosedPipe {
+		t.Fatal(n, err)
+	}
+}
+
+func TestLowerRateDecodes64k(t *testing.T) {
+	// A 56 or 48 kbit decoder ignores the bits only the 64 kbit mode uses.
+	input := tone(16000, 1000, 8000)
+	enc, _ := NewFrameEncoder(Rate64000)
+	payload := make([]byte, len(input)/2)
+	_, _ = enc.Encode(input, payload)
+	dec, _ := NewFrameDecoder(Rate48000)
+	output := make([]int16, len(input))
+	_, _ = dec.Decode(payload, output)
+	if s, _ := snr(input, output); s < 25 {
+		t.Fatalf("SNR %.1fdB", s)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := NewFrameEncoder(32000); err != ErrRate {
+		t.Fatal(err)
+	}
+	enc, _ := NewFrameEncoder(Rate64000)
+	if _, err := enc.Encode(make([]int16, 3), make([]byte, 2)); err != ErrFrameSize {
+		t.Fatal(err)
+	}
+	if _, err := enc.Encode(make([]int16, 4), make([]byte, 1)); err != ErrShort {
+		t.Fatal(err)
+	}
+	if Ticks(160) != 160 {
+		t.Fatal(Ticks(160))
+	}
+}
@@ -0,0 +1,138 @@
+package g722
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The ITU-T G.722 test sequences aren't redistributable. To run
+// TestITUVectors, copy the sequences of Appendix II, T1C1.XMT, T2R1.COD,
+// T3L1.RC1 to T3L1.RC3, T3H1.RC0 and their second set, anywhere under
+// testdata/itu.
+const ituDir = "testdata/itu"
+
+// ituVectors maps the names of the files under ituDir to their paths.
+func ituVectors(t *testing.T) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(ituDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files[strings.ToUpper(info.Name())] = path
+		}
+		return err
+	})
+	if os.IsNotExist(err) {
+		t.Skip("ITU-T test sequences not in", ituDir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// readVector returns the 16bit words of a test sequence. The sequences are
+// distributed as lines of hexadecimal words ending with a checksum byte, after
+// comment lines starting with /*. Sequences converted to binary are read as
+// little endian words.
+func readVector(t *testing.T, path string) []int16 {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := true
+	for _, b := range data {
+		if (b < ' ' || b > '~') && b != '\r' && b != '\n' && b != '\t' {
+			text = false
+			break
+		}
+	}
+	if !text {
+		words := make([]int16, len(data)/2)
+		if err = binary.Read(bytes.NewReader(data[:2*len(words)]), binary.LittleEndian, words); err != nil {
+			t.Fatal(err)
+		}
+		return words
+	}
+
+	var words []int16
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.Join(strings.Fields(scanner.Text()), "")
+		if line == "" || strings.HasPrefix(line, "/*") {
+			continue
+		}
+		if len(line)%4 == 2 {
+			line = line[:len(line)-2]
+		}
+		for ; len(line) >= 4; line = line[4:] {
+			word, err := strconv.ParseUint(line[:4], 16, 16)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			words = append(words, int16(word))
+		}
+	}
+	return words
+}
+
+// TestITUVectors checks the sub-band coders are bit exact with the test
+// sequences, which bypass the QMF. As in the reference, both bands are coded
+// from the input halved and the decoded bands are doubled.
+func TestITUVectors(t *testing.T) {
+	files := ituVectors(t)
+	for _, set := range []string{"1", "2"} {
+		codesPath, ok := files["T2R"+set+".COD"]
+		if !ok {
+			t.Log("Missing T2R" + set + ".COD")
+			continue
+		}
+		codes := readVector(t, codesPath)
+
+		if path, ok := files["T1C"+set+".XMT"]; ok {
+			input := readVector(t, path)
+			if len(input) != len(codes) {
+				t.Fatalf("T1C%s.XMT: %d samples, T2R%s.COD has %d", set, len(input), set, len(codes))
+			}
+			var s encoderState
+			s.reset(8)
+			for i, x := range input {
+				if code := s.encodeBands(int(x)>>1, int(x)>>1); int16(code) != codes[i] {
+					t.Fatalf("T2R%s.COD: sample %d coded %#x, expected %#x", set, i, code, codes[i])
+				}
+			}
+		}
+
+		high, ok := files["T3H"+set+".RC0"]
+		if !ok {
+			t.Log("Missing T3H" + set + ".RC0")
+			continue
+		}
+		highBand := readVector(t, high)
+		for mode, bits := range []int{8, 7, 6} {
+			name := "T3L" + set + ".RC" + strconv.Itoa(mode+1)
+			path, ok := files[name]
+			if !ok {
+				t.Log("Missing", name)
+				continue
+			}
+			lowBand := readVector(t, path)
+			if len(lowBand) != len(codes) || len(highBand) != len(codes) {
+				t.Fatalf("%s: %d and %d samples, T2R%s.COD has %d", name, len(lowBand), len(highBand), set, len(codes))
+			}
+			var s decoderState
+			s.reset(bits)
+			for i, code := range codes {
+				rlow, rhigh := s.decodeBands(byte(code))
+				if int16(rlow<<1) != lowBand[i] || int16(rhigh<<1) != highBand[i] {
+					t.Fatalf("%s: sample %d decoded %d %d, expected %d %d", name, i, rlow<<1, rhigh<<1, lowBand[i], highBand[i])
+				}
+			}
+		}
+	}
+}
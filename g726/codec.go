@@ -0,0 +1,372 @@
+package g726
+
+// The ITU-T G.726 ADPCM algorithm, after the public domain reference code by
+// Sun Microsystems. Samples are coded as the difference from an adaptive
+// pole-zero predictor, quantized with an adaptive step size to 2, 3, 4 or 5
+// bits. The encoder runs the decoder to stay in step with it.
+
+// quantizer holds the tables of a bit rate.
+type quantizer struct {
+	bits   int
+	levels []int // Decision levels of the quantizer in the log domain.
+	dqln   []int // Reconstruction levels in the log domain.
+	wi     []int // Scale factor multipliers.
+	fi     []int // Adaptation speed control.
+}
+
+var (
+	quantizer16 = &quantizer{
+		bits:   2,
+		levels: []int{261},
+		dqln:   []int{116, 365, 365, 116},
+		wi:     []int{-704, 14048, 14048, -704},
+		fi:     []int{0x000, 0xE00, 0xE00, 0x000},
+	}
+	quantizer24 = &quantizer{
+		bits:   3,
+		levels: []int{8, 218, 331},
+		dqln:   []int{-2048, 135, 273, 373, 373, 273, 135, -2048},
+		wi:     []int{-128, 960, 4384, 18624, 18624, 4384, 960, -128},
+		fi:     []int{0x000, 0x200, 0x400, 0xE00, 0xE00, 0x400, 0x200, 0x000},
+	}
+	quantizer32 = &quantizer{
+		bits:   4,
+		levels: []int{-124, 80, 178, 246, 300, 349, 400},
+		dqln:   []int{-2048, 4, 135, 213, 273, 323, 373, 425, 425, 373, 323, 273, 213, 135, 4, -2048},
+		wi:     []int{-384, 576, 1312, 2048, 3584, 6336, 11360, 35904, 35904, 11360, 6336, 3584, 2048, 1312, 576, -384},
+		fi:     []int{0x000, 0x000, 0x000, 0x200, 0x200, 0x200, 0x600, 0xE00, 0xE00, 0x600, 0x200, 0x200, 0x200, 0x000, 0x000, 0x000},
+	}
+	quantizer40 = &quantizer{
+		bits:   5,
+		levels: []int{-122, -16, 68, 139, 198, 250, 298, 339, 378, 413, 445, 475, 502, 527, 552},
+		dqln: []int{-2048, -66, 28, 104, 169, 224, 274, 318, 358, 395, 429, 459, 488, 514, 539, 566,
+			566, 539, 514, 488, 459, 429, 395, 358, 318, 274, 224, 169, 104, 28, -66, -2048},
+		wi: []int{448, 448, 768, 1248, 1280, 1312, 1856, 3200, 4512, 5728, 7008, 8960, 11456, 14080, 16928, 22272,
+			22272, 16928, 14080, 11456, 8960, 7008, 5728, 4512, 3200, 1856, 1312, 1280, 1248, 768, 448, 448},
+		fi: []int{0x000, 0x000, 0x000, 0x000, 0x000, 0x200, 0x200, 0x200, 0x200, 0x200, 0x400, 0x600, 0x800, 0xA00, 0xC00, 0xC00,
+			0xC00, 0xC00, 0xA00, 0x800, 0x600, 0x400, 0x200, 0x200, 0x200, 0x200, 0x200, 0x000, 0x000, 0x000, 0x000, 0x000},
+	}
+)
+
+// state is the adaptive predictor and quantizer state. The fields are sized
+// as in the reference so they wrap the same way.
+type state struct {
+	q *quantizer
+
+	yl  int32 // Locked quantizer scale factor.
+	yu  int16 // Unlocked quantizer scale factor.
+	dms int16 // Short term energy estimate.
+	dml int16 // Long term energy estimate.
+	ap  int16 // Linear weighting coefficient of yl and yu.
+	a   [2]int16
+	b   [6]int16
+	pk  [2]int16 // Signs of previous partial reconstructed signals.
+	dq  [6]int16 // Previous quantized differences in floating point.
+	sr  [2]int16 // Previous reconstructed signals in floating point.
+	td  bool     // Tone detected.
+}
+
+func (s *state) reset(q *quantizer) {
+	*s = state{
+		q:  q,
+		yl: 34816,
+		yu: 544,
+		sr: [2]int16{32, 32},
+		dq: [6]int16{32, 32, 32, 32, 32, 32},
+	}
+}
+
+// log2 returns the number of bits needed for val, which is the index of the
+// first power of 2 above it.
+func log2(val int) int {
+	i := 0
+	for i < 15 && val >= 1<<i {
+		i++
+	}
+	return i
+}
+
+func quan(val int, table []int) int {
+	i := 0
+	for i < len(table) && val >= table[i] {
+		i++
+	}
+	return i
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// fmult multiplies a predictor coefficient by a value in the 11 bit floating
+// point format of dq and sr.
+func fmult(an, srn int) int {
+	anmag := an
+	if an <= 0 {
+		anmag = -an & 0x1FFF
+	}
+	anexp := log2(anmag) - 6
+	var anmant int
+	switch {
+	case anmag == 0:
+		anmant = 32
+	case anexp >= 0:
+		anmant = anmag >> uint(anexp)
+	default:
+		anmant = anmag << uint(-anexp)
+	}
+	wanexp := anexp + ((srn >> 6) & 0xF) - 13
+	wanmant := (anmant*(srn&0x3F) + 0x30) >> 4
+	var retval int
+	if wanexp >= 0 {
+		retval = (wanmant << uint(wanexp)) & 0x7FFF
+	} else {
+		retval = wanmant >> uint(-wanexp)
+	}
+	if (an ^ srn) < 0 {
+		return -retval
+	}
+	return retval
+}
+
+// predictZero returns the estimate of the sixth order zero predictor.
+func (s *state) predictZero() int {
+	sezi := 0
+	for i := range s.b {
+		sezi += fmult(int(s.b[i])>>2, int(s.dq[i]))
+	}
+	return sezi
+}
+
+// predictPole returns the estimate of the second order pole predictor.
+func (s *state) predictPole() int {
+	return fmult(int(s.a[1])>>2, int(s.sr[1])) + fmult(int(s.a[0])>>2, int(s.sr[0]))
+}
+
+// stepSize returns the quantizer scale factor.
+func (s *state) stepSize() int {
+	if s.ap >= 256 {
+		return int(s.yu)
+	}
+	y := int(s.yl >> 6)
+	dif := int(s.yu) - y
+	al := int(s.ap) >> 2
+	if dif > 0 {
+		y += (dif * al) >> 6
+	} else if dif < 0 {
+		y += (dif*al + 0x3F) >> 6
+	}
+	return y
+}
+
+// quantize returns the code of the difference d.
+func (s *state) quantize(d, y int) int {
+	dqm := abs(d)
+	exp := log2(dqm >> 1)
+	mant := ((dqm << 7) >> uint(exp)) & 0x7F
+	dl := (exp << 7) + mant
+	dln := dl - (y >> 2)
+	size := len(s.q.levels)
+	i := quan(dln, s.q.levels)
+	if d < 0 {
+		return (size << 1) + 1 - i
+	}
+	// Except at 16 kbit/s the all zero code isn't used. A positive
+	// difference below the first level is sent as negative zero.
+	if i == 0 && size > 1 {
+		return (size << 1) + 1
+	}
+	return i
+}
+
+// reconstruct returns the quantized difference of a code in sign-magnitude.
+func reconstruct(sign bool, dqln, y int) int {
+	dql := dqln + (y >> 2)
+	if dql < 0 {
+		if sign {
+			return -0x8000
+		}
+		return 0
+	}
+	dex := (dql >> 7) & 15
+	dqt := 128 + (dql & 127)
+	dq := (dqt << 7) >> uint(14-dex)
+	if sign {
+		return dq - 0x8000
+	}
+	return dq
+}
+
+// float converts a magnitude to the 4 bit exponent, 6 bit mantissa format
+// with the sign in bit 10.
+func float(mag int, negative bool) int16 {
+	var v int
+	if mag == 0 {
+		v = 0x20
+	} else {
+		exp := log2(mag)
+		v = (exp << 6) + ((mag << 6) >> uint(exp))
+	}
+	if negative {
+		v -= 0x400
+	}
+	return int16(v)
+}
+
+// code runs the predictor for one sample. For the encoder sl is the 14 bit
+// input and i is ignored. For the decoder i is the code. Returns the code and
+// the 14 bit reconstructed signal.
+func (s *state) code(sl, i int, encode bool) (int, int) {
+	sezi := s.predictZero()
+	sez := sezi >> 1
+	se := (sezi + s.predictPole()) >> 1
+	y := s.stepSize()
+	if encode {
+		i = s.quantize(sl-se, y)
+	}
+	signBit := 1 << uint(s.q.bits-1)
+	dq := reconstruct(i&signBit != 0, s.q.dqln[i], y)
+	var sr int
+	if dq < 0 {
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
+	dqsez := sr + sez - se
+	s.update(y, s.q.wi[i], s.q.fi[i], dq, sr, dqsez)
+	return i, sr
+}
+
+func (s *state) update(y, wi, fi, dq, sr, dqsez int) {
+	var pk0 int16
+	if dqsez < 0 {
+		pk0 = 1
+	}
+	mag := dq & 0x7FFF
+
+	// Transition detector.
+	ylint := int(s.yl >> 15)
+	ylfrac := int(s.yl>>10) & 0x1F
+	thr1 := (32 + ylfrac) << uint(ylint)
+	thr2 := thr1
+	if ylint > 9 {
+		thr2 = 31 << 10
+	}
+	dqthr := (thr2 + (thr2 >> 1)) >> 1
+	tr := s.td && mag > dqthr
+
+	// Quantizer scale factor adaptation.
+	yu := y + ((wi - y) >> 5)
+	if yu < 544 {
+		yu = 544
+	} else if yu > 5120 {
+		yu = 5120
+	}
+	s.yu = int16(yu)
+	s.yl += int32(yu) + ((-s.yl) >> 6)
+
+	// Adaptive predictor coefficients.
+	var a2p int
+	if tr {
+		s.a = [2]int16{}
+		s.b = [6]int16{}
+	} else {
+		pks1 := pk0 ^ s.pk[0]
+		a2p = int(s.a[1]) - (int(s.a[1]) >> 7)
+		if dqsez != 0 {
+			fa1 := -int(s.a[0])
+			if pks1 != 0 {
+				fa1 = int(s.a[0])
+			}
+			if fa1 < -8191 {
+				a2p -= 0x100
+			} else if fa1 > 8191 {
+				a2p += 0xFF
+			} else {
+				a2p += fa1 >> 5
+			}
+			if pk0^s.pk[1] != 0 {
+				if a2p <= -12160 {
+					a2p = -12288
+				} else if a2p >= 12416 {
+					a2p = 12288
+				} else {
+					a2p -= 0x80
+				}
+			} else if a2p <= -12416 {
+				a2p = -12288
+			} else if a2p >= 12160 {
+				a2p = 12288
+			} else {
+				a2p += 0x80
+			}
+		}
+		s.a[1] = int16(a2p)
+
+		a1 := int(s.a[0]) - (int(s.a[0]) >> 8)
+		if dqsez != 0 {
+			if pks1 == 0 {
+				a1 += 192
+			} else {
+				a1 -= 192
+			}
+		}
+		a1ul := 15360 - a2p
+		if a1 < -a1ul {
+			a1 = -a1ul
+		} else if a1 > a1ul {
+			a1 = a1ul
+		}
+		s.a[0] = int16(a1)
+
+		for i := range s.b {
+			if s.q.bits == 5 {
+				s.b[i] -= s.b[i] >> 9
+			} else {
+				s.b[i] -= s.b[i] >> 8
+			}
+			if mag != 0 {
+				if (dq ^ int(s.dq[i])) >= 0 {
+					s.b[i] += 128
+				} else {
+					s.b[i] -= 128
+				}
+			}
+		}
+	}
+
+	copy(s.dq[1:], s.dq[:5])
+	s.dq[0] = float(mag, dq < 0)
+
+	s.sr[1] = s.sr[0]
+	switch {
+	case sr >= 0:
+		s.sr[0] = float(sr, false)
+	case sr > -32768:
+		s.sr[0] = float(-sr, true)
+	default:
+		s.sr[0] = -992 // 0xFC20
+	}
+
+	s.pk[1] = s.pk[0]
+	s.pk[0] = pk0
+
+	// Tone detector.
+	s.td = !tr && a2p < -11776
+
+	// Adaptation speed control.
+	s.dms += int16((fi - int(s.dms)) >> 5)
+	s.dml += int16(((fi << 2) - int(s.dml)) >> 7)
+
+	switch {
+	case tr:
+		s.ap = 256
+	case y < 1536, s.td, abs((int(s.dms)<<2)-int(s.dml)) >= int(s.dml)>>3:
+		s.ap += (0x200 - s.ap) >> 4
+	default:
+		s.ap += (-s.ap) >> 4
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Package g726 implements encoding and decoding of G726 ADPCM audio.
+G.726 is an ITU-T standard coding 8Khz audio at 16, 24, 32 or 40 kbit/s, which
+is 2, 3, 4 or 5 bits per sample.
+
+Codes are packed into bytes in one of two orders. RTP payloads named G726-32
+etc. put the first sample in the least significant bits (RFC 3551). Payloads
+named AAL2-G726-32 and WAV files put it in the most significant bits, as in
+ITU-T I.366.2.
+*/
+package g726
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// Bit rates
+	Rate16000 = 16000
+	Rate24000 = 24000
+	Rate32000 = 32000
+	Rate40000 = 40000
+
+	SampleRate = 8000
+)
+
+// Packing orders
+const (
+	RFC3551 = iota // First sample in the least significant bits.
+	AAL2           // First sample in the most significant bits.
+)
+
+var (
+	ErrRate      = errors.New("Invalid bit rate")
+	ErrPacking   = errors.New("Invalid packing")
+	ErrFrameSize = errors.New("frame must fill whole bytes")
+	ErrShort     = errors.New("buffer is too small")
+)
+
+func quantizerOf(rate int) (*quantizer, error) {
+	switch rate {
+	case Rate16000:
+		return quantizer16, nil
+	case Rate24000:
+		return quantizer24, nil
+	case Rate32000:
+		return quantizer32, nil
+	case Rate40000:
+		return quantizer40, nil
+	}
+	return nil, ErrRate
+}
+
+// Bits returns the number of bits per sample of a bit rate.
+func Bits(rate int) int {
+	return rate / SampleRate
+}
+
+// EncodingName returns the RTP encoding name of a bit rate and packing, for
+// example G726-32 or AAL2-G726-32. G726 has no static payload type.
+func EncodingName(rate, packing int) string {
+	if packing == AAL2 {
+		return fmt.Sprintf("AAL2-G726-%d", rate/1000)
+	}
+	return fmt.Sprintf("G726-%d", rate/1000)
+}
+
+// FrameEncoder encodes 8Khz LPCM frames to G726 payloads.
+type FrameEncoder struct {
+	state   state
+	packing int
+}
+
+// NewFrameEncoder returns a FrameEncoder for the bit rate and packing.
+func NewFrameEncoder(rate, packing int) (*FrameEncoder, error) {
+	q, err := quantizerOf(rate)
+	if err != nil {
+		return nil, err
+	}
+	if packing != RFC3551 && packing != AAL2 {
+		return nil, ErrPacking
+	}
+	e := &FrameEncoder{packing: packing}
+	e.state.reset(q)
+	return e, nil
+}
+
+// Encode encodes frame into payload and returns the payload length. The codes
+// of a frame must fill whole bytes, so at 24 and 40 kbit/s the frame size must
+// be a multiple of 8.
+func (e *FrameEncoder) Encode(frame []int16, payload []byte) (int, error) {
+	bits := e.state.q.bits
+	if len(frame)*bits%8 != 0 {
+		return 0, ErrFrameSize
+	}
+	size := len(frame) * bits / 8
+	if len(payload) < size {
+		return 0, ErrShort
+	}
+	var acc uint
+	var accBits uint
+	n := 0
+	for _, sample := range frame {
+		code, _ := e.state.code(int(sample)>>2, 0, true)
+		if e.packing == AAL2 {
+			acc = acc<<uint(bits) | uint(code)
+			accBits += uint(bits)
+			if accBits >= 8 {
+				accBits -= 8
+				payload[n] = byte(acc >> accBits)
+				n++
+			}
+		} else {
+			acc |= uint(code) << accBits
+			accBits += uint(bits)
+			if accBits >= 8 {
+				payload[n] = byte(acc)
+				n++
+				acc >>= 8
+				accBits -= 8
+			}
+		}
+	}
+	return n, nil
+}
+
+// Reset discards the encoder state.
+func (e *FrameEncoder) Reset() {
+	e.state.reset(e.state.q)
+}
+
+// FrameDecoder decodes G726 payloads to 8Khz LPCM frames.
+type FrameDecoder struct {
+	state   state
+	packing int
+}
+
+// NewFrameDecoder returns a FrameDecoder for the bit rate and packing.
+func NewFrameDecoder(rate, packing int) (*FrameDecoder, error) {
+	q, err := quantizerOf(rate)
+	if err != nil {
+		return nil, err
+	}
+	if packing != RFC3551 && packing != AAL2 {
+		return nil, ErrPacking
+	}
+	d := &FrameDecoder{packing: packing}
+	d.state.reset(q)
+	return d, nil
+}
+
+// Samples returns the number of samples in a payload. Bits that don't make a
+// whole code are ignored.
+func (d *FrameDecoder) Samples(payloadSize int) int {
+	return payloadSize * 8 / d.state.q.bits
+}
+
+// Decode decodes payload into frame and returns the number of samples.
+func (d *FrameDecoder) Decode(payload []byte, frame []int16) (int, error) {
+	bits := uint(d.state.q.bits)
+	if len(frame) < d.Samples(len(payload)) {
+		return 0, ErrShort
+	}
+	mask := uint(1)<<bits - 1
+	var acc uint
+	var accBits uint
+	n := 0
+	for _, b := range payload {
+		if d.packing == AAL2 {
+			acc = acc<<8 | uint(b)
+		} else {
+			acc |= uint(b) << accBits
+		}
+		accBits += 8
+		for accBits >= bits {
+			var code uint
+			if d.packing == AAL2 {
+				accBits -= bits
+				code = (acc >> accBits) & mask
+			} else {
+				code = acc & mask
+				acc >>= bits
+				accBits -= bits
+			}
+			_, sr := d.state.code(0, int(code), false)
+			frame[n] = saturate(sr << 2)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Reset discards the decoder state.
+func (d *FrameDecoder) Reset() {
+	d.state.reset(d.state.q)
+}
+
+func saturate(amp int) int16 {
+	if amp > 32767 {
+		return 32767
+	}
+	if amp < -32768 {
+		return -32768
+	}
+	return int16(amp)
+}
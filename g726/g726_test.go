@@ -0,0 +1,138 @@
+package g726
+
+import (
+	"math"
+	"testing"
+)
+
+func tone(n int, freq, amplitude float64) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/SampleRate))
+	}
+	return out
+}
+
+// snr returns the SNR of output against input in dB after the adaptation
+// settles. G726 has no delay.
+func snr(input, output []int16) float64 {
+	var signal, noise float64
+	for i := 800; i < len(input); i++ {
+		s := float64(input[i])
+		e := float64(output[i]) - s
+		signal += s * s
+		noise += e * e
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		rate   int
+		minSNR float64
+	}{
+		{Rate40000, 35},
+		{Rate32000, 30},
+		{Rate24000, 22},
+		{Rate16000, 18},
+	} {
+		for _, packing := range []int{RFC3551, AAL2} {
+			for _, freq := range []float64{300, 1000, 3000} {
+				input := tone(8000, freq, 8000)
+				enc, err := NewFrameEncoder(test.rate, packing)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dec, err := NewFrameDecoder(test.rate, packing)
+				if err != nil {
+					t.Fatal(err)
+				}
+				output := make([]int16, len(input))
+				size := 160 * Bits(test.rate) / 8
+				payload := make([]byte, size)
+				for i := 0; i < len(input); i += 160 {
+					n, err := enc.Encode(input[i:i+160], payload)
+					if err != nil || n != size {
+						t.Fatal(n, err)
+					}
+					if n, err = dec.Decode(payload, output[i:]); err != nil || n != 160 {
+						t.Fatal(n, err)
+					}
+				}
+				s := snr(input, output)
+				t.Logf("%s %.0fHz: %.1fdB", EncodingName(test.rate, packing), freq, s)
+				if s < test.minSNR {
+					t.Fatalf("%s %.0fHz: SNR %.1fdB", EncodingName(test.rate, packing), freq, s)
+				}
+			}
+		}
+	}
+}
+
+func TestPacking(t *testing.T) {
+	input := tone(160, 1000, 8000)
+	for _, rate := range []int{Rate16000, Rate24000, Rate32000, Rate40000} {
+		bits := uint(Bits(rate))
+		rfc, _ := NewFrameEncoder(rate, RFC3551)
+		aal2, _ := NewFrameEncoder(rate, AAL2)
+		a := make([]byte, 100)
+		b := make([]byte, 100)
+		n, err := rfc.Encode(input, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = aal2.Encode(input, b); err != nil {
+			t.Fatal(err)
+		}
+
+		// The same codes in opposite orders.
+		for i := 0; i < n*8/int(bits); i++ {
+			var x, y uint
+			for j := uint(0); j < bits; j++ {
+				bit := uint(i)*bits + j
+				x |= uint(a[bit/8]>>(bit%8)&1) << j
+				bit = uint(i)*bits + bits - 1 - j
+				y |= uint(b[bit/8]>>(7-bit%8)&1) << j
+			}
+			if x != y {
+				t.Fatalf("%d: code %d is %d and %d", rate, i, x, y)
+			}
+		}
+	}
+}
+
+func TestNoZeroCode(t *testing.T) {
+	enc, _ := NewFrameEncoder(Rate32000, RFC3551)
+	payload := make([]byte, 80)
+	if _, err := enc.Encode(make([]int16, 160), payload); err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range payload {
+		if b&0x0F == 0 || b>>4 == 0 {
+			t.Fatalf("byte %d is %x", i, b)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := NewFrameEncoder(8000, RFC3551); err != ErrRate {
+		t.Fatal(err)
+	}
+	if _, err := NewFrameDecoder(Rate32000, 2); err != ErrPacking {
+		t.Fatal(err)
+	}
+	enc, _ := NewFrameEncoder(Rate24000, AAL2)
+	if _, err := enc.Encode(make([]int16, 10), make([]byte, 10)); err != ErrFrameSize {
+		t.Fatal(err)
+	}
+	if _, err := enc.Encode(make([]int16, 16), make([]byte, 5)); err != ErrShort {
+		t.Fatal(err)
+	}
+	dec, _ := NewFrameDecoder(Rate40000, AAL2)
+	if n := dec.Samples(7); n != 11 {
+		t.Fatal(n)
+	}
+	if _, err := dec.Decode(make([]byte, 5), make([]int16, 7)); err != ErrShort {
+		t.Fatal(err)
+	}
+}
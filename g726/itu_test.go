@@ -0,0 +1,158 @@
+package g726
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pidato/audio/g711"
+)
+
+// The ITU-T G.726 test sequences aren't redistributable. To run
+// TestITUVectors, copy the sequences of Appendix II, such as NRM.M,
+// RN32FM.I and RN32FM.O, anywhere under testdata/itu.
+const ituDir = "testdata/itu"
+
+// ituVectors maps the names of the files under ituDir to their paths.
+func ituVectors(t *testing.T) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(ituDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files[strings.ToUpper(info.Name())] = path
+		}
+		return err
+	})
+	if os.IsNotExist(err) {
+		t.Skip("ITU-T test sequences not in", ituDir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// readVector returns the samples of a test sequence, one per byte. Sequences
+// converted to 16bit words, as in the ITU-T STL, are accepted in either byte
+// order.
+func readVector(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data)%2 != 0 {
+		return data
+	}
+	for _, high := range []int{0, 1} {
+		words := true
+		for i := high; i < len(data); i += 2 {
+			if data[i] != 0 {
+				words = false
+				break
+			}
+		}
+		if words {
+			samples := make([]byte, len(data)/2)
+			for i := range samples {
+				samples[i] = data[2*i+1-high]
+			}
+			return samples
+		}
+	}
+	return data
+}
+
+// levels returns the G711 reconstruction levels in increasing order.
+func levels(expand func(uint8) int16) []int16 {
+	var values []int16
+	seen := map[int16]bool{}
+	for code := 0; code < 256; code++ {
+		if v := expand(uint8(code)); !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+// TestITUVectors checks the encoder is bit exact with the test sequences for
+// log PCM input. The decoder outputs linear PCM, so it skips the synchronous
+// coding adjustment of the sequences' log PCM output, which moves a sample by
+// at most one G711 level. Decoded samples must be within a level of it.
+func TestITUVectors(t *testing.T) {
+	files := ituVectors(t)
+	for _, law := range []struct {
+		suffix string
+		expand func(uint8) int16
+	}{
+		{"M", g711.DecodeUlawFrame},
+		{"A", g711.DecodeAlawFrame},
+	} {
+		levels := levels(law.expand)
+		for _, rate := range []int{Rate16000, Rate24000, Rate32000, Rate40000} {
+			q, err := quantizerOf(rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kbit := rate / 1000
+			for _, seq := range []struct{ input, codes, output string }{
+				{"NRM." + law.suffix, fmt.Sprintf("RN%dF%s.I", kbit, law.suffix), fmt.Sprintf("RN%dF%s.O", kbit, law.suffix)},
+				{"OVR." + law.suffix, fmt.Sprintf("RV%dF%s.I", kbit, law.suffix), fmt.Sprintf("RV%dF%s.O", kbit, law.suffix)},
+				{"", fmt.Sprintf("I%d", kbit), fmt.Sprintf("RI%dF%s.O", kbit, law.suffix)},
+			} {
+				codesPath, ok := files[seq.codes]
+				if !ok {
+					t.Log("Missing", seq.codes)
+					continue
+				}
+				codes := readVector(t, codesPath)
+
+				if path, ok := files[seq.input]; ok && seq.input != "" {
+					input := readVector(t, path)
+					if len(input) != len(codes) {
+						t.Fatalf("%s: %d samples, %s has %d", seq.input, len(input), seq.codes, len(codes))
+					}
+					var s state
+					s.reset(q)
+					for i, sample := range input {
+						code, _ := s.code(int(law.expand(sample))>>2, 0, true)
+						if code != int(codes[i]) {
+							t.Fatalf("%s: sample %d coded %d, expected %d", seq.codes, i, code, codes[i])
+						}
+					}
+				}
+
+				path, ok := files[seq.output]
+				if !ok {
+					t.Log("Missing", seq.output)
+					continue
+				}
+				output := readVector(t, path)
+				if len(output) != len(codes) {
+					t.Fatalf("%s: %d samples, %s has %d", seq.output, len(output), seq.codes, len(codes))
+				}
+				var s state
+				s.reset(q)
+				for i, code := range codes {
+					_, sr := s.code(0, int(code), false)
+					decoded := saturate(sr << 2)
+					// The levels either side of decoded, and one more.
+					j := sort.Search(len(levels), func(j int) bool { return levels[j] >= decoded })
+					lo, hi := j-2, j+1
+					if j < len(levels) && levels[j] == decoded {
+						lo = j - 1
+					}
+					expected := law.expand(output[i])
+					k := sort.Search(len(levels), func(k int) bool { return levels[k] >= expected })
+					if k < lo || k > hi {
+						t.Fatalf("%s: sample %d decoded %d, expected %d", seq.output, i, decoded, expected)
+					}
+				}
+			}
+		}
+	}
+}
@@ -7,13 +7,15 @@ package g729
 */
 import "C"
 import (
-	"errors"
 	"os"
 	"unsafe"
 )
 
 type Decoder struct {
 	dec *C.bcg729DecoderChannelContextStruct
+
+	// bcg729 reads the bitstream even when it is ignored.
+	empty [VoiceFrameSize]byte
 }
 
 func NewDecoder() *Decoder {
@@ -41,36 +43,80 @@ func (d *Decoder) Close() error {
 /*      -(o) signal : a decoded frame 80 samples (16 bits PCM)               */
 /*                                                                           */
 /*****************************************************************************/
-func (d *Decoder) Decode(packet []byte, frameErased, SIDFrameFlag, rfc3389PayloadFlag bool, decoded []int16) error {
-	if len(decoded) != 80 {
-		return errors.New("decoded must have a length of 80")
-	}
-	var frameErased_ C.uint8_t
-	if frameErased {
-		frameErased_ = C.uint8_t(1)
-	} else {
-		frameErased_ = C.uint8_t(0)
+func (d *Decoder) decode(bitStream []byte, frameErased, SIDFrameFlag, rfc3389PayloadFlag bool, decoded []int16) (int, error) {
+	if d.dec == nil {
+		return 0, os.ErrClosed
 	}
-	var SIDFrameFlag_ C.uint8_t
-	if SIDFrameFlag {
-		SIDFrameFlag_ = C.uint8_t(1)
-	} else {
-		SIDFrameFlag_ = C.uint8_t(0)
+	if len(decoded) < FrameSize {
+		return 0, ErrShort
 	}
-	var rfc3389PayloadFlag_ C.uint8_t
-	if rfc3389PayloadFlag {
-		rfc3389PayloadFlag_ = C.uint8_t(1)
-	} else {
-		rfc3389PayloadFlag_ = C.uint8_t(0)
+	length := len(bitStream)
+	if length == 0 {
+		bitStream = d.empty[:]
 	}
-	bitStream := unsafe.Pointer(&packet[0])
-	signal := unsafe.Pointer(&decoded[0])
 	C.bcg729Decoder(d.dec,
-		(*C.uint8_t)(bitStream),
-		C.uint8_t(len(packet)),
-		frameErased_,
-		SIDFrameFlag_,
-		rfc3389PayloadFlag_,
-		(*C.int16_t)(signal))
-	return nil
+		(*C.uint8_t)(unsafe.Pointer(&bitStream[0])),
+		C.uint8_t(length),
+		flag(frameErased),
+		flag(SIDFrameFlag),
+		flag(rfc3389PayloadFlag),
+		(*C.int16_t)(unsafe.Pointer(&decoded[0])))
+	return FrameSize, nil
+}
+
+func flag(v bool) C.uint8_t {
+	if v {
+		return C.uint8_t(1)
+	}
+	return C.uint8_t(0)
+}
+
+// Decode decodes a voice (10 byte), SID (2 byte) or untransmitted (empty)
+// frame into 80 samples. Returns the number of samples and the frame type.
+func (d *Decoder) Decode(frame []byte, decoded []int16) (int, FrameType, error) {
+	frameType, err := frameTypeOf(len(frame))
+	if err != nil {
+		return 0, frameType, err
+	}
+	n, err := d.decode(frame, false, frameType != Voice, false, decoded)
+	return n, frameType, err
+}
+
+// Erase conceals a lost frame.
+func (d *Decoder) Erase(decoded []int16) (int, error) {
+	return d.decode(nil, true, false, false, decoded)
+}
+
+// DecodeRFC3389 generates a frame of comfort noise from an RFC 3389 CN
+// payload.
+func (d *Decoder) DecodeRFC3389(payload []byte, decoded []int16) (int, error) {
+	if len(payload) == 0 {
+		return 0, ErrPayloadSize
+	}
+	return d.decode(payload, false, true, true, decoded)
+}
+
+// DecodePayload decodes every frame of an RTP payload into decoded and
+// returns the number of samples.
+func (d *Decoder) DecodePayload(payload []byte, decoded []int16) (int, error) {
+	frames, err := PayloadFrames(payload)
+	if err != nil {
+		return 0, err
+	}
+	if len(decoded) < frames*FrameSize {
+		return 0, ErrShort
+	}
+	n := 0
+	for len(payload) > 0 {
+		size := VoiceFrameSize
+		if len(payload) < size {
+			size = len(payload)
+		}
+		if _, _, err = d.Decode(payload[:size], decoded[n:]); err != nil {
+			return n, err
+		}
+		payload = payload[size:]
+		n += FrameSize
+	}
+	return n, nil
 }
@@ -7,10 +7,14 @@ package g729
 */
 import "C"
 import (
+	"errors"
 	"os"
 	"unsafe"
 )
 
+// Length of an RFC 3389 payload with the filter order of 10 used by Annex B.
+const RFC3389PayloadSize = 11
+
 type Encoder struct {
 	enc *C.bcg729EncoderChannelContextStruct
 }
@@ -46,20 +50,67 @@ func (e *Encoder) Close() error {
 /*           if VAD/DTX is enabled                                           */
 /*                                                                           */
 /*****************************************************************************/
-func (e *Encoder) Encode(frame20ms []int16, encoded []byte) error {
-	//if len(frame10ms) != 80 {
-	//	return errors.New("frame10ms must be exactly 80 samples")
-	//}
-	//if len(encoded) < 80 {
-	//	return errors.New("encoded size is too small")
-	//}
-	input := unsafe.Pointer(&frame20ms[0])
+
+// Encode encodes a 10ms frame of 80 samples. encoded must hold at least 10
+// bytes. Returns the encoded length, which is 10 for voice, 2 for SID and 0
+// for untransmitted frames.
+func (e *Encoder) Encode(frame []int16, encoded []byte) (int, FrameType, error) {
+	if e.enc == nil {
+		return 0, Untransmitted, os.ErrClosed
+	}
+	if len(frame) != FrameSize {
+		return 0, Untransmitted, ErrFrameSize
+	}
+	if len(encoded) < VoiceFrameSize {
+		return 0, Untransmitted, ErrShort
+	}
+	input := unsafe.Pointer(&frame[0])
 	output := unsafe.Pointer(&encoded[0])
 	var bitStreamLength C.uint8_t
 	C.bcg729Encoder(e.enc, (*C.int16_t)(input), (*C.uint8_t)(output), &bitStreamLength)
-	return nil
+	n := int(bitStreamLength)
+	frameType, err := frameTypeOf(n)
+	return n, frameType, err
 }
 
-func (e *Encoder) RFC3389Payload(payload []byte) {
-	// TODO: Implement
+// EncodePayload encodes 10ms frames from pcm into an RTP payload until a SID
+// or untransmitted frame ends it, since voice can't follow either in the same
+// payload. Returns the payload length and the number of frames consumed. The
+// RTP timestamp of the next payload is frames*80 later, even if the payload
+// is empty.
+func (e *Encoder) EncodePayload(pcm []int16, payload []byte) (n, frames int, err error) {
+	if len(pcm) == 0 || len(pcm)%FrameSize != 0 {
+		return 0, 0, ErrFrameSize
+	}
+	if len(payload) < len(pcm)/FrameSize*VoiceFrameSize {
+		return 0, 0, ErrShort
+	}
+	for len(pcm) > 0 {
+		size, frameType, err := e.Encode(pcm[:FrameSize], payload[n:])
+		if err != nil {
+			return n, frames, err
+		}
+		n += size
+		frames++
+		pcm = pcm[FrameSize:]
+		if frameType != Voice {
+			break
+		}
+	}
+	return n, frames, nil
+}
+
+// RFC3389Payload writes the comfort noise parameters of the last SID frame
+// as an RFC 3389 CN payload, so DTX can be signalled to endpoints that don't
+// understand G.729 Annex B. The payload can be played with cn.Decoder or
+// Decoder.DecodeRFC3389.
+func (e *Encoder) RFC3389Payload(payload []byte) (int, error) {
+	if e.enc == nil {
+		return 0, os.ErrClosed
+	}
+	if len(payload) < RFC3389PayloadSize {
+		return 0, errors.New("payload must be at least 11 bytes")
+	}
+	C.bcg729GetRFC3389Payload(e.enc, (*C.uint8_t)(unsafe.Pointer(&payload[0])))
+	return RFC3389PayloadSize, nil
 }
@@ -0,0 +1,92 @@
+// Package g729 wraps bcg729, a G.729 Annex A and B codec. Frames are 10ms of
+// 8Khz audio. With VAD enabled (Annex B) silent frames are coded as 2 byte SID
+// frames describing the background noise, or not transmitted at all.
+package g729
+
+import (
+	"errors"
+)
+
+const (
+	SampleRate = 8000
+	// Samples in a 10ms frame.
+	FrameSize = 80
+	// Encoded size of a voice frame.
+	VoiceFrameSize = 10
+	// Encoded size of an Annex B SID frame.
+	SIDFrameSize = 2
+	// Static RTP payload type.
+	PayloadType = 18
+)
+
+var (
+	ErrFrameSize   = errors.New("frame must be 80 samples")
+	ErrPayloadSize = errors.New("invalid payload size")
+	ErrShort       = errors.New("buffer is too small")
+)
+
+// FrameType is the kind of an encoded frame.
+type FrameType int
+
+const (
+	// Not transmitted by DTX. The decoder continues the comfort noise.
+	Untransmitted FrameType = iota
+	// Annex B silence insertion descriptor.
+	SID
+	Voice
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case Untransmitted:
+		return "untransmitted"
+	case SID:
+		return "SID"
+	case Voice:
+		return "voice"
+	}
+	return "unknown"
+}
+
+// frameTypeOf returns the type of an encoded frame from its size.
+func frameTypeOf(size int) (FrameType, error) {
+	switch size {
+	case 0:
+		return Untransmitted, nil
+	case SIDFrameSize:
+		return SID, nil
+	case VoiceFrameSize:
+		return Voice, nil
+	}
+	return Untransmitted, ErrPayloadSize
+}
+
+// SplitPayload splits an RTP payload into its frames. A payload holds zero or
+// more voice frames followed by at most one SID frame (RFC 3551).
+func SplitPayload(payload []byte, frames [][]byte) ([][]byte, error) {
+	sid := len(payload) % VoiceFrameSize
+	if sid != 0 && sid != SIDFrameSize {
+		return frames, ErrPayloadSize
+	}
+	for len(payload) >= VoiceFrameSize {
+		frames = append(frames, payload[:VoiceFrameSize])
+		payload = payload[VoiceFrameSize:]
+	}
+	if len(payload) > 0 {
+		frames = append(frames, payload)
+	}
+	return frames, nil
+}
+
+// PayloadFrames returns the number of 10ms frames in an RTP payload.
+func PayloadFrames(payload []byte) (int, error) {
+	sid := len(payload) % VoiceFrameSize
+	if sid != 0 && sid != SIDFrameSize {
+		return 0, ErrPayloadSize
+	}
+	n := len(payload) / VoiceFrameSize
+	if sid != 0 {
+		n++
+	}
+	return n, nil
+}
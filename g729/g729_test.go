@@ -0,0 +1,118 @@
+package g729
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPayloadFrames(t *testing.T) {
+	for _, test := range []struct {
+		size   int
+		frames int
+		last   FrameType
+		err    error
+	}{
+		{0, 0, Untransmitted, nil},
+		{2, 1, SID, nil},
+		{10, 1, Voice, nil},
+		{12, 2, SID, nil},
+		{20, 2, Voice, nil},
+		{22, 3, SID, nil},
+		{15, 0, Untransmitted, ErrPayloadSize},
+	} {
+		payload := make([]byte, test.size)
+		frames, err := PayloadFrames(payload)
+		if frames != test.frames || err != test.err {
+			t.Errorf("%d bytes: %d frames, %v", test.size, frames, err)
+		}
+		split, err := SplitPayload(payload, nil)
+		if err != test.err || len(split) != test.frames {
+			t.Errorf("%d bytes: split in %d, %v", test.size, len(split), err)
+		}
+		if len(split) > 0 {
+			if last, _ := frameTypeOf(len(split[len(split)-1])); last != test.last {
+				t.Errorf("%d bytes: last frame %s", test.size, last)
+			}
+		}
+	}
+	for size, want := range map[int]FrameType{0: Untransmitted, 2: SID, 10: Voice} {
+		if got, err := frameTypeOf(size); got != want || err != nil {
+			t.Errorf("%d bytes: %s, %v", size, got, err)
+		}
+	}
+	for _, size := range []int{1, 3, 9, 11, 20} {
+		if _, err := frameTypeOf(size); err != ErrPayloadSize {
+			t.Errorf("%d bytes: %v", size, err)
+		}
+	}
+}
+
+func TestVAD(t *testing.T) {
+	// 300ms of voice, then 700ms of faint noise.
+	random := rand.New(rand.NewSource(1))
+	pcm := make([]int16, SampleRate)
+	for i := range pcm {
+		if i < 2400 {
+			pcm[i] = int16(8000 * math.Sin(2*math.Pi*300*float64(i)/SampleRate))
+		} else {
+			pcm[i] = int16(random.Intn(21) - 10)
+		}
+	}
+	enc := NewEncoder(true)
+	defer enc.Close()
+	dec := NewDecoder()
+	defer dec.Close()
+
+	payload := make([]byte, 2*VoiceFrameSize)
+	decoded := make([]int16, 2*FrameSize)
+	ticks, sid, untransmitted := 0, 0, 0
+	// 20ms at a time, as an RTP sender would.
+	for rest := pcm; len(rest) > 0; {
+		chunk := rest
+		if len(chunk) > 2*FrameSize {
+			chunk = chunk[:2*FrameSize]
+		}
+		n, frames, err := enc.EncodePayload(chunk, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[frames*FrameSize:]
+		ticks += frames * FrameSize
+
+		inPayload, err := PayloadFrames(payload[:n])
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		switch {
+		case n == 0:
+			// An untransmitted frame ends a payload by itself.
+			untransmitted++
+			if inPayload != 0 || frames != 1 {
+				t.Fatal(n, frames)
+			}
+			if _, _, err := dec.Decode(nil, decoded); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		case n%VoiceFrameSize == SIDFrameSize:
+			sid++
+		case frames*FrameSize != len(chunk):
+			// Only a SID or untransmitted frame ends a payload early.
+			t.Fatalf("%d voice frames for %d samples", frames, len(chunk))
+		}
+		if inPayload != frames {
+			t.Fatalf("%d bytes for %d frames", n, frames)
+		}
+		samples, err := dec.DecodePayload(payload[:n], decoded)
+		if err != nil || samples != frames*FrameSize {
+			t.Fatal(samples, err)
+		}
+	}
+	if ticks != len(pcm) {
+		t.Fatal(ticks)
+	}
+	if sid == 0 || untransmitted == 0 {
+		t.Fatalf("%d SID and %d untransmitted payloads", sid, untransmitted)
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/pidato/audio
 
-go 1.14
+go 1.18
 
 require (
 	github.com/go-audio/riff v1.0.0
@@ -11,5 +11,31 @@ require (
 	github.com/pion/rtp v1.4.0
 	github.com/pion/webrtc/v2 v2.2.4
 	github.com/stretchr/testify v1.5.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-audio/audio v1.0.0 // indirect
+	github.com/pion/datachannel v1.4.16 // indirect
+	github.com/pion/dtls/v2 v2.0.0-rc.9 // indirect
+	github.com/pion/ice v0.7.10 // indirect
+	github.com/pion/logging v0.2.2 // indirect
+	github.com/pion/mdns v0.0.4 // indirect
+	github.com/pion/rtcp v1.2.1 // indirect
+	github.com/pion/sctp v1.7.6 // indirect
+	github.com/pion/sdp/v2 v2.3.4 // indirect
+	github.com/pion/srtp v1.3.1 // indirect
+	github.com/pion/stun v0.3.3 // indirect
+	github.com/pion/transport v0.10.0 // indirect
+	github.com/pion/turn/v2 v2.0.3 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d // indirect
+	golang.org/x/net v0.0.0-20200301022130-244492dfa37a // indirect
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
+	golang.org/x/sys v0.0.0-20190429190828-d89cdac9e872 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
+
+replace github.com/pidato/vad-go => ./vad
@@ -0,0 +1,147 @@
+package gsm
+
+import (
+	"math/bits"
+)
+
+// Fixed point arithmetic of GSM 06.10. Words are 16 bit and longwords 32 bit.
+
+const (
+	minWord = -32768
+	maxWord = 32767
+)
+
+var (
+	// Table 4.1 quantization of the log area ratios.
+	larA   = [8]int16{20480, 20480, 20480, 20480, 13964, 15360, 8534, 9036}
+	larB   = [8]int16{0, 0, 2048, -2560, 94, -1792, -341, -1144}
+	larMIC = [8]int16{-32, -32, -16, -16, -8, -8, -4, -4}
+	larMAC = [8]int16{31, 31, 15, 15, 7, 7, 3, 3}
+	larINV = [8]int16{13107, 13107, 13107, 13107, 19223, 17476, 31454, 29708}
+
+	// Table 4.3 LTP gain decision levels and quantization levels.
+	dlb = [4]int16{6554, 16384, 26214, 32767}
+	qlb = [4]int16{3277, 11469, 21299, 32767}
+
+	// Table 4.5 normalized inverse mantissa and mantissa of the RPE samples.
+	nrfac = [8]int16{29128, 26215, 23832, 21846, 20165, 18725, 17476, 16384}
+	fac   = [8]int16{18431, 20479, 22527, 24575, 26623, 28671, 30719, 32767}
+)
+
+func saturate(v int32) int16 {
+	if v > maxWord {
+		return maxWord
+	}
+	if v < minWord {
+		return minWord
+	}
+	return int16(v)
+}
+
+func add(a, b int16) int16 {
+	return saturate(int32(a) + int32(b))
+}
+
+func sub(a, b int16) int16 {
+	return saturate(int32(a) - int32(b))
+}
+
+func abs(a int16) int16 {
+	if a < 0 {
+		if a == minWord {
+			return maxWord
+		}
+		return -a
+	}
+	return a
+}
+
+// mult returns a*b with the scaling of Q15.
+func mult(a, b int16) int16 {
+	if a == minWord && b == minWord {
+		return maxWord
+	}
+	return int16((int32(a) * int32(b)) >> 15)
+}
+
+// multR is mult with rounding.
+func multR(a, b int16) int16 {
+	if a == minWord && b == minWord {
+		return maxWord
+	}
+	return int16((int32(a)*int32(b) + 16384) >> 15)
+}
+
+func lAdd(a, b int32) int32 {
+	s := int64(a) + int64(b)
+	if s > 1<<31-1 {
+		return 1<<31 - 1
+	}
+	if s < -1<<31 {
+		return -1 << 31
+	}
+	return int32(s)
+}
+
+// norm returns the number of left shifts that normalize a, so that
+// a<<norm(a) is in [0x40000000, 0x7FFFFFFF] or [-0x80000000, -0x40000000].
+func norm(a int32) int {
+	if a < 0 {
+		if a <= -1073741824 {
+			return 0
+		}
+		a = ^a
+	}
+	return bits.LeadingZeros32(uint32(a)) - 1
+}
+
+// div returns num/denum in Q15, where 0 <= num <= denum.
+func div(num, denum int16) int16 {
+	if num == 0 {
+		return 0
+	}
+	lNum := int32(num)
+	lDenum := int32(denum)
+	var d int16
+	for k := 0; k < 15; k++ {
+		d <<= 1
+		lNum <<= 1
+		if lNum >= lDenum {
+			lNum -= lDenum
+			d++
+		}
+	}
+	return d
+}
+
+func asl(a int16, n int) int16 {
+	if n >= 16 {
+		return 0
+	}
+	if n <= -16 {
+		if a < 0 {
+			return -1
+		}
+		return 0
+	}
+	if n < 0 {
+		return asr(a, -n)
+	}
+	return a << uint(n)
+}
+
+func asr(a int16, n int) int16 {
+	if n >= 16 {
+		if a < 0 {
+			return -1
+		}
+		return 0
+	}
+	if n <= -16 {
+		return 0
+	}
+	if n < 0 {
+		return a << uint(-n)
+	}
+	return a >> uint(n)
+}
@@ -0,0 +1,99 @@
+package gsm
+
+// params are the coded parameters of a 20ms frame.
+type params struct {
+	larc  [8]int16     // Log area ratios.
+	nc    [4]int16     // LTP lag of each subframe.
+	bc    [4]int16     // LTP gain.
+	mc    [4]int16     // RPE grid position.
+	xmaxc [4]int16     // RPE block maximum.
+	xMc   [4][13]int16 // RPE pulses.
+}
+
+// encoderState is the state of the GSM 06.10 encoder.
+type encoderState struct {
+	z1  int16 // Offset compensation.
+	lz2 int32
+	mp  int16 // Preemphasis.
+
+	shortTerm shortTerm
+	dp0       [280]int16 // Reconstructed residual.
+	e         [50]int16
+}
+
+// encode codes 160 samples.
+func (st *encoderState) encode(s []int16, p *params) {
+	var so [160]int16
+	st.preprocess(s, &so)
+	lpcAnalysis(&so, &p.larc)
+	st.shortTerm.analysis(&p.larc, &so)
+
+	for k := 0; k < 4; k++ {
+		dp := st.dp0[k*40:]
+		var dpp [40]int16
+		e := st.e[5:45]
+		p.bc[k], p.nc[k] = longTermPredictor(so[k*40:], dp, e, dpp[:])
+		rpeEncoding(st.e[:], &p.xmaxc[k], &p.mc[k], &p.xMc[k])
+		for i := 0; i < 40; i++ {
+			dp[120+i] = add(e[i], dpp[i])
+		}
+	}
+	copy(st.dp0[:120], st.dp0[160:])
+}
+
+// preprocess downscales s to 13 bits, removes the offset and applies
+// preemphasis (4.2.1 - 4.2.3).
+func (st *encoderState) preprocess(s []int16, so *[160]int16) {
+	z1, lz2, mp := st.z1, st.lz2, st.mp
+	for k := range so {
+		sof := (s[k] >> 3) << 2
+
+		s1 := sof - z1
+		z1 = sof
+		ls2 := int32(s1) << 15
+		msp := int16(lz2 >> 15)
+		lsp := int16(lz2 - int32(msp)<<15)
+		ls2 += int32(multR(lsp, 32735))
+		lz2 = lAdd(int32(msp)*32735, ls2)
+
+		lTemp := lAdd(lz2, 16384)
+		msp = multR(mp, -28180)
+		mp = int16(lTemp >> 15)
+		so[k] = add(mp, msp)
+	}
+	st.z1, st.lz2, st.mp = z1, lz2, mp
+}
+
+// decoderState is the state of the GSM 06.10 decoder.
+type decoderState struct {
+	shortTerm shortTerm
+	drp       [160]int16 // Reconstructed residual.
+	nrp       int16      // Last valid LTP lag.
+	msr       int16      // Deemphasis.
+}
+
+func (st *decoderState) reset() {
+	*st = decoderState{nrp: 40}
+}
+
+// decode decodes a frame into 160 samples.
+func (st *decoderState) decode(p *params, s []int16) {
+	var erp [40]int16
+	var wt [160]int16
+	for j := 0; j < 4; j++ {
+		rpeDecoding(p.xmaxc[j], p.mc[j], &p.xMc[j], erp[:])
+		longTermSynthesis(&st.nrp, p.nc[j], p.bc[j], erp[:], &st.drp)
+		copy(wt[j*40:], st.drp[120:])
+		copy(st.drp[:120], st.drp[40:])
+	}
+	var sr [160]int16
+	st.shortTerm.synthesis(&p.larc, &wt, &sr)
+
+	// Deemphasis, upscaling and truncation (4.3.5 - 4.3.7).
+	msr := st.msr
+	for k, v := range sr {
+		msr = add(v, multR(msr, 28180))
+		s[k] = add(msr, msr) &^ 7
+	}
+	st.msr = msr
+}
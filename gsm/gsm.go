@@ -0,0 +1,298 @@
+/*
+Package gsm implements encoding and decoding of GSM 06.10 full rate audio.
+Every 20ms frame of 160 8Khz samples is coded in 260 bits.
+
+RTP payloads (RFC 3551) hold one or more 33 byte frames, each starting with
+the 0xD signature. Microsoft WAV49 files (WAV format 0x31) pack two frames
+without the signature into 65 byte blocks, least significant bit first.
+*/
+package gsm
+
+import (
+	"errors"
+)
+
+const (
+	SampleRate = 8000
+	// Samples in a frame.
+	FrameSize = 160
+	// Bytes in an RTP frame.
+	EncodedFrameSize = 33
+	// Static RTP payload type.
+	PayloadType = 3
+
+	// Samples and bytes in a WAV49 block of two frames.
+	WAV49FrameSize = 2 * FrameSize
+	WAV49BlockSize = 65
+
+	magic = 0xD
+)
+
+var (
+	ErrFrameSize   = errors.New("frame must be a multiple of 160 samples")
+	ErrPayloadSize = errors.New("invalid payload size")
+	ErrMagic       = errors.New("invalid frame signature")
+	ErrShort       = errors.New("buffer is too small")
+)
+
+// Bits of the parameters in the order they are packed.
+var (
+	larBits = [8]uint{6, 6, 5, 5, 4, 4, 3, 3}
+)
+
+const (
+	ncBits    = 7
+	bcBits    = 2
+	mcBits    = 2
+	xmaxcBits = 6
+	xMcBits   = 3
+)
+
+// bitWriter packs fields most significant bit first, or least significant
+// bit first for WAV49.
+type bitWriter struct {
+	buf  []byte
+	pos  uint
+	lsbf bool
+}
+
+func (w *bitWriter) write(v int16, bits uint) {
+	for i := uint(0); i < bits; i++ {
+		var bit int16
+		if w.lsbf {
+			bit = v >> i & 1
+			w.buf[w.pos/8] |= byte(bit) << (w.pos % 8)
+		} else {
+			bit = v >> (bits - 1 - i) & 1
+			w.buf[w.pos/8] |= byte(bit) << (7 - w.pos%8)
+		}
+		w.pos++
+	}
+}
+
+func (w *bitWriter) params(p *params) {
+	for i, bits := range larBits {
+		w.write(p.larc[i], bits)
+	}
+	for k := 0; k < 4; k++ {
+		w.write(p.nc[k], ncBits)
+		w.write(p.bc[k], bcBits)
+		w.write(p.mc[k], mcBits)
+		w.write(p.xmaxc[k], xmaxcBits)
+		for _, v := range p.xMc[k] {
+			w.write(v, xMcBits)
+		}
+	}
+}
+
+type bitReader struct {
+	buf  []byte
+	pos  uint
+	lsbf bool
+}
+
+func (r *bitReader) read(bits uint) int16 {
+	var v int16
+	for i := uint(0); i < bits; i++ {
+		if r.lsbf {
+			bit := int16(r.buf[r.pos/8] >> (r.pos % 8) & 1)
+			v |= bit << i
+		} else {
+			bit := int16(r.buf[r.pos/8] >> (7 - r.pos%8) & 1)
+			v = v<<1 | bit
+		}
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) params(p *params) {
+	for i, bits := range larBits {
+		p.larc[i] = r.read(bits)
+	}
+	for k := 0; k < 4; k++ {
+		p.nc[k] = r.read(ncBits)
+		p.bc[k] = r.read(bcBits)
+		p.mc[k] = r.read(mcBits)
+		p.xmaxc[k] = r.read(xmaxcBits)
+		for i := range p.xMc[k] {
+			p.xMc[k][i] = r.read(xMcBits)
+		}
+	}
+}
+
+// FrameEncoder encodes 8Khz LPCM frames to GSM RTP payloads.
+type FrameEncoder struct {
+	state encoderState
+}
+
+func NewFrameEncoder() *FrameEncoder {
+	return &FrameEncoder{}
+}
+
+// Encode encodes frame, a multiple of 160 samples, into payload and returns
+// the payload length, 33 bytes per 160 samples.
+func (e *FrameEncoder) Encode(frame []int16, payload []byte) (int, error) {
+	if len(frame) == 0 || len(frame)%FrameSize != 0 {
+		return 0, ErrFrameSize
+	}
+	n := len(frame) / FrameSize * EncodedFrameSize
+	if len(payload) < n {
+		return 0, ErrShort
+	}
+	var p params
+	for i := 0; i < len(frame)/FrameSize; i++ {
+		e.state.encode(frame[i*FrameSize:], &p)
+		out := payload[i*EncodedFrameSize : (i+1)*EncodedFrameSize]
+		for j := range out {
+			out[j] = 0
+		}
+		w := bitWriter{buf: out}
+		w.write(magic, 4)
+		w.params(&p)
+	}
+	return n, nil
+}
+
+// Reset discards the encoder state.
+func (e *FrameEncoder) Reset() {
+	e.state = encoderState{}
+}
+
+// FrameDecoder decodes GSM RTP payloads to 8Khz LPCM frames.
+type FrameDecoder struct {
+	state decoderState
+	last  params
+	lost  int // Frames concealed since the last one received.
+}
+
+func NewFrameDecoder() *FrameDecoder {
+	d := &FrameDecoder{}
+	d.state.reset()
+	return d
+}
+
+// Decode decodes payload, a multiple of 33 bytes, into frame and returns the
+// number of samples.
+func (d *FrameDecoder) Decode(payload []byte, frame []int16) (int, error) {
+	if len(payload) == 0 || len(payload)%EncodedFrameSize != 0 {
+		return 0, ErrPayloadSize
+	}
+	frames := len(payload) / EncodedFrameSize
+	if len(frame) < frames*FrameSize {
+		return 0, ErrShort
+	}
+	for i := 0; i < frames; i++ {
+		r := bitReader{buf: payload[i*EncodedFrameSize:]}
+		if r.read(4) != magic {
+			return i * FrameSize, ErrMagic
+		}
+		r.params(&d.last)
+		d.state.decode(&d.last, frame[i*FrameSize:])
+	}
+	d.lost = 0
+	return frames * FrameSize, nil
+}
+
+// Conceal fills frame, a multiple of 160 samples, with a replacement for
+// lost frames. As recommended in GSM 06.11, the last frame is repeated and
+// muted gradually.
+func (d *FrameDecoder) Conceal(frame []int16) error {
+	if len(frame)%FrameSize != 0 {
+		return ErrFrameSize
+	}
+	for i := 0; i < len(frame); i += FrameSize {
+		d.lost++
+		if d.lost > 1 {
+			// Halve the excitation for each lost frame after the first and
+			// weaken the long term prediction so it dies out.
+			for k := range d.last.xmaxc {
+				d.last.xmaxc[k] -= 8
+				if d.last.xmaxc[k] < 0 {
+					d.last.xmaxc[k] = 0
+				}
+				if d.last.bc[k] > 1 {
+					d.last.bc[k] = 1
+				}
+			}
+		}
+		d.state.decode(&d.last, frame[i:])
+	}
+	return nil
+}
+
+// Reset discards the decoder state.
+func (d *FrameDecoder) Reset() {
+	d.state.reset()
+	d.last = params{}
+	d.lost = 0
+}
+
+// WAV49Encoder encodes blocks of WAV49 GSM.
+type WAV49Encoder struct {
+	state encoderState
+}
+
+func NewWAV49Encoder() *WAV49Encoder {
+	return &WAV49Encoder{}
+}
+
+// SamplesPerBlock returns the number of samples in a block.
+func (e *WAV49Encoder) SamplesPerBlock() int {
+	return WAV49FrameSize
+}
+
+// Encode encodes 320 samples into a 65 byte block. A short frame is padded
+// with silence.
+func (e *WAV49Encoder) Encode(frame []int16, block []byte) (int, error) {
+	if len(frame) == 0 || len(frame) > WAV49FrameSize {
+		return 0, ErrFrameSize
+	}
+	if len(block) < WAV49BlockSize {
+		return 0, ErrShort
+	}
+	if len(frame) < WAV49FrameSize {
+		padded := make([]int16, WAV49FrameSize)
+		copy(padded, frame)
+		frame = padded
+	}
+	block = block[:WAV49BlockSize]
+	for i := range block {
+		block[i] = 0
+	}
+	w := bitWriter{buf: block, lsbf: true}
+	var p params
+	for i := 0; i < 2; i++ {
+		e.state.encode(frame[i*FrameSize:], &p)
+		w.params(&p)
+	}
+	return WAV49BlockSize, nil
+}
+
+// WAV49Decoder decodes blocks of WAV49 GSM.
+type WAV49Decoder struct {
+	state decoderState
+}
+
+func NewWAV49Decoder() *WAV49Decoder {
+	d := &WAV49Decoder{}
+	d.state.reset()
+	return d
+}
+
+// Decode decodes a 65 byte block into 320 samples.
+func (d *WAV49Decoder) Decode(block []byte, frame []int16) (int, error) {
+	if len(block) != WAV49BlockSize {
+		return 0, ErrPayloadSize
+	}
+	if len(frame) < WAV49FrameSize {
+		return 0, ErrShort
+	}
+	r := bitReader{buf: block, lsbf: true}
+	var p params
+	for i := 0; i < 2; i++ {
+		r.params(&p)
+		d.state.decode(&p, frame[i*FrameSize:])
+	}
+	return WAV49FrameSize, nil
+}
@@ -0,0 +1,147 @@
+package gsm
+
+import (
+	"math"
+	"testing"
+)
+
+// voice returns a pitched, vowel-like test signal.
+func voice(n int) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		t := float64(i) / SampleRate
+		var v float64
+		for h := 1; h <= 10; h++ {
+			f := 150 * float64(h)
+			// Formants near 700 and 1200Hz.
+			a := 1/(1+math.Pow((f-700)/200, 2)) + 0.5/(1+math.Pow((f-1200)/300, 2))
+			v += a * math.Sin(2*math.Pi*f*t)
+		}
+		out[i] = int16(6000 * v)
+	}
+	return out
+}
+
+// snr returns the SNR of output against input in dB after the first frames.
+func snr(input, output []int16) float64 {
+	var signal, noise float64
+	for i := 4 * FrameSize; i < len(input); i++ {
+		s := float64(input[i])
+		e := float64(output[i]) - s
+		signal += s * s
+		noise += e * e
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	input := voice(8000)
+	enc := NewFrameEncoder()
+	dec := NewFrameDecoder()
+	output := make([]int16, len(input))
+	payload := make([]byte, 2*EncodedFrameSize)
+	for i := 0; i < len(input); i += 2 * FrameSize {
+		n, err := enc.Encode(input[i:i+2*FrameSize], payload)
+		if err != nil || n != 2*EncodedFrameSize {
+			t.Fatal(n, err)
+		}
+		if payload[0]>>4 != 0xD || payload[EncodedFrameSize]>>4 != 0xD {
+			t.Fatalf("signature %x", payload[0])
+		}
+		if n, err = dec.Decode(payload, output[i:]); err != nil || n != 2*FrameSize {
+			t.Fatal(n, err)
+		}
+	}
+	s := snr(input, output)
+	t.Logf("SNR %.1fdB", s)
+	if s < 12 {
+		t.Fatalf("SNR %.1fdB", s)
+	}
+}
+
+func TestWAV49(t *testing.T) {
+	input := voice(3200)
+	enc := NewFrameEncoder()
+	dec := NewFrameDecoder()
+	wavEnc := NewWAV49Encoder()
+	wavDec := NewWAV49Decoder()
+	payload := make([]byte, 2*EncodedFrameSize)
+	block := make([]byte, WAV49BlockSize)
+	want := make([]int16, WAV49FrameSize)
+	output := make([]int16, WAV49FrameSize)
+	for i := 0; i < len(input); i += WAV49FrameSize {
+		frame := input[i : i+WAV49FrameSize]
+		if _, err := enc.Encode(frame, payload); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dec.Decode(payload, want); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := wavEnc.Encode(frame, block); err != nil || n != WAV49BlockSize {
+			t.Fatal(n, err)
+		}
+		if n, err := wavDec.Decode(block, output); err != nil || n != WAV49FrameSize {
+			t.Fatal(n, err)
+		}
+		// The same parameters in a different packing.
+		for j := range want {
+			if output[j] != want[j] {
+				t.Fatalf("sample %d is %d, want %d", i+j, output[j], want[j])
+			}
+		}
+	}
+}
+
+func TestConceal(t *testing.T) {
+	input := voice(1600)
+	enc := NewFrameEncoder()
+	dec := NewFrameDecoder()
+	payload := make([]byte, EncodedFrameSize)
+	frame := make([]int16, FrameSize)
+	for i := 0; i < len(input); i += FrameSize {
+		if _, err := enc.Encode(input[i:i+FrameSize], payload); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dec.Decode(payload, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	energy := func(frame []int16) float64 {
+		var sum float64
+		for _, s := range frame {
+			sum += float64(s) * float64(s)
+		}
+		return sum
+	}
+	last := energy(frame)
+	for i := 0; i < 6; i++ {
+		if err := dec.Conceal(frame); err != nil {
+			t.Fatal(err)
+		}
+		e := energy(frame)
+		if i == 0 && e < last/4 {
+			t.Fatalf("first concealed frame energy %.0f after %.0f", e, last)
+		}
+		if i > 1 && e >= last {
+			t.Fatalf("concealed frame %d energy %.0f after %.0f", i, e, last)
+		}
+		last = e
+	}
+}
+
+func TestErrors(t *testing.T) {
+	enc := NewFrameEncoder()
+	if _, err := enc.Encode(make([]int16, 100), make([]byte, 33)); err != ErrFrameSize {
+		t.Fatal(err)
+	}
+	if _, err := enc.Encode(make([]int16, 320), make([]byte, 33)); err != ErrShort {
+		t.Fatal(err)
+	}
+	dec := NewFrameDecoder()
+	if _, err := dec.Decode(make([]byte, 32), make([]int16, 160)); err != ErrPayloadSize {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(make([]byte, 33), make([]int16, 160)); err != ErrMagic {
+		t.Fatal(err)
+	}
+}
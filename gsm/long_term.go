@@ -0,0 +1,94 @@
+package gsm
+
+// 4.2.11 - 4.2.12 and 4.3.2 long term prediction of the residual from the
+// last 120 samples of reconstructed residual.
+
+// ltpParameters returns the gain code bc and the lag Nc for the segment d
+// given the reconstructed residual dp[-120..-1], which is dp[0..119] here.
+func ltpParameters(d []int16, dp []int16) (bc, nc int16) {
+	var dmax int16
+	for _, v := range d[:40] {
+		if v := abs(v); v > dmax {
+			dmax = v
+		}
+	}
+	temp := 0
+	if dmax != 0 {
+		temp = norm(int32(dmax) << 16)
+	}
+	scal := 0
+	if temp <= 6 {
+		scal = 6 - temp
+	}
+
+	var wt [40]int16
+	for k := range wt {
+		wt[k] = d[k] >> uint(scal)
+	}
+
+	// Search for the maximum cross-correlation.
+	var lMax int32
+	nc = 40
+	for lambda := 40; lambda <= 120; lambda++ {
+		var sum int32
+		for k := 0; k < 40; k++ {
+			sum += int32(wt[k]) * int32(dp[120+k-lambda])
+		}
+		if sum > lMax {
+			nc = int16(lambda)
+			lMax = sum
+		}
+	}
+	lMax <<= 1
+	lMax >>= uint(6 - scal)
+
+	var lPower int32
+	for k := 0; k < 40; k++ {
+		t := int32(dp[120+k-int(nc)] >> 3)
+		lPower += t * t
+	}
+	lPower <<= 1
+
+	if lMax <= 0 {
+		return 0, nc
+	}
+	if lMax >= lPower {
+		return 3, nc
+	}
+	temp = norm(lPower)
+	r := int16((lMax << uint(temp)) >> 16)
+	s := int16((lPower << uint(temp)) >> 16)
+	for bc = 0; bc <= 2; bc++ {
+		if r <= mult(s, dlb[bc]) {
+			break
+		}
+	}
+	return bc, nc
+}
+
+// longTermPredictor computes the LTP parameters of d and the residual e and
+// prediction dpp of it.
+func longTermPredictor(d, dp, e, dpp []int16) (bc, nc int16) {
+	bc, nc = ltpParameters(d, dp)
+	bp := qlb[bc]
+	for k := 0; k < 40; k++ {
+		dpp[k] = multR(bp, dp[120+k-int(nc)])
+		e[k] = sub(d[k], dpp[k])
+	}
+	return bc, nc
+}
+
+// longTermSynthesis reconstructs drp[120..159] from erp and the residual in
+// drp[0..119].
+func longTermSynthesis(nrp *int16, ncr, bcr int16, erp []int16, drp *[160]int16) {
+	nr := ncr
+	if nr < 40 || nr > 120 {
+		nr = *nrp
+	}
+	*nrp = nr
+	brp := qlb[bcr]
+	for k := 0; k < 40; k++ {
+		drpp := multR(brp, drp[120+k-int(nr)])
+		drp[120+k] = add(erp[k], drpp)
+	}
+}
@@ -0,0 +1,123 @@
+package gsm
+
+// 4.2.4 - 4.2.7 LPC analysis of a frame, giving the coded log area ratios.
+
+func lpcAnalysis(s *[160]int16, larc *[8]int16) {
+	var acf [9]int32
+	autocorrelation(s, &acf)
+	var r [8]int16
+	reflectionCoefficients(&acf, &r)
+	logAreaRatios(&r)
+	quantizeLAR(&r, larc)
+}
+
+// autocorrelation scales s to avoid overflow, which also rounds it.
+func autocorrelation(s *[160]int16, acf *[9]int32) {
+	var smax int16
+	for _, v := range s {
+		if v := abs(v); v > smax {
+			smax = v
+		}
+	}
+	scalauto := 0
+	if smax != 0 {
+		scalauto = 4 - norm(int32(smax)<<16)
+	}
+	if scalauto > 0 {
+		factor := int16(16384 >> uint(scalauto-1))
+		for k := range s {
+			s[k] = multR(s[k], factor)
+		}
+	}
+
+	for k := range acf {
+		var sum int32
+		for i := k; i < 160; i++ {
+			sum += int32(s[i]) * int32(s[i-k])
+		}
+		acf[k] = sum << 1
+	}
+
+	if scalauto > 0 {
+		for k := range s {
+			s[k] <<= uint(scalauto)
+		}
+	}
+}
+
+// reflectionCoefficients uses the Schur recursion.
+func reflectionCoefficients(lACF *[9]int32, r *[8]int16) {
+	if lACF[0] == 0 {
+		*r = [8]int16{}
+		return
+	}
+	temp := norm(lACF[0])
+	var acf, p, k [9]int16
+	for i := range acf {
+		acf[i] = int16((lACF[i] << uint(temp)) >> 16)
+	}
+	for i := 1; i <= 7; i++ {
+		k[i] = acf[i]
+	}
+	p = acf
+
+	for n := 1; n <= 8; n++ {
+		t := abs(p[1])
+		if p[0] < t {
+			for i := n; i <= 8; i++ {
+				r[i-1] = 0
+			}
+			return
+		}
+		rn := div(t, p[0])
+		if p[1] > 0 {
+			rn = -rn
+		}
+		r[n-1] = rn
+		if n == 8 {
+			return
+		}
+		p[0] = add(p[0], multR(p[1], rn))
+		for m := 1; m <= 8-n; m++ {
+			p[m] = add(p[m+1], multR(k[m], rn))
+			k[m] = add(k[m], multR(p[m+1], rn))
+		}
+	}
+}
+
+// logAreaRatios transforms the reflection coefficients with a piecewise
+// linear approximation.
+func logAreaRatios(r *[8]int16) {
+	for i, v := range r {
+		t := abs(v)
+		switch {
+		case t < 22118:
+			t >>= 1
+		case t < 31130:
+			t -= 11059
+		default:
+			t = (t - 26112) << 2
+		}
+		if v < 0 {
+			t = -t
+		}
+		r[i] = t
+	}
+}
+
+func quantizeLAR(lar, larc *[8]int16) {
+	for i, v := range lar {
+		t := mult(larA[i], v)
+		t = add(t, larB[i])
+		t = add(t, 256)
+		t >>= 9
+		switch {
+		case t > larMAC[i]:
+			larc[i] = larMAC[i] - larMIC[i]
+		case t < larMIC[i]:
+			larc[i] = 0
+		default:
+			larc[i] = t - larMIC[i]
+		}
+	}
+}
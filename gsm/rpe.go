@@ -0,0 +1,132 @@
+package gsm
+
+// 4.2.13 - 4.2.18 and 4.3.1 regular pulse excitation coding. The residual is
+// weighted, decimated by 3 and the 13 pulses of the grid with most energy
+// quantized with an adaptive block scale.
+
+// weightingFilter filters e[0..39], which is preceded and followed by 5
+// zeros in the slice.
+func weightingFilter(e []int16, x *[40]int16) {
+	h := [11]int32{-134, -374, 0, 2054, 5741, 8192, 5741, 2054, 0, -374, -134}
+	for k := range x {
+		sum := int32(8192 >> 1)
+		for i, c := range h {
+			sum += int32(e[k+i]) * c
+		}
+		x[k] = saturate(sum >> 13)
+	}
+}
+
+// gridSelection returns the grid position Mc with the most energy and its
+// pulses.
+func gridSelection(x *[40]int16, xM *[13]int16) int16 {
+	var em int32
+	var mc int16
+	for m := 0; m < 4; m++ {
+		var sum int32
+		for i := 0; i < 13 && m+3*i < 40; i++ {
+			t := int32(x[m+3*i] >> 2)
+			sum += t * t
+		}
+		sum <<= 1
+		if m == 0 || sum > em {
+			mc = int16(m)
+			em = sum
+		}
+	}
+	for i := range xM {
+		xM[i] = x[int(mc)+3*i]
+	}
+	return mc
+}
+
+// xmaxcToExpMant returns the exponent and mantissa of a coded block
+// maximum.
+func xmaxcToExpMant(xmaxc int16) (exp, mant int16) {
+	if xmaxc > 15 {
+		exp = (xmaxc >> 3) - 1
+	}
+	mant = xmaxc - (exp << 3)
+	if mant == 0 {
+		return -4, 7
+	}
+	for mant <= 7 {
+		mant = mant<<1 | 1
+		exp--
+	}
+	return exp, mant - 8
+}
+
+// apcmQuantization codes the block maximum and the pulses.
+func apcmQuantization(xM *[13]int16, xMc *[13]int16) (xmaxc, exp, mant int16) {
+	var xmax int16
+	for _, v := range xM {
+		if v := abs(v); v > xmax {
+			xmax = v
+		}
+	}
+
+	exp = 0
+	temp := xmax >> 9
+	itest := false
+	for i := 0; i <= 5; i++ {
+		itest = itest || temp <= 0
+		temp >>= 1
+		if !itest {
+			exp++
+		}
+	}
+	xmaxc = add(xmax>>uint(exp+5), exp<<3)
+
+	exp, mant = xmaxcToExpMant(xmaxc)
+	temp1 := uint(6 - exp)
+	temp2 := nrfac[mant]
+	for i, v := range xM {
+		t := v << temp1
+		t = mult(t, temp2)
+		xMc[i] = (t >> 12) + 4
+	}
+	return xmaxc, exp, mant
+}
+
+func apcmInverseQuantization(xMc *[13]int16, exp, mant int16, xMp *[13]int16) {
+	temp1 := fac[mant]
+	temp2 := int(sub(6, exp))
+	temp3 := asl(1, int(sub(int16(temp2), 1)))
+	for i, c := range xMc {
+		t := (c << 1) - 7
+		t <<= 12
+		t = multR(temp1, t)
+		t = add(t, temp3)
+		xMp[i] = asr(t, temp2)
+	}
+}
+
+func gridPositioning(mc int16, xMp *[13]int16, ep []int16) {
+	for i := range ep[:40] {
+		ep[i] = 0
+	}
+	for i, v := range xMp {
+		ep[int(mc)+3*i] = v
+	}
+}
+
+// rpeEncoding codes e[5..44] of e[0..49] and replaces it with the decoded
+// excitation.
+func rpeEncoding(e []int16, xmaxc, mc *int16, xMc *[13]int16) {
+	var x [40]int16
+	var xM, xMp [13]int16
+	weightingFilter(e, &x)
+	*mc = gridSelection(&x, &xM)
+	var exp, mant int16
+	*xmaxc, exp, mant = apcmQuantization(&xM, xMc)
+	apcmInverseQuantization(xMc, exp, mant, &xMp)
+	gridPositioning(*mc, &xMp, e[5:])
+}
+
+func rpeDecoding(xmaxcr, mcr int16, xMcr *[13]int16, erp []int16) {
+	var xMp [13]int16
+	exp, mant := xmaxcToExpMant(xmaxcr)
+	apcmInverseQuantization(xMcr, exp, mant, &xMp)
+	gridPositioning(mcr, &xMp, erp)
+}
@@ -0,0 +1,118 @@
+package gsm
+
+// 4.2.8 - 4.2.10 and 4.3.4 short term analysis and synthesis filtering with
+// reflection coefficients interpolated between frames.
+
+type shortTerm struct {
+	larpp [2][8]int16 // Decoded log area ratios of this and the last frame.
+	j     int
+	u     [8]int16 // Analysis filter state.
+	v     [9]int16 // Synthesis filter state.
+}
+
+// decodeLAR decodes the coded log area ratios.
+func decodeLAR(larc *[8]int16, larpp *[8]int16) {
+	for i, c := range larc {
+		t := add(c, larMIC[i]) << 10
+		t = sub(t, larB[i]<<1)
+		t = multR(larINV[i], t)
+		larpp[i] = add(t, t)
+	}
+}
+
+// interpolate sets the log area ratios of one of the 4 segments of a frame.
+func interpolate(segment int, prev, cur *[8]int16, larp *[8]int16) {
+	for i := range larp {
+		switch segment {
+		case 0:
+			larp[i] = add(prev[i]>>2, cur[i]>>2)
+			larp[i] = add(larp[i], prev[i]>>1)
+		case 1:
+			larp[i] = add(prev[i]>>1, cur[i]>>1)
+		case 2:
+			larp[i] = add(prev[i]>>2, cur[i]>>2)
+			larp[i] = add(larp[i], cur[i]>>1)
+		default:
+			larp[i] = cur[i]
+		}
+	}
+}
+
+// larpToRP converts log area ratios back to reflection coefficients.
+func larpToRP(larp *[8]int16) {
+	for i, v := range larp {
+		var t int16
+		if v < 0 {
+			if v == minWord {
+				t = maxWord
+			} else {
+				t = -v
+			}
+		} else {
+			t = v
+		}
+		switch {
+		case t < 11059:
+			t <<= 1
+		case t < 20070:
+			t += 11059
+		default:
+			t = add(t>>2, 26112)
+		}
+		if v < 0 {
+			t = -t
+		}
+		larp[i] = t
+	}
+}
+
+// Segments of a frame with their own interpolated coefficients.
+var segments = [5]int{0, 13, 27, 40, 160}
+
+// analysis filters s in place.
+func (st *shortTerm) analysis(larc *[8]int16, s *[160]int16) {
+	cur := &st.larpp[st.j]
+	st.j ^= 1
+	prev := &st.larpp[st.j]
+	decodeLAR(larc, cur)
+
+	var rp [8]int16
+	for seg := 0; seg < 4; seg++ {
+		interpolate(seg, prev, cur, &rp)
+		larpToRP(&rp)
+		for k := segments[seg]; k < segments[seg+1]; k++ {
+			di := s[k]
+			sav := di
+			for i := 0; i < 8; i++ {
+				ui := st.u[i]
+				st.u[i] = sav
+				sav = add(ui, multR(rp[i], di))
+				di = add(di, multR(rp[i], ui))
+			}
+			s[k] = di
+		}
+	}
+}
+
+// synthesis filters the residual wt into s.
+func (st *shortTerm) synthesis(larc *[8]int16, wt, s *[160]int16) {
+	cur := &st.larpp[st.j]
+	st.j ^= 1
+	prev := &st.larpp[st.j]
+	decodeLAR(larc, cur)
+
+	var rrp [8]int16
+	for seg := 0; seg < 4; seg++ {
+		interpolate(seg, prev, cur, &rrp)
+		larpToRP(&rrp)
+		for k := segments[seg]; k < segments[seg+1]; k++ {
+			sri := wt[k]
+			for i := 7; i >= 0; i-- {
+				sri = sub(sri, multR(rrp[i], st.v[i]))
+				st.v[i+1] = add(st.v[i], multR(rrp[i], sri))
+			}
+			st.v[0] = sri
+			s[k] = sri
+		}
+	}
+}
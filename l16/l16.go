@@ -0,0 +1,90 @@
+/*
+Package l16 implements the L16 RTP payload format (RFC 3551): uncompressed
+16 bit linear PCM in network byte order. Channels are interleaved as in pcm
+frames, so a sample of every channel is 2 * channels bytes.
+
+Any sample rate and channel count may be negotiated. Only 44100Hz mono and
+stereo have static payload types.
+*/
+package l16
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// RTP encoding name.
+	EncodingName = "L16"
+
+	// Static RTP payload types at 44100Hz.
+	PayloadTypeStereo = 10
+	PayloadTypeMono   = 11
+	StaticSampleRate  = 44100
+)
+
+var (
+	ErrChannels    = errors.New("invalid channel count")
+	ErrPayloadSize = errors.New("payload must hold whole samples")
+	ErrShort       = errors.New("buffer is too small")
+)
+
+// PayloadType returns the static RTP payload type of a sample rate and
+// channel count. ok is false when the payload type must be negotiated.
+func PayloadType(sampleRate, channels int) (payloadType uint8, ok bool) {
+	if sampleRate != StaticSampleRate {
+		return 0, false
+	}
+	switch channels {
+	case 1:
+		return PayloadTypeMono, true
+	case 2:
+		return PayloadTypeStereo, true
+	}
+	return 0, false
+}
+
+// PayloadSize returns the number of bytes carrying samples per channel.
+func PayloadSize(samples, channels int) int {
+	return samples * channels * 2
+}
+
+// Encode writes the interleaved samples of frame to payload in network byte
+// order and returns the payload length.
+func Encode(frame []int16, payload []byte) (int, error) {
+	if len(payload) < len(frame)*2 {
+		return 0, ErrShort
+	}
+	for i, s := range frame {
+		binary.BigEndian.PutUint16(payload[2*i:], uint16(s))
+	}
+	return len(frame) * 2, nil
+}
+
+// Decode reads the samples of payload into frame and returns the number of
+// interleaved samples.
+func Decode(payload []byte, frame []int16) (int, error) {
+	if len(payload)%2 != 0 {
+		return 0, ErrPayloadSize
+	}
+	n := len(payload) / 2
+	if len(frame) < n {
+		return 0, ErrShort
+	}
+	for i := range frame[:n] {
+		frame[i] = int16(binary.BigEndian.Uint16(payload[2*i:]))
+	}
+	return n, nil
+}
+
+// Samples returns the number of samples per channel in payload, which is also
+// the number of RTP timestamp ticks it covers.
+func Samples(payload []byte, channels int) (int, error) {
+	if channels < 1 {
+		return 0, ErrChannels
+	}
+	if len(payload)%(2*channels) != 0 {
+		return 0, ErrPayloadSize
+	}
+	return len(payload) / (2 * channels), nil
+}
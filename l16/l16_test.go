@@ -0,0 +1,53 @@
+package l16
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	frame := []int16{0, 1, -1, 0x1234, -32768, 32767}
+	payload := make([]byte, PayloadSize(3, 2))
+	n, err := Encode(frame, payload)
+	if err != nil || n != 12 {
+		t.Fatal(n, err)
+	}
+	want := []byte{0, 0, 0, 1, 0xff, 0xff, 0x12, 0x34, 0x80, 0, 0x7f, 0xff}
+	if !bytes.Equal(payload, want) {
+		t.Fatalf("%x", payload)
+	}
+	if samples, err := Samples(payload, 2); err != nil || samples != 3 {
+		t.Fatal(samples, err)
+	}
+
+	decoded := make([]int16, len(frame))
+	if n, err = Decode(payload, decoded); err != nil || n != len(frame) {
+		t.Fatal(n, err)
+	}
+	for i := range frame {
+		if decoded[i] != frame[i] {
+			t.Fatalf("sample %d is %d, want %d", i, decoded[i], frame[i])
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Encode(make([]int16, 2), make([]byte, 3)); err != ErrShort {
+		t.Fatal(err)
+	}
+	if _, err := Decode(make([]byte, 3), make([]int16, 2)); err != ErrPayloadSize {
+		t.Fatal(err)
+	}
+	if _, err := Decode(make([]byte, 4), make([]int16, 1)); err != ErrShort {
+		t.Fatal(err)
+	}
+	if _, err := Samples(make([]byte, 6), 2); err != ErrPayloadSize {
+		t.Fatal(err)
+	}
+	if pt, ok := PayloadType(44100, 2); !ok || pt != PayloadTypeStereo {
+		t.Fatal(pt, ok)
+	}
+	if _, ok := PayloadType(48000, 1); ok {
+		t.Fatal("48000Hz has no static payload type")
+	}
+}
@@ -0,0 +1,400 @@
+package opus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMinBitrate   = 6000
+	DefaultMaxBitrate   = 64000
+	DefaultStartBitrate = 32000
+
+	defaultLogSize = 64
+)
+
+var (
+	ErrNilEncoder = errors.New("nil encoder")
+)
+
+// ControllerConfig tunes a Controller. Zero values are replaced with defaults.
+type ControllerConfig struct {
+	// Bitrate range. A bandwidth estimate below MinBitrate takes priority.
+	MinBitrate   int
+	MaxBitrate   int
+	StartBitrate int
+
+	// Smoothed loss percentage above which the bitrate is cut.
+	DecreaseLoss float64
+	// Smoothed loss percentage below which the bitrate may grow.
+	IncreaseLoss float64
+	// Multiplicative increase applied once per HoldTime while loss is low.
+	IncreaseRate float64
+	// Minimum time between a decrease and the next increase.
+	HoldTime time.Duration
+	// Round trip time above which the bitrate is not increased.
+	MaxRTT time.Duration
+
+	// Inband FEC is enabled once the smoothed loss reaches FECOnLoss and
+	// disabled once it drops below FECOffLoss.
+	FECOnLoss  float64
+	FECOffLoss float64
+
+	// Weight of the newest loss sample in the smoothed loss (0-1).
+	LossSmoothing float64
+
+	// Number of decisions kept for debugging.
+	LogSize int
+}
+
+func (c *ControllerConfig) setDefaults() {
+	if c.MinBitrate <= 0 {
+		c.MinBitrate = DefaultMinBitrate
+	}
+	if c.MaxBitrate <= 0 {
+		c.MaxBitrate = DefaultMaxBitrate
+	}
+	if c.MaxBitrate < c.MinBitrate {
+		c.MaxBitrate = c.MinBitrate
+	}
+	if c.StartBitrate <= 0 {
+		c.StartBitrate = DefaultStartBitrate
+	}
+	if c.DecreaseLoss <= 0 {
+		c.DecreaseLoss = 10
+	}
+	if c.IncreaseLoss <= 0 {
+		c.IncreaseLoss = 2
+	}
+	if c.IncreaseRate <= 1 {
+		c.IncreaseRate = 1.08
+	}
+	if c.HoldTime <= 0 {
+		c.HoldTime = time.Second * 2
+	}
+	if c.MaxRTT <= 0 {
+		c.MaxRTT = time.Millisecond * 400
+	}
+	if c.FECOnLoss <= 0 {
+		c.FECOnLoss = 2
+	}
+	if c.FECOffLoss <= 0 || c.FECOffLoss > c.FECOnLoss {
+		c.FECOffLoss = c.FECOnLoss / 4
+	}
+	if c.LossSmoothing <= 0 || c.LossSmoothing > 1 {
+		c.LossSmoothing = 0.3
+	}
+	if c.LogSize <= 0 {
+		c.LogSize = defaultLogSize
+	}
+}
+
+// Decision records a change the Controller applied to the Encoder along with
+// the inputs that caused it.
+type Decision struct {
+	At     time.Time
+	Reason string
+
+	// Inputs.
+	Loss     float64 // Smoothed loss percentage.
+	RTT      time.Duration
+	Estimate int // Latest REMB / TWCC estimate in bits per second, 0 if none.
+
+	// Encoder settings after the decision.
+	Bitrate        int
+	FEC            bool
+	PacketLossPerc int
+	MaxBandwidth   Bandwidth
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%s %s: loss=%.1f%% rtt=%v estimate=%d bitrate=%d fec=%t loss_perc=%d max_bw=%d",
+		d.At.Format("15:04:05.000"), d.Reason, d.Loss, d.RTT, d.Estimate,
+		d.Bitrate, d.FEC, d.PacketLossPerc, d.MaxBandwidth)
+}
+
+// Controller adapts an Encoder's bitrate, inband FEC, expected packet loss and
+// maximum bandwidth at runtime from RTCP receiver report loss and round trip
+// time, and optionally from a bandwidth estimate (REMB or TWCC).
+//
+// The bitrate is cut in proportion to the loss once it is above DecreaseLoss
+// and grows slowly once it is below IncreaseLoss, at most once per HoldTime.
+// FEC and maximum bandwidth switch with hysteresis so they don't flap when
+// the inputs sit on a threshold.
+type Controller struct {
+	enc    *Encoder
+	config ControllerConfig
+
+	loss         float64
+	rtt          time.Duration
+	estimate     int
+	hasLoss      bool
+	lastDecrease time.Time
+	lastIncrease time.Time
+
+	bitrate      int
+	fec          bool
+	lossPerc     int
+	maxBandwidth Bandwidth
+
+	log      []Decision
+	logIndex int
+	logCount int
+
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+// NewController creates a Controller and applies the start bitrate to enc.
+func NewController(enc *Encoder, config ControllerConfig) (*Controller, error) {
+	if enc == nil {
+		return nil, ErrNilEncoder
+	}
+	config.setDefaults()
+	c := &Controller{
+		enc:    enc,
+		config: config,
+		log:    make([]Decision, config.LogSize),
+		now:    time.Now,
+	}
+	c.bitrate = clampBitrate(config.StartBitrate, config.MinBitrate, config.MaxBitrate)
+	c.maxBandwidth = bandwidthFor(c.bitrate, Fullband)
+
+	if err := enc.SetBitrate(c.bitrate); err != nil {
+		return nil, err
+	}
+	if err := enc.SetInBandFEC(false); err != nil {
+		return nil, err
+	}
+	if err := enc.SetPacketLossPerc(0); err != nil {
+		return nil, err
+	}
+	if err := enc.SetMaxBandwidth(c.maxBandwidth); err != nil {
+		return nil, err
+	}
+	c.record(c.now(), "start")
+	return c, nil
+}
+
+// Bitrate returns the bitrate currently applied to the Encoder.
+func (c *Controller) Bitrate() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bitrate
+}
+
+// FEC reports whether inband FEC is currently enabled on the Encoder.
+func (c *Controller) FEC() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fec
+}
+
+// Loss returns the smoothed loss percentage.
+func (c *Controller) Loss() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loss
+}
+
+// Decisions returns the most recent decisions, oldest first.
+func (c *Controller) Decisions() []Decision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Decision, 0, c.logCount)
+	start := c.logIndex - c.logCount
+	for i := 0; i < c.logCount; i++ {
+		idx := (start + i + len(c.log)) % len(c.log)
+		out = append(out, c.log[idx])
+	}
+	return out
+}
+
+// OnReceiverReport feeds the fraction lost field of an RTCP receiver report
+// block (loss * 256) and the round trip time computed from its LSR and DLSR
+// fields. rtt may be 0 when unknown.
+func (c *Controller) OnReceiverReport(fractionLost uint8, rtt time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	loss := float64(fractionLost) * 100 / 256
+	if !c.hasLoss {
+		c.loss = loss
+		c.hasLoss = true
+	} else {
+		a := c.config.LossSmoothing
+		c.loss = c.loss*(1-a) + loss*a
+	}
+	if rtt > 0 {
+		c.rtt = rtt
+	}
+	return c.update("receiver report")
+}
+
+// OnEstimate feeds a receiver side bandwidth estimate (REMB or TWCC) in bits
+// per second. The bitrate never exceeds the latest estimate, even when it is
+// below MinBitrate.
+func (c *Controller) OnEstimate(bitrate int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.estimate = bitrate
+	return c.update("estimate")
+}
+
+func (c *Controller) update(source string) error {
+	now := c.now()
+	config := &c.config
+	bitrate := c.bitrate
+	reason := ""
+
+	switch {
+	case c.loss > config.DecreaseLoss:
+		bitrate = int(float64(bitrate) * (1 - 0.5*c.loss/100))
+		c.lastDecrease = now
+		reason = "loss decrease"
+	case c.loss < config.IncreaseLoss &&
+		(c.rtt == 0 || c.rtt <= config.MaxRTT) &&
+		now.Sub(c.lastDecrease) >= config.HoldTime &&
+		now.Sub(c.lastIncrease) >= config.HoldTime:
+		bitrate = int(float64(bitrate)*config.IncreaseRate) + 1000
+		c.lastIncrease = now
+		reason = "increase"
+	}
+
+	bitrate = clampBitrate(bitrate, config.MinBitrate, config.MaxBitrate)
+	if c.estimate > 0 && bitrate > c.estimate {
+		bitrate = c.estimate
+		// The cap is only a reason when it changes the bitrate.
+		switch {
+		case bitrate == c.bitrate:
+			// It held back an increase.
+			reason = ""
+		case reason == "loss decrease":
+			reason = joinReason(reason, "estimate cap")
+		default:
+			reason = "estimate cap"
+		}
+	}
+
+	fec := c.fec
+	if !fec && c.loss >= config.FECOnLoss {
+		fec = true
+	} else if fec && c.loss < config.FECOffLoss {
+		fec = false
+	}
+
+	lossPerc := int(c.loss + 0.5)
+	if lossPerc > 100 {
+		lossPerc = 100
+	}
+
+	maxBandwidth := bandwidthFor(bitrate, c.maxBandwidth)
+
+	changed := false
+	if bitrate != c.bitrate {
+		if err := c.enc.SetBitrate(bitrate); err != nil {
+			return err
+		}
+		c.bitrate = bitrate
+		changed = true
+	}
+	if fec != c.fec {
+		if err := c.enc.SetInBandFEC(fec); err != nil {
+			return err
+		}
+		c.fec = fec
+		if fec {
+			reason = joinReason(reason, "fec on")
+		} else {
+			reason = joinReason(reason, "fec off")
+		}
+		changed = true
+	}
+	if lossPerc != c.lossPerc {
+		if err := c.enc.SetPacketLossPerc(lossPerc); err != nil {
+			return err
+		}
+		c.lossPerc = lossPerc
+		changed = true
+	}
+	if maxBandwidth != c.maxBandwidth {
+		if err := c.enc.SetMaxBandwidth(maxBandwidth); err != nil {
+			return err
+		}
+		c.maxBandwidth = maxBandwidth
+		reason = joinReason(reason, "bandwidth")
+		changed = true
+	}
+
+	if changed {
+		if reason == "" {
+			reason = "loss"
+		}
+		c.record(now, source+": "+reason)
+	}
+	return nil
+}
+
+func (c *Controller) record(at time.Time, reason string) {
+	c.log[c.logIndex%len(c.log)] = Decision{
+		At:             at,
+		Reason:         reason,
+		Loss:           c.loss,
+		RTT:            c.rtt,
+		Estimate:       c.estimate,
+		Bitrate:        c.bitrate,
+		FEC:            c.fec,
+		PacketLossPerc: c.lossPerc,
+		MaxBandwidth:   c.maxBandwidth,
+	}
+	c.logIndex = (c.logIndex + 1) % len(c.log)
+	if c.logCount < len(c.log) {
+		c.logCount++
+	}
+}
+
+func joinReason(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + ", " + b
+}
+
+func clampBitrate(bitrate, min, max int) int {
+	if bitrate < min {
+		return min
+	}
+	if bitrate > max {
+		return max
+	}
+	return bitrate
+}
+
+// Bitrate at which each bandwidth becomes useful for speech.
+var bandwidthThresholds = []struct {
+	bitrate   int
+	bandwidth Bandwidth
+}{
+	{0, Narrowband},
+	{12000, Wideband},
+	{20000, SuperWideband},
+	{28000, Fullband},
+}
+
+// bandwidthFor selects the maximum bandwidth for a bitrate. Moving up requires
+// the bitrate to clear the threshold by 10% so the bandwidth doesn't flap.
+func bandwidthFor(bitrate int, current Bandwidth) Bandwidth {
+	selected := Narrowband
+	for _, t := range bandwidthThresholds {
+		if bitrate < t.bitrate {
+			break
+		}
+		if t.bandwidth > current && bitrate < t.bitrate+t.bitrate/10 {
+			break
+		}
+		selected = t.bandwidth
+	}
+	return selected
+}
@@ -0,0 +1,205 @@
+package opus
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestController(t *testing.T, config ControllerConfig) (*Controller, *Encoder, *time.Time) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	c, err := NewController(enc, config)
+	if err != nil {
+		t.Fatalf("Error creating controller: %v", err)
+	}
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	return c, enc, &now
+}
+
+func TestController_LossDecrease(t *testing.T) {
+	c, enc, _ := newTestController(t, ControllerConfig{})
+	if c.Bitrate() != DefaultStartBitrate {
+		t.Fatalf("Unexpected start bitrate %d", c.Bitrate())
+	}
+
+	// 25% loss.
+	if err := c.OnReceiverReport(64, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() >= DefaultStartBitrate {
+		t.Errorf("Expected bitrate to drop, got %d", c.Bitrate())
+	}
+	if !c.FEC() {
+		t.Errorf("Expected FEC to be enabled")
+	}
+	bitrate, err := enc.Bitrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bitrate != c.Bitrate() {
+		t.Errorf("Encoder bitrate %d does not match controller %d", bitrate, c.Bitrate())
+	}
+	fec, err := enc.InBandFEC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fec {
+		t.Errorf("Expected encoder FEC to be enabled")
+	}
+	perc, err := enc.PacketLossPerc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perc != 25 {
+		t.Errorf("Unexpected packet loss percentage %d", perc)
+	}
+
+	decisions := c.Decisions()
+	if len(decisions) != 2 || decisions[0].Reason != "start" {
+		t.Fatalf("Unexpected decisions %v", decisions)
+	}
+}
+
+func TestController_Increase(t *testing.T) {
+	c, _, now := newTestController(t, ControllerConfig{StartBitrate: 10000})
+	if err := c.OnReceiverReport(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	first := c.Bitrate()
+	if first <= 10000 {
+		t.Fatalf("Expected bitrate to grow, got %d", first)
+	}
+
+	// Held until HoldTime has passed.
+	if err := c.OnReceiverReport(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() != first {
+		t.Errorf("Expected bitrate to hold at %d, got %d", first, c.Bitrate())
+	}
+
+	*now = now.Add(3 * time.Second)
+	if err := c.OnReceiverReport(0, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() != first {
+		t.Errorf("Expected high RTT to prevent increase, got %d", c.Bitrate())
+	}
+
+	if err := c.OnReceiverReport(0, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() <= first {
+		t.Errorf("Expected bitrate to grow past %d, got %d", first, c.Bitrate())
+	}
+}
+
+func TestController_FECHysteresis(t *testing.T) {
+	c, _, _ := newTestController(t, ControllerConfig{LossSmoothing: 1})
+
+	// 3% loss enables FEC without cutting the bitrate.
+	if err := c.OnReceiverReport(8, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !c.FEC() {
+		t.Fatalf("Expected FEC to be enabled")
+	}
+	// 1% loss is below FECOnLoss but above FECOffLoss.
+	if err := c.OnReceiverReport(3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !c.FEC() {
+		t.Errorf("Expected FEC to stay enabled")
+	}
+	if err := c.OnReceiverReport(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.FEC() {
+		t.Errorf("Expected FEC to be disabled")
+	}
+}
+
+func TestController_Estimate(t *testing.T) {
+	c, enc, _ := newTestController(t, ControllerConfig{})
+	if err := c.OnEstimate(16000); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() != 16000 {
+		t.Errorf("Expected bitrate to be capped at 16000, got %d", c.Bitrate())
+	}
+	bw, err := enc.MaxBandwidth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bw != Wideband {
+		t.Errorf("Unexpected max bandwidth %d", bw)
+	}
+}
+
+func TestController_EstimateBelowMin(t *testing.T) {
+	c, _, _ := newTestController(t, ControllerConfig{MinBitrate: 8000})
+	if err := c.OnEstimate(5000); err != nil {
+		t.Fatal(err)
+	}
+	decisions := c.Decisions()
+	if reason := decisions[len(decisions)-1].Reason; c.Bitrate() != 5000 || !strings.HasPrefix(reason, "estimate: estimate cap") {
+		t.Fatalf("Unexpected bitrate %d, reason %q", c.Bitrate(), reason)
+	}
+}
+
+func TestController_EstimateReason(t *testing.T) {
+	c, _, now := newTestController(t, ControllerConfig{StartBitrate: 30000, LossSmoothing: 1})
+	last := func() string {
+		decisions := c.Decisions()
+		return decisions[len(decisions)-1].Reason
+	}
+
+	if err := c.OnEstimate(20000); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() != 20000 || !strings.HasPrefix(last(), "estimate: estimate cap") {
+		t.Fatalf("Unexpected bitrate %d, reason %q", c.Bitrate(), last())
+	}
+
+	// An increase held back by the cap leaves the bitrate alone.
+	*now = now.Add(3 * time.Second)
+	if err := c.OnReceiverReport(3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() != 20000 || last() != "receiver report: loss" {
+		t.Fatalf("Unexpected bitrate %d, reason %q", c.Bitrate(), last())
+	}
+
+	// A decrease for loss is still reported when the cap lowers it further.
+	if err := c.OnEstimate(40000); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.OnReceiverReport(64, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(last(), "receiver report: loss decrease, fec on") {
+		t.Fatalf("Unexpected reason %q", last())
+	}
+	if err := c.OnEstimate(10000); err != nil {
+		t.Fatal(err)
+	}
+	if c.Bitrate() != 10000 || !strings.HasPrefix(last(), "estimate: loss decrease, estimate cap") {
+		t.Fatalf("Unexpected bitrate %d, reason %q", c.Bitrate(), last())
+	}
+}
+
+func TestBandwidthFor(t *testing.T) {
+	if bw := bandwidthFor(20500, Wideband); bw != Wideband {
+		t.Errorf("Expected hysteresis to hold Wideband, got %d", bw)
+	}
+	if bw := bandwidthFor(20500, SuperWideband); bw != SuperWideband {
+		t.Errorf("Expected SuperWideband to be kept, got %d", bw)
+	}
+	if bw := bandwidthFor(19000, SuperWideband); bw != Wideband {
+		t.Errorf("Expected Wideband below threshold, got %d", bw)
+	}
+}
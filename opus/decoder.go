@@ -17,6 +17,54 @@ bridge_decoder_get_last_packet_duration(OpusDecoder *st, opus_int32 *samples)
 {
 	return opus_decoder_ctl(st, OPUS_GET_LAST_PACKET_DURATION(samples));
 }
+
+int
+bridge_decoder_set_gain(OpusDecoder *st, opus_int32 gain)
+{
+	return opus_decoder_ctl(st, OPUS_SET_GAIN(gain));
+}
+
+int
+bridge_decoder_get_gain(OpusDecoder *st, opus_int32 *gain)
+{
+	return opus_decoder_ctl(st, OPUS_GET_GAIN(gain));
+}
+
+int
+bridge_decoder_set_phase_inversion_disabled(OpusDecoder *st, opus_int32 phase_inversion_disabled)
+{
+	return opus_decoder_ctl(st, OPUS_SET_PHASE_INVERSION_DISABLED(phase_inversion_disabled));
+}
+
+int
+bridge_decoder_get_phase_inversion_disabled(OpusDecoder *st, opus_int32 *phase_inversion_disabled)
+{
+	return opus_decoder_ctl(st, OPUS_GET_PHASE_INVERSION_DISABLED(phase_inversion_disabled));
+}
+
+int
+bridge_decoder_get_pitch(OpusDecoder *st, opus_int32 *pitch)
+{
+	return opus_decoder_ctl(st, OPUS_GET_PITCH(pitch));
+}
+
+int
+bridge_decoder_get_bandwidth(OpusDecoder *st, opus_int32 *bandwidth)
+{
+	return opus_decoder_ctl(st, OPUS_GET_BANDWIDTH(bandwidth));
+}
+
+int
+bridge_decoder_get_final_range(OpusDecoder *st, opus_uint32 *final_range)
+{
+	return opus_decoder_ctl(st, OPUS_GET_FINAL_RANGE(final_range));
+}
+
+int
+bridge_decoder_get_sample_rate(OpusDecoder *st, opus_int32 *sample_rate)
+{
+	return opus_decoder_ctl(st, OPUS_GET_SAMPLE_RATE(sample_rate));
+}
 */
 import "C"
 
@@ -145,7 +193,8 @@ func (dec *Decoder) DecodeFloat32(data []byte, pcm []float32) (int, error) {
 
 // DecodeFEC encoded Opus data into the supplied buffer with forward error
 // correction. It is to be used on the packet directly following the lost one.
-// The supplied buffer needs to be exactly the duration of audio that is missing
+// The supplied buffer needs to be exactly the duration of audio that is
+// missing: its length, not its capacity, sets the duration decoded.
 func (dec *Decoder) DecodeFEC(data []byte, pcm []int16) error {
 	if dec.p == nil {
 		return errDecUninitialized
@@ -156,15 +205,15 @@ func (dec *Decoder) DecodeFEC(data []byte, pcm []int16) error {
 	if len(pcm) == 0 {
 		return fmt.Errorf("opus: target buffer empty")
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	if len(pcm)%dec.channels != 0 {
+		return fmt.Errorf("opus: target buffer length must be multiple of channels")
 	}
 	n := int(C.opus_decode(
 		dec.p,
 		(*C.uchar)(&data[0]),
 		C.opus_int32(len(data)),
 		(*C.opus_int16)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(len(pcm)/dec.channels),
 		1))
 	if n < 0 {
 		return Error(n)
@@ -172,9 +221,35 @@ func (dec *Decoder) DecodeFEC(data []byte, pcm []int16) error {
 	return nil
 }
 
+// DecodePLC conceals a lost packet. The supplied buffer needs to be exactly
+// the duration of audio that is missing and a multiple of 2.5ms.
+func (dec *Decoder) DecodePLC(pcm []int16) error {
+	if dec.p == nil {
+		return errDecUninitialized
+	}
+	if len(pcm) == 0 {
+		return fmt.Errorf("opus: target buffer empty")
+	}
+	if len(pcm)%dec.channels != 0 {
+		return fmt.Errorf("opus: target buffer length must be multiple of channels")
+	}
+	n := int(C.opus_decode(
+		dec.p,
+		nil,
+		0,
+		(*C.opus_int16)(&pcm[0]),
+		C.int(len(pcm)/dec.channels),
+		0))
+	if n < 0 {
+		return Error(n)
+	}
+	return nil
+}
+
 // DecodeFECFloat32 encoded Opus data into the supplied buffer with forward error
 // correction. It is to be used on the packet directly following the lost one.
-// The supplied buffer needs to be exactly the duration of audio that is missing
+// The supplied buffer needs to be exactly the duration of audio that is
+// missing: its length, not its capacity, sets the duration decoded.
 func (dec *Decoder) DecodeFECFloat32(data []byte, pcm []float32) error {
 	if dec.p == nil {
 		return errDecUninitialized
@@ -185,15 +260,15 @@ func (dec *Decoder) DecodeFECFloat32(data []byte, pcm []float32) error {
 	if len(pcm) == 0 {
 		return fmt.Errorf("opus: target buffer empty")
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	if len(pcm)%dec.channels != 0 {
+		return fmt.Errorf("opus: target buffer length must be multiple of channels")
 	}
 	n := int(C.opus_decode_float(
 		dec.p,
 		(*C.uchar)(&data[0]),
 		C.opus_int32(len(data)),
 		(*C.float)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(len(pcm)/dec.channels),
 		1))
 	if n < 0 {
 		return Error(n)
@@ -211,3 +286,88 @@ func (dec *Decoder) LastPacketDuration() (int, error) {
 	}
 	return int(samples), nil
 }
+
+// SetGain configures the decoder output gain in Q8 dB units (-32768 to 32767).
+func (dec *Decoder) SetGain(gain int) error {
+	res := C.bridge_decoder_set_gain(dec.p, C.opus_int32(gain))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// Gain gets the decoder's configured output gain in Q8 dB units.
+func (dec *Decoder) Gain() (int, error) {
+	var gain C.opus_int32
+	res := C.bridge_decoder_get_gain(dec.p, &gain)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return int(gain), nil
+}
+
+// SetPhaseInversionDisabled disables the use of phase inversion for intensity
+// stereo.
+func (dec *Decoder) SetPhaseInversionDisabled(disabled bool) error {
+	i := 0
+	if disabled {
+		i = 1
+	}
+	res := C.bridge_decoder_set_phase_inversion_disabled(dec.p, C.opus_int32(i))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// PhaseInversionDisabled reports whether phase inversion is disabled.
+func (dec *Decoder) PhaseInversionDisabled() (bool, error) {
+	var disabled C.opus_int32
+	res := C.bridge_decoder_get_phase_inversion_disabled(dec.p, &disabled)
+	if res != C.OPUS_OK {
+		return false, Error(res)
+	}
+	return disabled != 0, nil
+}
+
+// Pitch gets the pitch period of the last decoded frame in samples at 48Khz,
+// or 0 if not available.
+func (dec *Decoder) Pitch() (int, error) {
+	var pitch C.opus_int32
+	res := C.bridge_decoder_get_pitch(dec.p, &pitch)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return int(pitch), nil
+}
+
+// Bandwidth gets the bandpass of the last decoded packet.
+func (dec *Decoder) Bandwidth() (Bandwidth, error) {
+	var bw C.opus_int32
+	res := C.bridge_decoder_get_bandwidth(dec.p, &bw)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return Bandwidth(bw), nil
+}
+
+// FinalRange gets the final state of the codec's entropy coder after decoding
+// the last packet.
+func (dec *Decoder) FinalRange() (uint32, error) {
+	var rng C.opus_uint32
+	res := C.bridge_decoder_get_final_range(dec.p, &rng)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return uint32(rng), nil
+}
+
+// SampleRate returns the decoder sample rate in Hz.
+func (dec *Decoder) SampleRate() (int, error) {
+	var sr C.opus_int32
+	res := C.bridge_decoder_get_sample_rate(dec.p, &sr)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return int(sr), nil
+}
@@ -66,3 +66,99 @@ func TestDecoder_GetLastPacketDuration(t *testing.T) {
 		t.Fatalf("Wrong duration length. Expected %d. Got %d", n, samples)
 	}
 }
+
+func TestDecoder_SetGetGain(t *testing.T) {
+	dec, err := NewDecoder(48000, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	vals := []int{-32768, -256, 0, 256, 32767}
+	for _, gain := range vals {
+		if err := dec.SetGain(gain); err != nil {
+			t.Error("Error setting gain:", err)
+		}
+		got, err := dec.Gain()
+		if err != nil {
+			t.Error("Error getting gain", err)
+		}
+		if got != gain {
+			t.Errorf("Unexpected gain. Got %d, but expected %d", got, gain)
+		}
+	}
+	if err := dec.SetGain(32768); err != ErrBadArg {
+		t.Errorf("Expected ErrBadArg for an invalid gain, got %v", err)
+	}
+}
+
+func TestDecoder_SetGetPhaseInversionDisabled(t *testing.T) {
+	dec, err := NewDecoder(48000, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	vals := []bool{true, false}
+	for _, disabled := range vals {
+		if err := dec.SetPhaseInversionDisabled(disabled); err != nil {
+			t.Fatalf("Error setting phase inversion disabled to %t: %v", disabled, err)
+		}
+		gotv, err := dec.PhaseInversionDisabled()
+		if err != nil {
+			t.Fatalf("Error getting phase inversion disabled (%t): %v", disabled, err)
+		}
+		if gotv != disabled {
+			t.Errorf("Error set phase inversion disabled: expect %v, got %v", disabled, gotv)
+		}
+	}
+}
+
+func TestDecoder_PacketInfo(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 16000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	pcm := make([]int16, FRAME_SIZE)
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetBandwidth(Wideband); err != nil {
+		t.Fatal(err)
+	}
+	addSine(pcm, SAMPLE_RATE, G4)
+
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	sampleRate, err := dec.SampleRate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sampleRate != SAMPLE_RATE {
+		t.Errorf("Unexpected sample rate. Got %d, but expected %d", sampleRate, SAMPLE_RATE)
+	}
+
+	data := make([]byte, 1000)
+	for i := 0; i < 10; i++ {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		if _, err = dec.Decode(data[:n], pcm); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+	}
+
+	bw, err := dec.Bandwidth()
+	if err != nil {
+		t.Fatal("Error getting bandwidth", err)
+	}
+	if bw != Wideband {
+		t.Errorf("Unexpected bandwidth. Got %d, but expected %d", bw, Wideband)
+	}
+	pitch, err := dec.Pitch()
+	if err != nil {
+		t.Fatal("Error getting pitch", err)
+	}
+	if pitch < 0 {
+		t.Errorf("Unexpected pitch %d", pitch)
+	}
+}
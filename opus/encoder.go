@@ -91,6 +91,144 @@ bridge_encoder_get_packet_loss_perc(OpusEncoder *st, opus_int32 *loss_perc)
 	return opus_encoder_ctl(st, OPUS_GET_PACKET_LOSS_PERC(loss_perc));
 }
 
+int
+bridge_encoder_set_vbr(OpusEncoder *st, opus_int32 vbr)
+{
+	return opus_encoder_ctl(st, OPUS_SET_VBR(vbr));
+}
+
+int
+bridge_encoder_get_vbr(OpusEncoder *st, opus_int32 *vbr)
+{
+	return opus_encoder_ctl(st, OPUS_GET_VBR(vbr));
+}
+
+int
+bridge_encoder_set_vbr_constraint(OpusEncoder *st, opus_int32 vbr_constraint)
+{
+	return opus_encoder_ctl(st, OPUS_SET_VBR_CONSTRAINT(vbr_constraint));
+}
+
+int
+bridge_encoder_get_vbr_constraint(OpusEncoder *st, opus_int32 *vbr_constraint)
+{
+	return opus_encoder_ctl(st, OPUS_GET_VBR_CONSTRAINT(vbr_constraint));
+}
+
+int
+bridge_encoder_set_signal(OpusEncoder *st, opus_int32 signal)
+{
+	return opus_encoder_ctl(st, OPUS_SET_SIGNAL(signal));
+}
+
+int
+bridge_encoder_get_signal(OpusEncoder *st, opus_int32 *signal)
+{
+	return opus_encoder_ctl(st, OPUS_GET_SIGNAL(signal));
+}
+
+int
+bridge_encoder_set_force_channels(OpusEncoder *st, opus_int32 force_channels)
+{
+	return opus_encoder_ctl(st, OPUS_SET_FORCE_CHANNELS(force_channels));
+}
+
+int
+bridge_encoder_get_force_channels(OpusEncoder *st, opus_int32 *force_channels)
+{
+	return opus_encoder_ctl(st, OPUS_GET_FORCE_CHANNELS(force_channels));
+}
+
+int
+bridge_encoder_set_lsb_depth(OpusEncoder *st, opus_int32 lsb_depth)
+{
+	return opus_encoder_ctl(st, OPUS_SET_LSB_DEPTH(lsb_depth));
+}
+
+int
+bridge_encoder_get_lsb_depth(OpusEncoder *st, opus_int32 *lsb_depth)
+{
+	return opus_encoder_ctl(st, OPUS_GET_LSB_DEPTH(lsb_depth));
+}
+
+int
+bridge_encoder_set_prediction_disabled(OpusEncoder *st, opus_int32 prediction_disabled)
+{
+	return opus_encoder_ctl(st, OPUS_SET_PREDICTION_DISABLED(prediction_disabled));
+}
+
+int
+bridge_encoder_get_prediction_disabled(OpusEncoder *st, opus_int32 *prediction_disabled)
+{
+	return opus_encoder_ctl(st, OPUS_GET_PREDICTION_DISABLED(prediction_disabled));
+}
+
+int
+bridge_encoder_set_expert_frame_duration(OpusEncoder *st, opus_int32 expert_frame_duration)
+{
+	return opus_encoder_ctl(st, OPUS_SET_EXPERT_FRAME_DURATION(expert_frame_duration));
+}
+
+int
+bridge_encoder_get_expert_frame_duration(OpusEncoder *st, opus_int32 *expert_frame_duration)
+{
+	return opus_encoder_ctl(st, OPUS_GET_EXPERT_FRAME_DURATION(expert_frame_duration));
+}
+
+int
+bridge_encoder_set_bandwidth(OpusEncoder *st, opus_int32 bandwidth)
+{
+	return opus_encoder_ctl(st, OPUS_SET_BANDWIDTH(bandwidth));
+}
+
+int
+bridge_encoder_get_bandwidth(OpusEncoder *st, opus_int32 *bandwidth)
+{
+	return opus_encoder_ctl(st, OPUS_GET_BANDWIDTH(bandwidth));
+}
+
+int
+bridge_encoder_set_application(OpusEncoder *st, opus_int32 application)
+{
+	return opus_encoder_ctl(st, OPUS_SET_APPLICATION(application));
+}
+
+int
+bridge_encoder_get_application(OpusEncoder *st, opus_int32 *application)
+{
+	return opus_encoder_ctl(st, OPUS_GET_APPLICATION(application));
+}
+
+int
+bridge_encoder_set_phase_inversion_disabled(OpusEncoder *st, opus_int32 phase_inversion_disabled)
+{
+	return opus_encoder_ctl(st, OPUS_SET_PHASE_INVERSION_DISABLED(phase_inversion_disabled));
+}
+
+int
+bridge_encoder_get_phase_inversion_disabled(OpusEncoder *st, opus_int32 *phase_inversion_disabled)
+{
+	return opus_encoder_ctl(st, OPUS_GET_PHASE_INVERSION_DISABLED(phase_inversion_disabled));
+}
+
+int
+bridge_encoder_get_lookahead(OpusEncoder *st, opus_int32 *lookahead)
+{
+	return opus_encoder_ctl(st, OPUS_GET_LOOKAHEAD(lookahead));
+}
+
+int
+bridge_encoder_get_final_range(OpusEncoder *st, opus_uint32 *final_range)
+{
+	return opus_encoder_ctl(st, OPUS_GET_FINAL_RANGE(final_range));
+}
+
+int
+bridge_encoder_get_in_dtx(OpusEncoder *st, opus_int32 *in_dtx)
+{
+	return opus_encoder_ctl(st, OPUS_GET_IN_DTX(in_dtx));
+}
+
 */
 import "C"
 
@@ -107,6 +245,59 @@ const (
 	SuperWideband = Bandwidth(C.OPUS_BANDWIDTH_SUPERWIDEBAND)
 	// 20 kHz passband
 	Fullband = Bandwidth(C.OPUS_BANDWIDTH_FULLBAND)
+	// Let the encoder select the bandpass
+	BandwidthAuto = Bandwidth(C.OPUS_AUTO)
+)
+
+// Signal is a hint about the type of signal being encoded.
+type Signal int
+
+const (
+	// Let the encoder detect the signal type
+	SignalAuto = Signal(C.OPUS_AUTO)
+	// Bias thresholds towards choosing LPC or Hybrid modes
+	SignalVoice = Signal(C.OPUS_SIGNAL_VOICE)
+	// Bias thresholds towards choosing MDCT modes
+	SignalMusic = Signal(C.OPUS_SIGNAL_MUSIC)
+)
+
+// Channels forces the encoder to code mono or stereo.
+type Channels int
+
+const (
+	// Let the encoder choose based on the input
+	ChannelsAuto = Channels(C.OPUS_AUTO)
+	// Force mono
+	ChannelsMono = Channels(1)
+	// Force stereo
+	ChannelsStereo = Channels(2)
+)
+
+// FrameDuration controls the duration of the frames produced by the encoder
+// independently of the size of the PCM passed to Encode.
+type FrameDuration int
+
+const (
+	// Use the size of the PCM passed to Encode (default)
+	FrameDurationArg = FrameDuration(C.OPUS_FRAMESIZE_ARG)
+	// 2.5ms frames
+	FrameDuration2dot5ms = FrameDuration(C.OPUS_FRAMESIZE_2_5_MS)
+	// 5ms frames
+	FrameDuration5ms = FrameDuration(C.OPUS_FRAMESIZE_5_MS)
+	// 10ms frames
+	FrameDuration10ms = FrameDuration(C.OPUS_FRAMESIZE_10_MS)
+	// 20ms frames
+	FrameDuration20ms = FrameDuration(C.OPUS_FRAMESIZE_20_MS)
+	// 40ms frames
+	FrameDuration40ms = FrameDuration(C.OPUS_FRAMESIZE_40_MS)
+	// 60ms frames
+	FrameDuration60ms = FrameDuration(C.OPUS_FRAMESIZE_60_MS)
+	// 80ms frames
+	FrameDuration80ms = FrameDuration(C.OPUS_FRAMESIZE_80_MS)
+	// 100ms frames
+	FrameDuration100ms = FrameDuration(C.OPUS_FRAMESIZE_100_MS)
+	// 120ms frames
+	FrameDuration120ms = FrameDuration(C.OPUS_FRAMESIZE_120_MS)
 )
 
 var errEncUninitialized = fmt.Errorf("opus encoder uninitialized")
@@ -385,3 +576,248 @@ func (enc *Encoder) PacketLossPerc() (int, error) {
 	}
 	return int(lossPerc), nil
 }
+
+// SetVBR configures the encoder's use of variable bitrate (VBR). When
+// disabled the encoder uses constant bitrate (CBR).
+func (enc *Encoder) SetVBR(vbr bool) error {
+	i := 0
+	if vbr {
+		i = 1
+	}
+	res := C.bridge_encoder_set_vbr(enc.p, C.opus_int32(i))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// VBR reports whether the encoder is configured to use variable bitrate.
+func (enc *Encoder) VBR() (bool, error) {
+	var vbr C.opus_int32
+	res := C.bridge_encoder_get_vbr(enc.p, &vbr)
+	if res != C.OPUS_OK {
+		return false, Error(res)
+	}
+	return vbr != 0, nil
+}
+
+// SetVBRConstraint configures constrained VBR (CVBR). Has no effect unless
+// VBR is enabled.
+func (enc *Encoder) SetVBRConstraint(constraint bool) error {
+	i := 0
+	if constraint {
+		i = 1
+	}
+	res := C.bridge_encoder_set_vbr_constraint(enc.p, C.opus_int32(i))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// VBRConstraint reports whether constrained VBR is enabled.
+func (enc *Encoder) VBRConstraint() (bool, error) {
+	var constraint C.opus_int32
+	res := C.bridge_encoder_get_vbr_constraint(enc.p, &constraint)
+	if res != C.OPUS_OK {
+		return false, Error(res)
+	}
+	return constraint != 0, nil
+}
+
+// SetSignal configures the type of signal being encoded.
+func (enc *Encoder) SetSignal(signal Signal) error {
+	res := C.bridge_encoder_set_signal(enc.p, C.opus_int32(signal))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// Signal gets the encoder's configured signal type.
+func (enc *Encoder) Signal() (Signal, error) {
+	var signal C.opus_int32
+	res := C.bridge_encoder_get_signal(enc.p, &signal)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return Signal(signal), nil
+}
+
+// SetForceChannels configures mono or stereo coding regardless of the input.
+func (enc *Encoder) SetForceChannels(channels Channels) error {
+	res := C.bridge_encoder_set_force_channels(enc.p, C.opus_int32(channels))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// ForceChannels gets the encoder's forced channel configuration.
+func (enc *Encoder) ForceChannels() (Channels, error) {
+	var channels C.opus_int32
+	res := C.bridge_encoder_get_force_channels(enc.p, &channels)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return Channels(channels), nil
+}
+
+// SetLSBDepth configures the depth of the signal being encoded in bits (8-24).
+func (enc *Encoder) SetLSBDepth(depth int) error {
+	res := C.bridge_encoder_set_lsb_depth(enc.p, C.opus_int32(depth))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// LSBDepth gets the encoder's configured signal depth in bits.
+func (enc *Encoder) LSBDepth() (int, error) {
+	var depth C.opus_int32
+	res := C.bridge_encoder_get_lsb_depth(enc.p, &depth)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return int(depth), nil
+}
+
+// SetPredictionDisabled disables almost all use of prediction, making frames
+// almost completely independent. This reduces quality.
+func (enc *Encoder) SetPredictionDisabled(disabled bool) error {
+	i := 0
+	if disabled {
+		i = 1
+	}
+	res := C.bridge_encoder_set_prediction_disabled(enc.p, C.opus_int32(i))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// PredictionDisabled reports whether prediction is disabled.
+func (enc *Encoder) PredictionDisabled() (bool, error) {
+	var disabled C.opus_int32
+	res := C.bridge_encoder_get_prediction_disabled(enc.p, &disabled)
+	if res != C.OPUS_OK {
+		return false, Error(res)
+	}
+	return disabled != 0, nil
+}
+
+// SetExpertFrameDuration configures the encoder's use of variable duration
+// frames.
+func (enc *Encoder) SetExpertFrameDuration(duration FrameDuration) error {
+	res := C.bridge_encoder_set_expert_frame_duration(enc.p, C.opus_int32(duration))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// ExpertFrameDuration gets the encoder's configured frame duration.
+func (enc *Encoder) ExpertFrameDuration() (FrameDuration, error) {
+	var duration C.opus_int32
+	res := C.bridge_encoder_get_expert_frame_duration(enc.p, &duration)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return FrameDuration(duration), nil
+}
+
+// SetBandwidth sets the encoder's bandpass to a specific value. Unlike
+// SetMaxBandwidth, the encoder will not choose a lower bandpass on its own.
+func (enc *Encoder) SetBandwidth(bw Bandwidth) error {
+	res := C.bridge_encoder_set_bandwidth(enc.p, C.opus_int32(bw))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// Bandwidth gets the bandpass the encoder used for the last encoded frame.
+func (enc *Encoder) Bandwidth() (Bandwidth, error) {
+	var bw C.opus_int32
+	res := C.bridge_encoder_get_bandwidth(enc.p, &bw)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return Bandwidth(bw), nil
+}
+
+// SetApplication changes the encoder's intended application.
+func (enc *Encoder) SetApplication(application Application) error {
+	res := C.bridge_encoder_set_application(enc.p, C.opus_int32(application))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// Application gets the encoder's configured application.
+func (enc *Encoder) Application() (Application, error) {
+	var application C.opus_int32
+	res := C.bridge_encoder_get_application(enc.p, &application)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return Application(application), nil
+}
+
+// SetPhaseInversionDisabled disables the use of phase inversion for intensity
+// stereo. This improves the quality of mono downmixes.
+func (enc *Encoder) SetPhaseInversionDisabled(disabled bool) error {
+	i := 0
+	if disabled {
+		i = 1
+	}
+	res := C.bridge_encoder_set_phase_inversion_disabled(enc.p, C.opus_int32(i))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// PhaseInversionDisabled reports whether phase inversion is disabled.
+func (enc *Encoder) PhaseInversionDisabled() (bool, error) {
+	var disabled C.opus_int32
+	res := C.bridge_encoder_get_phase_inversion_disabled(enc.p, &disabled)
+	if res != C.OPUS_OK {
+		return false, Error(res)
+	}
+	return disabled != 0, nil
+}
+
+// Lookahead gets the total samples of delay added by the encoder, at the
+// encoder's sample rate. The Ogg pre-skip is always counted at 48Khz, so scale
+// it by 48000 / sample rate.
+func (enc *Encoder) Lookahead() (int, error) {
+	var lookahead C.opus_int32
+	res := C.bridge_encoder_get_lookahead(enc.p, &lookahead)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return int(lookahead), nil
+}
+
+// FinalRange gets the final state of the codec's entropy coder. Matches the
+// decoder's FinalRange after decoding the same packet.
+func (enc *Encoder) FinalRange() (uint32, error) {
+	var rng C.opus_uint32
+	res := C.bridge_encoder_get_final_range(enc.p, &rng)
+	if res != C.OPUS_OK {
+		return 0, Error(res)
+	}
+	return uint32(rng), nil
+}
+
+// InDTX reports whether the last encoded frame was a DTX frame.
+func (enc *Encoder) InDTX() (bool, error) {
+	var inDTX C.opus_int32
+	res := C.bridge_encoder_get_in_dtx(enc.p, &inDTX)
+	if res != C.OPUS_OK {
+		return false, Error(res)
+	}
+	return inDTX != 0, nil
+}
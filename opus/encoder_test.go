@@ -335,3 +335,315 @@ func TestEncoder_SetGetInvalidPacketLossPerc(t *testing.T) {
 		}
 	}
 }
+
+func TestEncoder_SetGetVBR(t *testing.T) {
+	enc, err := NewEncoder(8000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []bool{false, true}
+	for _, vbr := range vals {
+		if err := enc.SetVBR(vbr); err != nil {
+			t.Fatalf("Error setting VBR to %t: %v", vbr, err)
+		}
+		gotv, err := enc.VBR()
+		if err != nil {
+			t.Fatalf("Error getting VBR (%t): %v", vbr, err)
+		}
+		if gotv != vbr {
+			t.Errorf("Error set vbr: expect vbr=%v, got vbr=%v", vbr, gotv)
+		}
+	}
+}
+
+func TestEncoder_SetGetVBRConstraint(t *testing.T) {
+	enc, err := NewEncoder(8000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []bool{false, true}
+	for _, constraint := range vals {
+		if err := enc.SetVBRConstraint(constraint); err != nil {
+			t.Fatalf("Error setting VBR constraint to %t: %v", constraint, err)
+		}
+		gotv, err := enc.VBRConstraint()
+		if err != nil {
+			t.Fatalf("Error getting VBR constraint (%t): %v", constraint, err)
+		}
+		if gotv != constraint {
+			t.Errorf("Error set VBR constraint: expect %v, got %v", constraint, gotv)
+		}
+	}
+}
+
+func TestEncoder_SetGetSignal(t *testing.T) {
+	enc, err := NewEncoder(8000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []Signal{SignalVoice, SignalMusic, SignalAuto}
+	for _, signal := range vals {
+		if err := enc.SetSignal(signal); err != nil {
+			t.Error("Error setting signal:", err)
+		}
+		got, err := enc.Signal()
+		if err != nil {
+			t.Error("Error getting signal", err)
+		}
+		if got != signal {
+			t.Errorf("Unexpected signal. Got %d, but expected %d", got, signal)
+		}
+	}
+	if err := enc.SetSignal(Signal(42)); err != ErrBadArg {
+		t.Errorf("Expected ErrBadArg for an invalid signal, got %v", err)
+	}
+}
+
+func TestEncoder_SetGetForceChannels(t *testing.T) {
+	enc, err := NewEncoder(48000, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []Channels{ChannelsMono, ChannelsStereo, ChannelsAuto}
+	for _, channels := range vals {
+		if err := enc.SetForceChannels(channels); err != nil {
+			t.Error("Error setting force channels:", err)
+		}
+		got, err := enc.ForceChannels()
+		if err != nil {
+			t.Error("Error getting force channels", err)
+		}
+		if got != channels {
+			t.Errorf("Unexpected force channels. Got %d, but expected %d", got, channels)
+		}
+	}
+
+	mono, err := NewEncoder(48000, 1, AppAudio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mono.SetForceChannels(ChannelsStereo); err != ErrBadArg {
+		t.Errorf("Expected ErrBadArg forcing stereo on a mono encoder, got %v", err)
+	}
+}
+
+func TestEncoder_SetGetLSBDepth(t *testing.T) {
+	enc, err := NewEncoder(8000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []int{8, 16, 24}
+	for _, depth := range vals {
+		if err := enc.SetLSBDepth(depth); err != nil {
+			t.Error("Error setting LSB depth:", err)
+		}
+		got, err := enc.LSBDepth()
+		if err != nil {
+			t.Error("Error getting LSB depth", err)
+		}
+		if got != depth {
+			t.Errorf("Unexpected LSB depth. Got %d, but expected %d", got, depth)
+		}
+	}
+	invalidVals := []int{7, 25}
+	for _, depth := range invalidVals {
+		if err := enc.SetLSBDepth(depth); err == nil {
+			t.Errorf("Expected Error invalid LSB depth: %d", depth)
+		}
+	}
+}
+
+func TestEncoder_SetGetPredictionDisabled(t *testing.T) {
+	enc, err := NewEncoder(8000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []bool{true, false}
+	for _, disabled := range vals {
+		if err := enc.SetPredictionDisabled(disabled); err != nil {
+			t.Fatalf("Error setting prediction disabled to %t: %v", disabled, err)
+		}
+		gotv, err := enc.PredictionDisabled()
+		if err != nil {
+			t.Fatalf("Error getting prediction disabled (%t): %v", disabled, err)
+		}
+		if gotv != disabled {
+			t.Errorf("Error set prediction disabled: expect %v, got %v", disabled, gotv)
+		}
+	}
+}
+
+func TestEncoder_SetGetExpertFrameDuration(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []FrameDuration{
+		FrameDuration2dot5ms,
+		FrameDuration5ms,
+		FrameDuration10ms,
+		FrameDuration20ms,
+		FrameDuration40ms,
+		FrameDuration60ms,
+		FrameDuration80ms,
+		FrameDuration100ms,
+		FrameDuration120ms,
+		FrameDurationArg,
+	}
+	for _, duration := range vals {
+		if err := enc.SetExpertFrameDuration(duration); err != nil {
+			t.Error("Error setting expert frame duration:", err)
+		}
+		got, err := enc.ExpertFrameDuration()
+		if err != nil {
+			t.Error("Error getting expert frame duration", err)
+		}
+		if got != duration {
+			t.Errorf("Unexpected expert frame duration. Got %d, but expected %d", got, duration)
+		}
+	}
+
+	// A 10ms frame duration splits a 20ms input into two frames.
+	if err := enc.SetExpertFrameDuration(FrameDuration10ms); err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]int16, 960)
+	addSine(pcm, 48000, 391.995)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	spf, err := PacketSamplesPerFrame(data[:n], 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spf != 480 {
+		t.Errorf("Unexpected samples per frame. Got %d, but expected %d", spf, 480)
+	}
+}
+
+func TestEncoder_SetGetBandwidth(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, 960)
+	addSine(pcm, 48000, 391.995)
+	data := make([]byte, 1000)
+	// Mediumband is not used by the encoder and is promoted to Wideband.
+	vals := []Bandwidth{
+		Narrowband,
+		Wideband,
+		SuperWideband,
+		Fullband,
+	}
+	for _, bw := range vals {
+		if err := enc.SetBandwidth(bw); err != nil {
+			t.Error("Error setting Bandwidth:", err)
+		}
+		if _, err := enc.Encode(pcm, data); err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		got, err := enc.Bandwidth()
+		if err != nil {
+			t.Error("Error getting Bandwidth", err)
+		}
+		if got != bw {
+			t.Errorf("Unexpected Bandwidth value. Got %d, but expected %d", got, bw)
+		}
+	}
+	if err := enc.SetBandwidth(BandwidthAuto); err != nil {
+		t.Error("Error setting Bandwidth to auto:", err)
+	}
+}
+
+func TestEncoder_SetGetApplication(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []Application{AppAudio, AppVoIP}
+	for _, app := range vals {
+		if err := enc.SetApplication(app); err != nil {
+			t.Error("Error setting application:", err)
+		}
+		got, err := enc.Application()
+		if err != nil {
+			t.Error("Error getting application", err)
+		}
+		if got != app {
+			t.Errorf("Unexpected application. Got %d, but expected %d", got, app)
+		}
+	}
+}
+
+func TestEncoder_SetGetPhaseInversionDisabled(t *testing.T) {
+	enc, err := NewEncoder(48000, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []bool{true, false}
+	for _, disabled := range vals {
+		if err := enc.SetPhaseInversionDisabled(disabled); err != nil {
+			t.Fatalf("Error setting phase inversion disabled to %t: %v", disabled, err)
+		}
+		gotv, err := enc.PhaseInversionDisabled()
+		if err != nil {
+			t.Fatalf("Error getting phase inversion disabled (%t): %v", disabled, err)
+		}
+		if gotv != disabled {
+			t.Errorf("Error set phase inversion disabled: expect %v, got %v", disabled, gotv)
+		}
+	}
+}
+
+func TestEncoder_Lookahead(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	lookahead, err := enc.Lookahead()
+	if err != nil {
+		t.Fatal("Error getting lookahead", err)
+	}
+	// 2.5ms of CELT overlap plus 4ms of delay compensation at 48Khz.
+	if lookahead != 312 {
+		t.Errorf("Unexpected lookahead. Got %d, but expected %d", lookahead, 312)
+	}
+}
+
+func TestEncoder_FinalRange(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	pcm := make([]int16, 960)
+	addSine(pcm, SAMPLE_RATE, 391.995)
+	data := make([]byte, 1000)
+	for i := 0; i < 5; i++ {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		encRange, err := enc.FinalRange()
+		if err != nil {
+			t.Fatal("Error getting encoder final range", err)
+		}
+		if _, err := dec.Decode(data[:n], pcm); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		decRange, err := dec.FinalRange()
+		if err != nil {
+			t.Fatal("Error getting decoder final range", err)
+		}
+		if encRange != decRange {
+			t.Errorf("Final range mismatch on packet %d. Encoder %d, decoder %d", i, encRange, decRange)
+		}
+	}
+}
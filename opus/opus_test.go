@@ -152,7 +152,7 @@ func toOpus(t *testing.T, filename string, ptime int) (frames [][]byte, sampleRa
 	//)
 	//_ = packetizer
 
-	opusWriter, err := CreateFile("testdata/1.opus", uint32(sampleRate), 1)
+	opusWriter, err := CreateFile("testdata/1.opus", uint32(sampleRate), 1, defaultPreSkip)
 	if err != nil {
 		t.Fatal(err)
 	}
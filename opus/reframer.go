@@ -0,0 +1,182 @@
+package opus
+
+import (
+	"os"
+	"sync"
+
+	"github.com/pidato/audio/pool"
+)
+
+// PacketFunc receives packets produced by a Reframer. samples is the duration
+// of the packet and granulePos the position at the end of the packet, both in
+// 48Khz samples. The packet is only valid until PacketFunc returns.
+type PacketFunc func(packet []byte, samples int, granulePos uint64) error
+
+// Reframer converts a sequence of Opus packets from one ptime to another
+// without decoding. Packets are split into frames and the frames are merged
+// into packets of the target ptime, so the conversion is lossless.
+//
+// A packet can only be split on frame boundaries, so the target ptime must be
+// a multiple of the frame duration of the incoming packets. When the encoder
+// configuration changes mid-stream (mode, bandwidth, frame size or channels)
+// the frames collected so far are emitted as a shorter packet.
+//
+// Granule positions are carried through untouched. The sum of emitted
+// samples always equals the sum of samples written, which is what OggWriter
+// uses to produce page granule positions.
+type Reframer struct {
+	split *Repacketizer
+	merge *Repacketizer
+
+	ptime        int
+	samples      int // Target packet duration in 48Khz samples.
+	frameSamples int // Frame duration of the frames being merged.
+	granulePos   uint64
+
+	fn  PacketFunc
+	buf []byte
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewReframer creates a Reframer that emits packets of ptime milliseconds to fn.
+// granulePos is the position of the first packet written, usually 0.
+func NewReframer(ptime int, granulePos uint64, fn PacketFunc) (*Reframer, error) {
+	samples := pool.OpusFrameSizeOf(ptime)
+	if samples == 0 {
+		return nil, pool.ErrUnsupported
+	}
+	if fn == nil {
+		return nil, os.ErrInvalid
+	}
+	split, err := NewRepacketizer()
+	if err != nil {
+		return nil, err
+	}
+	merge, err := NewRepacketizer()
+	if err != nil {
+		_ = split.Close()
+		return nil, err
+	}
+	return &Reframer{
+		split:      split,
+		merge:      merge,
+		ptime:      ptime,
+		samples:    samples,
+		granulePos: granulePos,
+		fn:         fn,
+		buf:        make([]byte, repacketizerBufferSize),
+	}, nil
+}
+
+// Ptime returns the target ptime in milliseconds.
+func (r *Reframer) Ptime() int {
+	return r.ptime
+}
+
+// GranulePos returns the granule position at the end of the last emitted packet.
+func (r *Reframer) GranulePos() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.granulePos
+}
+
+// Write submits the next packet of the stream. Zero or more packets of the
+// target ptime are emitted before Write returns.
+func (r *Reframer) Write(packet []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+
+	frameSamples, err := PacketSamplesPerFrame(packet, 48000)
+	if err != nil {
+		return err
+	}
+	if frameSamples > r.samples || r.samples%frameSamples != 0 {
+		return ErrFrameSize
+	}
+
+	if err = r.split.Reset(); err != nil {
+		return err
+	}
+	if err = r.split.Cat(packet); err != nil {
+		return err
+	}
+
+	frames := r.split.NumFrames()
+	for i := 0; i < frames; i++ {
+		n, err := r.split.OutRange(i, i+1, r.buf)
+		if err != nil {
+			return err
+		}
+		if err = r.mergeFrame(r.buf[:n], frameSamples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reframer) mergeFrame(frame []byte, frameSamples int) error {
+	err := r.merge.Cat(frame)
+	if err == ErrInvalidPacket {
+		// Configuration changed. Emit what we have and start over.
+		if err = r.flush(); err != nil {
+			return err
+		}
+		err = r.merge.Cat(frame)
+	}
+	if err != nil {
+		return err
+	}
+	r.frameSamples = frameSamples
+
+	if r.merge.NumFrames()*r.frameSamples >= r.samples {
+		return r.flush()
+	}
+	return nil
+}
+
+// Flush emits the frames collected so far as a packet shorter than ptime.
+func (r *Reframer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	return r.flush()
+}
+
+func (r *Reframer) flush() error {
+	frames := r.merge.NumFrames()
+	if frames == 0 {
+		return nil
+	}
+	// Merge buffer is sized to always fit the output.
+	n, err := r.merge.Out(r.buf)
+	if err != nil {
+		return err
+	}
+	samples := frames * r.frameSamples
+	if err = r.merge.Reset(); err != nil {
+		return err
+	}
+	r.granulePos += uint64(samples)
+	return r.fn(r.buf[:n], samples, r.granulePos)
+}
+
+// Close flushes any remaining frames and frees native resources.
+func (r *Reframer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	err := r.flush()
+	_ = r.split.Close()
+	_ = r.merge.Close()
+	return err
+}
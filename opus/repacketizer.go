@@ -0,0 +1,233 @@
+package opus
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <opus.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+const (
+	// Maximum number of frames a repacketizer can hold (120ms of 2.5ms frames).
+	maxRepacketizerFrames = 48
+	// Maximum size of a single Opus frame in bytes.
+	maxFrameBytes = 1275
+	// Repacketizer data buffer size. Large enough to hold 120ms of frames at the
+	// maximum frame size including TOC and framing overhead.
+	repacketizerBufferSize = maxRepacketizerFrames * (maxFrameBytes + 2)
+)
+
+var errRepUninitialized = fmt.Errorf("opus repacketizer uninitialized")
+
+// Repacketizer merges multiple Opus packets into a single packet or splits
+// packets with multiple frames into smaller packets without decoding.
+//
+// libopus keeps pointers to the submitted packet data until the next Reset.
+// Packets are therefore copied into a C allocated buffer owned by the
+// Repacketizer. Close must be called to free it.
+type Repacketizer struct {
+	p *C.struct_OpusRepacketizer
+	// Memory for the repacketizer struct allocated on the Go heap.
+	mem []byte
+	// Packet data submitted through Cat.
+	data *C.uchar
+	size int
+}
+
+// NewRepacketizer allocates a new Opus repacketizer.
+func NewRepacketizer() (*Repacketizer, error) {
+	var rp Repacketizer
+	err := rp.Init()
+	if err != nil {
+		return nil, err
+	}
+	return &rp, nil
+}
+
+// Init initializes a pre-allocated repacketizer. Unless the repacketizer has
+// been created using NewRepacketizer, this method must be called exactly once
+// in the life-time of this object, before calling any other methods.
+func (rp *Repacketizer) Init() error {
+	if rp.p != nil {
+		return fmt.Errorf("opus repacketizer already initialized")
+	}
+	size := int(C.opus_repacketizer_get_size())
+	if cap(rp.mem) < size {
+		rp.mem = make([]byte, size)
+	} else {
+		rp.mem = rp.mem[:size]
+	}
+	rp.p = (*C.OpusRepacketizer)(unsafe.Pointer(&rp.mem[0]))
+	rp.data = (*C.uchar)(C.malloc(C.size_t(repacketizerBufferSize)))
+	if rp.data == nil {
+		rp.p = nil
+		return ErrAllocFail
+	}
+	rp.size = 0
+	C.opus_repacketizer_init(rp.p)
+	return nil
+}
+
+// Reset clears all packets submitted through Cat. It must be called before
+// submitting a packet with a different configuration (mode, bandwidth, frame
+// size or channel count) or once 120ms of audio has been submitted.
+func (rp *Repacketizer) Reset() error {
+	if rp.p == nil {
+		return errRepUninitialized
+	}
+	rp.size = 0
+	C.opus_repacketizer_init(rp.p)
+	return nil
+}
+
+// Close frees the packet data buffer.
+func (rp *Repacketizer) Close() error {
+	if rp.p == nil {
+		return errRepUninitialized
+	}
+	C.free(unsafe.Pointer(rp.data))
+	rp.data = nil
+	rp.p = nil
+	rp.size = 0
+	return nil
+}
+
+// Cat adds a packet to the current repacketizer state. The packet must match
+// the configuration of any packets already submitted since the last Reset and
+// the total duration must not exceed 120ms. ErrInvalidPacket is returned
+// otherwise and the state is left untouched.
+func (rp *Repacketizer) Cat(packet []byte) error {
+	if rp.p == nil {
+		return errRepUninitialized
+	}
+	if len(packet) == 0 {
+		return fmt.Errorf("opus: no data supplied")
+	}
+	if rp.size+len(packet) > repacketizerBufferSize {
+		return ErrBufferTooSmall
+	}
+	dst := unsafe.Pointer(uintptr(unsafe.Pointer(rp.data)) + uintptr(rp.size))
+	C.memcpy(dst, unsafe.Pointer(&packet[0]), C.size_t(len(packet)))
+	res := C.opus_repacketizer_cat(rp.p, (*C.uchar)(dst), C.opus_int32(len(packet)))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	rp.size += len(packet)
+	return nil
+}
+
+// NumFrames returns the number of frames submitted through Cat since the last
+// Reset.
+func (rp *Repacketizer) NumFrames() int {
+	if rp.p == nil {
+		return 0
+	}
+	return int(C.opus_repacketizer_get_nb_frames(rp.p))
+}
+
+// OutRange constructs a new packet from frames [begin, end) previously
+// submitted through Cat and stores it in the supplied buffer. On success,
+// returns the number of bytes used up by the packet.
+func (rp *Repacketizer) OutRange(begin, end int, data []byte) (int, error) {
+	if rp.p == nil {
+		return 0, errRepUninitialized
+	}
+	if len(data) == 0 {
+		return 0, fmt.Errorf("opus: no target buffer")
+	}
+	n := int(C.opus_repacketizer_out_range(
+		rp.p,
+		C.int(begin),
+		C.int(end),
+		(*C.uchar)(&data[0]),
+		C.opus_int32(len(data))))
+	if n < 0 {
+		return 0, Error(n)
+	}
+	return n, nil
+}
+
+// Out constructs a new packet from all the frames submitted through Cat since
+// the last Reset.
+func (rp *Repacketizer) Out(data []byte) (int, error) {
+	return rp.OutRange(0, rp.NumFrames(), data)
+}
+
+// PacketPad pads a packet to newLen bytes in place. The capacity of the
+// packet must be at least newLen. Returns the padded packet.
+func PacketPad(packet []byte, newLen int) ([]byte, error) {
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("opus: no data supplied")
+	}
+	if cap(packet) < newLen {
+		return nil, ErrBufferTooSmall
+	}
+	res := C.opus_packet_pad(
+		(*C.uchar)(&packet[0]),
+		C.opus_int32(len(packet)),
+		C.opus_int32(newLen))
+	if res != C.OPUS_OK {
+		return nil, Error(res)
+	}
+	return packet[:newLen], nil
+}
+
+// PacketUnpad removes all padding from a packet in place and rewrites the TOC
+// sequence to minimize space usage. Returns the stripped packet.
+func PacketUnpad(packet []byte) ([]byte, error) {
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("opus: no data supplied")
+	}
+	n := int(C.opus_packet_unpad(
+		(*C.uchar)(&packet[0]),
+		C.opus_int32(len(packet))))
+	if n < 0 {
+		return nil, Error(n)
+	}
+	return packet[:n], nil
+}
+
+// PacketFrames returns the number of frames in a packet.
+func PacketFrames(packet []byte) (int, error) {
+	if len(packet) == 0 {
+		return 0, fmt.Errorf("opus: no data supplied")
+	}
+	n := int(C.opus_packet_get_nb_frames(
+		(*C.uchar)(&packet[0]),
+		C.opus_int32(len(packet))))
+	if n < 0 {
+		return 0, Error(n)
+	}
+	return n, nil
+}
+
+// PacketSamples returns the number of samples per channel in a packet at the
+// supplied sample rate.
+func PacketSamples(packet []byte, sampleRate int) (int, error) {
+	if len(packet) == 0 {
+		return 0, fmt.Errorf("opus: no data supplied")
+	}
+	n := int(C.opus_packet_get_nb_samples(
+		(*C.uchar)(&packet[0]),
+		C.opus_int32(len(packet)),
+		C.opus_int32(sampleRate)))
+	if n < 0 {
+		return 0, Error(n)
+	}
+	return n, nil
+}
+
+// PacketSamplesPerFrame returns the number of samples per frame in a packet at
+// the supplied sample rate.
+func PacketSamplesPerFrame(packet []byte, sampleRate int) (int, error) {
+	if len(packet) == 0 {
+		return 0, fmt.Errorf("opus: no data supplied")
+	}
+	return int(C.opus_packet_get_samples_per_frame(
+		(*C.uchar)(&packet[0]),
+		C.opus_int32(sampleRate))), nil
+}
@@ -0,0 +1,212 @@
+package opus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func encodeSine(t *testing.T, frameSize, frames int) [][]byte {
+	const G4 = 391.995
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, frameSize*frames)
+	addSine(pcm, 48000, G4)
+
+	packets := make([][]byte, 0, frames)
+	for i := 0; i < frames; i++ {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm[i*frameSize:(i+1)*frameSize], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets = append(packets, data[:n])
+	}
+	return packets
+}
+
+func TestRepacketizer_CatOut(t *testing.T) {
+	packets := encodeSine(t, 960, 3)
+
+	rp, err := NewRepacketizer()
+	if err != nil {
+		t.Fatalf("Error creating repacketizer: %v", err)
+	}
+	defer rp.Close()
+
+	for _, packet := range packets {
+		if err := rp.Cat(packet); err != nil {
+			t.Fatalf("Error adding packet: %v", err)
+		}
+	}
+	if rp.NumFrames() != 3 {
+		t.Fatalf("Unexpected frame count. Got %d, but expected %d", rp.NumFrames(), 3)
+	}
+
+	out := make([]byte, 4000)
+	n, err := rp.Out(out)
+	if err != nil {
+		t.Fatalf("Error creating packet: %v", err)
+	}
+	samples, err := PacketSamples(out[:n], 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if samples != 2880 {
+		t.Errorf("Unexpected packet duration. Got %d, but expected %d", samples, 2880)
+	}
+
+	for i, packet := range packets {
+		n, err := rp.OutRange(i, i+1, out)
+		if err != nil {
+			t.Fatalf("Error creating packet %d: %v", i, err)
+		}
+		if !bytes.Equal(out[:n], packet) {
+			t.Errorf("Packet %d does not match the original", i)
+		}
+	}
+
+	if _, err := rp.OutRange(2, 4, out); err != ErrBadArg {
+		t.Errorf("Expected ErrBadArg for an invalid range, got %v", err)
+	}
+
+	// Only the length of the buffer is available to the packet.
+	if _, err := rp.Out(out[:1]); err != ErrBufferTooSmall {
+		t.Errorf("Expected ErrBufferTooSmall for a short buffer, got %v", err)
+	}
+}
+
+func TestPacketPadUnpad(t *testing.T) {
+	packets := encodeSine(t, 960, 1)
+	packet := make([]byte, len(packets[0]), len(packets[0])+100)
+	copy(packet, packets[0])
+
+	padded, err := PacketPad(packet, len(packet)+100)
+	if err != nil {
+		t.Fatalf("Error padding packet: %v", err)
+	}
+	if len(padded) != len(packets[0])+100 {
+		t.Fatalf("Unexpected padded length. Got %d", len(padded))
+	}
+
+	dec, err := NewDecoder(48000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]int16, 960)
+	if _, err := dec.Decode(padded, pcm); err != nil {
+		t.Fatalf("Couldn't decode padded packet: %v", err)
+	}
+
+	unpadded, err := PacketUnpad(padded)
+	if err != nil {
+		t.Fatalf("Error unpadding packet: %v", err)
+	}
+	if !bytes.Equal(unpadded, packets[0]) {
+		t.Errorf("Unpadded packet does not match the original")
+	}
+
+	exact := packets[0][:len(packets[0]):len(packets[0])]
+	if _, err := PacketPad(exact, len(exact)+1); err != ErrBufferTooSmall {
+		t.Errorf("Expected ErrBufferTooSmall, got %v", err)
+	}
+}
+
+func TestReframer_MergeSplit(t *testing.T) {
+	packets := encodeSine(t, 960, 12)
+
+	var merged [][]byte
+	merge, err := NewReframer(60, 0, func(packet []byte, samples int, granulePos uint64) error {
+		if samples != 2880 {
+			t.Errorf("Unexpected merged duration. Got %d, but expected %d", samples, 2880)
+		}
+		if granulePos != uint64(2880*(len(merged)+1)) {
+			t.Errorf("Unexpected granule position %d", granulePos)
+		}
+		merged = append(merged, append([]byte(nil), packet...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, packet := range packets {
+		if err := merge.Write(packet); err != nil {
+			t.Fatalf("Error merging packet: %v", err)
+		}
+	}
+	if err := merge.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 4 {
+		t.Fatalf("Unexpected merged packet count. Got %d, but expected %d", len(merged), 4)
+	}
+
+	var split [][]byte
+	splitter, err := NewReframer(20, 0, func(packet []byte, samples int, granulePos uint64) error {
+		split = append(split, append([]byte(nil), packet...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, packet := range merged {
+		if err := splitter.Write(packet); err != nil {
+			t.Fatalf("Error splitting packet: %v", err)
+		}
+	}
+	if err := splitter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if splitter.GranulePos() != 960*12 {
+		t.Errorf("Unexpected granule position. Got %d, but expected %d", splitter.GranulePos(), 960*12)
+	}
+	if len(split) != len(packets) {
+		t.Fatalf("Unexpected split packet count. Got %d, but expected %d", len(split), len(packets))
+	}
+	for i := range packets {
+		if !bytes.Equal(split[i], packets[i]) {
+			t.Errorf("Packet %d does not survive a merge and split", i)
+		}
+	}
+}
+
+func TestReframer_FrameSize(t *testing.T) {
+	packets := encodeSine(t, 960, 1)
+	r, err := NewReframer(10, 0, func(packet []byte, samples int, granulePos uint64) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Write(packets[0]); err != ErrFrameSize {
+		t.Errorf("Expected ErrFrameSize for a 20ms frame into 10ms packets, got %v", err)
+	}
+}
+
+func TestReframer_Flush(t *testing.T) {
+	packets := encodeSine(t, 960, 2)
+	var durations []int
+	r, err := NewReframer(60, 0, func(packet []byte, samples int, granulePos uint64) error {
+		durations = append(durations, samples)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, packet := range packets {
+		if err := r.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(durations) != 0 {
+		t.Fatalf("Expected no packets before flush, got %d", len(durations))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(durations) != 1 || durations[0] != 1920 {
+		t.Errorf("Unexpected flushed packets %v", durations)
+	}
+}
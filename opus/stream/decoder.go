@@ -23,12 +23,12 @@ type Decoder struct {
 	mu     sync.Mutex
 }
 
-func NewDecoder(sampleRate, ptime, maxFrames int) (*Decoder, error) {
-	d, err := opus.NewDecoder(sampleRate, 1)
+func NewDecoder(sampleRate, channels, ptime, maxFrames int) (*Decoder, error) {
+	d, err := opus.NewDecoder(sampleRate, channels)
 	if err != nil {
 		return nil, err
 	}
-	buf, err := pcm.NewBuffer(sampleRate, ptime, maxFrames)
+	buf, err := pcm.NewBuffer(sampleRate, channels, ptime, maxFrames)
 	if err != nil {
 		return nil, err
 	}
@@ -37,10 +37,10 @@ func NewDecoder(sampleRate, ptime, maxFrames int) (*Decoder, error) {
 		dec:             d,
 		pcm:             buf,
 		sampleRate:      sampleRate,
-		channels:        1,
+		channels:        channels,
 		ptime:           ptime,
-		samplesPerFrame: buf.FrameSize(),
-		pcmBuf:          make([]int16, 2880*2),
+		samplesPerFrame: buf.FrameSize() * channels,
+		pcmBuf:          make([]int16, 5760*channels),
 		next:            nil,
 		nextLen:         0,
 	}, nil
@@ -73,6 +73,7 @@ func (d *Decoder) Drop(frames int) error {
 	for i := 0; i < frames; i++ {
 
 	}
+	return nil
 }
 
 func (d *Decoder) Write(packet []byte) error {
@@ -92,7 +93,8 @@ func (d *Decoder) Write(packet []byte) error {
 
 	samplesPerFrame := d.samplesPerFrame
 
-	buf := d.pcmBuf[0:samples]
+	buf := d.pcmBuf[0 : samples*d.channels]
+	samples = len(buf)
 	next := d.next
 
 	if d.nextLen > 0 {
@@ -130,6 +132,7 @@ func (d *Decoder) Write(packet []byte) error {
 			if err != nil {
 				return err
 			}
+			next = d.pcm.Alloc()
 			buf = buf[samplesPerFrame:]
 		} else if len(buf) == samplesPerFrame {
 			copy(next, buf)
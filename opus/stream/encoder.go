@@ -10,13 +10,17 @@ type Encoder struct {
 	pcm *pcm.Buffer
 }
 
-func NewEncoder(sampleRate, ptime, maxFrames int, app opus.Application) (*Encoder, error) {
-	buf, err := pcm.NewBuffer(sampleRate, ptime, maxFrames)
+func NewEncoder(sampleRate, channels, ptime, maxFrames int, app opus.Application) (*Encoder, error) {
+	buf, err := pcm.NewBuffer(sampleRate, channels, ptime, maxFrames)
 	if err != nil {
 		return nil, err
 	}
 
-	enc, err := opus.NewEncoder(sampleRate, 1, app)
+	enc, err := opus.NewEncoder(sampleRate, channels, app)
+	if err != nil {
+		_ = buf.Close()
+		return nil, err
+	}
 
 	return &Encoder{
 		enc: enc,
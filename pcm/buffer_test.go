@@ -12,7 +12,7 @@ func TestBuffer_Write(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	buffer, err := NewBuffer(16000, 10, 100)
+	buffer, err := NewBuffer(16000, 1, 10, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -63,3 +63,97 @@ func TestBuffer_Write(t *testing.T) {
 	_ = buffer.Close()
 	fmt.Println(buffer.MaxDivergence())
 }
+
+func TestBuffer_Stereo(t *testing.T) {
+	buffer, err := NewBuffer(16000, 2, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buffer.Close()
+	if buffer.Channels() != 2 || buffer.FrameSize() != 320 {
+		t.Fatalf("Unexpected channels %d or frame size %d", buffer.Channels(), buffer.FrameSize())
+	}
+	frame := buffer.Alloc()
+	if len(frame) != 640 {
+		t.Fatalf("Unexpected interleaved frame length %d", len(frame))
+	}
+	if err := buffer.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buffer.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.Elapsed() != Ptime20ms {
+		t.Errorf("Unexpected elapsed %v", buffer.Elapsed())
+	}
+
+	if _, err := NewBuffer(16000, 0, 20, 2); err == nil {
+		t.Errorf("Expected error for 0 channels")
+	}
+}
+
+func TestBuffer_ReadFrameOwnership(t *testing.T) {
+	buffer, err := NewBuffer(8000, 1, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := buffer.Write(buffer.Alloc()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	frame, err := buffer.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the frame still buffered is left for Close to release.
+	held := 0
+	for _, f := range buffer.frames {
+		if f == nil {
+			continue
+		}
+		if &f[0] == &frame[0] {
+			t.Fatal("buffer holds the frame it returned")
+		}
+		held++
+	}
+	if held != 1 {
+		t.Fatalf("buffer holds %d frames, want 1", held)
+	}
+	if err := buffer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Release(frame)
+}
+
+func TestBuffer_Reset(t *testing.T) {
+	buffer, err := NewBuffer(16000, 1, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buffer.Close()
+	if err = buffer.Write(buffer.Alloc()); err != nil {
+		t.Fatal(err)
+	}
+	if err = buffer.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		frame := buffer.Alloc()
+		frame[0] = int16(i)
+		if err = buffer.WriteBlocking(frame); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	if err = buffer.Write(buffer.Alloc()); err != io.ErrShortBuffer {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		frame, err := buffer.ReadFrame()
+		if err != nil || frame[0] != int16(i) {
+			t.Fatal(i, err)
+		}
+		buffer.Release(frame)
+	}
+}
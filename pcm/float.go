@@ -0,0 +1,245 @@
+package pcm
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/pool"
+)
+
+// 32bit float PCM reader. Samples are normalized to [-1, 1].
+type FloatReader interface {
+	io.Closer
+
+	Elapsed() time.Duration
+
+	// Clock speed in hertz. (i.e. 16000 for 16Khz)
+	SampleRate() int
+
+	// Number of interleaved channels.
+	Channels() int
+
+	// Number of samples per channel per frame. Frames are interleaved, so a
+	// frame holds FrameSize() * Channels() float32 values.
+	FrameSize() int
+
+	// Frame duration.
+	Ptime() time.Duration
+
+	// Release buffer to allow it to be recycled.
+	Release(p []float32)
+
+	// Allocate a new Frame.
+	Alloc() []float32
+
+	// ReadFrame the next Frame.
+	ReadFrame() ([]float32, error)
+}
+
+const (
+	int16Scale    = 32768
+	int16ScaleInv = 1.0 / int16Scale
+)
+
+// Int16ToFloat32 converts src into dst and returns the number of samples
+// converted, which is the smaller of the two lengths.
+func Int16ToFloat32(dst []float32, src []int16) int {
+	if len(src) < len(dst) {
+		dst = dst[:len(src)]
+	}
+	for i := range dst {
+		dst[i] = float32(src[i]) * int16ScaleInv
+	}
+	return len(dst)
+}
+
+// Float32ToInt16 converts src into dst rounding to the nearest value and
+// saturating anything outside of [-1, 1). NaN converts to 0. Returns the
+// number of samples converted, which is the smaller of the two lengths.
+func Float32ToInt16(dst []int16, src []float32) int {
+	if len(src) < len(dst) {
+		dst = dst[:len(src)]
+	}
+	for i := range dst {
+		dst[i] = SaturateInt16(src[i] * int16Scale)
+	}
+	return len(dst)
+}
+
+// SaturateInt16 rounds v to the nearest int16 clamping to the int16 range.
+func SaturateInt16(v float32) int16 {
+	switch {
+	case v != v:
+		return 0
+	case v >= 32767:
+		return 32767
+	case v <= -32768:
+		return -32768
+	case v > 0:
+		return int16(v + 0.5)
+	default:
+		return int16(v - 0.5)
+	}
+}
+
+// pcmPoolOf returns the frame pool for a sample rate and frame duration.
+func pcmPoolOf(sampleRate, channels int, ptime time.Duration) (*pool.PCM, error) {
+	ms := int(ptime / time.Millisecond)
+	if ptime == time.Microsecond*2500 {
+		ms = 3
+	}
+	p, err := pool.Of(sampleRate, channels, ms)
+	if err != nil {
+		return nil, err
+	}
+	return p.ForPtime(ms), nil
+}
+
+// FloatConvertingReader adapts a Reader to a FloatReader. It isn't zero-copy:
+// every frame read from the backing reader is converted into a pooled float32
+// frame and released.
+type FloatConvertingReader struct {
+	reader  Reader
+	pcmPool *pool.Float32
+	closed  bool
+	mu      sync.Mutex
+}
+
+func NewFloatConvertingReader(reader Reader) (*FloatConvertingReader, error) {
+	p, err := pcmPoolOf(reader.SampleRate(), reader.Channels(), reader.Ptime())
+	if err != nil {
+		return nil, err
+	}
+	if p.FrameSize != reader.FrameSize() {
+		return nil, pool.ErrUnsupported
+	}
+	return &FloatConvertingReader{
+		reader:  reader,
+		pcmPool: p.Float,
+	}, nil
+}
+
+func (r *FloatConvertingReader) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	r.closed = true
+	r.mu.Unlock()
+	return r.reader.Close()
+}
+
+func (r *FloatConvertingReader) Elapsed() time.Duration {
+	return r.reader.Elapsed()
+}
+
+func (r *FloatConvertingReader) SampleRate() int {
+	return r.reader.SampleRate()
+}
+
+func (r *FloatConvertingReader) Channels() int {
+	return r.reader.Channels()
+}
+
+func (r *FloatConvertingReader) FrameSize() int {
+	return r.reader.FrameSize()
+}
+
+func (r *FloatConvertingReader) Ptime() time.Duration {
+	return r.reader.Ptime()
+}
+
+func (r *FloatConvertingReader) Alloc() []float32 {
+	return r.pcmPool.Get()
+}
+
+func (r *FloatConvertingReader) Release(p []float32) {
+	r.pcmPool.Release(p)
+}
+
+func (r *FloatConvertingReader) ReadFrame() ([]float32, error) {
+	frame, err := r.reader.ReadFrame()
+	if len(frame) == 0 {
+		return nil, err
+	}
+	buf := r.pcmPool.Get()
+	n := Int16ToFloat32(buf, frame)
+	r.reader.Release(frame)
+	return buf[:n], err
+}
+
+// Int16ConvertingReader adapts a FloatReader to a Reader. It isn't zero-copy:
+// every frame read from the backing reader is converted with saturation into
+// a pooled int16 frame and released.
+type Int16ConvertingReader struct {
+	reader  FloatReader
+	pcmPool *pool.PCM
+	closed  bool
+	mu      sync.Mutex
+}
+
+func NewInt16ConvertingReader(reader FloatReader) (*Int16ConvertingReader, error) {
+	p, err := pcmPoolOf(reader.SampleRate(), reader.Channels(), reader.Ptime())
+	if err != nil {
+		return nil, err
+	}
+	if p.FrameSize != reader.FrameSize() {
+		return nil, pool.ErrUnsupported
+	}
+	return &Int16ConvertingReader{
+		reader:  reader,
+		pcmPool: p,
+	}, nil
+}
+
+func (r *Int16ConvertingReader) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	r.closed = true
+	r.mu.Unlock()
+	return r.reader.Close()
+}
+
+func (r *Int16ConvertingReader) Elapsed() time.Duration {
+	return r.reader.Elapsed()
+}
+
+func (r *Int16ConvertingReader) SampleRate() int {
+	return r.reader.SampleRate()
+}
+
+func (r *Int16ConvertingReader) Channels() int {
+	return r.reader.Channels()
+}
+
+func (r *Int16ConvertingReader) FrameSize() int {
+	return r.reader.FrameSize()
+}
+
+func (r *Int16ConvertingReader) Ptime() time.Duration {
+	return r.reader.Ptime()
+}
+
+func (r *Int16ConvertingReader) Alloc() []int16 {
+	return r.pcmPool.Get()
+}
+
+func (r *Int16ConvertingReader) Release(p []int16) {
+	r.pcmPool.Release(p)
+}
+
+func (r *Int16ConvertingReader) ReadFrame() ([]int16, error) {
+	frame, err := r.reader.ReadFrame()
+	if len(frame) == 0 {
+		return nil, err
+	}
+	buf := r.pcmPool.Get()
+	n := Float32ToInt16(buf, frame)
+	r.reader.Release(frame)
+	return buf[:n], err
+}
@@ -0,0 +1,24 @@
+package pcm
+
+import (
+	"github.com/pidato/audio/pool"
+)
+
+// FloatBuffer is the float32 counterpart of Buffer. It buffers between a
+// FloatReader and a Writer. Once internal frame buffer is full, it blocks
+// until either the Reader or Writer processes the next frame.
+type FloatBuffer struct {
+	frameBuffer[float32]
+}
+
+func NewFloatBuffer(sampleRate, channels, ptime, maxFrames int) (*FloatBuffer, error) {
+	p, err := pool.Of(sampleRate, channels, ptime)
+	if err != nil {
+		return nil, err
+	}
+	pcmPool := p.ForPtime(ptime).Float
+
+	b := &FloatBuffer{}
+	b.init(sampleRate, channels, ptime, pcmPool.FrameSize, maxFrames, pcmPool)
+	return b, nil
+}
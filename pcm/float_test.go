@@ -0,0 +1,128 @@
+package pcm
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+func TestFloat32ToInt16_Saturation(t *testing.T) {
+	src := []float32{0, 0.5, -0.5, 1, -1, 1.5, -1.5, float32(math.NaN()), 1.0 / 32768, -1.0 / 32768}
+	expected := []int16{0, 16384, -16384, 32767, -32768, 32767, -32768, 0, 1, -1}
+	dst := make([]int16, len(src))
+	if n := Float32ToInt16(dst, src); n != len(src) {
+		t.Fatalf("Unexpected count %d", n)
+	}
+	for i := range expected {
+		if dst[i] != expected[i] {
+			t.Errorf("Sample %d: got %d, expected %d", i, dst[i], expected[i])
+		}
+	}
+}
+
+func TestInt16Float32_RoundTrip(t *testing.T) {
+	src := []int16{0, 1, -1, 12345, -12345, 32767, -32768}
+	f := make([]float32, len(src))
+	Int16ToFloat32(f, src)
+	dst := make([]int16, len(src))
+	Float32ToInt16(dst, f)
+	for i := range src {
+		if src[i] != dst[i] {
+			t.Errorf("Sample %d: got %d, expected %d", i, dst[i], src[i])
+		}
+	}
+}
+
+func TestConvertingReaders(t *testing.T) {
+	original, err := OpenWavFile("testdata/recording.wav", Ptime20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer original.Close()
+	reader, err := OpenWavFile("testdata/recording.wav", Ptime20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFloatConvertingReader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewInt16ConvertingReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	buffer, err := NewFloatBuffer(f.SampleRate(), f.Channels(), Ptime20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buffer.Close()
+
+	for frames := 0; ; frames++ {
+		expected, err1 := original.ReadFrame()
+		frame, err2 := r.ReadFrame()
+		if len(expected) != len(frame) {
+			t.Fatalf("Frame %d: got %d samples, expected %d", frames, len(frame), len(expected))
+		}
+		for i := range expected {
+			if expected[i] != frame[i] {
+				t.Fatalf("Frame %d sample %d: got %d, expected %d", frames, i, frame[i], expected[i])
+			}
+		}
+		if len(frame) > 0 {
+			fl := buffer.Alloc()
+			Int16ToFloat32(fl, frame)
+			if err := buffer.Write(fl); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := buffer.ReadFrame(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		original.Release(expected)
+		r.Release(frame)
+		if err1 != nil || err2 != nil {
+			if err1 != io.EOF || err2 != io.EOF {
+				t.Fatalf("Unexpected errors %v, %v", err1, err2)
+			}
+			break
+		}
+	}
+}
+
+func TestFloatBuffer_Reset(t *testing.T) {
+	buffer, err := NewFloatBuffer(16000, 1, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buffer.Close()
+	if err = buffer.Write(buffer.Alloc()); err != nil {
+		t.Fatal(err)
+	}
+	if err = buffer.WriteFinal(); err != nil {
+		t.Fatal(err)
+	}
+	if err = buffer.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reset buffer holds maxFrames again.
+	for i := 0; i < 2; i++ {
+		frame := buffer.Alloc()
+		frame[0] = float32(i)
+		if err = buffer.WriteBlocking(frame); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	if err = buffer.Write(buffer.Alloc()); err != io.ErrShortBuffer {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		frame, err := buffer.ReadFrame()
+		if err != nil || frame[0] != float32(i) {
+			t.Fatal(i, err)
+		}
+		buffer.Release(frame)
+	}
+}
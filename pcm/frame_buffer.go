@@ -0,0 +1,325 @@
+package pcm
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// framePool allocates and recycles frames of a single size.
+type framePool[T int16 | float32] interface {
+	Get() []T
+	Release(p []T)
+}
+
+// frameBuffer is the ring of frames behind Buffer and FloatBuffer. Once it is
+// full, it blocks until either the reader or the writer processes the next
+// frame.
+type frameBuffer[T int16 | float32] struct {
+	sampleRate int
+	channels   int
+	ptime      int
+	frameSize  int
+	pcmPool    framePool[T]
+
+	eof bool
+
+	max           int
+	frames        [][]T
+	readerIndex   int
+	writerIndex   int
+	size          int
+	maxDivergence int
+
+	writesBlocked int
+
+	samplesRead    int
+	sampleDuration time.Duration
+
+	closed     bool
+	readerWait bool
+	writerWait bool
+	readerWg   sync.WaitGroup
+	writerWg   sync.WaitGroup
+	mu         sync.Mutex
+}
+
+func (b *frameBuffer[T]) init(sampleRate, channels, ptime, frameSize, maxFrames int, pcmPool framePool[T]) {
+	if maxFrames < 1 {
+		maxFrames = 1
+	}
+	if maxFrames > 10000 {
+		maxFrames = 10000
+	}
+	b.sampleRate = sampleRate
+	b.channels = channels
+	b.ptime = ptime
+	b.frameSize = frameSize
+	b.pcmPool = pcmPool
+	b.max = maxFrames
+	b.frames = make([][]T, maxFrames)
+	b.sampleDuration = time.Second / time.Duration(sampleRate)
+}
+
+func (b *frameBuffer[T]) MaxDivergenceFrames() int {
+	return b.maxDivergence
+}
+
+func (b *frameBuffer[T]) MaxDivergence() time.Duration {
+	return time.Duration(b.maxDivergence) * time.Millisecond * time.Duration(b.ptime)
+}
+
+func (b *frameBuffer[T]) Elapsed() time.Duration {
+	return time.Duration(b.samplesRead/b.channels) * b.sampleDuration
+}
+
+func (f *frameBuffer[T]) SampleRate() int {
+	return f.sampleRate
+}
+
+func (f *frameBuffer[T]) Channels() int {
+	return f.channels
+}
+
+func (f *frameBuffer[T]) FrameSize() int {
+	return f.frameSize
+}
+
+func (f *frameBuffer[T]) Ptime() time.Duration {
+	return time.Duration(f.ptime) * time.Millisecond
+}
+
+func (f *frameBuffer[T]) Alloc() []T {
+	return f.pcmPool.Get()
+}
+
+func (f *frameBuffer[T]) Release(b []T) {
+	f.pcmPool.Release(b)
+}
+
+func (r *frameBuffer[T]) Reset() error {
+	r.mu.Lock()
+	if r.closed {
+		r.reset()
+		r.mu.Unlock()
+		return nil
+	}
+	r.mu.Unlock()
+
+	err := r.Close()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if !r.closed {
+		r.mu.Unlock()
+		return errors.New("race")
+	}
+	r.reset()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *frameBuffer[T]) reset() {
+	r.closed = false
+	r.eof = false
+	r.size = 0
+	r.readerIndex = 0
+	r.writerIndex = 0
+	// Close released the frames.
+	r.frames = make([][]T, r.max)
+}
+
+func (f *frameBuffer[T]) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	f.closed = true
+	if f.readerWait {
+		f.readerWait = false
+		// Unblock readers.
+		f.readerWg.Done()
+	}
+	if f.writerWait {
+		// Unblock writer.
+		f.writerWait = false
+		f.writerWg.Done()
+	}
+	// Release frames.
+	for i, buf := range f.frames {
+		f.pcmPool.Release(buf)
+		f.frames[i] = nil
+	}
+	f.frames = nil
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *frameBuffer[T]) WriteFinal() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	f.eof = true
+	writerWait := f.writerWait
+	if f.writerWait {
+		f.writerWait = false
+	}
+	readerWait := f.readerWait
+	if f.readerWait {
+		f.readerWait = false
+	}
+	f.mu.Unlock()
+
+	if writerWait {
+		f.writerWg.Done()
+	}
+	if readerWait {
+		f.readerWg.Done()
+	}
+	return nil
+}
+
+func (f *frameBuffer[T]) Write(p []T) error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	if f.eof {
+		f.mu.Unlock()
+		return io.EOF
+	}
+	if f.size == len(f.frames) {
+		f.mu.Unlock()
+		return io.ErrShortBuffer
+	}
+
+	f.frames[f.writerIndex%len(f.frames)] = p
+	f.writerIndex++
+	f.size++
+	if f.maxDivergence < f.size {
+		f.maxDivergence = f.size
+	}
+
+	// Unblock reader.
+	readerWait := f.readerWait
+	if f.readerWait {
+		f.readerWait = false
+	}
+	f.mu.Unlock()
+
+	if readerWait {
+		f.readerWg.Done()
+	}
+	return nil
+}
+
+func (f *frameBuffer[T]) UnblockWriter() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.writerWait {
+		f.writerWait = false
+		f.writerWg.Done()
+		return true
+	}
+	return false
+}
+
+func (f *frameBuffer[T]) WriteBlocking(p []T) error {
+	f.mu.Lock()
+	// Was it recently closed.
+	if f.closed {
+		f.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	if f.eof {
+		f.mu.Unlock()
+		return io.EOF
+	}
+	if f.size == f.max {
+		// Wait for reader to read next frame.
+		if !f.writerWait {
+			f.writerWait = true
+			f.writerWg.Add(1)
+		}
+		f.writesBlocked++
+		f.mu.Unlock()
+		// Wait for next ReadFrame to free up a slot.
+		f.writerWg.Wait()
+		// Try Non-Blocking write. This may return error.
+		return f.Write(p)
+	}
+	f.frames[f.writerIndex%f.max] = p
+	f.writerIndex++
+	f.size++
+	if f.maxDivergence < f.size {
+		f.maxDivergence = f.size
+	}
+
+	readerWait := f.readerWait
+	if readerWait {
+		f.readerWait = false
+	}
+
+	f.mu.Unlock()
+
+	if readerWait {
+		f.readerWg.Done()
+	}
+	return nil
+}
+
+// Buffered returns the number of frames ReadFrame returns without blocking.
+func (f *frameBuffer[T]) Buffered() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.size
+}
+
+func (f *frameBuffer[T]) ReadFrame() ([]T, error) {
+	for {
+		f.mu.Lock()
+		if f.closed {
+			f.mu.Unlock()
+			return nil, io.ErrClosedPipe
+		}
+
+		if f.size == 0 {
+			if f.eof {
+				f.mu.Unlock()
+				return nil, io.EOF
+			}
+
+			// Wait for next write.
+			if !f.readerWait {
+				f.readerWait = true
+				f.readerWg.Add(1)
+			}
+			f.mu.Unlock()
+			f.readerWg.Wait()
+			continue
+		}
+
+		buf := f.frames[f.readerIndex%f.max]
+		// The reader owns the frame now, Close must not release it.
+		f.frames[f.readerIndex%f.max] = nil
+		f.readerIndex++
+		f.size--
+		f.samplesRead += len(buf)
+
+		// Notify writer if needed.
+		if f.writerWait {
+			f.writerWait = false
+			f.writerWg.Done()
+		}
+		f.mu.Unlock()
+
+		return buf, nil
+	}
+}
@@ -0,0 +1,358 @@
+package pcm
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/pool"
+)
+
+var (
+	ErrMixerFormat = errors.New("input format does not match mixer")
+	ErrNotInput    = errors.New("not an input of this mixer")
+)
+
+const (
+	// Number of frames buffered per input before its reader is blocked.
+	mixerInputQueue = 4
+	// Level above which the limiter starts compressing, relative to full scale.
+	limiterKnee = 0.75
+)
+
+// MixerInput is a participant of a Mixer.
+type MixerInput struct {
+	ID     int
+	reader Reader
+	mixer  *Mixer
+
+	frames chan []int16
+	done   chan struct{}
+	exited chan struct{} // Closed when pump returns.
+
+	gain    float32
+	muted   bool
+	eof     bool
+	stalled int
+
+	contrib []float32
+}
+
+// SetGain sets the linear gain applied to the input. 1 is unity.
+func (in *MixerInput) SetGain(gain float32) {
+	in.mixer.mu.Lock()
+	in.gain = gain
+	in.mixer.mu.Unlock()
+}
+
+func (in *MixerInput) Gain() float32 {
+	in.mixer.mu.Lock()
+	defer in.mixer.mu.Unlock()
+	return in.gain
+}
+
+// SetMuted removes the input from every mix. A muted participant still
+// receives its mix-minus output.
+func (in *MixerInput) SetMuted(muted bool) {
+	in.mixer.mu.Lock()
+	in.muted = muted
+	in.mixer.mu.Unlock()
+}
+
+func (in *MixerInput) Muted() bool {
+	in.mixer.mu.Lock()
+	defer in.mixer.mu.Unlock()
+	return in.muted
+}
+
+// Stalled returns the number of consecutive mixes the input had no frame for.
+func (in *MixerInput) Stalled() int {
+	in.mixer.mu.Lock()
+	defer in.mixer.mu.Unlock()
+	return in.stalled
+}
+
+func (in *MixerInput) pump() {
+	defer close(in.exited)
+	for {
+		frame, err := in.reader.ReadFrame()
+		if len(frame) > 0 {
+			select {
+			case in.frames <- frame:
+			case <-in.done:
+				in.reader.Release(frame)
+				return
+			}
+		}
+		if err != nil {
+			close(in.frames)
+			return
+		}
+	}
+}
+
+// Mix is the result of a single Mixer.Mix call. Frames must be returned with
+// Mixer.Release.
+type Mix struct {
+	// Full mix of every unmuted input, for recording.
+	Full []int16
+	// Mix-minus output per input ID. Each output holds every unmuted input
+	// except the participant's own.
+	Outputs map[int][]int16
+}
+
+// Mixer sums a dynamic set of Readers with a common sample rate, channel count
+// and ptime. Every input is read on its own goroutine so a stalled input only
+// contributes silence instead of holding up the mix. Inputs that reach EOF are
+// removed and closed.
+//
+// Mixing is done in float32 and the outputs go through a soft limiter so the
+// sum of loud participants doesn't wrap or hard clip.
+type Mixer struct {
+	sampleRate int
+	channels   int
+	ptime      int
+	pcmPool    *pool.PCM
+
+	inputs []*MixerInput
+	nextID int
+	full   []float32
+	mixes  int
+
+	closed bool
+	mu     sync.Mutex
+}
+
+func NewMixer(sampleRate, channels, ptime int) (*Mixer, error) {
+	p, err := pool.Of(sampleRate, channels, ptime)
+	if err != nil {
+		return nil, err
+	}
+	pcmPool := p.ForPtime(ptime)
+	return &Mixer{
+		sampleRate: sampleRate,
+		channels:   channels,
+		ptime:      ptime,
+		pcmPool:    pcmPool,
+		full:       make([]float32, pcmPool.FrameSize*channels),
+	}, nil
+}
+
+func (m *Mixer) SampleRate() int {
+	return m.sampleRate
+}
+
+func (m *Mixer) Channels() int {
+	return m.channels
+}
+
+func (m *Mixer) FrameSize() int {
+	return m.pcmPool.FrameSize
+}
+
+func (m *Mixer) Ptime() time.Duration {
+	return time.Duration(m.ptime) * time.Millisecond
+}
+
+// Elapsed returns the duration mixed so far.
+func (m *Mixer) Elapsed() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return time.Duration(m.mixes) * m.Ptime()
+}
+
+// Release returns the frames of a Mix to the pool.
+func (m *Mixer) Release(mix *Mix) {
+	if mix == nil {
+		return
+	}
+	if mix.Full != nil {
+		m.pcmPool.Release(mix.Full)
+		mix.Full = nil
+	}
+	for id, frame := range mix.Outputs {
+		m.pcmPool.Release(frame)
+		delete(mix.Outputs, id)
+	}
+}
+
+// Add starts mixing reader. The reader must match the mixer's sample rate,
+// channel count and frame size, and Close must unblock its ReadFrame, as it
+// does for Buffer.
+func (m *Mixer) Add(reader Reader) (*MixerInput, error) {
+	if reader.SampleRate() != m.sampleRate ||
+		reader.Channels() != m.channels ||
+		reader.FrameSize() != m.pcmPool.FrameSize {
+		return nil, ErrMixerFormat
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, io.ErrClosedPipe
+	}
+	m.nextID++
+	in := &MixerInput{
+		ID:      m.nextID,
+		reader:  reader,
+		mixer:   m,
+		frames:  make(chan []int16, mixerInputQueue),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+		gain:    1,
+		contrib: make([]float32, len(m.full)),
+	}
+	m.inputs = append(m.inputs, in)
+	go in.pump()
+	return in, nil
+}
+
+// Remove stops mixing an input and closes its reader.
+func (m *Mixer) Remove(in *MixerInput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, input := range m.inputs {
+		if input == in {
+			m.inputs = append(m.inputs[:i], m.inputs[i+1:]...)
+			return m.stop(in)
+		}
+	}
+	return ErrNotInput
+}
+
+func (m *Mixer) stop(in *MixerInput) error {
+	close(in.done)
+	err := in.reader.Close()
+	// The pump may still queue a frame it read before seeing done, so wait
+	// for it before releasing what it queued.
+	<-in.exited
+	for {
+		select {
+		case frame, ok := <-in.frames:
+			if !ok {
+				return err
+			}
+			in.reader.Release(frame)
+		default:
+			return err
+		}
+	}
+}
+
+// Inputs returns the current inputs.
+func (m *Mixer) Inputs() []*MixerInput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*MixerInput(nil), m.inputs...)
+}
+
+// Mix takes the next available frame of every input and produces the full mix
+// and a mix-minus output for each input. It never blocks on an input. Mix is
+// meant to be called once per ptime by the caller's clock.
+func (m *Mixer) Mix() (*Mix, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, io.ErrClosedPipe
+	}
+
+	for i := range m.full {
+		m.full[i] = 0
+	}
+
+	active := m.inputs[:0]
+	for _, in := range m.inputs {
+		for i := range in.contrib {
+			in.contrib[i] = 0
+		}
+
+		var frame []int16
+		select {
+		case f, ok := <-in.frames:
+			if !ok {
+				in.eof = true
+			}
+			frame = f
+		default:
+		}
+		if in.eof {
+			close(in.done)
+			_ = in.reader.Close()
+			continue
+		}
+		active = append(active, in)
+
+		if frame == nil {
+			in.stalled++
+			continue
+		}
+		in.stalled = 0
+		if !in.muted {
+			gain := in.gain * int16ScaleInv
+			contrib := in.contrib[:len(frame)]
+			for i, s := range frame {
+				v := float32(s) * gain
+				contrib[i] = v
+				m.full[i] += v
+			}
+		}
+		in.reader.Release(frame)
+	}
+	m.inputs = active
+	m.mixes++
+
+	mix := &Mix{
+		Full:    m.pcmPool.Get(),
+		Outputs: make(map[int][]int16, len(m.inputs)),
+	}
+	limit(mix.Full, m.full)
+	for _, in := range m.inputs {
+		out := m.pcmPool.Get()
+		for i, v := range m.full {
+			out[i] = SaturateInt16(softClip(v-in.contrib[i]) * int16Scale)
+		}
+		mix.Outputs[in.ID] = out
+	}
+	return mix, nil
+}
+
+// Close removes every input and closes their readers.
+func (m *Mixer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return io.ErrClosedPipe
+	}
+	m.closed = true
+	for _, in := range m.inputs {
+		_ = m.stop(in)
+	}
+	m.inputs = nil
+	return nil
+}
+
+func limit(dst []int16, src []float32) {
+	for i, v := range src {
+		dst[i] = SaturateInt16(softClip(v) * int16Scale)
+	}
+}
+
+// softClip leaves samples below the knee untouched and smoothly compresses
+// anything above it so the output approaches but never exceeds full scale.
+func softClip(v float32) float32 {
+	a := v
+	if a < 0 {
+		a = -a
+	}
+	if a <= limiterKnee {
+		return v
+	}
+	const r = 1 - limiterKnee
+	c := limiterKnee + r*float32(math.Tanh(float64((a-limiterKnee)/r)))
+	if v < 0 {
+		return -c
+	}
+	return c
+}
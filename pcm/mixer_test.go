@@ -0,0 +1,204 @@
+package pcm
+
+import (
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newMixerSource(t *testing.T, value int16) *Buffer {
+	b, err := NewBuffer(8000, 1, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeConstant(t, b, value)
+	return b
+}
+
+func writeConstant(t *testing.T, b *Buffer, value int16) {
+	frame := b.Alloc()
+	for i := range frame {
+		frame[i] = value
+	}
+	if err := b.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitQueued waits for the input pumps to pick up the written frames.
+func waitQueued(t *testing.T, inputs ...*MixerInput) {
+	deadline := time.Now().Add(time.Second)
+	for _, in := range inputs {
+		for len(in.frames) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Input %d never queued a frame", in.ID)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestMixer_MixMinus(t *testing.T) {
+	m, err := NewMixer(8000, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	a, _ := m.Add(newMixerSource(t, 1000))
+	b, _ := m.Add(newMixerSource(t, 2000))
+	c, _ := m.Add(newMixerSource(t, 4000))
+	b.SetGain(0.5)
+	waitQueued(t, a, b, c)
+
+	mix, err := m.Mix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Release(mix)
+
+	if mix.Full[0] != 6000 {
+		t.Errorf("Unexpected full mix %d", mix.Full[0])
+	}
+	expected := map[int]int16{a.ID: 5000, b.ID: 5000, c.ID: 2000}
+	for id, value := range expected {
+		if mix.Outputs[id][0] != value {
+			t.Errorf("Input %d: got %d, expected %d", id, mix.Outputs[id][0], value)
+		}
+	}
+}
+
+func TestMixer_MuteStallEOF(t *testing.T) {
+	m, err := NewMixer(8000, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	sa := newMixerSource(t, 1000)
+	sb := newMixerSource(t, 2000)
+	a, _ := m.Add(sa)
+	b, _ := m.Add(sb)
+	a.SetMuted(true)
+	waitQueued(t, a, b)
+
+	mix, err := m.Mix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix.Full[0] != 2000 || mix.Outputs[a.ID][0] != 2000 || mix.Outputs[b.ID][0] != 0 {
+		t.Errorf("Unexpected muted mix %d %d %d", mix.Full[0], mix.Outputs[a.ID][0], mix.Outputs[b.ID][0])
+	}
+	m.Release(mix)
+
+	// a stalls, b reaches EOF.
+	_ = sb.WriteFinal()
+	deadline := time.Now().Add(time.Second)
+	for len(m.Inputs()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Input at EOF was not removed")
+		}
+		mix, err = m.Mix()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Release(mix)
+		time.Sleep(time.Millisecond)
+	}
+	if a.Stalled() == 0 {
+		t.Errorf("Expected stalled input to be reported")
+	}
+	if err := m.Remove(b); err != ErrNotInput {
+		t.Errorf("Expected ErrNotInput, got %v", err)
+	}
+	if err := m.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMixer_Limiter(t *testing.T) {
+	m, err := NewMixer(8000, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	a, _ := m.Add(newMixerSource(t, 30000))
+	b, _ := m.Add(newMixerSource(t, 30000))
+	waitQueued(t, a, b)
+
+	mix, err := m.Mix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Release(mix)
+	if mix.Full[0] <= 30000 || mix.Full[0] > 32767 {
+		t.Errorf("Unexpected limited sample %d", mix.Full[0])
+	}
+	// A single input above the knee is compressed but not past its own level.
+	if out := mix.Outputs[a.ID][0]; out <= 24576 || out > 30000 {
+		t.Errorf("Unexpected mix-minus sample %d", out)
+	}
+}
+
+func TestMixer_Format(t *testing.T) {
+	m, err := NewMixer(16000, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Add(newMixerSource(t, 0)); err != ErrMixerFormat {
+		t.Errorf("Expected ErrMixerFormat, got %v", err)
+	}
+}
+
+// closingReader returns a frame when it is closed, as a reader whose ReadFrame
+// was decoding when Close was called, and then io.EOF.
+type closingReader struct {
+	*Buffer
+	closed   chan struct{}
+	reads    int32
+	released int32
+}
+
+func (r *closingReader) ReadFrame() ([]int16, error) {
+	<-r.closed
+	if atomic.AddInt32(&r.reads, 1) > 1 {
+		return nil, io.EOF
+	}
+	return r.Alloc(), nil
+}
+
+func (r *closingReader) Release(p []int16) {
+	atomic.AddInt32(&r.released, 1)
+	r.Buffer.Release(p)
+}
+
+func (r *closingReader) Close() error {
+	close(r.closed)
+	return r.Buffer.Close()
+}
+
+func TestMixer_RemoveReleases(t *testing.T) {
+	m, err := NewMixer(8000, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	buffer, err := NewBuffer(8000, 1, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &closingReader{Buffer: buffer, closed: make(chan struct{})}
+	in, err := m.Add(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove(in); err != nil {
+		t.Fatal(err)
+	}
+	if released := atomic.LoadInt32(&r.released); released != 1 {
+		t.Fatalf("Released %d frames, expected 1", released)
+	}
+}
@@ -14,7 +14,11 @@ type Reader interface {
 	// Clock speed in hertz. (i.e. 16000 for 16Khz)
 	SampleRate() int
 
-	// Number of 16bit integers per frame.
+	// Number of interleaved channels.
+	Channels() int
+
+	// Number of samples per channel per frame. Frames are interleaved, so a
+	// frame holds FrameSize() * Channels() 16bit integers.
 	FrameSize() int
 
 	// Frame duration.
@@ -114,6 +114,10 @@ func (r *ReplayReader) SampleRate() int {
 }
 
 // Number of 16bit integers per frame.
+func (r *ReplayReader) Channels() int {
+	return r.reader.Channels()
+}
+
 func (r *ReplayReader) FrameSize() int {
 	return r.reader.FrameSize()
 }
@@ -1,24 +1,45 @@
 package pcm
 
 import (
+	"encoding/binary"
 	"errors"
 	"github.com/go-audio/riff"
 	"github.com/go-audio/wav"
 	"io"
 	"os"
-	"reflect"
 	"sync"
 	"time"
-	"unsafe"
 
+	"github.com/pidato/audio/g711"
+	"github.com/pidato/audio/g726"
+	"github.com/pidato/audio/gsm"
 	"github.com/pidato/audio/pool"
 )
 
 var (
 	ErrNoChunk  = errors.New("no chunk")
 	ErrNot16bit = errors.New("not 16bit")
+	ErrNot8bit  = errors.New("not 8bit")
 )
 
+// WAV audio formats.
+const (
+	WavFormatPCM      = 1
+	WavFormatMSADPCM  = 2
+	WavFormatAlaw     = 6
+	WavFormatUlaw     = 7
+	WavFormatIMAADPCM = 0x11
+	WavFormatGSM610   = 0x31 // WAV49
+	// ITU G.726 ADPCM and the older APICOM code for it.
+	WavFormatG726       = 0x45
+	WavFormatG726Apicom = 0x64
+)
+
+// wavCodec decodes blocks of a compressed WAV format.
+type wavCodec interface {
+	Decode(block []byte, frame []int16) (int, error)
+}
+
 func OpenWavFile(filename string, ptime int) (*WavReader, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -46,17 +67,31 @@ type WavReader struct {
 	pool           *pool.Pool
 	pcmPool        *pool.PCM
 	sampleRate     int
+	channels       int
 	bytesPerSample uint16
 	samplesRead    int
 	sampleDuration time.Duration
 
+	// Decodes A-law and u-law samples.
+	decode  func(uint8) int16
+	encoded []byte
+
+	// Decodes blocks of blockSize bytes to at most blockSamples samples.
+	codec        wavCodec
+	blockSize    int
+	blockSamples int
+	decoded      []int16
+	pending      []int16 // Decoded samples not read yet.
+	remaining    int     // Samples left by the fact chunk, -1 without one.
+
 	chunk *riff.Chunk
 
 	mu sync.Mutex
 }
 
 func OpenWav(reader io.ReadCloser, ptime int) (*WavReader, error) {
-	r := NewSeekReader(reader)
+	recorder := &headerRecorder{ReadCloser: reader}
+	r := NewSeekReader(recorder)
 	w := &WavReader{
 		reader:         r,
 		decoder:        wav.NewDecoder(r),
@@ -72,6 +107,7 @@ func OpenWav(reader io.ReadCloser, ptime int) (*WavReader, error) {
 			return nil, w.decoder.Err()
 		}
 	}
+	recorder.done = true
 	w.chunk = w.decoder.PCMChunk
 	if w.chunk == nil {
 		_ = w.Close()
@@ -79,16 +115,64 @@ func OpenWav(reader io.ReadCloser, ptime int) (*WavReader, error) {
 	}
 
 	w.sampleRate = int(w.decoder.SampleRate)
+	w.channels = int(w.decoder.NumChans)
 	w.bytesPerSample = (w.decoder.BitDepth-1)/8 + 1
 	w.sampleDuration = time.Second / time.Duration(w.sampleRate)
 
-	if w.decoder.BitDepth != 16 {
-		_ = w.Close()
-		return nil, ErrNot16bit
+	switch w.decoder.WavAudioFormat {
+	case WavFormatAlaw, WavFormatUlaw:
+		if w.decoder.BitDepth != 8 {
+			_ = w.Close()
+			return nil, ErrNot8bit
+		}
+		if w.decoder.WavAudioFormat == WavFormatAlaw {
+			w.decode = g711.DecodeAlawFrame
+		} else {
+			w.decode = g711.DecodeUlawFrame
+		}
+	case WavFormatG726, WavFormatG726Apicom:
+		// The bit depth is the code size. WAV files use the AAL2 packing.
+		bits := int(w.decoder.BitDepth)
+		dec, err := g726.NewFrameDecoder(bits*g726.SampleRate, g726.AAL2)
+		if err != nil || w.channels != 1 {
+			_ = w.Close()
+			return nil, ErrWavFormat
+		}
+		w.codec = dec
+		w.blockSamples = 160
+		w.blockSize = w.blockSamples * bits / 8
+	case WavFormatGSM610:
+		if w.channels != 1 {
+			_ = w.Close()
+			return nil, ErrWavFormat
+		}
+		w.codec = gsm.NewWAV49Decoder()
+		w.blockSize = gsm.WAV49BlockSize
+		w.blockSamples = gsm.WAV49FrameSize
+	case WavFormatIMAADPCM, WavFormatMSADPCM:
+		var err error
+		w.codec, w.blockSize, w.blockSamples, err = newADPCMDecoder(
+			w.decoder.WavAudioFormat, w.channels, recorder.chunk("fmt "))
+		if err != nil {
+			_ = w.Close()
+			return nil, err
+		}
+	default:
+		if w.decoder.BitDepth != 16 {
+			_ = w.Close()
+			return nil, ErrNot16bit
+		}
+	}
+
+	// The last block of a compressed file is padded. The fact chunk has the
+	// number of samples per channel, without the padding.
+	w.remaining = -1
+	if fact := recorder.chunk("fact"); w.codec != nil && len(fact) >= 4 {
+		w.remaining = int(binary.LittleEndian.Uint32(fact)) * w.channels
 	}
 
 	var err error
-	w.pool, err = pool.Of(w.sampleRate, ptime)
+	w.pool, err = pool.Of(w.sampleRate, w.channels, ptime)
 	if err != nil {
 		_ = w.Close()
 		return nil, err
@@ -103,7 +187,7 @@ func (w *WavReader) WavAudioFormat() uint16 {
 }
 
 func (w *WavReader) Elapsed() time.Duration {
-	return time.Duration(w.samplesRead) * w.sampleDuration
+	return time.Duration(w.samplesRead/w.channels) * w.sampleDuration
 }
 
 func (w *WavReader) Close() error {
@@ -134,6 +218,10 @@ func (f *WavReader) SampleRate() int {
 	return f.sampleRate
 }
 
+func (f *WavReader) Channels() int {
+	return f.channels
+}
+
 func (f *WavReader) FrameSize() int {
 	return f.pcmPool.FrameSize
 }
@@ -163,6 +251,8 @@ func (w *WavReader) ReadFrame() ([]int16, error) {
 	return buf, err
 }
 
+// Read reads up to len(buffer) samples. A-law, u-law and compressed files are
+// decoded to 16bit samples.
 func (w *WavReader) Read(buffer []int16) (n int, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -171,47 +261,112 @@ func (w *WavReader) Read(buffer []int16) (n int, err error) {
 		return 0, io.ErrShortBuffer
 	}
 
-	frameBuffer := *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{
-		Data: (uintptr)(unsafe.Pointer(&buffer[0])),
-		Len:  len(buffer) * 2,
-		Cap:  len(buffer) * 2,
-	}))
-	buf := frameBuffer
-	count := 0
+	if w.codec != nil {
+		if w.remaining >= 0 {
+			if w.remaining == 0 {
+				return 0, io.EOF
+			}
+			if len(buffer) > w.remaining {
+				buffer = buffer[:w.remaining]
+			}
+		}
+		for n < len(buffer) && err == nil {
+			if len(w.pending) == 0 {
+				if err = w.readBlock(); err != nil {
+					break
+				}
+			}
+			count := copy(buffer[n:], w.pending)
+			w.pending = w.pending[count:]
+			n += count
+		}
+		if w.remaining >= 0 {
+			w.remaining -= n
+		}
+		w.samplesRead += n
+		return n, err
+	}
+
+	if w.decode != nil {
+		if cap(w.encoded) < len(buffer) {
+			w.encoded = make([]byte, len(buffer))
+		}
+		encoded := w.encoded[:len(buffer)]
+		n, err = w.read(encoded)
+		for i, b := range encoded[:n] {
+			buffer[i] = w.decode(b)
+		}
+		w.samplesRead += n
+		return n, err
+	}
+
+	// Samples are little-endian whatever the host byte order.
+	if cap(w.encoded) < len(buffer)*2 {
+		w.encoded = make([]byte, len(buffer)*2)
+	}
+	encoded := w.encoded[:len(buffer)*2]
+	n, err = w.read(encoded)
+	n /= 2
+	for i := 0; i < n; i++ {
+		buffer[i] = int16(binary.LittleEndian.Uint16(encoded[2*i:]))
+	}
+	w.samplesRead += n
+	return n, err
+}
+
+// readBlock decodes the next block into pending. A short block at the end of
+// the file is decoded and io.EOF returned by the next call.
+func (w *WavReader) readBlock() error {
+	if w.decoded == nil {
+		w.encoded = make([]byte, w.blockSize)
+		w.decoded = make([]int16, w.blockSamples)
+	}
+	count, err := w.read(w.encoded)
+	if count == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return err
+	}
+	n, err := w.codec.Decode(w.encoded[:count], w.decoded)
+	if err != nil {
+		return err
+	}
+	w.pending = w.decoded[:n]
+	return nil
+}
 
-	for {
+// read fills buf from the data chunks and returns the number of bytes read.
+func (w *WavReader) read(buf []byte) (count int, err error) {
+	for count < len(buf) {
 		if w.chunk == nil || w.chunk.IsFullyRead() {
 			w.chunk, err = w.decoder.NextChunk()
 			if err != nil {
 				if err.Error() == "error reading chunk header - EOF" {
-					return n, io.EOF
+					return count, io.EOF
 				}
-				return n, err
+				return count, err
+			}
+			// Skip metadata after the audio.
+			if w.chunk.ID != riff.DataFormatID {
+				w.chunk.Drain()
+				w.chunk = nil
+				continue
 			}
 		}
 
 		var read int
-		read, err = w.chunk.Read(buf)
+		read, err = w.chunk.Read(buf[count:])
+		count += read
 		if err != nil {
 			if errors.Is(err, io.EOF) {
+				w.chunk = nil
 				continue
 			}
-			return n, err
-		}
-		if read == 0 {
-			err = io.EOF
-			continue
-		}
-
-		buf = frameBuffer[count:read]
-		count += read
-		n += read / 2
-		w.samplesRead += n
-
-		if len(buf) == cap(buf) {
-			return n, nil
+			return count, err
 		}
 	}
+	return count, nil
 }
 
 // sampleDecodeFunc returns a function that can be used to convert
@@ -0,0 +1,121 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pidato/audio/adpcm"
+)
+
+// wavEncoder encodes blocks of a compressed WAV format.
+type wavEncoder interface {
+	SamplesPerBlock() int
+	Encode(frame []int16, block []byte) (int, error)
+}
+
+// adpcmBlockSize returns the block align used for ADPCM files, 256 bytes per
+// channel up to 11025Hz and doubling with the sample rate above that.
+func adpcmBlockSize(sampleRate, channels int) int {
+	size := 256
+	for rate := 11025; rate < sampleRate; rate *= 2 {
+		size *= 2
+	}
+	return size * channels
+}
+
+// newADPCMEncoder returns an encoder and the fmt chunk extension.
+func newADPCMEncoder(format uint16, channels, blockSize int) (wavEncoder, []byte, error) {
+	if format == WavFormatIMAADPCM {
+		enc, err := adpcm.NewIMAEncoder(channels, blockSize)
+		if err != nil {
+			return nil, nil, ErrWavFormat
+		}
+		return enc, appendUint16(nil, uint16(enc.SamplesPerBlock())), nil
+	}
+	enc, err := adpcm.NewMSEncoder(channels, blockSize)
+	if err != nil {
+		return nil, nil, ErrWavFormat
+	}
+	extension := appendUint16(nil, uint16(enc.SamplesPerBlock()))
+	extension = appendUint16(extension, uint16(len(adpcm.MSCoefficients)))
+	for _, coef := range adpcm.MSCoefficients {
+		extension = appendUint16(extension, uint16(coef[0]))
+		extension = appendUint16(extension, uint16(coef[1]))
+	}
+	return enc, extension, nil
+}
+
+// newADPCMDecoder returns a decoder for the fmt chunk and its block sizes in
+// bytes and samples.
+func newADPCMDecoder(format uint16, channels int, fmtChunk []byte) (wavCodec, int, int, error) {
+	if len(fmtChunk) < 16 {
+		return nil, 0, 0, ErrWavFormat
+	}
+	blockSize := int(binary.LittleEndian.Uint16(fmtChunk[12:]))
+	if format == WavFormatIMAADPCM {
+		dec, err := adpcm.NewIMADecoder(channels, blockSize)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return dec, blockSize, dec.SamplesPerBlock() * channels, nil
+	}
+
+	// The coefficients follow cbSize, the samples per block and their count.
+	var coefs [][2]int
+	if len(fmtChunk) >= 22 {
+		count := int(binary.LittleEndian.Uint16(fmtChunk[20:]))
+		b := fmtChunk[22:]
+		for i := 0; i < count && len(b) >= 4; i++ {
+			coefs = append(coefs, [2]int{
+				int(int16(binary.LittleEndian.Uint16(b))),
+				int(int16(binary.LittleEndian.Uint16(b[2:]))),
+			})
+			b = b[4:]
+		}
+	}
+	dec, err := adpcm.NewMSDecoder(channels, blockSize, coefs)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return dec, blockSize, dec.SamplesPerBlock() * channels, nil
+}
+
+// headerRecorder keeps the bytes read until the audio starts, so fields of
+// the fmt chunk that go-audio/wav skips can be parsed.
+type headerRecorder struct {
+	io.ReadCloser
+	header []byte
+	done   bool
+}
+
+func (r *headerRecorder) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if !r.done {
+		r.header = append(r.header, b[:n]...)
+	}
+	return n, err
+}
+
+// chunk returns the body of the first header chunk with the id.
+func (r *headerRecorder) chunk(id string) []byte {
+	if len(r.header) < 12 {
+		return nil
+	}
+	b := r.header[12:]
+	for len(b) >= 8 {
+		size := int(binary.LittleEndian.Uint32(b[4:]))
+		body := b[8:]
+		if size > len(body) {
+			return nil
+		}
+		if string(b[:4]) == id {
+			return body[:size]
+		}
+		size += size & 1
+		if size > len(body) {
+			return nil
+		}
+		b = body[size:]
+	}
+	return nil
+}
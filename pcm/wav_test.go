@@ -1,9 +1,15 @@
 package pcm
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"testing"
+
+	"github.com/pidato/audio/g726"
+	"github.com/pidato/audio/gsm"
 )
 
 func TestOpenWav_Read(t *testing.T) {
@@ -67,3 +73,142 @@ func TestOpenWav_PoolAlloc(t *testing.T) {
 
 	fmt.Println("EOF")
 }
+
+func TestOpenWav_G726(t *testing.T) {
+	input := make([]int16, 8000)
+	for i := range input {
+		input[i] = int16(10000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	// End on a partial block.
+	input = input[:len(input)-4]
+	enc, _ := g726.NewFrameEncoder(g726.Rate32000, g726.AAL2)
+	data := make([]byte, len(input)/2)
+	if _, err := enc.Encode(input, data); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]int16, len(input))
+	dec, _ := g726.NewFrameDecoder(g726.Rate32000, g726.AAL2)
+	if _, err := dec.Decode(data, want); err != nil {
+		t.Fatal(err)
+	}
+
+	var header []byte
+	header = append(header, "RIFF"...)
+	header = appendUint32(header, uint32(4+8+18+8+len(data)+8+4))
+	header = append(header, "WAVEfmt "...)
+	header = appendUint32(header, 18)
+	header = appendUint16(header, WavFormatG726)
+	header = appendUint16(header, 1)
+	header = appendUint32(header, 8000)
+	header = appendUint32(header, 4000)
+	header = appendUint16(header, 1)
+	header = appendUint16(header, 4)
+	header = appendUint16(header, 0)
+	header = append(header, "data"...)
+	header = appendUint32(header, uint32(len(data)))
+	file := append(header, data...)
+	file = append(file, "LIST"...)
+	file = appendUint32(file, 4)
+	file = append(file, "INFO"...)
+
+	r, err := OpenWav(ioutil.NopCloser(bytes.NewReader(file)), Ptime20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var output []int16
+	for {
+		frame, err := r.ReadFrame()
+		output = append(output, frame...)
+		if frame != nil {
+			r.Release(frame)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(output) != len(want) {
+		t.Fatalf("read %d samples, want %d", len(output), len(want))
+	}
+	for i := range want {
+		if output[i] != want[i] {
+			t.Fatalf("sample %d is %d, want %d", i, output[i], want[i])
+		}
+	}
+}
+
+func TestOpenWav_GSM(t *testing.T) {
+	input := make([]int16, 3200)
+	for i := range input {
+		input[i] = int16(8000 * math.Sin(2*math.Pi*300*float64(i)/8000))
+	}
+	enc := gsm.NewWAV49Encoder()
+	dec := gsm.NewWAV49Decoder()
+	var data []byte
+	var want []int16
+	block := make([]byte, gsm.WAV49BlockSize)
+	frame := make([]int16, gsm.WAV49FrameSize)
+	for i := 0; i < len(input); i += gsm.WAV49FrameSize {
+		if _, err := enc.Encode(input[i:i+gsm.WAV49FrameSize], block); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dec.Decode(block, frame); err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, block...)
+		want = append(want, frame...)
+	}
+
+	var file []byte
+	file = append(file, "RIFF"...)
+	file = appendUint32(file, uint32(4+8+20+12+8+len(data)))
+	file = append(file, "WAVEfmt "...)
+	file = appendUint32(file, 20)
+	file = appendUint16(file, WavFormatGSM610)
+	file = appendUint16(file, 1)
+	file = appendUint32(file, 8000)
+	file = appendUint32(file, 1625)
+	file = appendUint16(file, gsm.WAV49BlockSize)
+	file = appendUint16(file, 0)
+	file = appendUint16(file, 2)
+	file = appendUint16(file, gsm.WAV49FrameSize)
+	// The last block is padding after 100 samples.
+	want = want[:len(want)-gsm.WAV49FrameSize+100]
+	file = append(file, "fact"...)
+	file = appendUint32(file, 4)
+	file = appendUint32(file, uint32(len(want)))
+	file = append(file, "data"...)
+	file = appendUint32(file, uint32(len(data)))
+	file = append(file, data...)
+
+	r, err := OpenWav(ioutil.NopCloser(bytes.NewReader(file)), Ptime20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var output []int16
+	for {
+		frame, err := r.ReadFrame()
+		output = append(output, frame...)
+		if frame != nil {
+			r.Release(frame)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(output) != len(want) {
+		t.Fatalf("read %d samples, want %d", len(output), len(want))
+	}
+	for i := range want {
+		if output[i] != want[i] {
+			t.Fatalf("sample %d is %d, want %d", i, output[i], want[i])
+		}
+	}
+}
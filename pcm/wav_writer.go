@@ -0,0 +1,275 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pidato/audio/g711"
+)
+
+var ErrWavFormat = errors.New("unsupported wav format")
+
+// WavWriter writes interleaved 16bit frames to a WAV file as 16bit PCM,
+// A-law, u-law or ADPCM. The sizes in the header are filled in by Close.
+type WavWriter struct {
+	writer io.WriteSeeker
+	closer io.Closer
+
+	format     uint16
+	sampleRate int
+	channels   int
+	encode     func(int16) uint8
+
+	// Encodes ADPCM blocks. Samples are kept in pending until a block is
+	// full.
+	codec     wavEncoder
+	blockSize int
+	pending   []int16
+
+	dataPos int64 // Offset of the data chunk size.
+	factPos int64 // Offset of the fact chunk sample count, 0 for PCM.
+	written int   // Bytes of audio written.
+	samples int   // Samples written.
+	buf     []byte
+
+	err    error
+	closed bool
+	mu     sync.Mutex
+}
+
+// CreateWavFile creates a WAV file. format is WavFormatPCM, WavFormatAlaw,
+// WavFormatUlaw, WavFormatIMAADPCM or WavFormatMSADPCM. Close also closes the
+// file.
+func CreateWavFile(filename string, sampleRate, channels int, format uint16) (*WavWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWavWriter(file, sampleRate, channels, format)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	w.closer = file
+	return w, nil
+}
+
+// NewWavWriter writes a WAV header to writer. Close doesn't close writer.
+func NewWavWriter(writer io.WriteSeeker, sampleRate, channels int, format uint16) (*WavWriter, error) {
+	w := &WavWriter{
+		writer:     writer,
+		format:     format,
+		sampleRate: sampleRate,
+		channels:   channels,
+	}
+	if sampleRate <= 0 || channels <= 0 {
+		return nil, ErrWavFormat
+	}
+	bitDepth := 16
+	blockAlign := 0
+	var extension []byte
+	switch format {
+	case WavFormatPCM:
+	case WavFormatAlaw:
+		w.encode = g711.EncodeAlawFrame
+		bitDepth = 8
+	case WavFormatUlaw:
+		w.encode = g711.EncodeUlawFrame
+		bitDepth = 8
+	case WavFormatIMAADPCM, WavFormatMSADPCM:
+		var err error
+		blockAlign = adpcmBlockSize(sampleRate, channels)
+		w.blockSize = blockAlign
+		w.codec, extension, err = newADPCMEncoder(format, channels, blockAlign)
+		if err != nil {
+			return nil, err
+		}
+		bitDepth = 4
+	default:
+		return nil, ErrWavFormat
+	}
+
+	byteRate := sampleRate * channels * bitDepth / 8
+	if blockAlign == 0 {
+		blockAlign = channels * bitDepth / 8
+	} else {
+		byteRate = sampleRate * blockAlign / w.codec.SamplesPerBlock()
+	}
+	header := make([]byte, 0, 58+len(extension))
+	header = append(header, "RIFF"...)
+	header = appendUint32(header, 0)
+	header = append(header, "WAVE"...)
+	header = append(header, "fmt "...)
+	if format == WavFormatPCM {
+		header = appendUint32(header, 16)
+	} else {
+		// Non-PCM formats have a cbSize field.
+		header = appendUint32(header, uint32(18+len(extension)))
+	}
+	header = appendUint16(header, format)
+	header = appendUint16(header, uint16(channels))
+	header = appendUint32(header, uint32(sampleRate))
+	header = appendUint32(header, uint32(byteRate))
+	header = appendUint16(header, uint16(blockAlign))
+	header = appendUint16(header, uint16(bitDepth))
+	if format != WavFormatPCM {
+		header = appendUint16(header, uint16(len(extension)))
+		header = append(header, extension...)
+		header = append(header, "fact"...)
+		header = appendUint32(header, 4)
+		w.factPos = int64(len(header))
+		header = appendUint32(header, 0)
+	}
+	header = append(header, "data"...)
+	w.dataPos = int64(len(header))
+	header = appendUint32(header, 0)
+
+	if _, err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (w *WavWriter) SampleRate() int {
+	return w.sampleRate
+}
+
+func (w *WavWriter) Channels() int {
+	return w.channels
+}
+
+// Write appends an interleaved frame.
+func (w *WavWriter) Write(frame []int16) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.codec != nil {
+		w.pending = append(w.pending, frame...)
+		blockSamples := w.codec.SamplesPerBlock() * w.channels
+		for len(w.pending) >= blockSamples {
+			if err := w.writeBlock(w.pending[:blockSamples]); err != nil {
+				return err
+			}
+			w.pending = w.pending[:copy(w.pending, w.pending[blockSamples:])]
+		}
+		return nil
+	}
+
+	var buf []byte
+	if w.encode != nil {
+		buf = w.scratch(len(frame))
+		for i, s := range frame {
+			buf[i] = w.encode(s)
+		}
+	} else {
+		buf = w.scratch(len(frame) * 2)
+		for i, s := range frame {
+			binary.LittleEndian.PutUint16(buf[i*2:], uint16(s))
+		}
+	}
+	return w.write(buf, len(frame))
+}
+
+func (w *WavWriter) write(buf []byte, samples int) error {
+	n, err := w.writer.Write(buf)
+	w.written += n
+	w.samples += samples
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// writeBlock encodes and writes an ADPCM block.
+func (w *WavWriter) writeBlock(frame []int16) error {
+	buf := w.scratch(w.blockSize)
+	n, err := w.codec.Encode(frame, buf)
+	if err != nil {
+		return err
+	}
+	return w.write(buf[:n], len(frame))
+}
+
+func (w *WavWriter) scratch(size int) []byte {
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+	return w.buf[:size]
+}
+
+// Close fills in the header sizes.
+func (w *WavWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	err := w.finish()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (w *WavWriter) finish() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.pending) > 0 {
+		if err := w.writeBlock(w.pending); err != nil {
+			return err
+		}
+	}
+	size := w.written
+	// Chunks are word aligned.
+	if size%2 == 1 {
+		if _, err := w.writer.Write([]byte{0}); err != nil {
+			return err
+		}
+		size++
+	}
+
+	var b [4]byte
+	patch := func(offset int64, v uint32) error {
+		binary.LittleEndian.PutUint32(b[:], v)
+		if _, err := w.writer.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := w.writer.Write(b[:])
+		return err
+	}
+	if err := patch(4, uint32(w.dataPos+4+int64(size)-8)); err != nil {
+		return err
+	}
+	if w.factPos > 0 {
+		if err := patch(w.factPos, uint32(w.samples/w.channels)); err != nil {
+			return err
+		}
+	}
+	if err := patch(w.dataPos, uint32(w.written)); err != nil {
+		return err
+	}
+	_, err := w.writer.Seek(0, io.SeekEnd)
+	return err
+}
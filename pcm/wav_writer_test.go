@@ -0,0 +1,164 @@
+package pcm
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWavWriter_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := make([]int16, 8000)
+	for i := range input {
+		input[i] = int16(10000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+
+	for _, test := range []struct {
+		format    uint16
+		tolerance float64
+	}{
+		{WavFormatPCM, 0},
+		{WavFormatAlaw, 400},
+		{WavFormatUlaw, 400},
+	} {
+		filename := filepath.Join(dir, "test.wav")
+		w, err := CreateWavFile(filename, 8000, 1, test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(input); i += 160 {
+			if err = w.Write(input[i : i+160]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := OpenWavFile(filename, Ptime20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.WavAudioFormat() != test.format || r.SampleRate() != 8000 || r.Channels() != 1 {
+			t.Fatal(r.WavAudioFormat(), r.SampleRate(), r.Channels())
+		}
+		var output []int16
+		for {
+			frame, err := r.ReadFrame()
+			output = append(output, frame...)
+			if frame != nil {
+				r.Release(frame)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = r.Close()
+
+		if len(output) != len(input) {
+			t.Fatalf("format %d: read %d samples, want %d", test.format, len(output), len(input))
+		}
+		for i := range input {
+			if math.Abs(float64(output[i])-float64(input[i])) > test.tolerance {
+				t.Fatalf("format %d: sample %d is %d, want %d", test.format, i, output[i], input[i])
+			}
+		}
+	}
+}
+
+func TestWavWriter_Format(t *testing.T) {
+	if _, err := NewWavWriter(nil, 8000, 1, 3); err != ErrWavFormat {
+		t.Fatal(err)
+	}
+}
+
+func TestWavWriter_ADPCM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []uint16{WavFormatIMAADPCM, WavFormatMSADPCM} {
+		for _, channels := range []int{1, 2, 6} {
+			filename := filepath.Join(dir, "test.wav")
+			if format == WavFormatMSADPCM && channels > 2 {
+				// MS ADPCM only defines mono and stereo blocks.
+				if _, err := CreateWavFile(filename, 8000, channels, format); err != ErrWavFormat {
+					t.Fatalf("%d channels: expected ErrWavFormat, got %v", channels, err)
+				}
+				continue
+			}
+			input := make([]int16, 8003*channels)
+			for i := range input {
+				freq := 440 + 110*float64(i%channels)
+				input[i] = int16(10000 * math.Sin(2*math.Pi*freq*float64(i/channels)/8000))
+			}
+
+			w, err := CreateWavFile(filename, 8000, channels, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(input); i += 160 * channels {
+				end := i + 160*channels
+				if end > len(input) {
+					end = len(input)
+				}
+				if err = w.Write(input[i:end]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := OpenWavFile(filename, Ptime20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.WavAudioFormat() != format || r.Channels() != channels {
+				t.Fatal(r.WavAudioFormat(), r.Channels())
+			}
+			var output []int16
+			for {
+				frame, err := r.ReadFrame()
+				output = append(output, frame...)
+				if frame != nil {
+					r.Release(frame)
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = r.Close()
+
+			// The padding of the last block is cut by the fact chunk.
+			if len(output) != len(input) {
+				t.Fatalf("format %d: read %d samples, want %d", format, len(output), len(input))
+			}
+			var signal, noise float64
+			for i := range input {
+				e := float64(output[i]) - float64(input[i])
+				signal += float64(input[i]) * float64(input[i])
+				noise += e * e
+			}
+			if snr := 10 * math.Log10(signal/noise); snr < 20 {
+				t.Fatalf("format %d, %d channels: SNR %.1fdB", format, channels, snr)
+			}
+		}
+	}
+}
@@ -20,23 +20,32 @@ const (
 )
 
 var (
-	Pool8kHz  = newPool(8000)
-	Pool12kHz = newPool(12000)
-	Pool16kHz = newPool(16000)
-	Pool24kHz = newPool(24000)
-	Pool48kHz = newPool(48000)
-
-	Opus2dot5ms = newPCMPool(48000, frameSize48khz2dot5ms, nil)
-	Opus5ms     = newPCMPool(48000, frameSize48khz5ms, nil)
-	Opus10ms    = newPCMPool(48000, frameSize48khz10ms, nil)
-	Opus20ms    = newPCMPool(48000, frameSize48khz20ms, nil)
-	Opus40ms    = newPCMPool(48000, frameSize48khz40ms, nil)
-	Opus60ms    = newPCMPool(48000, frameSize48khz60ms, nil)
-	Opus120ms   = newPCMPool(48000, frameSize48khz120ms, nil)
+	Pool48kHz = newPool(48000, 1, nil)
+	Pool8kHz  = newPool(8000, 1, Pool48kHz)
+	Pool12kHz = newPool(12000, 1, Pool48kHz)
+	Pool16kHz = newPool(16000, 1, Pool48kHz)
+	Pool24kHz = newPool(24000, 1, Pool48kHz)
+
+	Pool48kHzStereo = newPool(48000, 2, nil)
+	Pool8kHzStereo  = newPool(8000, 2, Pool48kHzStereo)
+	Pool12kHzStereo = newPool(12000, 2, Pool48kHzStereo)
+	Pool16kHzStereo = newPool(16000, 2, Pool48kHzStereo)
+	Pool24kHzStereo = newPool(24000, 2, Pool48kHzStereo)
+
+	Opus2dot5ms = Pool48kHz.PCM2dot5
+	Opus5ms     = Pool48kHz.PCM5ms
+	Opus10ms    = Pool48kHz.PCM10ms
+	Opus20ms    = Pool48kHz.PCM20ms
+	Opus40ms    = Pool48kHz.PCM40ms
+	Opus60ms    = Pool48kHz.PCM60ms
+	Opus120ms   = Pool48kHz.PCM120ms
 )
 
+// Pool holds frame pools for a sample rate and channel count. Frames are
+// interleaved, so a frame holds FrameSize * Channels samples.
 type Pool struct {
 	ClockSpeed int
+	Channels   int
 	Multiple   int
 	PCM2dot5   *PCM
 	PCM5ms     *PCM
@@ -47,22 +56,28 @@ type Pool struct {
 	PCM120ms   *PCM
 }
 
-func newPool(clockSpeed int) *Pool {
+// newPool creates the pools for a sample rate. opus is the 48Khz pool with the
+// same channel count, nil when creating the 48Khz pool itself.
+func newPool(clockSpeed, channels int, opus *Pool) *Pool {
 	multiple := 48000 / clockSpeed
 	if 48000%clockSpeed != 0 {
 		panic("clockSpeed not multiple of 48000")
 	}
+	if opus == nil {
+		opus = &Pool{}
+	}
 
 	return &Pool{
 		ClockSpeed: clockSpeed,
+		Channels:   channels,
 		Multiple:   multiple,
-		PCM2dot5:   newPCMPool(clockSpeed, frameSize48khz2dot5ms/multiple, Opus2dot5ms),
-		PCM5ms:     newPCMPool(clockSpeed, frameSize48khz5ms/multiple, Opus5ms),
-		PCM10ms:    newPCMPool(clockSpeed, frameSize48khz10ms/multiple, Opus10ms),
-		PCM20ms:    newPCMPool(clockSpeed, frameSize48khz20ms/multiple, Opus20ms),
-		PCM40ms:    newPCMPool(clockSpeed, frameSize48khz40ms/multiple, Opus40ms),
-		PCM60ms:    newPCMPool(clockSpeed, frameSize48khz60ms/multiple, Opus60ms),
-		PCM120ms:   newPCMPool(clockSpeed, frameSize48khz120ms/multiple, Opus120ms),
+		PCM2dot5:   newPCMPool(clockSpeed, channels, frameSize48khz2dot5ms/multiple, opus.PCM2dot5),
+		PCM5ms:     newPCMPool(clockSpeed, channels, frameSize48khz5ms/multiple, opus.PCM5ms),
+		PCM10ms:    newPCMPool(clockSpeed, channels, frameSize48khz10ms/multiple, opus.PCM10ms),
+		PCM20ms:    newPCMPool(clockSpeed, channels, frameSize48khz20ms/multiple, opus.PCM20ms),
+		PCM40ms:    newPCMPool(clockSpeed, channels, frameSize48khz40ms/multiple, opus.PCM40ms),
+		PCM60ms:    newPCMPool(clockSpeed, channels, frameSize48khz60ms/multiple, opus.PCM60ms),
+		PCM120ms:   newPCMPool(clockSpeed, channels, frameSize48khz120ms/multiple, opus.PCM120ms),
 	}
 }
 
@@ -88,7 +103,16 @@ func (p *Pool) ForPtime(ptime int) *PCM {
 	return p.PCM20ms
 }
 
-func Of(sampleRate, ptime int) (*Pool, error) {
+// Pools for more than 2 channels are created on first use, keyed by channel
+// count and then by sample rate.
+var (
+	multichannel   = map[int]map[int]*Pool{}
+	multichannelMu sync.Mutex
+)
+
+// Of returns the pool for a sample rate and channel count. Mono and stereo
+// return the package pools, wider layouts share a pool created on first use.
+func Of(sampleRate, channels, ptime int) (*Pool, error) {
 	switch ptime {
 	case 3:
 	case 5:
@@ -99,21 +123,62 @@ func Of(sampleRate, ptime int) (*Pool, error) {
 	default:
 		return nil, ErrUnsupported
 	}
-	switch sampleRate {
-	case 8000:
-		return Pool8kHz, nil
-	case 12000:
-		return Pool12kHz, nil
-	case 16000:
-		return Pool16kHz, nil
-	case 24000:
-		return Pool24kHz, nil
-	case 48000:
-		return Pool48kHz, nil
+	switch channels {
+	case 1:
+		switch sampleRate {
+		case 8000:
+			return Pool8kHz, nil
+		case 12000:
+			return Pool12kHz, nil
+		case 16000:
+			return Pool16kHz, nil
+		case 24000:
+			return Pool24kHz, nil
+		case 48000:
+			return Pool48kHz, nil
+		}
+	case 2:
+		switch sampleRate {
+		case 8000:
+			return Pool8kHzStereo, nil
+		case 12000:
+			return Pool12kHzStereo, nil
+		case 16000:
+			return Pool16kHzStereo, nil
+		case 24000:
+			return Pool24kHzStereo, nil
+		case 48000:
+			return Pool48kHzStereo, nil
+		}
+	default:
+		if channels > 2 {
+			return ofMultichannel(sampleRate, channels)
+		}
 	}
 	return nil, ErrUnsupported
 }
 
+func ofMultichannel(sampleRate, channels int) (*Pool, error) {
+	switch sampleRate {
+	case 8000, 12000, 16000, 24000, 48000:
+	default:
+		return nil, ErrUnsupported
+	}
+	multichannelMu.Lock()
+	defer multichannelMu.Unlock()
+	pools := multichannel[channels]
+	if pools == nil {
+		pools = map[int]*Pool{48000: newPool(48000, channels, nil)}
+		multichannel[channels] = pools
+	}
+	p := pools[sampleRate]
+	if p == nil {
+		p = newPool(sampleRate, channels, pools[48000])
+		pools[sampleRate] = p
+	}
+	return p, nil
+}
+
 func OpusFrameSizeOf(ptime int) int {
 	switch ptime {
 	case 3:
@@ -134,24 +199,31 @@ func OpusFrameSizeOf(ptime int) int {
 	return 0
 }
 
+// PCM pools frames of a single duration. FrameSize is the number of samples
+// per channel. Frames returned by Get hold FrameSize * Channels interleaved
+// samples.
 type PCM struct {
 	ClockSpeed int
+	Channels   int
 	FrameSize  int
 	Opus       *PCM
+	Float      *Float32
 	pool       sync.Pool
 }
 
-func newPCMPool(clockSpeed, size int, opus *PCM) *PCM {
+func newPCMPool(clockSpeed, channels, size int, opus *PCM) *PCM {
 	p := &PCM{
 		ClockSpeed: clockSpeed,
+		Channels:   channels,
 		FrameSize:  size,
 		Opus:       opus,
+		Float:      newFloat32Pool(clockSpeed, channels, size),
 		pool: sync.Pool{New: func() interface{} {
-			return make([]int16, size)
+			return make([]int16, size*channels)
 		}},
 	}
 	if opus == nil {
-		p.Opus = opus
+		p.Opus = p
 	}
 	return p
 }
@@ -161,9 +233,42 @@ func (p *PCM) Get() []int16 {
 }
 
 func (p *PCM) Release(pcm []int16) {
-	if cap(pcm) < p.FrameSize {
+	size := p.FrameSize * p.Channels
+	if cap(pcm) < size {
+		return
+	}
+	pcm = pcm[:size]
+	p.pool.Put(pcm)
+}
+
+// Float32 pools float32 frames of the same size as its PCM counterpart.
+type Float32 struct {
+	ClockSpeed int
+	Channels   int
+	FrameSize  int
+	pool       sync.Pool
+}
+
+func newFloat32Pool(clockSpeed, channels, size int) *Float32 {
+	return &Float32{
+		ClockSpeed: clockSpeed,
+		Channels:   channels,
+		FrameSize:  size,
+		pool: sync.Pool{New: func() interface{} {
+			return make([]float32, size*channels)
+		}},
+	}
+}
+
+func (p *Float32) Get() []float32 {
+	return p.pool.Get().([]float32)
+}
+
+func (p *Float32) Release(pcm []float32) {
+	size := p.FrameSize * p.Channels
+	if cap(pcm) < size {
 		return
 	}
-	pcm = pcm[:p.FrameSize]
+	pcm = pcm[:size]
 	p.pool.Put(pcm)
 }
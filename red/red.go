@@ -0,0 +1,241 @@
+/*
+Package red implements redundant audio data (RFC 2198): RTP payloads that
+carry copies of the payloads of previous packets, so a receiver can recover
+packets lost in a burst.
+
+A RED payload is a list of blocks, the most recent one, the primary, last.
+Redundant blocks have a payload type, a 14 bit timestamp offset from the
+packet and a 10 bit length. The primary only has a payload type.
+*/
+package red
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// RTP encoding name.
+	EncodingName = "red"
+
+	// Limits of redundant blocks.
+	MaxTimestampOffset = 1<<14 - 1
+	MaxBlockLength     = 1<<10 - 1
+)
+
+var (
+	ErrCorrupt     = errors.New("corrupt RED payload")
+	ErrOffset      = errors.New("timestamp offset exceeds 14 bits")
+	ErrBlockLength = errors.New("redundant block exceeds 1023 bytes")
+	ErrPayloadType = errors.New("payload type exceeds 7 bits")
+	ErrShort       = errors.New("buffer is too small")
+)
+
+// Block is one payload of a RED payload.
+type Block struct {
+	PayloadType uint8
+	// Ticks the block precedes the packet by, 0 for the primary.
+	Offset  uint16
+	Payload []byte
+}
+
+// Size returns the length of the RED payload of blocks.
+func Size(blocks []Block) int {
+	size := 0
+	for _, b := range blocks {
+		size += 4 + len(b.Payload)
+	}
+	if len(blocks) > 0 {
+		size -= 3
+	}
+	return size
+}
+
+// Marshal writes blocks, the primary last, to buf and returns the length.
+func Marshal(blocks []Block, buf []byte) (int, error) {
+	if len(blocks) == 0 {
+		return 0, ErrCorrupt
+	}
+	size := Size(blocks)
+	if len(buf) < size {
+		return 0, ErrShort
+	}
+	last := len(blocks) - 1
+	n := 0
+	for i, b := range blocks {
+		if b.PayloadType > 127 {
+			return 0, ErrPayloadType
+		}
+		if i == last {
+			buf[n] = b.PayloadType
+			n++
+			break
+		}
+		if b.Offset > MaxTimestampOffset {
+			return 0, ErrOffset
+		}
+		if len(b.Payload) > MaxBlockLength {
+			return 0, ErrBlockLength
+		}
+		buf[n] = 0x80 | b.PayloadType
+		binary.BigEndian.PutUint16(buf[n+1:], b.Offset<<2|uint16(len(b.Payload))>>8)
+		buf[n+3] = byte(len(b.Payload))
+		n += 4
+	}
+	for _, b := range blocks {
+		n += copy(buf[n:], b.Payload)
+	}
+	return n, nil
+}
+
+// Unmarshal returns the blocks of a RED payload, the primary last. The block
+// payloads point into payload.
+func Unmarshal(payload []byte) ([]Block, error) {
+	var blocks []Block
+	n, length := 0, 0
+	for {
+		if n >= len(payload) {
+			return nil, ErrCorrupt
+		}
+		if payload[n]&0x80 == 0 {
+			blocks = append(blocks, Block{PayloadType: payload[n]})
+			n++
+			break
+		}
+		if n+4 > len(payload) {
+			return nil, ErrCorrupt
+		}
+		header := binary.BigEndian.Uint32(payload[n:])
+		blocks = append(blocks, Block{
+			PayloadType: payload[n] & 0x7f,
+			Offset:      uint16(header >> 10 & MaxTimestampOffset),
+			Payload:     make([]byte, header&MaxBlockLength),
+		})
+		length += int(header & MaxBlockLength)
+		n += 4
+	}
+	if n+length > len(payload) {
+		return nil, ErrCorrupt
+	}
+	for i := range blocks[:len(blocks)-1] {
+		size := len(blocks[i].Payload)
+		blocks[i].Payload = payload[n : n+size : n+size]
+		n += size
+	}
+	blocks[len(blocks)-1].Payload = payload[n:]
+	return blocks, nil
+}
+
+// Encoder adds the payloads of previous packets to each payload.
+type Encoder struct {
+	PayloadType uint8
+	// Number of previous payloads to add at most.
+	Distance int
+
+	history []previous // Oldest first.
+	blocks  []Block
+}
+
+type previous struct {
+	timestamp uint32
+	payload   []byte
+}
+
+// NewEncoder creates an Encoder for payloads of payloadType that adds up to
+// distance previous payloads.
+func NewEncoder(payloadType uint8, distance int) *Encoder {
+	return &Encoder{
+		PayloadType: payloadType,
+		Distance:    distance,
+	}
+}
+
+// Encode writes the RED payload of a payload sent with timestamp to buf and
+// returns its length. Previous payloads are added newest first while their
+// timestamp offset and length fit in the block header and the RED payload
+// fits in buf. The blocks are always the payloads of the packets right before
+// this one, so receivers can tell their sequence numbers.
+func (e *Encoder) Encode(payload []byte, timestamp uint32, buf []byte) (int, error) {
+	if e.PayloadType > 127 {
+		return 0, ErrPayloadType
+	}
+	size := 1 + len(payload)
+	if len(buf) < size {
+		return 0, ErrShort
+	}
+
+	first := len(e.history)
+	for first > 0 && len(e.history)-first < e.Distance {
+		p := e.history[first-1]
+		offset := timestamp - p.timestamp
+		if offset == 0 || offset > MaxTimestampOffset || len(p.payload) > MaxBlockLength {
+			break
+		}
+		if size+4+len(p.payload) > len(buf) {
+			break
+		}
+		size += 4 + len(p.payload)
+		first--
+	}
+	e.blocks = e.blocks[:0]
+	for _, p := range e.history[first:] {
+		e.blocks = append(e.blocks, Block{
+			PayloadType: e.PayloadType,
+			Offset:      uint16(timestamp - p.timestamp),
+			Payload:     p.payload,
+		})
+	}
+	e.blocks = append(e.blocks, Block{PayloadType: e.PayloadType, Payload: payload})
+	n, err := Marshal(e.blocks, buf)
+	if err != nil {
+		return 0, err
+	}
+	e.remember(payload, timestamp)
+	return n, nil
+}
+
+// remember keeps a copy of payload, reusing the oldest one.
+func (e *Encoder) remember(payload []byte, timestamp uint32) {
+	if e.Distance <= 0 {
+		e.history = e.history[:0]
+		return
+	}
+	var p previous
+	if len(e.history) >= e.Distance {
+		p = e.history[0]
+		e.history = append(e.history[:0], e.history[len(e.history)-e.Distance+1:]...)
+	}
+	p.timestamp = timestamp
+	p.payload = append(p.payload[:0], payload...)
+	e.history = append(e.history, p)
+}
+
+// Reset forgets the previous payloads, such as after a timestamp jump.
+func (e *Encoder) Reset() {
+	e.history = e.history[:0]
+}
+
+// Split returns the packets in a RED packet, oldest first. Redundant blocks
+// are taken to be the packets right before it: their sequence numbers count
+// back from the RED packet's.
+func Split(packet *rtp.Packet) ([]*rtp.Packet, error) {
+	blocks, err := Unmarshal(packet.Payload)
+	if err != nil {
+		return nil, err
+	}
+	packets := make([]*rtp.Packet, len(blocks))
+	last := len(blocks) - 1
+	for i, b := range blocks {
+		p := &rtp.Packet{Header: packet.Header, Payload: b.Payload}
+		p.PayloadType = b.PayloadType
+		p.SequenceNumber -= uint16(last - i)
+		p.Timestamp -= uint32(b.Offset)
+		if i < last {
+			p.Marker = false
+		}
+		packets[i] = p
+	}
+	return packets, nil
+}
@@ -0,0 +1,123 @@
+package red
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+func TestMarshal(t *testing.T) {
+	blocks := []Block{
+		{PayloadType: 111, Offset: 1920, Payload: []byte{1, 2, 3}},
+		{PayloadType: 111, Offset: 960, Payload: bytes.Repeat([]byte{4}, 300)},
+		{PayloadType: 111, Payload: []byte{5, 6}},
+	}
+	buf := make([]byte, Size(blocks))
+	n, err := Marshal(blocks, buf)
+	if err != nil || n != 4+4+1+3+300+2 {
+		t.Fatal(n, err)
+	}
+	// F bit, payload type 111, offset 1920 and length 3 (RFC 2198 section 3).
+	if !bytes.Equal(buf[:4], []byte{0xef, 0x1e, 0x00, 0x03}) || buf[8] != 111 {
+		t.Fatalf("% x", buf[:9])
+	}
+	got, err := Unmarshal(buf[:n])
+	if err != nil || len(got) != 3 {
+		t.Fatal(got, err)
+	}
+	for i := range got {
+		if got[i].PayloadType != 111 || got[i].Offset != blocks[i].Offset || !bytes.Equal(got[i].Payload, blocks[i].Payload) {
+			t.Fatal(i, got[i])
+		}
+	}
+
+	if _, err := Marshal([]Block{{Offset: MaxTimestampOffset + 1}, {}}, buf); err != ErrOffset {
+		t.Fatal(err)
+	}
+	if _, err := Marshal([]Block{{Payload: make([]byte, MaxBlockLength+1)}, {}}, make([]byte, 2000)); err != ErrBlockLength {
+		t.Fatal(err)
+	}
+	for _, corrupt := range [][]byte{nil, {0x80, 0, 0}, {0xef, 0x1e, 0x00, 0x03, 111, 1}} {
+		if _, err := Unmarshal(corrupt); err != ErrCorrupt {
+			t.Fatal(corrupt, err)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	e := NewEncoder(111, 2)
+	buf := make([]byte, 1200)
+	var timestamp uint32
+	encode := func(payload []byte) []Block {
+		n, err := e.Encode(payload, timestamp, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestamp += 960
+		blocks, err := Unmarshal(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blocks
+	}
+
+	if blocks := encode([]byte{1}); len(blocks) != 1 {
+		t.Fatal(blocks)
+	}
+	if blocks := encode([]byte{2}); len(blocks) != 2 || blocks[0].Offset != 960 || blocks[0].Payload[0] != 1 {
+		t.Fatal(blocks)
+	}
+	blocks := encode([]byte{3})
+	if len(blocks) != 3 || blocks[0].Offset != 1920 || blocks[0].Payload[0] != 1 || blocks[1].Payload[0] != 2 || blocks[2].Payload[0] != 3 {
+		t.Fatal(blocks)
+	}
+	// Only the 2 previous payloads.
+	if blocks := encode([]byte{4}); len(blocks) != 3 || blocks[0].Payload[0] != 2 {
+		t.Fatal(blocks)
+	}
+
+	// Too long to be redundant, and what precedes it is left out too.
+	encode(make([]byte, MaxBlockLength+1))
+	if blocks := encode([]byte{6}); len(blocks) != 1 {
+		t.Fatal(blocks)
+	}
+	// Doesn't fit with both previous payloads.
+	encode(bytes.Repeat([]byte{7}, 600))
+	if blocks := encode(bytes.Repeat([]byte{8}, 592)); len(blocks) != 2 || len(blocks[0].Payload) != 600 {
+		t.Fatal(len(blocks))
+	}
+	// Offsets over 14 bits.
+	timestamp += MaxTimestampOffset
+	if blocks := encode([]byte{9}); len(blocks) != 1 {
+		t.Fatal(blocks)
+	}
+
+	if _, err := e.Encode(make([]byte, 1200), timestamp, buf); err != ErrShort {
+		t.Fatal(err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	e := NewEncoder(111, 2)
+	buf := make([]byte, 1200)
+	e.Encode([]byte{1}, 1000, buf)
+	e.Encode([]byte{2}, 1960, buf)
+	n, _ := e.Encode([]byte{3}, 2920, buf)
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, Marker: true, PayloadType: 63, SequenceNumber: 1, Timestamp: 2920, SSRC: 7},
+		Payload: buf[:n],
+	}
+	packets, err := Split(packet)
+	if err != nil || len(packets) != 3 {
+		t.Fatal(packets, err)
+	}
+	for i, p := range packets {
+		if p.PayloadType != 111 || p.SSRC != 7 || p.Payload[0] != byte(i+1) || p.Marker != (i == 2) {
+			t.Fatal(i, p)
+		}
+		if p.SequenceNumber != uint16(65535+i) || p.Timestamp != uint32(1000+960*i) {
+			t.Fatal(i, p.SequenceNumber, p.Timestamp)
+		}
+	}
+}
@@ -192,7 +192,7 @@ func TestResample8000_16000(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pool, err := pool.Of(8000, ptime)
+	pool, err := pool.Of(8000, 1, ptime)
 	if err != nil {
 		t.Fatal(err)
 	}
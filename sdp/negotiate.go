@@ -0,0 +1,209 @@
+package sdp
+
+import (
+	"github.com/pidato/audio/transcode"
+)
+
+// First dynamic RTP payload type.
+const dynamicPayloadType = 96
+
+// DefaultFormats returns the formats offered by default in order of
+// preference. L16 is left out, offer it explicitly between media servers.
+func DefaultFormats() []Format {
+	opus := Format{PayloadType: 111, Name: "opus", ClockRate: 48000, Channels: 2}
+	opus.SetOpus(OpusParams{UseInbandFEC: true})
+	return []Format{
+		opus,
+		staticFormats[9],
+		staticFormats[0],
+		staticFormats[8],
+		{PayloadType: 18, Name: "G729", ClockRate: 8000, Fmtp: "annexb=no"},
+		staticFormats[3],
+		{PayloadType: 101, Name: TelephoneEvent, ClockRate: 8000, Fmtp: "0-16"},
+	}
+}
+
+// Options configure Offer and Answer.
+type Options struct {
+	// Codecs that can be used, DefaultRegistry when nil.
+	Registry *transcode.Registry
+	// Formats to offer, or to accept in an answer. DefaultFormats when nil.
+	// Formats without a static or free payload type are numbered from 96.
+	Formats []Format
+
+	Address   string
+	Port      int
+	Ptime     int
+	MaxPtime  int
+	Direction Direction // SendRecv when empty.
+
+	// Local keys, one per suite that can be used. With keys the media is
+	// offered as RTP/SAVP.
+	Crypto []Crypto
+}
+
+func (o *Options) registry() *transcode.Registry {
+	if o.Registry == nil {
+		return transcode.DefaultRegistry
+	}
+	return o.Registry
+}
+
+func (o *Options) formats() []Format {
+	if o.Formats == nil {
+		return DefaultFormats()
+	}
+	return o.Formats
+}
+
+func (o *Options) direction() Direction {
+	if o.Direction == "" {
+		return SendRecv
+	}
+	return o.Direction
+}
+
+// supported reports whether f can be coded with the registry.
+func (o *Options) supported(f Format) bool {
+	if f.is(TelephoneEvent) {
+		return true
+	}
+	_, ok := o.registry().Lookup(f.Codec())
+	return ok
+}
+
+// Offer returns an offer of the formats the registry supports.
+func Offer(opts Options) (*Media, error) {
+	m := &Media{
+		Address:   opts.Address,
+		Port:      opts.Port,
+		Protocol:  RTPAVP,
+		Ptime:     opts.Ptime,
+		MaxPtime:  opts.MaxPtime,
+		Direction: opts.direction(),
+	}
+	used := make(map[uint8]bool)
+	for _, f := range opts.formats() {
+		if opts.supported(f) {
+			m.Formats = append(m.Formats, f)
+			used[f.PayloadType] = true
+		}
+	}
+	if len(m.Formats) == 0 {
+		return nil, ErrNoCodec
+	}
+	// Renumber clashing dynamic payload types.
+	seen := make(map[uint8]bool)
+	next := uint8(dynamicPayloadType)
+	for i := range m.Formats {
+		f := &m.Formats[i]
+		if !seen[f.PayloadType] {
+			seen[f.PayloadType] = true
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		if next > 127 {
+			return nil, ErrPayloadType
+		}
+		f.PayloadType = next
+		used[next], seen[next] = true, true
+	}
+	if len(opts.Crypto) > 0 {
+		m.Protocol = RTPSAVP
+		for i, c := range opts.Crypto {
+			c.Tag = i + 1
+			m.Crypto = append(m.Crypto, c)
+		}
+	}
+	return m, nil
+}
+
+// Answer returns the answer to an offer. It accepts the offered formats the
+// registry supports, in the order of the offer and with the offered payload
+// types, so the first is the codec to send. Local fmtp options of Opus and
+// G729 are applied.
+func Answer(offer *Media, opts Options) (*Media, error) {
+	m := &Media{
+		Address:   opts.Address,
+		Port:      opts.Port,
+		Protocol:  offer.Protocol,
+		Ptime:     opts.Ptime,
+		MaxPtime:  opts.MaxPtime,
+		Direction: answerDirection(offer.Direction, opts.direction()),
+	}
+	if offer.Port == 0 {
+		m.Port = 0
+		m.Formats = offer.Formats
+		return m, nil
+	}
+
+	local := opts.formats()
+	for _, f := range offer.Formats {
+		if !opts.supported(f) {
+			continue
+		}
+		var mine *Format
+		for i := range local {
+			if local[i].same(f) {
+				mine = &local[i]
+				break
+			}
+		}
+		if mine == nil {
+			continue
+		}
+		m.Formats = append(m.Formats, answerFormat(f, *mine))
+	}
+	if _, ok := m.Codec(); !ok {
+		return nil, ErrNoCodec
+	}
+
+	if m.Ptime == 0 {
+		m.Ptime = offer.Ptime
+	}
+	if offer.MaxPtime != 0 && m.Ptime > offer.MaxPtime {
+		m.Ptime = offer.MaxPtime
+	}
+
+	if offer.Protocol == RTPSAVP || len(offer.Crypto) > 0 {
+		crypto, ok := answerCrypto(offer.Crypto, opts.Crypto)
+		if !ok {
+			return nil, ErrCrypto
+		}
+		m.Crypto = []Crypto{crypto}
+	}
+	return m, nil
+}
+
+func answerDirection(offer, local Direction) Direction {
+	return directionOf(offer.receives() && local.sends(), offer.sends() && local.receives())
+}
+
+// answerFormat agrees on the fmtp of an offered format.
+func answerFormat(offered, local Format) Format {
+	f := offered
+	switch {
+	case f.is("opus"):
+		f.SetOpus(local.Opus())
+	case f.is("G729"):
+		if !offered.AnnexB() || !local.AnnexB() {
+			f.Fmtp = "annexb=no"
+		}
+	}
+	return f
+}
+
+// answerCrypto returns the first offered suite there is a local key for.
+func answerCrypto(offered, local []Crypto) (Crypto, bool) {
+	for _, o := range offered {
+		for _, l := range local {
+			if l.Suite == o.Suite {
+				l.Tag = o.Tag
+				return l, true
+			}
+		}
+	}
+	return Crypto{}, false
+}
@@ -0,0 +1,284 @@
+package sdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Formats of the static payload types (RFC 3551), which don't need an
+// rtpmap.
+var staticFormats = map[uint8]Format{
+	0:  {PayloadType: 0, Name: "PCMU", ClockRate: 8000},
+	3:  {PayloadType: 3, Name: "GSM", ClockRate: 8000},
+	8:  {PayloadType: 8, Name: "PCMA", ClockRate: 8000},
+	9:  {PayloadType: 9, Name: "G722", ClockRate: 8000},
+	10: {PayloadType: 10, Name: "L16", ClockRate: 44100, Channels: 2},
+	11: {PayloadType: 11, Name: "L16", ClockRate: 44100},
+	13: {PayloadType: 13, Name: "CN", ClockRate: 8000},
+	18: {PayloadType: 18, Name: "G729", ClockRate: 8000},
+}
+
+// ParseAudio parses the first audio media section of an SDP. The connection
+// address and direction of the session apply when the media has none.
+func ParseAudio(sdp string) (*Media, error) {
+	var (
+		m              *Media
+		formats        map[uint8]*Format
+		sessionAddress string
+		sessionDir     Direction
+		session        = true // Before the first m-line.
+	)
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		kind, value := line[0], line[2:]
+		if kind == 'm' {
+			session = false
+			if m != nil {
+				break
+			}
+			media, err := parseMLine(value)
+			if err != nil {
+				return nil, err
+			}
+			if media != nil {
+				m = media
+				formats = make(map[uint8]*Format)
+				for i := range m.Formats {
+					formats[m.Formats[i].PayloadType] = &m.Formats[i]
+				}
+			}
+			continue
+		}
+		switch {
+		case session && kind == 'c':
+			sessionAddress = parseConnection(value)
+		case session && kind == 'a':
+			if d := Direction(value); d.valid() {
+				sessionDir = d
+			}
+		case m == nil:
+			// A media section that isn't audio.
+		case kind == 'c':
+			m.Address = parseConnection(value)
+		case kind == 'a':
+			if err := m.parseAttribute(value, formats); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m == nil {
+		return nil, ErrNoAudio
+	}
+	if m.Address == "" {
+		m.Address = sessionAddress
+	}
+	if m.Direction == "" {
+		m.Direction = sessionDir
+	}
+	if m.Direction == "" {
+		m.Direction = SendRecv
+	}
+	return m, nil
+}
+
+func (d Direction) valid() bool {
+	switch d {
+	case SendRecv, SendOnly, RecvOnly, Inactive:
+		return true
+	}
+	return false
+}
+
+// parseMLine returns nil for media that isn't audio.
+func parseMLine(value string) (*Media, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, ErrSyntax
+	}
+	if fields[0] != "audio" {
+		return nil, nil
+	}
+	port := fields[1]
+	if i := strings.IndexByte(port, '/'); i >= 0 {
+		port = port[:i]
+	}
+	m := &Media{Protocol: fields[2]}
+	var err error
+	if m.Port, err = strconv.Atoi(port); err != nil {
+		return nil, ErrSyntax
+	}
+	for _, field := range fields[3:] {
+		pt, err := strconv.ParseUint(field, 10, 7)
+		if err != nil {
+			return nil, ErrSyntax
+		}
+		f, ok := staticFormats[uint8(pt)]
+		if !ok {
+			f = Format{PayloadType: uint8(pt)}
+		}
+		m.Formats = append(m.Formats, f)
+	}
+	return m, nil
+}
+
+// parseConnection returns the address of "IN IP4 <address>[/ttl]".
+func parseConnection(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return ""
+	}
+	address := fields[2]
+	if fields[1] == "IP4" {
+		if i := strings.IndexByte(address, '/'); i >= 0 {
+			address = address[:i]
+		}
+	}
+	return address
+}
+
+func (m *Media) parseAttribute(value string, formats map[uint8]*Format) error {
+	name, arg := value, ""
+	if i := strings.IndexByte(value, ':'); i >= 0 {
+		name, arg = value[:i], value[i+1:]
+	}
+	switch name {
+	case "rtpmap":
+		f, rest, err := formatOf(arg, formats)
+		if err != nil || f == nil {
+			return err
+		}
+		parts := strings.Split(rest, "/")
+		if len(parts) < 2 {
+			return ErrSyntax
+		}
+		f.Name = parts[0]
+		if f.ClockRate, err = strconv.Atoi(parts[1]); err != nil {
+			return ErrSyntax
+		}
+		f.Channels = 0
+		if len(parts) > 2 {
+			if f.Channels, err = strconv.Atoi(parts[2]); err != nil {
+				return ErrSyntax
+			}
+		}
+	case "fmtp":
+		f, rest, err := formatOf(arg, formats)
+		if err != nil || f == nil {
+			return err
+		}
+		f.Fmtp = rest
+	case "ptime", "maxptime":
+		// Fractions such as 20.0 are allowed.
+		ptime, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return ErrSyntax
+		}
+		if name == "ptime" {
+			m.Ptime = int(ptime)
+		} else {
+			m.MaxPtime = int(ptime)
+		}
+	case "crypto":
+		fields := strings.Fields(arg)
+		if len(fields) < 3 {
+			return ErrSyntax
+		}
+		tag, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return ErrSyntax
+		}
+		m.Crypto = append(m.Crypto, Crypto{
+			Tag:           tag,
+			Suite:         fields[1],
+			KeyParams:     fields[2],
+			SessionParams: strings.Join(fields[3:], " "),
+		})
+	default:
+		if d := Direction(value); d.valid() {
+			m.Direction = d
+			break
+		}
+		m.Attributes = append(m.Attributes, value)
+	}
+	return nil
+}
+
+// formatOf returns the format of "<payload type> <rest>", nil when it isn't
+// on the m-line.
+func formatOf(arg string, formats map[uint8]*Format) (*Format, string, error) {
+	fields := strings.SplitN(strings.TrimSpace(arg), " ", 2)
+	if len(fields) != 2 {
+		return nil, "", ErrSyntax
+	}
+	pt, err := strconv.ParseUint(fields[0], 10, 7)
+	if err != nil {
+		return nil, "", ErrSyntax
+	}
+	return formats[uint8(pt)], strings.TrimSpace(fields[1]), nil
+}
+
+// String returns the media section, from the m-line on.
+func (m *Media) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "m=audio %d %s", m.Port, m.Protocol)
+	for _, f := range m.Formats {
+		fmt.Fprintf(&b, " %d", f.PayloadType)
+	}
+	b.WriteString("\r\n")
+	if m.Address != "" {
+		fmt.Fprintf(&b, "c=%s\r\n", connection(m.Address))
+	}
+	for _, f := range m.Formats {
+		fmt.Fprintf(&b, "a=rtpmap:%d %s/%d", f.PayloadType, f.Name, f.ClockRate)
+		if f.Channels > 1 {
+			fmt.Fprintf(&b, "/%d", f.Channels)
+		}
+		b.WriteString("\r\n")
+		if f.Fmtp != "" {
+			fmt.Fprintf(&b, "a=fmtp:%d %s\r\n", f.PayloadType, f.Fmtp)
+		}
+	}
+	if m.Ptime != 0 {
+		fmt.Fprintf(&b, "a=ptime:%d\r\n", m.Ptime)
+	}
+	if m.MaxPtime != 0 {
+		fmt.Fprintf(&b, "a=maxptime:%d\r\n", m.MaxPtime)
+	}
+	for _, c := range m.Crypto {
+		fmt.Fprintf(&b, "a=crypto:%s\r\n", c)
+	}
+	for _, a := range m.Attributes {
+		fmt.Fprintf(&b, "a=%s\r\n", a)
+	}
+	if m.Direction != "" {
+		fmt.Fprintf(&b, "a=%s\r\n", m.Direction)
+	}
+	return b.String()
+}
+
+func connection(address string) string {
+	if strings.IndexByte(address, ':') >= 0 {
+		return "IN IP6 " + address
+	}
+	return "IN IP4 " + address
+}
+
+// Session returns a complete SDP with the media as its only media section.
+// version must increase with every offer of a session (RFC 3264).
+func Session(sessionID, version uint64, media *Media) string {
+	var b strings.Builder
+	b.WriteString("v=0\r\n")
+	address := media.Address
+	if address == "" {
+		address = "0.0.0.0"
+	}
+	fmt.Fprintf(&b, "o=- %d %d %s\r\n", sessionID, version, connection(address))
+	b.WriteString("s=-\r\n")
+	b.WriteString("t=0 0\r\n")
+	b.WriteString(media.String())
+	return b.String()
+}
@@ -0,0 +1,241 @@
+/*
+Package sdp parses and generates the audio media section of an SDP offer or
+answer (RFC 4566, RFC 3264) and negotiates it against the codecs of a
+transcode.Registry.
+
+Only what configures the audio path is modelled: formats with their rtpmap
+and fmtp, ptime and maxptime, direction and SDES crypto (RFC 4568). Other
+media attributes are kept verbatim.
+*/
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pidato/audio/transcode"
+)
+
+var (
+	ErrNoAudio     = errors.New("no audio media")
+	ErrSyntax      = errors.New("invalid sdp")
+	ErrNoCodec     = errors.New("no common codec")
+	ErrCrypto      = errors.New("no common crypto suite")
+	ErrPayloadType = errors.New("no free payload type")
+)
+
+// Transport protocols of the m-line.
+const (
+	RTPAVP  = "RTP/AVP"
+	RTPSAVP = "RTP/SAVP"
+)
+
+// TelephoneEvent is the encoding name of DTMF events (RFC 4733).
+const TelephoneEvent = "telephone-event"
+
+// Direction is the direction attribute of a media section.
+type Direction string
+
+const (
+	SendRecv Direction = "sendrecv"
+	SendOnly Direction = "sendonly"
+	RecvOnly Direction = "recvonly"
+	Inactive Direction = "inactive"
+)
+
+func (d Direction) sends() bool {
+	return d == SendRecv || d == SendOnly
+}
+
+func (d Direction) receives() bool {
+	return d == SendRecv || d == RecvOnly
+}
+
+func directionOf(send, receive bool) Direction {
+	switch {
+	case send && receive:
+		return SendRecv
+	case send:
+		return SendOnly
+	case receive:
+		return RecvOnly
+	}
+	return Inactive
+}
+
+// Reverse returns the direction seen from the other side.
+func (d Direction) Reverse() Direction {
+	return directionOf(d.receives(), d.sends())
+}
+
+// Format is a payload format of the m-line.
+type Format struct {
+	PayloadType uint8
+	Name        string
+	ClockRate   int
+	Channels    int // 0 when not signalled, which means 1.
+	Fmtp        string
+}
+
+// Codec returns the codec of the format in a transcode.Registry.
+func (f Format) Codec() transcode.Codec {
+	return transcode.Codec{Name: f.Name, ClockRate: f.ClockRate, Channels: f.Channels}
+}
+
+// Params returns the fmtp parameters. Parameters without a value, such as the
+// events of telephone-event, are keys with an empty value.
+func (f Format) Params() map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(f.Fmtp, ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			params[strings.ToLower(strings.TrimSpace(param[:i]))] = strings.TrimSpace(param[i+1:])
+		} else {
+			params[param] = ""
+		}
+	}
+	return params
+}
+
+func (f Format) is(name string) bool {
+	return strings.EqualFold(f.Name, name)
+}
+
+func (f Format) same(o Format) bool {
+	return f.is(o.Name) && f.ClockRate == o.ClockRate && channels(f) == channels(o)
+}
+
+func channels(f Format) int {
+	if f.Channels == 0 {
+		return 1
+	}
+	return f.Channels
+}
+
+// OpusParams are the fmtp parameters of Opus (RFC 7587).
+type OpusParams struct {
+	UseInbandFEC      bool
+	MaxAverageBitrate int // 0 when not signalled.
+	Stereo            bool
+	MaxPlaybackRate   int // 0 when not signalled.
+}
+
+// Opus returns the fmtp parameters of an Opus format.
+func (f Format) Opus() OpusParams {
+	params := f.Params()
+	p := OpusParams{
+		UseInbandFEC: params["useinbandfec"] == "1",
+		Stereo:       params["stereo"] == "1",
+	}
+	p.MaxAverageBitrate, _ = strconv.Atoi(params["maxaveragebitrate"])
+	p.MaxPlaybackRate, _ = strconv.Atoi(params["maxplaybackrate"])
+	return p
+}
+
+// SetOpus sets the fmtp parameters of an Opus format.
+func (f *Format) SetOpus(p OpusParams) {
+	var params []string
+	if p.MaxPlaybackRate != 0 {
+		params = append(params, "maxplaybackrate="+strconv.Itoa(p.MaxPlaybackRate))
+	}
+	if p.MaxAverageBitrate != 0 {
+		params = append(params, "maxaveragebitrate="+strconv.Itoa(p.MaxAverageBitrate))
+	}
+	if p.Stereo {
+		params = append(params, "stereo=1")
+	}
+	if p.UseInbandFEC {
+		params = append(params, "useinbandfec=1")
+	}
+	f.Fmtp = strings.Join(params, ";")
+}
+
+// AnnexB reports whether a G729 format allows Annex B silence suppression,
+// which is the default when annexb isn't signalled.
+func (f Format) AnnexB() bool {
+	return !strings.EqualFold(f.Params()["annexb"], "no")
+}
+
+// Crypto is an SDES crypto attribute (RFC 4568).
+type Crypto struct {
+	Tag   int
+	Suite string // Such as AES_CM_128_HMAC_SHA1_80.
+	// Key parameters, such as "inline:<key>", and session parameters.
+	KeyParams     string
+	SessionParams string
+}
+
+func (c Crypto) String() string {
+	s := fmt.Sprintf("%d %s %s", c.Tag, c.Suite, c.KeyParams)
+	if c.SessionParams != "" {
+		s += " " + c.SessionParams
+	}
+	return s
+}
+
+// Media is an audio media section.
+type Media struct {
+	Address  string // Connection address, from the session when not set on the media.
+	Port     int    // 0 rejects the media.
+	Protocol string
+	Formats  []Format // In order of preference.
+
+	Ptime     int // Milliseconds, 0 when not signalled.
+	MaxPtime  int
+	Direction Direction
+	Crypto    []Crypto
+
+	// Other attributes of the media, without "a=".
+	Attributes []string
+}
+
+// Codec returns the preferred format that isn't telephone-event.
+func (m *Media) Codec() (Format, bool) {
+	for _, f := range m.Formats {
+		if !f.is(TelephoneEvent) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// TelephoneEvent returns the telephone-event format with the clock rate of
+// the codec, or any when there is none.
+func (m *Media) TelephoneEvent() (Format, bool) {
+	codec, _ := m.Codec()
+	var found Format
+	ok := false
+	for _, f := range m.Formats {
+		if !f.is(TelephoneEvent) {
+			continue
+		}
+		if f.ClockRate == codec.ClockRate {
+			return f, true
+		}
+		if !ok {
+			found, ok = f, true
+		}
+	}
+	return found, ok
+}
+
+// Params returns the transcode.Params of a format of the media.
+func (m *Media) Params(f Format) transcode.Params {
+	p := transcode.Params{Ptime: m.Ptime}
+	switch {
+	case f.is("opus"):
+		opus := f.Opus()
+		p.FEC = opus.UseInbandFEC
+		if opus.Stereo {
+			p.Channels = 2
+		}
+	case f.is("G729"):
+		p.VAD = f.AnnexB()
+	}
+	return p
+}
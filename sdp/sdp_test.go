@@ -0,0 +1,156 @@
+package sdp
+
+import (
+	"reflect"
+	"testing"
+)
+
+const offer = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 192.0.2.1\r\n" +
+	"t=0 0\r\n" +
+	"a=sendrecv\r\n" +
+	"m=audio 49170 RTP/SAVP 111 0 18 101 13\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=fmtp:111 minptime=10;useinbandfec=1; stereo=1\r\n" +
+	"a=rtpmap:18 G729/8000\r\n" +
+	"a=fmtp:18 annexb=yes\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=fmtp:101 0-16\r\n" +
+	"a=ptime:20\r\n" +
+	"a=maxptime:60\r\n" +
+	"a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:32\r\n" +
+	"a=crypto:2 AES_CM_128_HMAC_SHA1_32 inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=recvonly\r\n" +
+	"m=video 49172 RTP/AVP 96\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"a=sendrecv\r\n"
+
+func TestParseAudio(t *testing.T) {
+	m, err := ParseAudio(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Address != "192.0.2.1" || m.Port != 49170 || m.Protocol != RTPSAVP {
+		t.Fatal(m.Address, m.Port, m.Protocol)
+	}
+	if m.Ptime != 20 || m.MaxPtime != 60 || m.Direction != RecvOnly {
+		t.Fatal(m.Ptime, m.MaxPtime, m.Direction)
+	}
+	want := []Format{
+		{PayloadType: 111, Name: "opus", ClockRate: 48000, Channels: 2, Fmtp: "minptime=10;useinbandfec=1; stereo=1"},
+		{PayloadType: 0, Name: "PCMU", ClockRate: 8000},
+		{PayloadType: 18, Name: "G729", ClockRate: 8000, Fmtp: "annexb=yes"},
+		{PayloadType: 101, Name: TelephoneEvent, ClockRate: 8000, Fmtp: "0-16"},
+		{PayloadType: 13, Name: "CN", ClockRate: 8000},
+	}
+	if !reflect.DeepEqual(m.Formats, want) {
+		t.Fatalf("%+v", m.Formats)
+	}
+	if p := m.Formats[0].Opus(); !p.UseInbandFEC || !p.Stereo || p.MaxAverageBitrate != 0 {
+		t.Fatalf("%+v", p)
+	}
+	if !m.Formats[2].AnnexB() {
+		t.Fatal("annexb")
+	}
+	if len(m.Crypto) != 2 || m.Crypto[1].Tag != 2 || m.Crypto[0].KeyParams != "inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:32" {
+		t.Fatalf("%+v", m.Crypto)
+	}
+	if !reflect.DeepEqual(m.Attributes, []string{"rtcp-mux"}) {
+		t.Fatal(m.Attributes)
+	}
+	if te, ok := m.TelephoneEvent(); !ok || te.PayloadType != 101 {
+		t.Fatal(te, ok)
+	}
+
+	// What is generated parses the same.
+	again, err := ParseAudio(Session(1, 1, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, m) {
+		t.Fatalf("%+v\n%+v", again, m)
+	}
+
+	if _, err = ParseAudio("v=0\r\nm=video 9 RTP/AVP 96\r\n"); err != ErrNoAudio {
+		t.Fatal(err)
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	m, err := ParseAudio(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := Crypto{Suite: "AES_CM_128_HMAC_SHA1_32", KeyParams: "inline:d0RmdmcmVCspeEc3QGZiNWpVLFJhQX1cfHAwJSoj"}
+	answer, err := Answer(m, Options{Address: "198.51.100.1", Port: 5004, Crypto: []Crypto{local}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, ok := answer.Codec()
+	if !ok || codec.Name != "opus" || codec.PayloadType != 111 {
+		t.Fatal(codec, ok)
+	}
+	if p := codec.Opus(); !p.UseInbandFEC || p.Stereo {
+		t.Fatalf("%+v", p)
+	}
+	// CN isn't in the default formats.
+	var names []string
+	for _, f := range answer.Formats {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"opus", "PCMU", "G729", TelephoneEvent}) {
+		t.Fatal(names)
+	}
+	if g729 := answer.Formats[2]; g729.AnnexB() {
+		t.Fatal(g729.Fmtp)
+	}
+	if answer.Direction != SendOnly || answer.Ptime != 20 {
+		t.Fatal(answer.Direction, answer.Ptime)
+	}
+	if len(answer.Crypto) != 1 || answer.Crypto[0].Tag != 2 || answer.Crypto[0].KeyParams != local.KeyParams {
+		t.Fatalf("%+v", answer.Crypto)
+	}
+	if p := answer.Params(codec); !p.FEC || p.Ptime != 20 {
+		t.Fatalf("%+v", p)
+	}
+
+	if _, err = Answer(m, Options{}); err != ErrCrypto {
+		t.Fatal(err)
+	}
+	if _, err = Answer(m, Options{Formats: []Format{staticFormats[9]}}); err != ErrNoCodec {
+		t.Fatal(err)
+	}
+}
+
+func TestOffer(t *testing.T) {
+	formats := append(DefaultFormats(),
+		Format{PayloadType: 111, Name: "L16", ClockRate: 16000},
+		Format{PayloadType: 111, Name: "L16", ClockRate: 44100})
+	m, err := Offer(Options{Address: "2001:db8::1", Port: 5004, Ptime: 20, Formats: formats})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// L16 at 44100 isn't supported, the clashing payload type is renumbered.
+	last := m.Formats[len(m.Formats)-1]
+	if last.Name != "L16" || last.ClockRate != 16000 || last.PayloadType != 96 {
+		t.Fatalf("%+v", last)
+	}
+	parsed, err := ParseAudio(Session(1, 1, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Address != "2001:db8::1" || len(parsed.Formats) != len(m.Formats) || parsed.Direction != SendRecv {
+		t.Fatalf("%+v", parsed)
+	}
+
+	answer, err := Answer(parsed, Options{Formats: []Format{staticFormats[8]}, Direction: RecvOnly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codec, _ := answer.Codec(); codec.Name != "PCMA" || answer.Direction != RecvOnly {
+		t.Fatal(codec, answer.Direction)
+	}
+}
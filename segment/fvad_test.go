@@ -0,0 +1,14 @@
+//go:build cgo
+// +build cgo
+
+package segment
+
+import (
+	"testing"
+
+	"github.com/pidato/vad-go"
+)
+
+func TestSegmenter(t *testing.T) {
+	testSegmenter(t, vad.New())
+}
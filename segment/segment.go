@@ -0,0 +1,378 @@
+// Package segment splits a PCM stream into utterances using voice activity
+// detection.
+package segment
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+	"github.com/pidato/vad-go"
+)
+
+var (
+	ErrNilDetector = errors.New("nil detector")
+)
+
+const (
+	DefaultOnset      = time.Millisecond * 60
+	DefaultHangover   = time.Millisecond * 200
+	DefaultMinSpeech  = time.Millisecond * 250
+	DefaultMinSilence = time.Millisecond * 500
+	DefaultPreRoll    = time.Millisecond * 300
+	DefaultMaxBuffer  = time.Second * 30
+)
+
+// Config tunes a Segmenter. Zero values are replaced with defaults.
+type Config struct {
+	// Continuous speech required to start an utterance.
+	Onset time.Duration
+	// Trailing silence kept at the end of an utterance.
+	Hangover time.Duration
+	// Minimum utterance length. Silence doesn't end an utterance before it.
+	MinSpeech time.Duration
+	// Continuous silence required to end an utterance.
+	MinSilence time.Duration
+	// Audio before the onset included at the beginning of an utterance.
+	PreRoll time.Duration
+	// Audio an utterance buffers before the Segmenter blocks on its reader.
+	MaxBuffer time.Duration
+}
+
+func (c *Config) setDefaults() {
+	if c.Onset <= 0 {
+		c.Onset = DefaultOnset
+	}
+	if c.Hangover <= 0 {
+		c.Hangover = DefaultHangover
+	}
+	if c.MinSpeech <= 0 {
+		c.MinSpeech = DefaultMinSpeech
+	}
+	if c.MinSilence <= 0 {
+		c.MinSilence = DefaultMinSilence
+	}
+	if c.Hangover > c.MinSilence {
+		c.Hangover = c.MinSilence
+	}
+	if c.PreRoll < 0 {
+		c.PreRoll = 0
+	} else if c.PreRoll == 0 {
+		c.PreRoll = DefaultPreRoll
+	}
+	if c.MaxBuffer <= 0 {
+		c.MaxBuffer = DefaultMaxBuffer
+	}
+}
+
+type EventType int
+
+const (
+	Start EventType = iota
+	End
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Start:
+		return "start"
+	case End:
+		return "end"
+	}
+	return "unknown"
+}
+
+// Event marks the start or end of an utterance. At is the position in the
+// source stream. For Start it is the beginning of the pre-roll, for End the
+// end of the hangover.
+type Event struct {
+	Type      EventType
+	At        time.Duration
+	Utterance *Utterance
+}
+
+// Utterance is a pcm.Reader over the audio of a single utterance. Frames are
+// available as soon as Start is emitted and the reader reaches io.EOF after
+// End. Utterances must be read or closed, otherwise the Segmenter blocks once
+// MaxBuffer is reached.
+type Utterance struct {
+	*pcm.Buffer
+	ID    int
+	Start time.Duration
+}
+
+// Segmenter wraps a pcm.Reader and a vad.Detector and emits Start and End events
+// for each utterance. Frames before the onset are replayed from a
+// pcm.ReplayReader so each utterance includes its true beginning.
+type Segmenter struct {
+	reader *pcm.ReplayReader
+	vad    vad.Detector
+	config Config
+
+	ptime     time.Duration
+	ptimeMs   int
+	vadChunk  int
+	maxFrames int
+
+	chunk    []int16 // Samples of the next chunk from frames shorter than it.
+	chunkLen int
+	active   bool // Decision of the last chunk.
+
+	onsetFrames      int
+	hangoverFrames   int
+	minSpeechFrames  int
+	minSilenceFrames int
+	preRollFrames    int
+
+	pos      int // Frames read.
+	lastEnd  int // Position of the end of the last utterance.
+	voiced   int // Consecutive voiced frames.
+	unvoiced int // Consecutive unvoiced frames.
+	nextID   int
+	current  *Utterance
+	frames   int       // Frames in the current utterance.
+	pending  [][]int16 // Silence held back until the utterance ends or speech resumes.
+	eof      bool
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// New creates a Segmenter. The detector sample rate and mode must already be
+// set. Only mono readers are supported.
+func New(reader pcm.Reader, v vad.Detector, config Config) (*Segmenter, error) {
+	if v == nil {
+		return nil, ErrNilDetector
+	}
+	if reader.Channels() != 1 {
+		return nil, pool.ErrUnsupported
+	}
+	config.setDefaults()
+
+	ptime := reader.Ptime()
+	if ptime <= 0 {
+		return nil, pool.ErrUnsupported
+	}
+	frames := func(d time.Duration) int {
+		n := int((d + ptime - 1) / ptime)
+		if n < 1 {
+			n = 1
+		}
+		return n
+	}
+
+	// libfvad accepts 10, 20 or 30ms frames. Other frames are regrouped into
+	// 10ms chunks for every detector so decisions don't depend on the backend.
+	vadChunk := reader.FrameSize()
+	switch ptime {
+	case time.Millisecond * 10, time.Millisecond * 20, time.Millisecond * 30:
+	default:
+		vadChunk = reader.SampleRate() / 100
+	}
+
+	s := &Segmenter{
+		vad:              v,
+		config:           config,
+		ptime:            ptime,
+		ptimeMs:          int(ptime / time.Millisecond),
+		vadChunk:         vadChunk,
+		chunk:            make([]int16, vadChunk),
+		maxFrames:        frames(config.MaxBuffer),
+		onsetFrames:      frames(config.Onset),
+		hangoverFrames:   int(config.Hangover / ptime),
+		minSpeechFrames:  frames(config.MinSpeech),
+		minSilenceFrames: frames(config.MinSilence),
+		preRollFrames:    int(config.PreRoll / ptime),
+	}
+	if ptime == time.Microsecond*2500 {
+		s.ptimeMs = 3
+	}
+	// Keep enough history for the pre-roll and the onset frames.
+	s.reader = pcm.NewReplayReader(reader, ptime*time.Duration(s.preRollFrames+s.onsetFrames+1))
+	return s, nil
+}
+
+// Next reads until the next event. Once the source reaches EOF an open
+// utterance is ended and io.EOF is returned afterwards.
+func (s *Segmenter) Next() (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.closed {
+			return Event{}, io.ErrClosedPipe
+		}
+		if s.eof {
+			if s.current != nil {
+				return s.end(), nil
+			}
+			return Event{}, io.EOF
+		}
+
+		frame, err := s.reader.ReadFrame()
+		if err != nil {
+			s.eof = true
+			if err != io.EOF {
+				if s.current != nil {
+					_ = s.current.WriteFinal()
+					s.current = nil
+				}
+				return Event{}, err
+			}
+			continue
+		}
+		s.pos++
+
+		if s.isVoiced(frame) {
+			s.voiced++
+			s.unvoiced = 0
+		} else {
+			s.voiced = 0
+			s.unvoiced++
+		}
+
+		if s.current == nil {
+			if s.voiced >= s.onsetFrames {
+				return s.start(frame)
+			}
+			continue
+		}
+
+		if s.unvoiced == 0 {
+			// Speech resumed. Silence held back belongs to the utterance.
+			s.flushPending(len(s.pending))
+			s.write(frame)
+			continue
+		}
+
+		s.pending = append(s.pending, s.copyFrame(frame))
+		if s.unvoiced >= s.minSilenceFrames && s.frames+len(s.pending) >= s.minSpeechFrames {
+			return s.end(), nil
+		}
+	}
+}
+
+// isVoiced returns whether a chunk that ends in frame is voiced. A frame that
+// doesn't end a chunk has the decision of the last one.
+func (s *Segmenter) isVoiced(frame []int16) bool {
+	decided, voiced := false, false
+	for len(frame) > 0 {
+		var chunk []int16
+		if s.chunkLen == 0 && len(frame) >= s.vadChunk {
+			chunk, frame = frame[:s.vadChunk], frame[s.vadChunk:]
+		} else {
+			n := copy(s.chunk[s.chunkLen:], frame)
+			s.chunkLen += n
+			frame = frame[n:]
+			if s.chunkLen < s.vadChunk {
+				break
+			}
+			chunk, s.chunkLen = s.chunk, 0
+		}
+		decided = true
+		if s.vad.Process(chunk) == vad.Active {
+			voiced = true
+		}
+	}
+	if decided {
+		s.active = voiced
+	}
+	return s.active
+}
+
+func (s *Segmenter) start(frame []int16) (Event, error) {
+	buffer, err := pcm.NewBuffer(s.reader.SampleRate(), s.reader.Channels(), s.ptimeMs, s.maxFrames)
+	if err != nil {
+		return Event{}, err
+	}
+	s.nextID++
+	u := &Utterance{
+		Buffer: buffer,
+		ID:     s.nextID,
+	}
+	s.current = u
+	s.frames = 0
+
+	// Replay everything before the current frame: the pre-roll and the
+	// earlier onset frames, without overlapping the previous utterance.
+	limit := s.preRollFrames + s.onsetFrames - 1
+	if max := s.pos - 1 - s.lastEnd; limit > max {
+		limit = max
+	}
+	replayed := 0
+	if limit > 0 {
+		replayed = s.reader.Replay(limit, func(p []int16) {
+			s.write(p)
+		})
+	}
+	s.write(frame)
+
+	u.Start = time.Duration(s.pos-1-replayed) * s.ptime
+	return Event{Type: Start, At: u.Start, Utterance: u}, nil
+}
+
+func (s *Segmenter) end() Event {
+	hangover := s.hangoverFrames
+	if hangover > len(s.pending) {
+		hangover = len(s.pending)
+	}
+	s.flushPending(hangover)
+	u := s.current
+	_ = u.WriteFinal()
+	s.current = nil
+	s.voiced = 0
+	end := u.Start + time.Duration(s.frames)*s.ptime
+	s.lastEnd = int(end / s.ptime)
+	return Event{Type: End, At: end, Utterance: u}
+}
+
+func (s *Segmenter) copyFrame(frame []int16) []int16 {
+	buf := s.current.Alloc()
+	copy(buf, frame)
+	return buf[:len(frame)]
+}
+
+// write copies a frame into the current utterance.
+func (s *Segmenter) write(frame []int16) {
+	s.writeOwned(s.copyFrame(frame))
+}
+
+func (s *Segmenter) writeOwned(frame []int16) {
+	s.frames++
+	if err := s.current.WriteBlocking(frame); err != nil {
+		// Reader closed the utterance early.
+		s.current.Release(frame)
+	}
+}
+
+// flushPending writes the first n held back frames and releases the rest.
+func (s *Segmenter) flushPending(n int) {
+	for i, frame := range s.pending {
+		if i < n {
+			s.writeOwned(frame)
+		} else {
+			s.current.Release(frame)
+		}
+		s.pending[i] = nil
+	}
+	s.pending = s.pending[:0]
+}
+
+// Close ends any open utterance and closes the source reader.
+func (s *Segmenter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	s.closed = true
+	if s.current != nil {
+		s.flushPending(0)
+		_ = s.current.WriteFinal()
+		s.current = nil
+	}
+	return s.reader.Close()
+}
@@ -0,0 +1,155 @@
+package segment
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/vad-go"
+)
+
+func TestSegmenter_Energy(t *testing.T) {
+	testSegmenter(t, vad.NewEnergy())
+}
+
+func testSegmenter(t *testing.T, v vad.Detector) {
+	reader, err := pcm.OpenWavFile("../pcm/testdata/recording.wav", pcm.Ptime20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	v.SetMode(vad.VeryAggressive)
+	v.SetSampleRate(int32(reader.SampleRate()))
+
+	s, err := New(reader, v, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		frames = map[int]int{}
+		starts = map[int]time.Duration{}
+		ends   = map[int]time.Duration{}
+	)
+	for {
+		ev, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := ev.Utterance
+		switch ev.Type {
+		case Start:
+			if _, ok := starts[u.ID]; ok {
+				t.Fatalf("Utterance %d started twice", u.ID)
+			}
+			starts[u.ID] = ev.At
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					frame, err := u.ReadFrame()
+					if err != nil {
+						return
+					}
+					u.Release(frame)
+					mu.Lock()
+					frames[u.ID]++
+					mu.Unlock()
+				}
+			}()
+		case End:
+			start, ok := starts[u.ID]
+			if !ok {
+				t.Fatalf("Utterance %d ended before it started", u.ID)
+			}
+			if ev.At-start < DefaultMinSpeech {
+				t.Errorf("Utterance %d shorter than MinSpeech: %v", u.ID, ev.At-start)
+			}
+			ends[u.ID] = ev.At
+		}
+	}
+	wg.Wait()
+
+	if len(starts) == 0 {
+		t.Fatal("No utterances found")
+	}
+	if len(ends) != len(starts) {
+		t.Fatalf("Got %d starts, but %d ends", len(starts), len(ends))
+	}
+	var last time.Duration
+	for id := 1; id <= len(starts); id++ {
+		if starts[id] < last {
+			t.Errorf("Utterance %d overlaps the previous one", id)
+		}
+		last = ends[id]
+		duration := time.Duration(frames[id]) * reader.Ptime()
+		if duration != ends[id]-starts[id] {
+			t.Errorf("Utterance %d: read %v, but events span %v", id, duration, ends[id]-starts[id])
+		}
+	}
+}
+
+// chunkDetector fails frames that libfvad would reject at 16Khz.
+type chunkDetector struct {
+	vad.Detector
+	invalid int
+}
+
+func (d *chunkDetector) Process(frame []int16) vad.Result {
+	switch len(frame) {
+	case 160, 320, 480:
+		return d.Detector.Process(frame)
+	}
+	d.invalid++
+	return vad.Invalid
+}
+
+func TestSegmenter_FrameSizes(t *testing.T) {
+	for _, ptime := range []int{3, 5, 40} {
+		reader, err := pcm.OpenWavFile("../pcm/testdata/recording.wav", ptime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &chunkDetector{Detector: vad.NewEnergy()}
+		d.SetMode(vad.VeryAggressive)
+		d.SetSampleRate(int32(reader.SampleRate()))
+		s, err := New(reader, d, Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		starts := 0
+		for {
+			ev, err := s.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ev.Type == Start {
+				starts++
+				go func(u *Utterance) {
+					for {
+						frame, err := u.ReadFrame()
+						if err != nil {
+							return
+						}
+						u.Release(frame)
+					}
+				}(ev.Utterance)
+			}
+		}
+		_ = s.Close()
+		if d.invalid > 0 || starts == 0 {
+			t.Fatalf("ptime %d: %d invalid frames, %d utterances", ptime, d.invalid, starts)
+		}
+	}
+}
@@ -21,6 +21,7 @@ var (
 // until either the Reader or Writer processes the next frame.
 type Decoder struct {
 	sampleRate       int
+	channels         int
 	ptime            int
 	pcmFrameSize     int
 	opusFrameSize    int
@@ -44,10 +45,10 @@ type Decoder struct {
 	size               int
 	decoded            [][]int16
 
-	partial     []int16 // Partial frame buffer size.
 	frameBuffer [5760 * 2]int16
 
-	nextFrame []int16
+	nextFrame []int16 // Partially filled frame.
+	nextLen   int
 
 	maxReaderPCMLag int
 
@@ -64,14 +65,16 @@ type Decoder struct {
 	mu         sync.Mutex
 }
 
-func NewDecoder(sampleRate, ptime, maxFrames int) (*Decoder, error) {
+// NewDecoder creates a Decoder producing interleaved PCM frames of the
+// supplied sample rate, channel count and ptime.
+func NewDecoder(sampleRate, channels, ptime, maxFrames int) (*Decoder, error) {
 	if maxFrames < 2 {
 		maxFrames = 2
 	}
 	if maxFrames > 10000 {
 		maxFrames = 10000
 	}
-	p, err := pool.Of(sampleRate, ptime)
+	p, err := pool.Of(sampleRate, channels, ptime)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +83,7 @@ func NewDecoder(sampleRate, ptime, maxFrames int) (*Decoder, error) {
 		return nil, pool.ErrUnsupported
 	}
 
-	dec, err := opus.NewDecoder(sampleRate, 1)
+	dec, err := opus.NewDecoder(sampleRate, channels)
 	if err != nil {
 		return nil, err
 	}
@@ -88,17 +91,20 @@ func NewDecoder(sampleRate, ptime, maxFrames int) (*Decoder, error) {
 	pcmPool := p.ForPtime(ptime)
 
 	e := &Decoder{
-		sampleRate:     sampleRate,
-		ptime:          ptime,
-		buffer:         pbytes.GetLen(2880 * 2),
-		pool:           p,
-		pcmPool:        pcmPool,
-		pcmFrameSize:   pcmPool.FrameSize,
-		maxFrames:      maxFrames,
-		decoded:        make([][]int16, maxFrames),
-		nextFrame:      nil,
-		decoder:        dec,
-		sampleDuration: time.Second / time.Duration(sampleRate),
+		sampleRate:       sampleRate,
+		channels:         channels,
+		ptime:            ptime,
+		buffer:           pbytes.GetLen(2880 * 2),
+		pool:             p,
+		pcmPool:          pcmPool,
+		pcmFrameSize:     pcmPool.FrameSize * channels,
+		opusFrameSize:    opusFrameSize,
+		opusFrameSizeInt: opusFrameSize,
+		maxFrames:        maxFrames,
+		decoded:          make([][]int16, maxFrames),
+		nextFrame:        nil,
+		decoder:          dec,
+		sampleDuration:   time.Second / time.Duration(sampleRate),
 	}
 
 	return e, nil
@@ -112,6 +118,11 @@ func (e *Decoder) SampleRate() int {
 	return e.sampleRate
 }
 
+func (e *Decoder) Channels() int {
+	return e.channels
+}
+
+// FrameSize returns the number of samples per channel in a PCM frame.
 func (e *Decoder) FrameSize() int {
 	return e.pcmPool.FrameSize
 }
@@ -186,6 +197,10 @@ func (e *Decoder) Close() error {
 		e.decoded[i] = nil
 	}
 	e.decoded = nil
+	if e.nextFrame != nil {
+		e.pcmPool.Release(e.nextFrame)
+		e.nextFrame = nil
+	}
 	if e.buffer != nil {
 		pbytes.Put(e.buffer)
 		e.buffer = nil
@@ -293,173 +308,84 @@ func (e *Decoder) WriteBlocking(packet []byte) error {
 	return nil
 }
 
+// WriteFEC recovers a lost packet from the inband FEC data of the packet that
+// followed it. samples is the duration of the lost packet per channel.
 func (f *Decoder) WriteFEC(packet []byte, samples int) error {
-	if samples > len(f.frameBuffer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if samples*f.channels > len(f.frameBuffer) {
 		return ErrCorrupted
 	}
 
-	buf := f.frameBuffer[:samples]
+	// Limit the capacity too: it mustn't decode more than the lost packet.
+	n := samples * f.channels
+	buf := f.frameBuffer[:n:n]
 	err := f.decoder.DecodeFEC(packet, buf)
 	if err != nil {
 		return err
 	}
 
+	if err = f.push(samples); err != nil {
+		return err
+	}
+	if f.readerWait {
+		f.readerWait = false
+		f.readerWg.Done()
+	}
 	return nil
 }
 
 func (f *Decoder) doDecode(packet []byte) error {
-	//switch frame.Samples {
-	//case 120: // 2.5ms
-	//case 240: // 5ms
-	//case 480: // 10ms
-	//case 960: // 20ms
-	//case 1920: // 40ms
-	//case 2880: // 60ms
-	//case 5760: // 120ms
-	//default:
-	//	return ErrCorrupted
-	//}
-
-	// Decode.
-	frameBuffer := f.frameBuffer[:]
-
-	n, err := f.decoder.Decode(packet, frameBuffer)
+	n, err := f.decoder.Decode(packet, f.frameBuffer[:])
 	if err != nil {
 		return err
 	}
 
-	// Make sure it's a valid packet duration.
-	if n%120 != 0 { // Must be divisible by 2.5ms
+	// Make sure it's a valid packet duration. Must be divisible by 2.5ms.
+	if n == 0 || n%(f.sampleRate/400) != 0 {
 		return ErrCorrupted
 	}
 
-	frameBuffer = frameBuffer[:n]
-
-	if len(f.nextFrame) > 0 {
-		remaining := f.pcmFrameSize - len(f.nextFrame)
-		if remaining < 0 {
-			return ErrCorrupted
-		}
-		if remaining == n {
-			// Perfect match.
-		} else if remaining > n {
+	return f.push(n)
+}
 
-		} else {
+// push splits the first n samples per channel of frameBuffer into PCM frames.
+// Samples that don't fill a frame are kept until the next packet.
+func (f *Decoder) push(n int) error {
+	buf := f.frameBuffer[:n*f.channels]
 
-		}
-	} else {
-		// Fit in with remaining.
-	}
-
-	switch n {
-	case 120: // 2.5ms
-	case 240: // 5ms
-	case 480: // 10ms
-	case 960: // 20ms
-	case 1920: // 40ms
-	case 2880: // 60ms
-	case 5760: // 120ms
-	default:
-		return ErrCorrupted
-	}
-
-	if len(f.nextFrame) > 0 {
-		// Is there a partial frame?
-		f.opusFrameSize
+	frames := (f.nextLen + len(buf)) / f.pcmFrameSize
+	if f.size+frames > len(f.decoded) {
+		return io.ErrShortBuffer
 	}
 
-	var pcm []int16
-
-	opusWritten := uint64(f.opusSamplesWritten)
-
-	// Missing any frames?
-	if frame.Pos > opusWritten {
-		diff := frame.Pos - opusWritten
-		framesMissing := int(diff) / f.opusFrameSize
-
-		// Expect a multiple of the frame size.
-		overflow := int(diff) % f.opusFrameSize
-		if overflow > 0 {
-			return ErrCorrupted
+	for len(buf) > 0 {
+		if f.nextFrame == nil {
+			f.nextFrame = f.pcmPool.Get()
+			f.nextLen = 0
 		}
-
-		if f.fec {
-			framesMissing -= 1
-			fixed := make([]int16, f.pcmFrameSize*2)
-			err := f.decoder.DecodeFEC(frame.Data, fixed)
-			if err != nil {
-				return err
-			}
-
-			frame1 := f.pcmPool.Get()
-			copy(frame1, fixed[:len(frame1)])
-			frame2 := f.pcmPool.Get()
-			copy(frame2, fixed[len(frame2):])
-
-			// Write the error corrected frame.
-			f.decoded[f.writerIndex%len(f.decoded)] = frame1
-			f.opusSamplesWritten += len(frame1)
-			f.pcmSamplesWritten += len(frame1)
-			f.writerIndex++
-			f.size++
-
-			// Write the next frame.
-			f.decoded[f.writerIndex%len(f.decoded)] = frame2
-			f.opusSamplesWritten += f.opusFrameSize
-			f.pcmSamplesWritten += len(frame2)
-			f.writerIndex++
-			f.size++
-
-			// Add missing frames.
-			f.pcmFramesRead += framesMissing
-			f.opusSamplesWritten += f.opusFrameSize * framesMissing
-		} else {
-			f.pcmFramesRead += framesMissing
-			pcm := f.pcmPool.Get()
-			n, err := f.decoder.Decode(frame.Data, pcm)
-			if err != nil {
-				return err
-			}
-			if n != len(pcm) {
-				return io.ErrShortWrite
-			}
-
-			// Write the next frame.
-			f.decoded[f.writerIndex%len(f.decoded)] = pcm
-			f.opusSamplesWritten += f.opusFrameSize
-			f.pcmSamplesWritten += len(pcm)
-			f.writerIndex++
-			f.size++
-
-			// Skip the reader ahead.
-			f.pcmFramesRead += framesMissing
-			// Skip the writer ahead.
-			f.opusSamplesWritten += f.opusFrameSize * framesMissing
-		}
-	} else {
-		// Allocate buffer.
-		pcm := f.pcmPool.Get()
-		n, err := f.decoder.Decode(frame.Data, pcm)
-		if err != nil {
-			f.pcmPool.Release(pcm)
-			return err
-		}
-		if n != len(pcm) {
-			return io.ErrShortWrite
+		copied := copy(f.nextFrame[f.nextLen:], buf)
+		f.nextLen += copied
+		buf = buf[copied:]
+		if f.nextLen < len(f.nextFrame) {
+			break
 		}
 
-		// Write the frameBuffer frame.
-		f.decoded[f.writerIndex%len(f.decoded)] = pcm
-		f.opusSamplesWritten += f.opusFrameSize
-		f.pcmSamplesWritten += len(pcm)
+		f.decoded[f.writerIndex%len(f.decoded)] = f.nextFrame
+		f.nextFrame = nil
+		f.nextLen = 0
+		f.pcmSamplesWritten += f.pcmPool.FrameSize
 		f.writerIndex++
 		f.size++
 	}
+	f.opusSamplesWritten += n * (48000 / f.sampleRate)
 
 	if f.maxReaderPCMLag < f.size {
 		f.maxReaderPCMLag = f.size
 	}
-
 	return nil
 }
 
@@ -500,7 +426,7 @@ func (e *Decoder) ReadFrame() (frame []int16, frameNumber int, err error) {
 		e.opusSamplesRead += e.opusFrameSize
 		frameNumber = e.pcmSamplesRead
 		e.pcmFramesRead++
-		e.pcmSamplesRead += len(frame)
+		e.pcmSamplesRead += len(frame) / e.channels
 
 		// Notify writer if needed.
 		if e.writerWait {
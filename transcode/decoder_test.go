@@ -0,0 +1,214 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/pidato/audio/opus"
+)
+
+// speech returns frames of frameSize samples of the 16Khz mono recording in
+// the opus testdata. Concealment can't predict speech as well as tones.
+func speech(t *testing.T, frameSize int) [][]int16 {
+	wav, err := ioutil.ReadFile("../opus/testdata/recording16.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := wav[44:]
+	var frames [][]int16
+	for len(data) >= 2*frameSize {
+		frame := make([]int16, frameSize)
+		for i := range frame {
+			frame[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		frames = append(frames, frame)
+		data = data[2*frameSize:]
+	}
+	return frames
+}
+
+// correlation returns the normalized cross-correlation of a and b.
+func correlation(a, b []int16) float64 {
+	var ab, aa, bb float64
+	for i := range a {
+		ab += float64(a[i]) * float64(b[i])
+		aa += float64(a[i]) * float64(a[i])
+		bb += float64(b[i]) * float64(b[i])
+	}
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+	return ab / math.Sqrt(aa*bb)
+}
+
+func TestDecoder_Write(t *testing.T) {
+	const sampleRate = 16000
+	for _, test := range []struct {
+		packetSize int // Samples per channel.
+		channels   int
+	}{
+		{160, 1}, // 10ms packets fill a frame in two.
+		{960, 1}, // 60ms packets fill three.
+		{320, 2},
+	} {
+		enc, err := opus.NewEncoder(sampleRate, test.channels, opus.AppVoIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The other channel is inverted.
+		var packets [][]byte
+		for _, mono := range speech(t, test.packetSize)[:48000/test.packetSize] {
+			frame := make([]int16, 0, len(mono)*test.channels)
+			for _, s := range mono {
+				frame = append(frame, s)
+				if test.channels == 2 {
+					frame = append(frame, -s)
+				}
+			}
+			packet := make([]byte, 1500)
+			n, err := enc.Encode(frame, packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			packets = append(packets, packet[:n])
+		}
+
+		ref, err := opus.NewDecoder(sampleRate, test.channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []int16
+		for _, packet := range packets {
+			pcm := make([]int16, test.packetSize*test.channels)
+			n, err := ref.Decode(packet, pcm)
+			if err != nil || n != test.packetSize {
+				t.Fatal(n, err)
+			}
+			want = append(want, pcm...)
+		}
+
+		dec, err := NewDecoder(sampleRate, test.channels, 20, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, packet := range packets {
+			if err := dec.Write(packet); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := dec.WriteFinal(); err != nil {
+			t.Fatal(err)
+		}
+		var got []int16
+		for {
+			frame, frameNumber, err := dec.ReadFrame()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(frame) != 320*test.channels || frameNumber != len(got)/test.channels {
+				t.Fatal(test.packetSize, len(frame), frameNumber)
+			}
+			got = append(got, frame...)
+		}
+		_ = dec.Close()
+		if len(got) != len(want) {
+			t.Fatal(test.packetSize, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%d: sample %d is %d, want %d", test.packetSize, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestDecoder_WriteFull(t *testing.T) {
+	enc, err := opus.NewEncoder(16000, 1, opus.AppVoIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := make([]byte, 1500)
+	n, err := enc.Encode(speech(t, 960)[0], packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A 60ms packet doesn't fit two 20ms frames.
+	dec, err := NewDecoder(16000, 1, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	if err := dec.Write(packet[:n]); err != io.ErrShortBuffer {
+		t.Fatal(err)
+	}
+	if err := dec.Write([]byte{0xff}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestDecoder_WriteFEC(t *testing.T) {
+	const sampleRate, lost = 16000, 20
+	enc, err := opus.NewEncoder(sampleRate, 1, opus.AppVoIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.SetInBandFEC(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.SetPacketLossPerc(30); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.SetBitrate(24000); err != nil {
+		t.Fatal(err)
+	}
+	var packets [][]byte
+	for _, frame := range speech(t, 320)[:lost+10] {
+		packet := make([]byte, 1500)
+		n, err := enc.Encode(frame, packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet[:n])
+	}
+
+	decode := func(recover bool) [][]int16 {
+		dec, err := NewDecoder(sampleRate, 1, 20, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Close releases the frames to the pool.
+		defer dec.Close()
+		var frames [][]int16
+		for i, packet := range packets {
+			if recover && i == lost {
+				continue
+			}
+			if recover && i == lost+1 {
+				if err := dec.WriteFEC(packet, 320); err != nil {
+					t.Fatal(err)
+				}
+				frame, _, _ := dec.ReadFrame()
+				frames = append(frames, append([]int16(nil), frame...))
+			}
+			if err := dec.Write(packet); err != nil {
+				t.Fatal(err)
+			}
+			frame, _, _ := dec.ReadFrame()
+			frames = append(frames, append([]int16(nil), frame...))
+		}
+		return frames
+	}
+	want, got := decode(false), decode(true)
+	if len(got) != len(want) {
+		t.Fatal(len(got), len(want))
+	}
+	if c := correlation(got[lost], want[lost]); c < 0.8 {
+		t.Fatalf("recovered frame correlates %.2f with the sent one", c)
+	}
+}
@@ -13,12 +13,14 @@ import (
 
 var (
 	ErrWrongFrameSize = errors.New("wrong frame size")
+	ErrPtime          = errors.New("ptime is not a multiple of the codec frame")
 )
 
 // Encoder between Reader and Writer. Once internal frame buffer is full, it blocks
 // until either the Reader or Writer processes the next frame.
 type Encoder struct {
 	sampleRate       int
+	channels         int
 	ptime            int
 	pcmFrameSize     int
 	opusFrameSize    int
@@ -56,8 +58,16 @@ type Encoder struct {
 	mu         sync.Mutex
 }
 
-func NewEncoder(sampleRate, ptime, maxFrames int) (*Encoder, error) {
-	p, err := pool.Of(sampleRate, ptime)
+// NewEncoder creates an Encoder for interleaved PCM frames of the supplied
+// sample rate, channel count and ptime.
+func NewEncoder(sampleRate, channels, ptime, maxFrames int) (*Encoder, error) {
+	if maxFrames < 1 {
+		maxFrames = 1
+	}
+	if maxFrames > 10000 {
+		maxFrames = 10000
+	}
+	p, err := pool.Of(sampleRate, channels, ptime)
 	if p == nil {
 		return nil, err
 	}
@@ -65,21 +75,27 @@ func NewEncoder(sampleRate, ptime, maxFrames int) (*Encoder, error) {
 	if opusFrameSize == 0 {
 		return nil, pool.ErrUnsupported
 	}
-	enc, err := opus.NewEncoder(sampleRate, 1, opus.AppVoIP)
+	enc, err := opus.NewEncoder(sampleRate, channels, opus.AppVoIP)
 	if err != nil {
 		return nil, err
 	}
 
+	pcmPool := p.ForPtime(ptime)
+
 	e := &Encoder{
-		sampleRate:     sampleRate,
-		ptime:          ptime,
-		buffer:         pbytes.GetLen(2880 * 2),
-		pool:           p,
-		pcmFrameSize:   p.PCM.FrameSize,
-		maxFrames:      maxFrames,
-		encoder:        enc,
-		encoded:        make([]OpusFrame, maxFrames),
-		sampleDuration: time.Second / time.Duration(sampleRate),
+		sampleRate:       sampleRate,
+		channels:         channels,
+		ptime:            ptime,
+		buffer:           pbytes.GetLen(2880 * 2),
+		pool:             p,
+		pcmPool:          pcmPool,
+		pcmFrameSize:     pcmPool.FrameSize * channels,
+		opusFrameSize:    opusFrameSize,
+		opusFrameSizeInt: opusFrameSize,
+		maxFrames:        maxFrames,
+		encoder:          enc,
+		encoded:          make([]OpusFrame, maxFrames),
+		sampleDuration:   time.Second / time.Duration(sampleRate),
 	}
 
 	return e, nil
@@ -93,8 +109,13 @@ func (e *Encoder) SampleRate() int {
 	return e.sampleRate
 }
 
+func (e *Encoder) Channels() int {
+	return e.channels
+}
+
+// FrameSize returns the number of samples per channel in a PCM frame.
 func (e *Encoder) FrameSize() int {
-	return e.pool.PCM.FrameSize
+	return e.pcmPool.FrameSize
 }
 
 func (e *Encoder) Ptime() time.Duration {
@@ -102,11 +123,11 @@ func (e *Encoder) Ptime() time.Duration {
 }
 
 func (e *Encoder) Alloc() []int16 {
-	return e.pool.PCM.Get()
+	return e.pcmPool.Get()
 }
 
 func (e *Encoder) Release(b []int16) {
-	e.pool.PCM.Release(b)
+	e.pcmPool.Release(b)
 }
 
 // Resets state
@@ -161,9 +182,11 @@ func (e *Encoder) Close() error {
 		e.writerWait = false
 		e.writerWg.Done()
 	}
-	// Release pcm.
+	// Release encoded frames.
 	for i, buf := range e.encoded {
-		e.pool.Opus.Release(buf.Data)
+		if buf.Data != nil {
+			pbytes.Put(buf.Data)
+		}
 		e.encoded[i].Data = nil
 	}
 	e.encoded = nil
@@ -298,7 +321,7 @@ func (e *Encoder) doEncode(p []int16) error {
 		Data:    next,
 	}
 	e.opusSamplesWritten += e.opusFrameSize
-	e.pcmSamplesWritten += len(p)
+	e.pcmSamplesWritten += len(p) / e.channels
 	e.writerIndex++
 	e.size++
 	if e.maxReaderPCMLag < e.size {
@@ -336,7 +359,7 @@ func (e *Encoder) ReadFrame() (OpusFrame, error) {
 		e.readerIndex++
 		e.size--
 		e.opusSamplesRead += int(page.Samples)
-		e.pcmSamplesRead += e.pcmFrameSize
+		e.pcmSamplesRead += e.pcmPool.FrameSize
 
 		// Notify writer if needed.
 		if e.writerWait {
@@ -0,0 +1,122 @@
+package transcode
+
+import (
+	"time"
+
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// framer splits decoded audio into PCM frames from the pool and buffers them
+// for a reader. It implements the pcm.Reader methods of the sample based
+// codec decoders. Callers hold their own lock around push.
+type framer struct {
+	sampleRate int
+	channels   int
+	ptime      int
+	pcmPool    *pool.PCM
+	buffer     *pcm.Buffer
+
+	decoded []int16
+
+	nextFrame []int16 // Partially filled frame.
+	nextLen   int
+}
+
+func newFramer(sampleRate, channels, ptime, maxFrames int) (*framer, error) {
+	buffer, err := pcm.NewBuffer(sampleRate, channels, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := pool.Of(sampleRate, channels, ptime)
+	return &framer{
+		sampleRate: sampleRate,
+		channels:   channels,
+		ptime:      ptime,
+		pcmPool:    p.ForPtime(ptime),
+		buffer:     buffer,
+	}, nil
+}
+
+func (f *framer) Elapsed() time.Duration {
+	return f.buffer.Elapsed()
+}
+
+func (f *framer) SampleRate() int {
+	return f.sampleRate
+}
+
+func (f *framer) Channels() int {
+	return f.channels
+}
+
+func (f *framer) FrameSize() int {
+	return f.pcmPool.FrameSize
+}
+
+func (f *framer) Ptime() time.Duration {
+	return time.Duration(f.ptime) * time.Millisecond
+}
+
+func (f *framer) Alloc() []int16 {
+	return f.pcmPool.Get()
+}
+
+func (f *framer) Release(b []int16) {
+	f.pcmPool.Release(b)
+}
+
+// WriteFinal signals there are no more payloads. Readers get io.EOF once the
+// buffered frames are read.
+func (f *framer) WriteFinal() error {
+	return f.buffer.WriteFinal()
+}
+
+func (f *framer) Buffered() int {
+	return f.buffer.Buffered()
+}
+
+func (f *framer) ReadFrame() ([]int16, error) {
+	return f.buffer.ReadFrame()
+}
+
+// scratch returns a buffer to decode samples into before push.
+func (f *framer) scratch(samples int) []int16 {
+	if cap(f.decoded) < samples {
+		f.decoded = make([]int16, samples)
+	}
+	return f.decoded[:samples]
+}
+
+// push splits buf into frames. Samples that don't fill a frame are kept until
+// the next push.
+func (f *framer) push(buf []int16) error {
+	for len(buf) > 0 {
+		if f.nextFrame == nil {
+			f.nextFrame = f.pcmPool.Get()
+			f.nextLen = 0
+		}
+		copied := copy(f.nextFrame[f.nextLen:], buf)
+		f.nextLen += copied
+		buf = buf[copied:]
+		if f.nextLen < len(f.nextFrame) {
+			break
+		}
+
+		frame := f.nextFrame
+		f.nextFrame = nil
+		if err := f.buffer.Write(frame); err != nil {
+			f.pcmPool.Release(frame)
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *framer) close() error {
+	if f.nextFrame != nil {
+		f.pcmPool.Release(f.nextFrame)
+		f.nextFrame = nil
+	}
+	return f.buffer.Close()
+}
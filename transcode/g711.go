@@ -0,0 +1,160 @@
+package transcode
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/g711"
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// Static RTP payload types of G711.
+const (
+	PayloadTypePCMU = 0
+	PayloadTypePCMA = 8
+)
+
+// G711Encoder encodes 8Khz mono PCM frames from the pool to G711 payloads.
+type G711Encoder struct {
+	format  int
+	ptime   int
+	pcmPool *pool.PCM
+	encoder *g711.FrameEncoder
+}
+
+// NewG711Encoder creates a G711Encoder for g711.Alaw or g711.Ulaw.
+func NewG711Encoder(format, ptime int) (*G711Encoder, error) {
+	p, err := pool.Of(8000, 1, ptime)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := g711.NewFrameEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	return &G711Encoder{
+		format:  format,
+		ptime:   ptime,
+		pcmPool: p.ForPtime(ptime),
+		encoder: enc,
+	}, nil
+}
+
+func (e *G711Encoder) SampleRate() int {
+	return 8000
+}
+
+func (e *G711Encoder) Channels() int {
+	return 1
+}
+
+func (e *G711Encoder) FrameSize() int {
+	return e.pcmPool.FrameSize
+}
+
+func (e *G711Encoder) Ptime() time.Duration {
+	return time.Duration(e.ptime) * time.Millisecond
+}
+
+// PayloadType returns the static RTP payload type.
+func (e *G711Encoder) PayloadType() uint8 {
+	if e.format == g711.Alaw {
+		return PayloadTypePCMA
+	}
+	return PayloadTypePCMU
+}
+
+func (e *G711Encoder) Alloc() []int16 {
+	return e.pcmPool.Get()
+}
+
+func (e *G711Encoder) Release(b []int16) {
+	e.pcmPool.Release(b)
+}
+
+// Encode encodes frame into payload and returns the payload length and the
+// RTP timestamp ticks it covers. G711 has a byte per sample, so both are
+// len(frame).
+func (e *G711Encoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	n, err = e.encoder.Encode(frame, payload)
+	return n, n, err
+}
+
+func (e *G711Encoder) Close() error {
+	return nil
+}
+
+var _ pcm.Reader = (*G711Decoder)(nil)
+
+// G711Decoder decodes G711 payloads into 8Khz mono PCM frames. It is a
+// pcm.Reader, so it can feed a pcm.Buffer, the VAD or the mixer.
+//
+// Payloads don't have to match the frame size. Samples that don't fill a
+// frame are kept until the next payload. A payload the jitter buffer gave up
+// on is reported with Missing and concealed.
+type G711Decoder struct {
+	*framer
+	decoder *g711.FrameDecoder
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewG711Decoder creates a G711Decoder for g711.Alaw or g711.Ulaw buffering up
+// to maxFrames. With plc, lost payloads are concealed as in G.711 Appendix I,
+// which delays output by g711.PLCDelay samples and requires payloads to be a
+// multiple of 10ms.
+func NewG711Decoder(format, ptime, maxFrames int, plc bool) (*G711Decoder, error) {
+	dec, err := g711.NewFrameDecoder(format, plc)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFramer(8000, 1, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	return &G711Decoder{
+		framer:  f,
+		decoder: dec,
+	}, nil
+}
+
+// Write decodes the next payload.
+func (d *G711Decoder) Write(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(len(payload))
+	if _, err := d.decoder.Decode(payload, buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+// Missing conceals samples that were lost.
+func (d *G711Decoder) Missing(samples int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(samples)
+	if err := d.decoder.Missing(buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+func (d *G711Decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	d.closed = true
+	return d.close()
+}
@@ -0,0 +1,146 @@
+package transcode
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/g722"
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// G722Encoder encodes 16Khz mono PCM frames from the pool to G722 payloads.
+type G722Encoder struct {
+	ptime   int
+	pcmPool *pool.PCM
+	encoder *g722.FrameEncoder
+}
+
+// NewG722Encoder creates a G722Encoder for one of the g722 bit rates.
+func NewG722Encoder(rate, ptime int) (*G722Encoder, error) {
+	p, err := pool.Of(g722.SampleRate, 1, ptime)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := g722.NewFrameEncoder(rate)
+	if err != nil {
+		return nil, err
+	}
+	return &G722Encoder{
+		ptime:   ptime,
+		pcmPool: p.ForPtime(ptime),
+		encoder: enc,
+	}, nil
+}
+
+func (e *G722Encoder) SampleRate() int {
+	return g722.SampleRate
+}
+
+func (e *G722Encoder) Channels() int {
+	return 1
+}
+
+func (e *G722Encoder) FrameSize() int {
+	return e.pcmPool.FrameSize
+}
+
+func (e *G722Encoder) Ptime() time.Duration {
+	return time.Duration(e.ptime) * time.Millisecond
+}
+
+// PayloadType returns the static RTP payload type.
+func (e *G722Encoder) PayloadType() uint8 {
+	return g722.PayloadType
+}
+
+func (e *G722Encoder) Alloc() []int16 {
+	return e.pcmPool.Get()
+}
+
+func (e *G722Encoder) Release(b []int16) {
+	e.pcmPool.Release(b)
+}
+
+// Encode encodes frame into payload and returns the payload length and the
+// RTP timestamp ticks it covers. The RTP clock rate of G722 is 8000, so both
+// are len(frame) / 2.
+func (e *G722Encoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	n, err = e.encoder.Encode(frame, payload)
+	return n, n, err
+}
+
+func (e *G722Encoder) Close() error {
+	return nil
+}
+
+var _ pcm.Reader = (*G722Decoder)(nil)
+
+// G722Decoder decodes G722 payloads into 16Khz mono PCM frames.
+//
+// Payloads don't have to match the frame size. A payload the jitter buffer
+// gave up on is reported with Missing and replaced with silence.
+type G722Decoder struct {
+	*framer
+	decoder *g722.FrameDecoder
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewG722Decoder creates a G722Decoder for one of the g722 bit rates buffering
+// up to maxFrames.
+func NewG722Decoder(rate, ptime, maxFrames int) (*G722Decoder, error) {
+	dec, err := g722.NewFrameDecoder(rate)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFramer(g722.SampleRate, 1, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	return &G722Decoder{
+		framer:  f,
+		decoder: dec,
+	}, nil
+}
+
+// Write decodes the next payload.
+func (d *G722Decoder) Write(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(len(payload) * 2)
+	if _, err := d.decoder.Decode(payload, buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+// Missing fills the RTP timestamp ticks that were lost with silence. G722
+// ticks at 8000, so that is twice as many samples.
+func (d *G722Decoder) Missing(ticks int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(ticks * 2)
+	for i := range buf {
+		buf[i] = 0
+	}
+	return d.push(buf)
+}
+
+func (d *G722Decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	d.closed = true
+	return d.close()
+}
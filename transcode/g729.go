@@ -0,0 +1,167 @@
+package transcode
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/g729"
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// G729Encoder encodes 8Khz mono PCM frames from the pool to G729 payloads of
+// one or more 10ms frames.
+type G729Encoder struct {
+	ptime   int
+	pcmPool *pool.PCM
+	encoder *g729.Encoder
+}
+
+// NewG729Encoder creates a G729Encoder. With vad, silence is coded as Annex B
+// SID frames or not sent at all.
+func NewG729Encoder(ptime int, vad bool) (*G729Encoder, error) {
+	if ptime <= 0 || ptime%10 != 0 {
+		return nil, ErrPtime
+	}
+	p, err := pool.Of(g729.SampleRate, 1, ptime)
+	if err != nil {
+		return nil, err
+	}
+	return &G729Encoder{
+		ptime:   ptime,
+		pcmPool: p.ForPtime(ptime),
+		encoder: g729.NewEncoder(vad),
+	}, nil
+}
+
+func (e *G729Encoder) SampleRate() int {
+	return g729.SampleRate
+}
+
+func (e *G729Encoder) Channels() int {
+	return 1
+}
+
+func (e *G729Encoder) FrameSize() int {
+	return e.pcmPool.FrameSize
+}
+
+func (e *G729Encoder) Ptime() time.Duration {
+	return time.Duration(e.ptime) * time.Millisecond
+}
+
+// PayloadType returns the static RTP payload type.
+func (e *G729Encoder) PayloadType() uint8 {
+	return g729.PayloadType
+}
+
+func (e *G729Encoder) Alloc() []int16 {
+	return e.pcmPool.Get()
+}
+
+func (e *G729Encoder) Release(b []int16) {
+	e.pcmPool.Release(b)
+}
+
+// Encode encodes frame into payload, which must hold 10 bytes per 10ms.
+//
+// Voice can't follow a SID frame in a payload, so with VAD a frame may need
+// more than one payload. Encode returns the length of the first and the
+// RTP timestamp ticks it covers, and is called again with the rest of the
+// frame. An empty payload is not sent but still advances the RTP timestamp.
+func (e *G729Encoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	n, frames, err := e.encoder.EncodePayload(frame, payload)
+	return n, frames * g729.FrameSize, err
+}
+
+func (e *G729Encoder) Close() error {
+	return e.encoder.Close()
+}
+
+var _ pcm.Reader = (*G729Decoder)(nil)
+
+// G729Decoder decodes G729 payloads into 8Khz mono PCM frames.
+//
+// Payloads don't have to match the frame size. Lost payloads reported with
+// Missing are concealed by the decoder, or continue the comfort noise when
+// the last frame received was a SID.
+type G729Decoder struct {
+	*framer
+	decoder *g729.Decoder
+	last    g729.FrameType
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewG729Decoder creates a G729Decoder buffering up to maxFrames.
+func NewG729Decoder(ptime, maxFrames int) (*G729Decoder, error) {
+	f, err := newFramer(g729.SampleRate, 1, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	return &G729Decoder{
+		framer:  f,
+		decoder: g729.NewDecoder(),
+		last:    g729.Voice,
+	}, nil
+}
+
+// Write decodes the next payload.
+func (d *G729Decoder) Write(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	frames, err := g729.PayloadFrames(payload)
+	if err != nil {
+		return err
+	}
+	buf := d.scratch(frames * g729.FrameSize)
+	if _, err = d.decoder.DecodePayload(payload, buf); err != nil {
+		return err
+	}
+	if len(payload)%g729.VoiceFrameSize != 0 {
+		d.last = g729.SID
+	} else if len(payload) > 0 {
+		d.last = g729.Voice
+	}
+	return d.push(buf)
+}
+
+// Missing conceals samples that were lost. samples is rounded up to whole
+// 10ms frames.
+func (d *G729Decoder) Missing(samples int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	frames := (samples + g729.FrameSize - 1) / g729.FrameSize
+	buf := d.scratch(frames * g729.FrameSize)
+	for i := 0; i < len(buf); i += g729.FrameSize {
+		var err error
+		if d.last == g729.Voice {
+			_, err = d.decoder.Erase(buf[i:])
+		} else {
+			_, _, err = d.decoder.Decode(nil, buf[i:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.push(buf)
+}
+
+func (d *G729Decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	d.closed = true
+	_ = d.decoder.Close()
+	return d.close()
+}
@@ -0,0 +1,95 @@
+package transcode
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/pidato/audio/g729"
+)
+
+// tone returns a sine of freq Hz at sampleRate, in every channel of
+// interleaved samples, each channel at freq times its number.
+func tone(freq float64, sampleRate, channels, samples int) []int16 {
+	out := make([]int16, samples*channels)
+	for i := 0; i < samples; i++ {
+		for c := 0; c < channels; c++ {
+			out[i*channels+c] = int16(10000 * math.Sin(2*math.Pi*freq*float64(c+1)*float64(i)/float64(sampleRate)))
+		}
+	}
+	return out
+}
+
+// power returns the mean square of samples.
+func power(samples []int16) float64 {
+	p := 0.0
+	for _, s := range samples {
+		p += float64(s) * float64(s)
+	}
+	return p / float64(len(samples))
+}
+
+func TestG729Decoder_Missing(t *testing.T) {
+	// Voice, then faint noise coded as SID and untransmitted frames.
+	random := rand.New(rand.NewSource(1))
+	pcm := tone(300, 8000, 1, 8000)
+	for i := 2400; i < len(pcm); i++ {
+		pcm[i] = int16(random.Intn(21) - 10)
+	}
+	enc := g729.NewEncoder(true)
+	defer enc.Close()
+	var voice, sid [][]byte
+	for i := 0; i < len(pcm); i += g729.FrameSize {
+		frame := make([]byte, g729.VoiceFrameSize)
+		n, frameType, err := enc.Encode(pcm[i:i+g729.FrameSize], frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch frameType {
+		case g729.Voice:
+			voice = append(voice, frame[:n])
+		case g729.SID:
+			sid = append(sid, frame[:n])
+		}
+	}
+	if len(voice) < 10 || len(sid) == 0 {
+		t.Fatal(len(voice), len(sid))
+	}
+
+	// missing returns the 20ms frame concealed after payloads.
+	missing := func(payloads ...[]byte) []int16 {
+		dec, err := NewG729Decoder(20, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dec.Close()
+		for _, payload := range payloads {
+			if err := dec.Write(payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Rounded up to two 10ms frames.
+		if err := dec.Missing(100); err != nil {
+			t.Fatal(err)
+		}
+		var frame []int16
+		for i := 0; i <= len(payloads)/2; i++ {
+			if frame, err = dec.ReadFrame(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return append([]int16(nil), frame...)
+	}
+
+	// Erasure after voice continues the voice into the second 10ms, where
+	// comfort noise would have faded out.
+	afterVoice := missing(voice[:10]...)
+	if p := power(afterVoice[g729.FrameSize:]); p < 1000*1000 {
+		t.Errorf("concealed voice has power %.0f", p)
+	}
+	// After a SID the comfort noise goes on.
+	afterSID := missing(append(append([][]byte(nil), voice[:9]...), sid[0])...)
+	if p := power(afterSID); p > 100*100 {
+		t.Errorf("comfort noise has power %.0f", p)
+	}
+}
@@ -0,0 +1,144 @@
+package transcode
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/gsm"
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// GSMEncoder encodes 8Khz mono PCM frames from the pool to GSM payloads of
+// one or more 20ms frames.
+type GSMEncoder struct {
+	ptime   int
+	pcmPool *pool.PCM
+	encoder *gsm.FrameEncoder
+}
+
+// NewGSMEncoder creates a GSMEncoder. ptime must be a multiple of 20.
+func NewGSMEncoder(ptime int) (*GSMEncoder, error) {
+	if ptime <= 0 || ptime%20 != 0 {
+		return nil, ErrPtime
+	}
+	p, err := pool.Of(gsm.SampleRate, 1, ptime)
+	if err != nil {
+		return nil, err
+	}
+	return &GSMEncoder{
+		ptime:   ptime,
+		pcmPool: p.ForPtime(ptime),
+		encoder: gsm.NewFrameEncoder(),
+	}, nil
+}
+
+func (e *GSMEncoder) SampleRate() int {
+	return gsm.SampleRate
+}
+
+func (e *GSMEncoder) Channels() int {
+	return 1
+}
+
+func (e *GSMEncoder) FrameSize() int {
+	return e.pcmPool.FrameSize
+}
+
+func (e *GSMEncoder) Ptime() time.Duration {
+	return time.Duration(e.ptime) * time.Millisecond
+}
+
+// PayloadType returns the static RTP payload type.
+func (e *GSMEncoder) PayloadType() uint8 {
+	return gsm.PayloadType
+}
+
+func (e *GSMEncoder) Alloc() []int16 {
+	return e.pcmPool.Get()
+}
+
+func (e *GSMEncoder) Release(b []int16) {
+	e.pcmPool.Release(b)
+}
+
+// Encode encodes frame into payload and returns the payload length, 33
+// bytes per 20ms, and the RTP timestamp ticks it covers, len(frame).
+func (e *GSMEncoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	n, err = e.encoder.Encode(frame, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, len(frame), nil
+}
+
+func (e *GSMEncoder) Close() error {
+	return nil
+}
+
+var _ pcm.Reader = (*GSMDecoder)(nil)
+
+// GSMDecoder decodes GSM payloads into 8Khz mono PCM frames.
+//
+// Payloads don't have to match the frame size. Lost payloads reported with
+// Missing are concealed by repeating the last frame, muted gradually.
+type GSMDecoder struct {
+	*framer
+	decoder *gsm.FrameDecoder
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewGSMDecoder creates a GSMDecoder buffering up to maxFrames.
+func NewGSMDecoder(ptime, maxFrames int) (*GSMDecoder, error) {
+	f, err := newFramer(gsm.SampleRate, 1, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	return &GSMDecoder{
+		framer:  f,
+		decoder: gsm.NewFrameDecoder(),
+	}, nil
+}
+
+// Write decodes the next payload.
+func (d *GSMDecoder) Write(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(len(payload) / gsm.EncodedFrameSize * gsm.FrameSize)
+	if _, err := d.decoder.Decode(payload, buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+// Missing conceals samples that were lost. samples is rounded up to whole
+// 20ms frames.
+func (d *GSMDecoder) Missing(samples int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	frames := (samples + gsm.FrameSize - 1) / gsm.FrameSize
+	buf := d.scratch(frames * gsm.FrameSize)
+	if err := d.decoder.Conceal(buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+func (d *GSMDecoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	d.closed = true
+	return d.close()
+}
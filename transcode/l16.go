@@ -0,0 +1,140 @@
+package transcode
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/l16"
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// L16Encoder encodes PCM frames from the pool to L16 payloads in network byte
+// order. Frames are limited to the sample rates and channel counts of the
+// pool, so 44100Hz, which has the static payload types, isn't supported.
+type L16Encoder struct {
+	sampleRate int
+	channels   int
+	ptime      int
+	pcmPool    *pool.PCM
+}
+
+// NewL16Encoder creates an L16Encoder.
+func NewL16Encoder(sampleRate, channels, ptime int) (*L16Encoder, error) {
+	p, err := pool.Of(sampleRate, channels, ptime)
+	if err != nil {
+		return nil, err
+	}
+	return &L16Encoder{
+		sampleRate: sampleRate,
+		channels:   channels,
+		ptime:      ptime,
+		pcmPool:    p.ForPtime(ptime),
+	}, nil
+}
+
+func (e *L16Encoder) SampleRate() int {
+	return e.sampleRate
+}
+
+func (e *L16Encoder) Channels() int {
+	return e.channels
+}
+
+func (e *L16Encoder) FrameSize() int {
+	return e.pcmPool.FrameSize
+}
+
+func (e *L16Encoder) Ptime() time.Duration {
+	return time.Duration(e.ptime) * time.Millisecond
+}
+
+func (e *L16Encoder) Alloc() []int16 {
+	return e.pcmPool.Get()
+}
+
+func (e *L16Encoder) Release(b []int16) {
+	e.pcmPool.Release(b)
+}
+
+// Encode encodes frame into payload and returns the payload length and the
+// RTP timestamp ticks it covers, len(frame) / Channels. Payloads of long
+// frames may not fit the MTU, use Packetizer.WriteSplit to send them.
+func (e *L16Encoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	n, err = l16.Encode(frame, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, len(frame) / e.channels, nil
+}
+
+func (e *L16Encoder) Close() error {
+	return nil
+}
+
+var _ pcm.Reader = (*L16Decoder)(nil)
+
+// L16Decoder decodes L16 payloads into PCM frames.
+//
+// Payloads don't have to match the frame size, so packets split to fit the
+// MTU can be written as they arrive. Lost payloads are replaced with silence.
+type L16Decoder struct {
+	*framer
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewL16Decoder creates an L16Decoder buffering up to maxFrames.
+func NewL16Decoder(sampleRate, channels, ptime, maxFrames int) (*L16Decoder, error) {
+	f, err := newFramer(sampleRate, channels, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	return &L16Decoder{
+		framer: f,
+	}, nil
+}
+
+// Write decodes the next payload.
+func (d *L16Decoder) Write(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	samples, err := l16.Samples(payload, d.channels)
+	if err != nil {
+		return err
+	}
+	buf := d.scratch(samples * d.channels)
+	if _, err := l16.Decode(payload, buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+// Missing fills the samples per channel that were lost with silence.
+func (d *L16Decoder) Missing(samples int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(samples * d.channels)
+	for i := range buf {
+		buf[i] = 0
+	}
+	return d.push(buf)
+}
+
+func (d *L16Decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	d.closed = true
+	return d.close()
+}
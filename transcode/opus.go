@@ -1,8 +1,218 @@
 package transcode
 
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/opus"
+	"github.com/pidato/audio/pcm"
+	"github.com/pidato/audio/pool"
+)
+
+// Samples per channel of the longest Opus packet, 120ms at 48Khz.
+const opusMaxSamples = 5760
+
 type OpusFrame struct {
 	Seq     uint64
 	Pos     uint64 // Granule position as number of samples at 48Khz sample rate.
 	Samples uint16 // Number of 48Khz samples.
 	Data    []byte // Opus encoded data.
 }
+
+// Opus always uses a 48Khz RTP clock whatever the sample rate of the frames.
+const opusClockRate = 48000
+
+// OpusEncoder encodes PCM frames from the pool to Opus payloads, one per
+// frame. Unlike Encoder it doesn't buffer, so it suits an RTP sender.
+type OpusEncoder struct {
+	sampleRate int
+	channels   int
+	ptime      int
+	pcmPool    *pool.PCM
+	encoder    *opus.Encoder
+}
+
+// NewOpusEncoder creates an OpusEncoder for frames of the supplied sample
+// rate, channel count and ptime.
+func NewOpusEncoder(sampleRate, channels, ptime int) (*OpusEncoder, error) {
+	p, err := pool.Of(sampleRate, channels, ptime)
+	if err != nil {
+		return nil, err
+	}
+	if pool.OpusFrameSizeOf(ptime) == 0 {
+		return nil, pool.ErrUnsupported
+	}
+	enc, err := opus.NewEncoder(sampleRate, channels, opus.AppVoIP)
+	if err != nil {
+		return nil, err
+	}
+	return &OpusEncoder{
+		sampleRate: sampleRate,
+		channels:   channels,
+		ptime:      ptime,
+		pcmPool:    p.ForPtime(ptime),
+		encoder:    enc,
+	}, nil
+}
+
+func (e *OpusEncoder) SampleRate() int {
+	return e.sampleRate
+}
+
+func (e *OpusEncoder) Channels() int {
+	return e.channels
+}
+
+func (e *OpusEncoder) FrameSize() int {
+	return e.pcmPool.FrameSize
+}
+
+func (e *OpusEncoder) Ptime() time.Duration {
+	return time.Duration(e.ptime) * time.Millisecond
+}
+
+func (e *OpusEncoder) Alloc() []int16 {
+	return e.pcmPool.Get()
+}
+
+func (e *OpusEncoder) Release(b []int16) {
+	e.pcmPool.Release(b)
+}
+
+// Opus returns the encoder to control the bitrate, DTX etc.
+func (e *OpusEncoder) Opus() *opus.Encoder {
+	return e.encoder
+}
+
+// Encode encodes frame into payload and returns the payload length and the
+// RTP timestamp ticks it covers at 48Khz. With DTX, payloads of 2 bytes or
+// less needn't be sent.
+func (e *OpusEncoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	n, err = e.encoder.Encode(frame, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, len(frame) / e.channels * (opusClockRate / e.sampleRate), nil
+}
+
+// SetFEC enables inband FEC, which only takes effect with packet loss.
+func (e *OpusEncoder) SetFEC(fec bool) error {
+	return e.encoder.SetInBandFEC(fec)
+}
+
+// SetPacketLoss sets the expected packet loss in percent.
+func (e *OpusEncoder) SetPacketLoss(percent int) error {
+	return e.encoder.SetPacketLossPerc(percent)
+}
+
+func (e *OpusEncoder) Close() error {
+	return nil
+}
+
+var _ pcm.Reader = (*OpusDecoder)(nil)
+
+// OpusDecoder decodes Opus payloads into PCM frames. Unlike Decoder it
+// implements pcm.Reader, conceals lost payloads and accepts any packet
+// duration.
+type OpusDecoder struct {
+	*framer
+	decoder *opus.Decoder
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewOpusDecoder creates an OpusDecoder producing frames of the supplied
+// sample rate, channel count and ptime, buffering up to maxFrames.
+func NewOpusDecoder(sampleRate, channels, ptime, maxFrames int) (*OpusDecoder, error) {
+	dec, err := opus.NewDecoder(sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFramer(sampleRate, channels, ptime, maxFrames)
+	if err != nil {
+		return nil, err
+	}
+	return &OpusDecoder{
+		framer:  f,
+		decoder: dec,
+	}, nil
+}
+
+// Write decodes the next payload.
+func (d *OpusDecoder) Write(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := d.scratch(opusMaxSamples * d.channels)
+	n, err := d.decoder.Decode(payload, buf)
+	if err != nil {
+		return err
+	}
+	return d.push(buf[:n*d.channels])
+}
+
+// Missing conceals the RTP timestamp ticks that were lost. ticks is rounded
+// up to whole 2.5ms.
+func (d *OpusDecoder) Missing(ticks int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	samples := d.samplesOf(ticks)
+	for samples > 0 {
+		n := samples
+		if max := d.sampleRate / 1000 * 120; n > max {
+			n = max
+		}
+		buf := d.scratch(n * d.channels)
+		if err := d.decoder.DecodePLC(buf); err != nil {
+			return err
+		}
+		if err := d.push(buf); err != nil {
+			return err
+		}
+		samples -= n
+	}
+	return nil
+}
+
+// WriteFEC recovers the ticks lost before payload from its inband FEC data.
+// Call Write with payload afterwards.
+func (d *OpusDecoder) WriteFEC(payload []byte, ticks int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	samples := d.samplesOf(ticks)
+	if samples > d.sampleRate/1000*120 {
+		return ErrCorrupted
+	}
+	buf := d.scratch(samples * d.channels)
+	if err := d.decoder.DecodeFEC(payload, buf); err != nil {
+		return err
+	}
+	return d.push(buf)
+}
+
+// samplesOf converts 48Khz ticks to samples per channel rounded up to 2.5ms.
+func (d *OpusDecoder) samplesOf(ticks int) int {
+	step := d.sampleRate / 400
+	samples := ticks / (opusClockRate / d.sampleRate)
+	return (samples + step - 1) / step * step
+}
+
+func (d *OpusDecoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	d.closed = true
+	return d.close()
+}
@@ -0,0 +1,68 @@
+package transcode
+
+import (
+	"testing"
+)
+
+func TestOpusDecoder_WriteFEC(t *testing.T) {
+	const sampleRate, lost = 16000, 20
+	enc, err := NewOpusEncoder(sampleRate, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.SetFEC(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.SetPacketLoss(30); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Opus().SetBitrate(24000); err != nil {
+		t.Fatal(err)
+	}
+	var payloads [][]byte
+	for _, frame := range speech(t, enc.FrameSize())[:lost+10] {
+		payload := make([]byte, 1500)
+		n, ticks, err := enc.Encode(frame, payload)
+		if err != nil || ticks != 960 {
+			t.Fatal(ticks, err)
+		}
+		payloads = append(payloads, payload[:n])
+	}
+
+	// decode returns the decoded frames, with the lost packet recovered from
+	// the FEC of the next one when recover is true.
+	decode := func(recover bool) [][]int16 {
+		dec, err := NewOpusDecoder(sampleRate, 1, 20, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dec.Close()
+		var frames [][]int16
+		for i, payload := range payloads {
+			if recover && i == lost {
+				continue
+			}
+			if recover && i == lost+1 {
+				if err := dec.WriteFEC(payload, 960); err != nil {
+					t.Fatal(err)
+				}
+				frame, _ := dec.ReadFrame()
+				frames = append(frames, append([]int16(nil), frame...))
+			}
+			if err := dec.Write(payload); err != nil {
+				t.Fatal(err)
+			}
+			frame, _ := dec.ReadFrame()
+			frames = append(frames, append([]int16(nil), frame...))
+		}
+		return frames
+	}
+	want, got := decode(false), decode(true)
+	if len(got) != len(want) {
+		t.Fatal(len(got), len(want))
+	}
+	// Concealment correlates poorly with the lost speech.
+	if c := correlation(got[lost], want[lost]); c < 0.8 {
+		t.Fatalf("recovered frame correlates %.2f with the sent one", c)
+	}
+}
@@ -1,19 +1,170 @@
 package transcode
 
-import "github.com/pion/rtp"
+import (
+	"errors"
 
-type Depacketizer struct {
-}
+	"github.com/pidato/audio/cn"
+	"github.com/pion/rtp"
+)
+
+var (
+	ErrMTU         = errors.New("payload can't be split to fit the MTU")
+	ErrPayloadType = errors.New("unexpected payload type")
+	ErrLate        = errors.New("packet is late")
+)
+
+// Size of the RTP header without CSRCs or extensions.
+const rtpHeaderSize = 12
 
+// Packetizer wraps encoded frames in RTP packets.
+//
+// During silence the encoder may send CN payloads (RFC 3389) with WriteCN or
+// nothing at all with Skip. The first packet of audio, and the first after
+// either, is marked as the start of a talkspurt.
 type Packetizer struct {
-	MTU         int
+	// Largest packet WriteSplit returns, 0 for no limit.
+	MTU int
+
 	PayloadType uint8
 	SSRC        uint32
 	Sequencer   rtp.Sequencer
 	Timestamp   uint32
 	ClockRate   uint32
+
+	// Payload type of CN packets. 0 uses the static type 13, which is only
+	// valid at 8Khz.
+	CNPayloadType uint8
+
+	// A talkspurt has started, the zero value marks the first packet.
+	talking bool
 }
 
+// Write returns a packet carrying payload and advances the timestamp by
+// samples.
 func (p *Packetizer) Write(payload []byte, samples int) *rtp.Packet {
-	return nil
+	packet := p.packet(p.PayloadType, payload)
+	packet.Marker = !p.talking
+	p.talking = true
+	p.Timestamp += uint32(samples)
+	return packet
+}
+
+// WriteSplit returns the packets carrying payload, split evenly into as few
+// packets as fit the MTU. Split packets hold whole samples, so a payload that
+// doesn't fit must have the same number of bytes for every sample.
+func (p *Packetizer) WriteSplit(payload []byte, samples int) ([]*rtp.Packet, error) {
+	max := p.MTU - rtpHeaderSize
+	if p.MTU == 0 || len(payload) <= max {
+		return []*rtp.Packet{p.Write(payload, samples)}, nil
+	}
+	if samples <= 0 || len(payload)%samples != 0 {
+		return nil, ErrMTU
+	}
+	size := len(payload) / samples
+	if max < size {
+		return nil, ErrMTU
+	}
+	count := (samples + max/size - 1) / (max / size)
+	perPacket := (samples + count - 1) / count
+	packets := make([]*rtp.Packet, 0, count)
+	for samples > 0 {
+		n := perPacket
+		if n > samples {
+			n = samples
+		}
+		packets = append(packets, p.Write(payload[:n*size], n))
+		payload = payload[n*size:]
+		samples -= n
+	}
+	return packets, nil
+}
+
+// WriteCN returns a CN packet carrying payload and advances the timestamp by
+// samples.
+func (p *Packetizer) WriteCN(payload []byte, samples int) *rtp.Packet {
+	payloadType := p.CNPayloadType
+	if payloadType == 0 {
+		payloadType = cn.PayloadType
+	}
+	packet := p.packet(payloadType, payload)
+	p.talking = false
+	p.Timestamp += uint32(samples)
+	return packet
+}
+
+// Skip advances the timestamp over samples that aren't transmitted.
+func (p *Packetizer) Skip(samples int) {
+	p.talking = false
+	p.Timestamp += uint32(samples)
+}
+
+func (p *Packetizer) packet(payloadType uint8, payload []byte) *rtp.Packet {
+	if p.Sequencer == nil {
+		p.Sequencer = rtp.NewRandomSequencer()
+	}
+	return &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    payloadType,
+			SequenceNumber: p.Sequencer.NextSequenceNumber(),
+			Timestamp:      p.Timestamp,
+			SSRC:           p.SSRC,
+		},
+		Payload: payload,
+	}
+}
+
+// Depacketizer checks received RTP packets and tracks their timestamps, so
+// the ticks that were lost can be reported to the decoder's Missing.
+//
+// Gaps after a CN packet, or before a packet marked as the start of a
+// talkspurt, are silence and not reported.
+type Depacketizer struct {
+	PayloadType uint8
+
+	// Payload type of CN packets. 0 uses the static type 13.
+	CNPayloadType uint8
+
+	ssrc      uint32
+	timestamp uint32 // Timestamp of the next packet.
+	started   bool
+	silent    bool
+}
+
+// Unmarshal parses a packet of the expected payload type or CN.
+func (d *Depacketizer) Unmarshal(buf []byte) (*rtp.Packet, error) {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	if packet.PayloadType != d.PayloadType && !d.IsCN(packet) {
+		return nil, ErrPayloadType
+	}
+	return packet, nil
+}
+
+// IsCN reports whether packet carries CN.
+func (d *Depacketizer) IsCN(packet *rtp.Packet) bool {
+	payloadType := d.CNPayloadType
+	if payloadType == 0 {
+		payloadType = cn.PayloadType
+	}
+	return packet.PayloadType == payloadType
+}
+
+// Read accepts packet, which covers samples timestamp ticks, and returns the
+// ticks lost before it. A packet older than the ones already read returns
+// ErrLate and should be dropped. A new SSRC starts over.
+func (d *Depacketizer) Read(packet *rtp.Packet, samples int) (missing int, err error) {
+	if !d.started || packet.SSRC != d.ssrc {
+		d.started = true
+		d.ssrc = packet.SSRC
+	} else if gap := int32(packet.Timestamp - d.timestamp); gap < 0 {
+		return 0, ErrLate
+	} else if !d.silent && !packet.Marker {
+		missing = int(gap)
+	}
+	d.silent = d.IsCN(packet)
+	d.timestamp = packet.Timestamp + uint32(samples)
+	return missing, nil
 }
@@ -0,0 +1,128 @@
+package transcode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pidato/audio/cn"
+	"github.com/pion/rtp"
+)
+
+func TestPacketizer_WriteSplit(t *testing.T) {
+	p := &Packetizer{
+		MTU:         500,
+		PayloadType: 96,
+		Sequencer:   rtp.NewFixedSequencer(65535),
+		Timestamp:   1000,
+	}
+	// 20ms of L16 at 16Khz stereo, 4 bytes per sample. 122 samples fit, so
+	// it is split into 3 packets of 107, 107 and 106.
+	payload := make([]byte, 320*4)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	packets, err := p.WriteSplit(payload, 320)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 3 {
+		t.Fatal(len(packets))
+	}
+	var joined []byte
+	for i, packet := range packets {
+		want := []int{107, 107, 106}[i]
+		if len(packet.Payload) != want*4 || packet.Timestamp != uint32(1000+107*i) ||
+			packet.SequenceNumber != uint16(65535+i) || packet.PayloadType != 96 {
+			t.Fatal(i, len(packet.Payload), packet.Header)
+		}
+		if size := packet.MarshalSize(); size > p.MTU {
+			t.Fatal(i, size)
+		}
+		joined = append(joined, packet.Payload...)
+	}
+	for i := range joined {
+		if joined[i] != byte(i) {
+			t.Fatal(i)
+		}
+	}
+	if p.Timestamp != 1320 {
+		t.Fatal(p.Timestamp)
+	}
+
+	// A payload that fits isn't split.
+	if packets, err = p.WriteSplit(payload[:400], 100); err != nil || len(packets) != 1 {
+		t.Fatal(len(packets), err)
+	}
+	// Payloads with a varying number of bytes per sample can't be split.
+	if _, err = p.WriteSplit(make([]byte, 1000), 960); err != ErrMTU {
+		t.Fatal(err)
+	}
+	if _, err = p.WriteSplit(make([]byte, 1000), 0); err != ErrMTU {
+		t.Fatal(err)
+	}
+	// Nor can samples larger than the MTU.
+	p.MTU = 20
+	if _, err = p.WriteSplit(make([]byte, 100), 10); err != ErrMTU {
+		t.Fatal(err)
+	}
+}
+
+func TestPacketizer_Marker(t *testing.T) {
+	p := &Packetizer{PayloadType: 0}
+	var markers []bool
+	write := func() {
+		markers = append(markers, p.Write(nil, 160).Marker)
+	}
+	write()
+	write()
+	markers = append(markers, p.WriteCN(nil, 160).Marker)
+	write()
+	p.Skip(160)
+	write()
+	write()
+	// The first packet, and the first after CN or a skip, start a talkspurt.
+	want := []bool{true, false, false, true, true, false}
+	if !reflect.DeepEqual(markers, want) {
+		t.Fatal(markers)
+	}
+	if p.Timestamp != 7*160 {
+		t.Fatal(p.Timestamp)
+	}
+}
+
+func TestDepacketizer_Read(t *testing.T) {
+	d := &Depacketizer{PayloadType: 0}
+	packet := func(ssrc, timestamp uint32, payloadType uint8, marker bool) *rtp.Packet {
+		return &rtp.Packet{Header: rtp.Header{
+			SSRC:        ssrc,
+			Timestamp:   timestamp,
+			PayloadType: payloadType,
+			Marker:      marker,
+		}}
+	}
+	for i, test := range []struct {
+		packet  *rtp.Packet
+		missing int
+		err     error
+	}{
+		{packet(1, 4294967136, 0, false), 0, nil},
+		{packet(1, 0, 0, false), 0, nil},
+		// Packets 160 and 320 were lost.
+		{packet(1, 480, 0, false), 320, nil},
+		{packet(1, 320, 0, false), 0, ErrLate},
+		// The gap before a talkspurt is silence.
+		{packet(1, 1600, 0, true), 0, nil},
+		// So is the gap after CN.
+		{packet(1, 1760, cn.PayloadType, false), 0, nil},
+		{packet(1, 3200, 0, false), 0, nil},
+		{packet(1, 3520, 0, false), 160, nil},
+		// A new SSRC starts over.
+		{packet(2, 100, 0, false), 0, nil},
+		{packet(2, 420, 0, false), 160, nil},
+	} {
+		missing, err := d.Read(test.packet, 160)
+		if missing != test.missing || err != test.err {
+			t.Fatal(i, missing, err)
+		}
+	}
+}
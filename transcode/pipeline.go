@@ -0,0 +1,273 @@
+package transcode
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	ErrRunning = errors.New("pipeline is already running")
+)
+
+// Endpoint describes one side of a Pipeline: the codec and the frames it is
+// decoded to or encoded from.
+type Endpoint struct {
+	Codec  Codec
+	Params Params
+}
+
+// Processor processes a PCM frame in place, such as gain or echo
+// cancellation. It runs on frames of the destination format.
+type Processor interface {
+	Process(frame []int16) error
+}
+
+// ProcessorFunc adapts a function to a Processor.
+type ProcessorFunc func(frame []int16) error
+
+func (f ProcessorFunc) Process(frame []int16) error {
+	return f(frame)
+}
+
+// SinkFunc receives the encoded payloads of a Pipeline and the RTP timestamp
+// ticks each covers, typically for a Packetizer. An empty payload is not
+// sent but still advances the RTP timestamp.
+type SinkFunc func(payload []byte, ticks int) error
+
+// Pipeline transcodes payloads of one codec to another: decoder, channel
+// mixing, resampling, processors and encoder. Frames of the source are
+// regrouped to the ptime of the destination.
+//
+// Payloads are written to the decoder with Write and Missing, typically from
+// a jitter buffer. Run does the rest on a single goroutine, so a call needs a
+// Pipeline per direction.
+type Pipeline struct {
+	decoder    FrameDecoder
+	encoder    FrameEncoder
+	clockRate  int // RTP clock rate of the destination.
+	resampler  *resampler
+	processors []Processor
+	sink       SinkFunc
+
+	mixed   []int16
+	payload []byte
+
+	nextFrame []int16 // Partially filled destination frame.
+	nextLen   int
+
+	running bool
+	closed  bool
+	mu      sync.Mutex
+}
+
+// NewPipeline creates a Pipeline with the codecs of registry, DefaultRegistry
+// when nil.
+func NewPipeline(registry *Registry, src, dst Endpoint, sink SinkFunc, processors ...Processor) (*Pipeline, error) {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	decoder, err := registry.NewDecoder(src.Codec, src.Params)
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := registry.NewEncoder(dst.Codec, dst.Params)
+	if err != nil {
+		_ = decoder.Close()
+		return nil, err
+	}
+	p := &Pipeline{
+		decoder:    decoder,
+		encoder:    encoder,
+		clockRate:  dst.Codec.ClockRate,
+		processors: processors,
+		sink:       sink,
+		// Enough for L16, the largest payload per sample.
+		payload: make([]byte, encoder.FrameSize()*encoder.Channels()*2+1500),
+	}
+	if decoder.SampleRate() != encoder.SampleRate() {
+		p.resampler = newResampler(decoder.SampleRate(), encoder.SampleRate(), encoder.Channels())
+	}
+	return p, nil
+}
+
+// Decoder returns the decoder of the source, for FEC.
+func (p *Pipeline) Decoder() FrameDecoder {
+	return p.decoder
+}
+
+// Encoder returns the encoder of the destination.
+func (p *Pipeline) Encoder() FrameEncoder {
+	return p.encoder
+}
+
+// Write decodes the next payload of the source.
+func (p *Pipeline) Write(payload []byte) error {
+	return p.decoder.Write(payload)
+}
+
+// Missing conceals RTP timestamp ticks of the source that were lost.
+func (p *Pipeline) Missing(ticks int) error {
+	return p.decoder.Missing(ticks)
+}
+
+// WriteFinal signals there are no more payloads. Run encodes what is left
+// and returns.
+func (p *Pipeline) WriteFinal() error {
+	return p.decoder.WriteFinal()
+}
+
+// Run transcodes decoded frames until WriteFinal or Close.
+func (p *Pipeline) Run() (err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return os.ErrClosed
+	}
+	if p.running {
+		p.mu.Unlock()
+		return ErrRunning
+	}
+	p.running = true
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.running = false
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			p.release()
+		}
+	}()
+
+	for {
+		frame, err := p.decoder.ReadFrame()
+		switch err {
+		case nil:
+		case io.EOF:
+			return p.flush()
+		case io.ErrClosedPipe:
+			return nil
+		default:
+			return err
+		}
+		err = p.process(frame)
+		p.decoder.Release(frame)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// process mixes and resamples a decoded frame into destination frames.
+func (p *Pipeline) process(frame []int16) error {
+	buf := p.mix(frame)
+	if p.resampler != nil {
+		buf = p.resampler.resample(buf)
+	}
+	for len(buf) > 0 {
+		if p.nextFrame == nil {
+			p.nextFrame = p.encoder.Alloc()
+			p.nextLen = 0
+		}
+		copied := copy(p.nextFrame[p.nextLen:], buf)
+		p.nextLen += copied
+		buf = buf[copied:]
+		if p.nextLen < len(p.nextFrame) {
+			break
+		}
+		if err := p.encode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mix converts frame to the channel count of the destination.
+func (p *Pipeline) mix(frame []int16) []int16 {
+	from, to := p.decoder.Channels(), p.encoder.Channels()
+	if from == to {
+		return frame
+	}
+	samples := len(frame) / from
+	if cap(p.mixed) < samples*to {
+		p.mixed = make([]int16, samples*to)
+	}
+	mixed := p.mixed[:samples*to]
+	for i := 0; i < samples; i++ {
+		if from == 1 {
+			mixed[2*i], mixed[2*i+1] = frame[i], frame[i]
+		} else {
+			mixed[i] = int16((int32(frame[2*i]) + int32(frame[2*i+1])) / 2)
+		}
+	}
+	return mixed
+}
+
+// encode runs the processors on the next frame, encodes it and releases it.
+func (p *Pipeline) encode() error {
+	frame := p.nextFrame
+	p.nextFrame = nil
+	defer p.encoder.Release(frame)
+
+	for _, processor := range p.processors {
+		if err := processor.Process(frame); err != nil {
+			return err
+		}
+	}
+	buf := frame
+	for len(buf) > 0 {
+		n, ticks, err := p.encoder.Encode(buf, p.payload)
+		if err != nil {
+			return err
+		}
+		if err = p.sink(p.payload[:n], ticks); err != nil {
+			return err
+		}
+		samples := ticks * p.encoder.SampleRate() / p.clockRate * p.encoder.Channels()
+		if samples <= 0 {
+			return ErrWrongFrameSize
+		}
+		buf = buf[samples:]
+	}
+	return nil
+}
+
+// flush pads the last frame with silence and encodes it.
+func (p *Pipeline) flush() error {
+	if p.nextFrame == nil {
+		return nil
+	}
+	for i := p.nextLen; i < len(p.nextFrame); i++ {
+		p.nextFrame[i] = 0
+	}
+	return p.encode()
+}
+
+// Close stops Run and releases the codecs.
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return os.ErrClosed
+	}
+	p.closed = true
+	running := p.running
+	p.mu.Unlock()
+
+	err := p.decoder.Close()
+	if !running {
+		p.release()
+	}
+	return err
+}
+
+func (p *Pipeline) release() {
+	if p.nextFrame != nil {
+		p.encoder.Release(p.nextFrame)
+		p.nextFrame = nil
+	}
+	_ = p.encoder.Close()
+}
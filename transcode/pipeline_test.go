@@ -0,0 +1,177 @@
+package transcode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pidato/audio/g711"
+	"github.com/pidato/audio/g729"
+	"github.com/pidato/audio/l16"
+)
+
+type sent struct {
+	payload []byte
+	ticks   int
+}
+
+// transcodeAll runs payloads through a Pipeline and returns what it sent.
+func transcodeAll(t *testing.T, src, dst Endpoint, payloads [][]byte) []sent {
+	var out []sent
+	sink := func(payload []byte, ticks int) error {
+		out = append(out, sent{append([]byte(nil), payload...), ticks})
+		return nil
+	}
+	// Decode all payloads before Run reads any.
+	src.Params.MaxFrames = 100
+	p, err := NewPipeline(nil, src, dst, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for _, payload := range payloads {
+		if err := p.Write(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.WriteFinal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestPipeline_OpusToPCMU(t *testing.T) {
+	opus := Endpoint{Codec{"opus", 48000, 2}, Params{SampleRate: 48000, Channels: 1}}
+	pcmu := Endpoint{Codec: Codec{"PCMU", 8000, 1}, Params: Params{Ptime: 40}}
+	// 180ms of 20ms frames, regrouped to four 40ms payloads and a padded one.
+	payloads := encodeAll(t, opus.Codec, opus.Params, tone(1000, 48000, 1, 8640))
+	out := transcodeAll(t, opus, pcmu, payloads)
+	if len(out) != 5 {
+		t.Fatal(len(out))
+	}
+	var decoded []int16
+	for _, s := range out {
+		if len(s.payload) != 320 || s.ticks != 320 {
+			t.Fatal(len(s.payload), s.ticks)
+		}
+		for _, b := range s.payload {
+			decoded = append(decoded, g711.DecodeUlawFrame(b))
+		}
+	}
+	if snr := toneSNR(decoded[320:1280], 0, 1, 1000, 8000); snr < 20 {
+		t.Errorf("SNR %.1fdB", snr)
+	}
+	// Silence pads the last payload. Ulaw codes 0 as 0xff.
+	if last := out[4].payload; !bytes.Equal(last[len(last)-100:], bytes.Repeat([]byte{0xff}, 100)) {
+		t.Errorf("not padded: % x", last[len(last)-100:])
+	}
+}
+
+func TestPipeline_G722ToL16Stereo(t *testing.T) {
+	g722 := Endpoint{Codec: Codec{"G722", 8000, 1}}
+	stereo := Endpoint{Codec: Codec{"L16", 16000, 2}}
+	payloads := encodeAll(t, g722.Codec, g722.Params, tone(1000, 16000, 1, 3200))
+	out := transcodeAll(t, g722, stereo, payloads)
+	if len(out) != 10 {
+		t.Fatal(len(out))
+	}
+	var decoded []int16
+	for _, s := range out {
+		if len(s.payload) != l16.PayloadSize(320, 2) || s.ticks != 320 {
+			t.Fatal(len(s.payload), s.ticks)
+		}
+		frame := make([]int16, 640)
+		if _, err := l16.Decode(s.payload, frame); err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, frame...)
+	}
+	for i := 0; i < len(decoded); i += 2 {
+		if decoded[i] != decoded[i+1] {
+			t.Fatalf("sample %d: left %d, right %d", i/2, decoded[i], decoded[i+1])
+		}
+	}
+	if snr := toneSNR(decoded[640:], 0, 2, 1000, 16000); snr < 20 {
+		t.Errorf("SNR %.1fdB", snr)
+	}
+}
+
+func TestPipeline_L16StereoToG722(t *testing.T) {
+	stereo := Endpoint{Codec: Codec{"L16", 16000, 2}}
+	g722 := Endpoint{Codec: Codec{"G722", 8000, 1}}
+	// The right channel is silent, so the mix is the left at half level.
+	samples := tone(1000, 16000, 2, 3200)
+	for i := 1; i < len(samples); i += 2 {
+		samples[i] = 0
+	}
+	payloads := encodeAll(t, stereo.Codec, stereo.Params, samples)
+	out := transcodeAll(t, stereo, g722, payloads)
+	if len(out) != 10 {
+		t.Fatal(len(out))
+	}
+	for _, s := range out {
+		// 320 samples at 16Khz are 160 ticks of the 8Khz G722 clock.
+		if len(s.payload) != 160 || s.ticks != 160 {
+			t.Fatal(len(s.payload), s.ticks)
+		}
+	}
+	dec, err := DefaultRegistry.NewDecoder(g722.Codec, Params{MaxFrames: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	var decoded []int16
+	for _, s := range out {
+		if err := dec.Write(s.payload); err != nil {
+			t.Fatal(err)
+		}
+		frame, err := dec.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, frame...)
+		dec.Release(frame)
+	}
+	if p := power(decoded[640:]); p < 3000*3000 || p > 4000*4000 {
+		t.Errorf("mixed power %.0f, want about %d", p, 5000*5000/2)
+	}
+}
+
+func TestPipeline_G729VAD(t *testing.T) {
+	l := Endpoint{Codec: Codec{"L16", 8000, 1}}
+	dst := Endpoint{Codec: Codec{"G729", 8000, 1}, Params: Params{VAD: true}}
+	// Speech then silence, which VAD codes as SID and untransmitted frames
+	// that may end a payload early.
+	samples := make([]int16, 8000)
+	copy(samples, tone(500, 8000, 1, 2000))
+	out := transcodeAll(t, l, dst, encodeAll(t, l.Codec, l.Params, samples))
+
+	ticks, sid, empty := 0, 0, 0
+	for _, s := range out {
+		frames, err := g729.PayloadFrames(s.payload)
+		if err != nil {
+			t.Fatal(len(s.payload), err)
+		}
+		switch {
+		case len(s.payload) == 0:
+			empty++
+			if s.ticks%g729.FrameSize != 0 || s.ticks == 0 {
+				t.Fatal(s.ticks)
+			}
+		case s.ticks != frames*g729.FrameSize:
+			t.Fatal(len(s.payload), s.ticks)
+		}
+		if len(s.payload)%g729.VoiceFrameSize == g729.SIDFrameSize {
+			sid++
+		}
+		ticks += s.ticks
+	}
+	if ticks != len(samples) {
+		t.Fatal(ticks, len(samples))
+	}
+	if sid == 0 || empty == 0 || len(out) <= len(samples)/160 {
+		t.Fatal(len(out), sid, empty)
+	}
+}
@@ -0,0 +1,48 @@
+package transcode
+
+import (
+	"github.com/pidato/audio/red"
+)
+
+// REDEncoder adds the payloads of previous packets to the payloads of an
+// encoder (RFC 2198), so receivers can recover from more loss than Opus
+// inband FEC covers. Payloads are sent with the RED payload type, and
+// payloadType is the one negotiated for the wrapped encoder.
+type REDEncoder struct {
+	FrameEncoder
+	red       *red.Encoder
+	timestamp uint32
+	scratch   []byte
+}
+
+// NewREDEncoder wraps encoder to add up to distance previous payloads to
+// each payload.
+func NewREDEncoder(encoder FrameEncoder, payloadType uint8, distance int) (*REDEncoder, error) {
+	if payloadType > 127 {
+		return nil, red.ErrPayloadType
+	}
+	return &REDEncoder{
+		FrameEncoder: encoder,
+		red:          red.NewEncoder(payloadType, distance),
+	}, nil
+}
+
+// Encode encodes frame and writes the RED payload to payload. Redundant
+// payloads that don't fit in payload are left out.
+func (e *REDEncoder) Encode(frame []int16, payload []byte) (n, ticks int, err error) {
+	if len(payload) < 1 {
+		return 0, 0, red.ErrShort
+	}
+	if cap(e.scratch) < len(payload) {
+		e.scratch = make([]byte, len(payload))
+	}
+	// Leave room for the header of the primary.
+	n, ticks, err = e.FrameEncoder.Encode(frame, e.scratch[:len(payload)-1])
+	if err != nil || n == 0 {
+		e.timestamp += uint32(ticks)
+		return 0, ticks, err
+	}
+	n, err = e.red.Encode(e.scratch[:n], e.timestamp, payload)
+	e.timestamp += uint32(ticks)
+	return n, ticks, err
+}
@@ -0,0 +1,358 @@
+package transcode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pidato/audio/g711"
+	"github.com/pidato/audio/g722"
+	"github.com/pidato/audio/g729"
+	"github.com/pidato/audio/gsm"
+	"github.com/pidato/audio/l16"
+	"github.com/pidato/audio/pool"
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown codec")
+	ErrRtpmap       = errors.New("invalid rtpmap encoding")
+)
+
+// Codec identifies an RTP payload format as in an SDP rtpmap: the encoding
+// name, clock rate and channel count.
+type Codec struct {
+	Name      string
+	ClockRate int
+	Channels  int // 0 is the same as 1.
+}
+
+// ParseCodec parses the encoding of an rtpmap, such as "PCMU/8000" or
+// "opus/48000/2".
+func ParseCodec(encoding string) (Codec, error) {
+	parts := strings.Split(strings.TrimSpace(encoding), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return Codec{}, ErrRtpmap
+	}
+	clockRate, err := strconv.Atoi(parts[1])
+	if err != nil || clockRate <= 0 {
+		return Codec{}, ErrRtpmap
+	}
+	c := Codec{Name: parts[0], ClockRate: clockRate, Channels: 1}
+	if len(parts) == 3 {
+		if c.Channels, err = strconv.Atoi(parts[2]); err != nil || c.Channels <= 0 {
+			return Codec{}, ErrRtpmap
+		}
+	}
+	return c, nil
+}
+
+// String returns the rtpmap encoding, leaving out a single channel.
+func (c Codec) String() string {
+	if c.Channels > 1 {
+		return fmt.Sprintf("%s/%d/%d", c.Name, c.ClockRate, c.Channels)
+	}
+	return fmt.Sprintf("%s/%d", c.Name, c.ClockRate)
+}
+
+// key matches encoding names case-insensitively as SDP does.
+func (c Codec) key() string {
+	channels := c.Channels
+	if channels == 0 {
+		channels = 1
+	}
+	return fmt.Sprintf("%s/%d/%d", strings.ToLower(c.Name), c.ClockRate, channels)
+}
+
+// Params configure the encoders and decoders a Registry creates. Zero values
+// use the defaults of the codec.
+type Params struct {
+	// PCM frames. Only Opus codes other sample rates and channel counts than
+	// the codec's. Opus frames default to 48000Hz mono whatever the rtpmap.
+	SampleRate int
+	Channels   int
+	// Frame duration in milliseconds, default 20.
+	Ptime int
+	// Frames buffered by decoders, default 10.
+	MaxFrames int
+
+	PLC bool // G711 Appendix I concealment.
+	VAD bool // G729 Annex B.
+	FEC bool // Opus inband FEC.
+}
+
+func (p Params) withDefaults(sampleRate, channels int) Params {
+	if p.SampleRate == 0 {
+		p.SampleRate = sampleRate
+	}
+	if p.Channels == 0 {
+		p.Channels = channels
+	}
+	if p.Ptime == 0 {
+		p.Ptime = 20
+	}
+	if p.MaxFrames == 0 {
+		p.MaxFrames = 10
+	}
+	return p
+}
+
+// fixed returns the params of a codec that only codes frames of one sample
+// rate and channel count.
+func (p Params) fixed(sampleRate, channels int) (Params, error) {
+	if (p.SampleRate != 0 && p.SampleRate != sampleRate) || (p.Channels != 0 && p.Channels != channels) {
+		return p, pool.ErrUnsupported
+	}
+	return p.withDefaults(sampleRate, channels), nil
+}
+
+// Registration describes how a Registry creates the encoder and decoder of a
+// codec.
+type Registration struct {
+	Codec
+
+	// Static RTP payload type, only valid when Static is set.
+	PayloadType uint8
+	Static      bool
+
+	NewEncoder func(c Codec, p Params) (FrameEncoder, error)
+	NewDecoder func(c Codec, p Params) (FrameDecoder, error)
+}
+
+// Registry creates encoders and decoders by codec, so a negotiated payload
+// type maps to a pipeline without a switch per codec.
+type Registry struct {
+	codecs map[string]Registration
+	mu     sync.RWMutex
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		codecs: make(map[string]Registration),
+	}
+}
+
+// Register adds a codec, replacing one with the same name, clock rate and
+// channels.
+func (r *Registry) Register(reg Registration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[reg.key()] = reg
+}
+
+// Lookup returns the registration of a codec.
+func (r *Registry) Lookup(c Codec) (Registration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reg, ok := r.codecs[c.key()]
+	return reg, ok
+}
+
+// LookupPayloadType returns the registration of a static payload type.
+func (r *Registry) LookupPayloadType(payloadType uint8) (Registration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, reg := range r.codecs {
+		if reg.Static && reg.PayloadType == payloadType {
+			return reg, true
+		}
+	}
+	return Registration{}, false
+}
+
+// Codecs returns the registered codecs ordered by name, clock rate and
+// channels.
+func (r *Registry) Codecs() []Registration {
+	r.mu.RLock()
+	regs := make([]Registration, 0, len(r.codecs))
+	for _, reg := range r.codecs {
+		regs = append(regs, reg)
+	}
+	r.mu.RUnlock()
+	sort.Slice(regs, func(i, j int) bool {
+		a, b := regs[i], regs[j]
+		if !strings.EqualFold(a.Name, b.Name) {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+		if a.ClockRate != b.ClockRate {
+			return a.ClockRate < b.ClockRate
+		}
+		return a.Channels < b.Channels
+	})
+	return regs
+}
+
+// NewEncoder creates an encoder for a codec.
+func (r *Registry) NewEncoder(c Codec, p Params) (FrameEncoder, error) {
+	reg, ok := r.Lookup(c)
+	if !ok || reg.NewEncoder == nil {
+		return nil, ErrUnknownCodec
+	}
+	return reg.NewEncoder(c, p)
+}
+
+// NewDecoder creates a decoder for a codec.
+func (r *Registry) NewDecoder(c Codec, p Params) (FrameDecoder, error) {
+	reg, ok := r.Lookup(c)
+	if !ok || reg.NewDecoder == nil {
+		return nil, ErrUnknownCodec
+	}
+	return reg.NewDecoder(c, p)
+}
+
+// DefaultRegistry holds the codecs of this module.
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(Registration{
+		Codec:       Codec{Name: "PCMU", ClockRate: 8000},
+		PayloadType: PayloadTypePCMU,
+		Static:      true,
+		NewEncoder:  newG711Encoder(g711.Ulaw),
+		NewDecoder:  newG711Decoder(g711.Ulaw),
+	})
+	r.Register(Registration{
+		Codec:       Codec{Name: "PCMA", ClockRate: 8000},
+		PayloadType: PayloadTypePCMA,
+		Static:      true,
+		NewEncoder:  newG711Encoder(g711.Alaw),
+		NewDecoder:  newG711Decoder(g711.Alaw),
+	})
+	r.Register(Registration{
+		Codec:       Codec{Name: "G722", ClockRate: 8000},
+		PayloadType: g722.PayloadType,
+		Static:      true,
+		NewEncoder: func(c Codec, p Params) (FrameEncoder, error) {
+			p, err := p.fixed(g722.SampleRate, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewG722Encoder(g722.Rate64000, p.Ptime)
+		},
+		NewDecoder: func(c Codec, p Params) (FrameDecoder, error) {
+			p, err := p.fixed(g722.SampleRate, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewG722Decoder(g722.Rate64000, p.Ptime, p.MaxFrames)
+		},
+	})
+	r.Register(Registration{
+		Codec:       Codec{Name: "G729", ClockRate: 8000},
+		PayloadType: g729.PayloadType,
+		Static:      true,
+		NewEncoder: func(c Codec, p Params) (FrameEncoder, error) {
+			p, err := p.fixed(g729.SampleRate, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewG729Encoder(p.Ptime, p.VAD)
+		},
+		NewDecoder: func(c Codec, p Params) (FrameDecoder, error) {
+			p, err := p.fixed(g729.SampleRate, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewG729Decoder(p.Ptime, p.MaxFrames)
+		},
+	})
+	r.Register(Registration{
+		Codec:       Codec{Name: "GSM", ClockRate: 8000},
+		PayloadType: gsm.PayloadType,
+		Static:      true,
+		NewEncoder: func(c Codec, p Params) (FrameEncoder, error) {
+			p, err := p.fixed(gsm.SampleRate, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewGSMEncoder(p.Ptime)
+		},
+		NewDecoder: func(c Codec, p Params) (FrameDecoder, error) {
+			p, err := p.fixed(gsm.SampleRate, 1)
+			if err != nil {
+				return nil, err
+			}
+			return NewGSMDecoder(p.Ptime, p.MaxFrames)
+		},
+	})
+	r.Register(Registration{
+		Codec: Codec{Name: "opus", ClockRate: opusClockRate, Channels: 2},
+		NewEncoder: func(c Codec, p Params) (FrameEncoder, error) {
+			p = p.withDefaults(opusClockRate, 1)
+			enc, err := NewOpusEncoder(p.SampleRate, p.Channels, p.Ptime)
+			if err != nil {
+				return nil, err
+			}
+			if p.FEC {
+				if err = enc.SetFEC(true); err != nil {
+					return nil, err
+				}
+			}
+			return enc, nil
+		},
+		NewDecoder: func(c Codec, p Params) (FrameDecoder, error) {
+			p = p.withDefaults(opusClockRate, 1)
+			return NewOpusDecoder(p.SampleRate, p.Channels, p.Ptime, p.MaxFrames)
+		},
+	})
+	// L16 at the rates and channel counts of the pool.
+	for _, sampleRate := range []int{8000, 12000, 16000, 24000, 48000} {
+		for _, channels := range []int{1, 2} {
+			r.Register(Registration{
+				Codec:      Codec{Name: l16.EncodingName, ClockRate: sampleRate, Channels: channels},
+				NewEncoder: newL16Encoder,
+				NewDecoder: newL16Decoder,
+			})
+		}
+	}
+	return r
+}
+
+func newG711Encoder(format int) func(c Codec, p Params) (FrameEncoder, error) {
+	return func(c Codec, p Params) (FrameEncoder, error) {
+		p, err := p.fixed(8000, 1)
+		if err != nil {
+			return nil, err
+		}
+		return NewG711Encoder(format, p.Ptime)
+	}
+}
+
+func newG711Decoder(format int) func(c Codec, p Params) (FrameDecoder, error) {
+	return func(c Codec, p Params) (FrameDecoder, error) {
+		p, err := p.fixed(8000, 1)
+		if err != nil {
+			return nil, err
+		}
+		return NewG711Decoder(format, p.Ptime, p.MaxFrames, p.PLC)
+	}
+}
+
+// L16 frames have the rate and channels of the rtpmap.
+func newL16Encoder(c Codec, p Params) (FrameEncoder, error) {
+	channels := c.Channels
+	if channels == 0 {
+		channels = 1
+	}
+	p, err := p.fixed(c.ClockRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	return NewL16Encoder(c.ClockRate, channels, p.Ptime)
+}
+
+func newL16Decoder(c Codec, p Params) (FrameDecoder, error) {
+	channels := c.Channels
+	if channels == 0 {
+		channels = 1
+	}
+	p, err := p.fixed(c.ClockRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	return NewL16Decoder(c.ClockRate, channels, p.Ptime, p.MaxFrames)
+}
@@ -0,0 +1,166 @@
+package transcode
+
+import (
+	"math"
+	"testing"
+)
+
+// encodeAll encodes interleaved samples with a codec of DefaultRegistry,
+// padding the last frame with silence.
+func encodeAll(t *testing.T, c Codec, p Params, samples []int16) [][]byte {
+	enc, err := DefaultRegistry.NewEncoder(c, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	var payloads [][]byte
+	frameLen := enc.FrameSize() * enc.Channels()
+	for len(samples) > 0 {
+		frame := enc.Alloc()
+		n := copy(frame, samples)
+		for i := n; i < len(frame); i++ {
+			frame[i] = 0
+		}
+		samples = samples[n:]
+		payload := make([]byte, frameLen*2+100)
+		n, _, err := enc.Encode(frame, payload)
+		enc.Release(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, payload[:n])
+	}
+	return payloads
+}
+
+// toneSNR fits a sine of freq Hz to one channel of interleaved samples and
+// returns the ratio of its power to the power of the rest in dB.
+func toneSNR(samples []int16, channel, channels int, freq float64, sampleRate int) float64 {
+	var ss, sc, cc, ys, yc float64
+	n := len(samples) / channels
+	for i := 0; i < n; i++ {
+		w := 2 * math.Pi * freq * float64(i) / float64(sampleRate)
+		s, c, y := math.Sin(w), math.Cos(w), float64(samples[i*channels+channel])
+		ss += s * s
+		sc += s * c
+		cc += c * c
+		ys += y * s
+		yc += y * c
+	}
+	det := ss*cc - sc*sc
+	a, b := (ys*cc-yc*sc)/det, (yc*ss-ys*sc)/det
+	var signal, noise float64
+	for i := 0; i < n; i++ {
+		w := 2 * math.Pi * freq * float64(i) / float64(sampleRate)
+		fit := a*math.Sin(w) + b*math.Cos(w)
+		d := float64(samples[i*channels+channel]) - fit
+		signal += fit * fit
+		noise += d * d
+	}
+	return 10 * math.Log10(signal/(noise+1))
+}
+
+func TestParseCodec(t *testing.T) {
+	for _, test := range []struct {
+		encoding string
+		want     Codec
+		err      error
+	}{
+		{"PCMU/8000", Codec{"PCMU", 8000, 1}, nil},
+		{"opus/48000/2", Codec{"opus", 48000, 2}, nil},
+		{" L16/16000/1 ", Codec{"L16", 16000, 1}, nil},
+		{"G722/8000/", Codec{}, ErrRtpmap},
+		{"PCMU", Codec{}, ErrRtpmap},
+		{"/8000", Codec{}, ErrRtpmap},
+		{"PCMU/0", Codec{}, ErrRtpmap},
+		{"PCMU/8k", Codec{}, ErrRtpmap},
+		{"opus/48000/0", Codec{}, ErrRtpmap},
+		{"opus/48000/2/1", Codec{}, ErrRtpmap},
+	} {
+		got, err := ParseCodec(test.encoding)
+		if got != test.want || err != test.err {
+			t.Errorf("%q: got %v, %v, want %v, %v", test.encoding, got, err, test.want, test.err)
+		}
+		if err == nil {
+			if again, _ := ParseCodec(got.String()); again != got {
+				t.Errorf("%q: %q parses to %v", test.encoding, got.String(), again)
+			}
+		}
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	for _, c := range []Codec{
+		{"pcmu", 8000, 0},
+		{"PCMU", 8000, 1},
+		{"OPUS", 48000, 2},
+		{"l16", 48000, 2},
+	} {
+		if _, ok := DefaultRegistry.Lookup(c); !ok {
+			t.Errorf("%v not found", c)
+		}
+	}
+	for _, c := range []Codec{{"PCMU", 16000, 1}, {"opus", 48000, 1}, {"L16", 44100, 1}, {"iLBC", 8000, 1}} {
+		if _, ok := DefaultRegistry.Lookup(c); ok {
+			t.Errorf("%v found", c)
+		}
+	}
+	if _, err := DefaultRegistry.NewEncoder(Codec{"iLBC", 8000, 1}, Params{}); err != ErrUnknownCodec {
+		t.Error(err)
+	}
+
+	for payloadType, name := range map[uint8]string{0: "PCMU", 3: "GSM", 8: "PCMA", 9: "G722", 18: "G729"} {
+		reg, ok := DefaultRegistry.LookupPayloadType(payloadType)
+		if !ok || reg.Name != name || reg.ClockRate != 8000 {
+			t.Errorf("payload type %d: %v, %v", payloadType, reg.Codec, ok)
+		}
+	}
+	if reg, ok := DefaultRegistry.LookupPayloadType(96); ok {
+		t.Errorf("payload type 96: %v", reg.Codec)
+	}
+}
+
+func TestRegistry_Codecs(t *testing.T) {
+	regs := DefaultRegistry.Codecs()
+	if len(regs) != 6+10 {
+		t.Fatal(len(regs))
+	}
+	for _, reg := range regs {
+		enc, err := DefaultRegistry.NewEncoder(reg.Codec, Params{})
+		if err != nil {
+			t.Fatal(reg.Codec, err)
+		}
+		dec, err := DefaultRegistry.NewDecoder(reg.Codec, Params{})
+		if err != nil {
+			t.Fatal(reg.Codec, err)
+		}
+		if enc.SampleRate() != dec.SampleRate() || enc.Channels() != dec.Channels() || enc.FrameSize() != dec.FrameSize() {
+			t.Fatalf("%v: encoder %d/%d/%d, decoder %d/%d/%d", reg.Codec,
+				enc.SampleRate(), enc.Channels(), enc.FrameSize(), dec.SampleRate(), dec.Channels(), dec.FrameSize())
+		}
+
+		// A few frames of a tone survive encoding and decoding.
+		samples := tone(400, enc.SampleRate(), enc.Channels(), enc.FrameSize()*5)
+		var decoded []int16
+		for _, payload := range encodeAll(t, reg.Codec, Params{}, samples) {
+			if err := dec.Write(payload); err != nil {
+				t.Fatal(reg.Codec, err)
+			}
+			frame, err := dec.ReadFrame()
+			if err != nil {
+				t.Fatal(reg.Codec, err)
+			}
+			if len(frame) != dec.FrameSize()*dec.Channels() {
+				t.Fatal(reg.Codec, len(frame))
+			}
+			decoded = append(decoded, frame...)
+			dec.Release(frame)
+		}
+		// Skip the codec delay.
+		if snr := toneSNR(decoded[len(decoded)/2:], 0, enc.Channels(), 400, enc.SampleRate()); snr < 15 {
+			t.Errorf("%v: SNR %.1fdB", reg.Codec, snr)
+		}
+		enc.Close()
+		dec.Close()
+	}
+}
@@ -0,0 +1,108 @@
+package transcode
+
+import (
+	"math"
+)
+
+// resampler converts interleaved frames between the sample rates of the pool
+// with a polyphase FIR filter. Unlike resample.Resampler it keeps its state
+// between frames, so frames of any size can be resampled as a stream.
+type resampler struct {
+	up       int // Interpolation factor L.
+	down     int // Decimation factor M.
+	taps     int // Filter taps per phase.
+	channels int
+	filter   []float32 // Phase major, taps of phase p at p*taps.
+
+	history []int16 // Last taps-1 input samples, then the current input.
+	t       int     // Time of the next output at the interpolated rate.
+	out     []int16
+}
+
+func newResampler(inputRate, outputRate, channels int) *resampler {
+	g := gcd(inputRate, outputRate)
+	up, down := outputRate/g, inputRate/g
+
+	// Filter out what doesn't fit the lower of the two rates.
+	taps := 16 * ((down + up - 1) / up)
+	n := up * taps
+	cutoff := 0.45 / float64(max(up, down))
+	filter := make([]float32, n)
+	center := float64(n-1) / 2
+	for i := 0; i < n; i++ {
+		x := float64(i) - center
+		h := 2 * cutoff
+		if x != 0 {
+			h = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		// Blackman window.
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) +
+			0.08*math.Cos(4*math.Pi*float64(i)/float64(n-1))
+		phase, k := i%up, i/up
+		filter[phase*taps+k] = float32(h * w * float64(up))
+	}
+	return &resampler{
+		up:       up,
+		down:     down,
+		taps:     taps,
+		channels: channels,
+		filter:   filter,
+		history:  make([]int16, (taps-1)*channels),
+		t:        (taps - 1) * up,
+	}
+}
+
+// resample returns in at the output rate. The result is only valid until the
+// next call.
+func (r *resampler) resample(in []int16) []int16 {
+	r.history = append(r.history, in...)
+	frames := len(r.history) / r.channels
+	r.out = r.out[:0]
+	for {
+		i, phase := r.t/r.up, r.t%r.up
+		if i >= frames {
+			break
+		}
+		coefs := r.filter[phase*r.taps : (phase+1)*r.taps]
+		for c := 0; c < r.channels; c++ {
+			var sum float32
+			for k, h := range coefs {
+				sum += h * float32(r.history[(i-k)*r.channels+c])
+			}
+			r.out = append(r.out, clamp16(sum))
+		}
+		r.t += r.down
+	}
+
+	// Keep the samples the next outputs still need.
+	shift := frames - (r.taps - 1)
+	n := copy(r.history, r.history[shift*r.channels:])
+	r.history = r.history[:n]
+	r.t -= shift * r.up
+	return r.out
+}
+
+func clamp16(v float32) int16 {
+	v = float32(math.Round(float64(v)))
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+package transcode
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var poolRates = []int{8000, 12000, 16000, 24000, 48000}
+
+func TestResampler_Tone(t *testing.T) {
+	for _, in := range poolRates {
+		for _, out := range poolRates {
+			if in == out {
+				continue
+			}
+			for _, channels := range []int{1, 2} {
+				r := newResampler(in, out, channels)
+				// Half a second in 20ms frames, skipping the filter delay.
+				var got []int16
+				input := tone(1000, in, channels, in/2)
+				frame := in / 50 * channels
+				for i := 0; i < len(input); i += frame {
+					got = append(got, r.resample(input[i:i+frame])...)
+				}
+				if want := out / 2 * channels; len(got) < want-r.taps*channels || len(got) > want {
+					t.Fatalf("%d to %d: %d samples, want about %d", in, out, len(got), want)
+				}
+				// The filter spans taps input samples.
+				got = got[2*r.taps*r.up/r.down*channels:]
+				for c := 0; c < channels; c++ {
+					if snr := toneSNR(got, c, channels, 1000*float64(c+1), out); snr < 60 {
+						t.Errorf("%d to %d, channel %d of %d: SNR %.1fdB", in, out, c, channels, snr)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestResampler_Stopband(t *testing.T) {
+	// 6Khz doesn't fit 8Khz and must be filtered out rather than alias to
+	// 2Khz.
+	for _, in := range []int{16000, 24000, 48000} {
+		r := newResampler(in, 8000, 1)
+		got := r.resample(tone(6000, in, 1, in/2))[2*r.taps:]
+		if db := 10 * math.Log10(power(got)/(10000*10000/2)); db > -40 {
+			t.Errorf("%d to 8000: 6Khz attenuated to %.1fdB", in, db)
+		}
+	}
+}
+
+func TestResampler_Chunks(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	input := make([]int16, 4800*2)
+	for i := range input {
+		input[i] = int16(random.Intn(20000) - 10000)
+	}
+	for _, rates := range [][2]int{{48000, 8000}, {8000, 48000}, {16000, 24000}, {24000, 16000}, {12000, 8000}} {
+		whole := append([]int16(nil), newResampler(rates[0], rates[1], 2).resample(input)...)
+
+		r := newResampler(rates[0], rates[1], 2)
+		var chunked []int16
+		for rest := input; len(rest) > 0; {
+			n := 2 * (1 + random.Intn(200))
+			if n > len(rest) {
+				n = len(rest)
+			}
+			chunked = append(chunked, r.resample(rest[:n])...)
+			rest = rest[n:]
+		}
+		if len(chunked) != len(whole) {
+			t.Fatalf("%v: %d samples in chunks, %d at once", rates, len(chunked), len(whole))
+		}
+		for i := range whole {
+			if chunked[i] != whole[i] {
+				t.Fatalf("%v: sample %d is %d in chunks, %d at once", rates, i, chunked[i], whole[i])
+			}
+		}
+	}
+}
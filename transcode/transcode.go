@@ -1,7 +1,78 @@
 package transcode
 
-type Transcode interface {
-	Write(packet []byte) error
+import (
+	"time"
 
-	ReadFrame() ([]int16, error)
+	"github.com/pidato/audio/pcm"
+)
+
+// FrameEncoder encodes PCM frames from its pool into RTP payloads.
+type FrameEncoder interface {
+	SampleRate() int
+	Channels() int
+	FrameSize() int
+	Ptime() time.Duration
+	Alloc() []int16
+	Release(p []int16)
+
+	// Encode encodes frame into payload and returns the payload length and
+	// the RTP timestamp ticks it covers. When ticks covers less than frame,
+	// Encode is called again with the rest. An empty payload is not sent but
+	// still advances the RTP timestamp.
+	Encode(frame []int16, payload []byte) (n, ticks int, err error)
+
+	Close() error
+}
+
+// FrameDecoder decodes RTP payloads into PCM frames read with ReadFrame.
+type FrameDecoder interface {
+	pcm.Reader
+
+	// Write decodes the next payload.
+	Write(payload []byte) error
+
+	// Missing conceals the RTP timestamp ticks that were lost.
+	Missing(ticks int) error
+
+	// WriteFinal signals there are no more payloads.
+	WriteFinal() error
+
+	// Buffered returns the number of decoded frames ReadFrame returns
+	// without blocking.
+	Buffered() int
 }
+
+// FECEncoder is a FrameEncoder that can add inband FEC to its payloads.
+type FECEncoder interface {
+	FrameEncoder
+
+	SetFEC(fec bool) error
+
+	// SetPacketLoss sets the expected packet loss in percent.
+	SetPacketLoss(percent int) error
+}
+
+// FECDecoder is a FrameDecoder that can recover the ticks lost before a
+// payload from the payload itself.
+type FECDecoder interface {
+	FrameDecoder
+
+	WriteFEC(payload []byte, ticks int) error
+}
+
+var (
+	_ FrameEncoder = (*G711Encoder)(nil)
+	_ FrameEncoder = (*G722Encoder)(nil)
+	_ FrameEncoder = (*G729Encoder)(nil)
+	_ FrameEncoder = (*GSMEncoder)(nil)
+	_ FrameEncoder = (*L16Encoder)(nil)
+	_ FECEncoder   = (*OpusEncoder)(nil)
+	_ FrameEncoder = (*REDEncoder)(nil)
+
+	_ FrameDecoder = (*G711Decoder)(nil)
+	_ FrameDecoder = (*G722Decoder)(nil)
+	_ FrameDecoder = (*G729Decoder)(nil)
+	_ FrameDecoder = (*GSMDecoder)(nil)
+	_ FrameDecoder = (*L16Decoder)(nil)
+	_ FECDecoder   = (*OpusDecoder)(nil)
+)
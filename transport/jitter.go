@@ -1,316 +1,301 @@
 package transport
 
-//
-//import (
-//	"errors"
-//	"github.com/pion/rtp"
-//	"math"
-//	"sync"
-//	"time"
-//)
-//
-//var (
-//	ErrDuplicate = errors.New("old packet")
-//)
-//
-//const (
-//	MaxPacketLoss = 100
-//)
-//
-//type Packet struct {
-//	Sequence  uint16 // Logical
-//	Timestamp uint32
-//	Data      []byte
-//}
-//
-//type Decoder interface {
-//	// Samples per second. (Hertz)
-//	ClockSpeed() int
-//
-//	Write(packet []byte) error
-//
-//	ReadFrame() ([]int16, int, error)
-//}
-//
-//type pkt struct {
-//	seq               uint16
-//	seqRollover       uint
-//	timestamp         uint32
-//	timestampRollover uint
-//
-//	data []byte
-//}
-//
-//func (p *pkt) Exists() bool {
-//	return p.data != nil
-//}
-//
-//// Receiver of RTP packets of a particular SSRC.
-//// Handles out-of-order and dropped packets
-//type Receiver struct {
-//	timer *time.Timer
-//
-//	latency float32
-//
-//	received int
-//
-//	firstTimestamp int
-//	lastTimestamp  uint32
-//
-//	rollover int
-//
-//	minSeq      uint16
-//	minRollover int
-//
-//	currentSeq      uint16
-//	currentRollover int
-//
-//	expectingSeq      uint16
-//	expectingRollover int
-//
-//	buf             [100]pkt
-//	bufferLength    int
-//	maxBufferLength int
-//
-//	decoder Decoder
-//
-//	Receive    func(packet *Packet) error
-//	ReceiveRTP func(packet *rtp.Packet) error
-//
-//	mu sync.Mutex
-//}
-//
-//func NewReceiver() *Receiver {
-//	r := &Receiver{}
-//	r.Receive = r.receiveFirst
-//	r.ReceiveRTP = r.receiveRTPFirst
-//	return r
-//}
-//
-//func (j *Receiver) Reset() {
-//	j.mu.Lock()
-//	defer j.mu.Unlock()
-//
-//	if j.timer != nil {
-//		j.timer.Stop()
-//		j.timer = nil
-//	}
-//
-//	j.received = 0
-//	j.firstTimestamp = 0
-//	j.currentSeq = 0
-//	j.lastTimestamp = 0
-//	j.minSeq = 0
-//	j.expectingSeq = 0
-//	j.bufferLength = 0
-//	j.maxBufferLength = 100
-//}
-//
-//func (r *Receiver) receiveFirst(packet *Packet) error {
-//	r.received++
-//
-//	if packet.Sequence == 0 {
-//		r.currentSeq = 0
-//		r.minSeq = 0
-//		r.expectingSeq = 1
-//		r.Receive = r.receive2ndWhenFirstWas0
-//		// Assume this is the first packet and immediately send to decoder.
-//		return r.decoder.Write(packet.Data)
-//	}
-//
-//	r.buf[0] = pkt{
-//		seq:               packet.Sequence,
-//		seqRollover:       0,
-//		timestamp:         packet.Timestamp,
-//		timestampRollover: 0,
-//		data:              packet.Data,
-//	}
-//	r.bufferLength++
-//
-//	r.Receive = r.receive2nd
-//
-//	return nil
-//}
-//
-//func (r *Receiver) receive2ndWhenFirstWas0(packet *Packet) error {
-//	r.received++
-//
-//	if packet.Sequence == 1 {
-//		r.currentSeq = 1
-//		r.minSeq = 1
-//		r.expectingSeq = 2
-//		r.Receive = r.receiveCaughtUp
-//		return r.decoder.Write(packet.Data)
-//	}
-//
-//	return nil
-//}
-//
-//func (r *Receiver) receive2nd(packet *Packet) error {
-//	first := r.buf[0]
-//
-//	if first.seq+1 == packet.Sequence {
-//		if packet.Sequence == 0 {
-//			r.rollover++
-//		}
-//		r.currentRollover = r.rollover
-//		r.currentSeq = packet.Sequence
-//		// 2 in a row is good enough to get moving.
-//		r.Receive = r.receiveCaughtUp
-//		return r.decoder.Write(packet.Data)
-//	}
-//
-//	// Could this packet maybe be the first?
-//	if first.seq-1 == packet.Sequence {
-//		// Move previous 1st to 2nd.
-//		r.buf[1] = r.buf[0]
-//		// Make this the new first.
-//		r.buf[0] = pkt{
-//			seq:               packet.Sequence,
-//			seqRollover:       0,
-//			timestamp:         packet.Timestamp,
-//			timestampRollover: 0,
-//			data:              packet.Data,
-//		}
-//		r.bufferLength++
-//		// We need the 3rd packet to determine if that's the case.
-//		r.Receive = r.receive3rdWhen2ndWasActuallyFirst
-//		return nil
-//	}
-//
-//	// Make sure it's not a duplicate of the first.
-//	if first.seq == packet.Sequence {
-//		return ErrDuplicate
-//	}
-//
-//	// Predict the order of the packet.
-//	if first.seq-MaxPacketLoss < packet.Sequence {
-//
-//	}
-//
-//	if packet.Sequence < first.seq {
-//		// Mark
-//		if packet.Sequence-1 == first.seq {
-//			r.buf[1] = r.buf[0]
-//			r.buf[0] = pkt{
-//				seq:               packet.Sequence,
-//				seqRollover:       0,
-//				timestamp:         packet.Timestamp,
-//				timestampRollover: 0,
-//				data:              nil,
-//			}
-//			r.bufferLength++
-//			r.Receive = r.receive3rdWhen2ndWasActuallyFirst
-//		}
-//	} else if packet.Sequence > first.seq {
-//
-//	}
-//}
-//
-//func (r *Receiver) receiveNthWhenToFindFirst(packet *Packet) error {
-//
-//}
-//
-//func (r *Receiver) receive3rdWhen2ndWasActuallyFirst(packet *Packet) error {
-//	first := r.buf[0]
-//
-//	if first.seq+1 == packet.Sequence {
-//		if packet.Sequence == 0 {
-//			r.rollover++
-//		}
-//		r.currentRollover = r.rollover
-//		r.currentSeq = packet.Sequence
-//		r.Receive = r.receiveCaughtUp
-//		return r.decoder.Write(packet.Data)
-//	}
-//}
-//
-////
-//func (r *Receiver) receiveCaughtUp(packet *Packet) error {
-//	r.received++
-//
-//	// Is it a match?
-//	if packet.Sequence == r.expectingSeq {
-//		// Increase the min.
-//		r.currentSeq = packet.Sequence
-//		r.currentRollover = r.expectingRollover
-//		r.minSeq = packet.Sequence
-//		r.minRollover = r.currentRollover
-//		r.expectingSeq = packet.Sequence + 1
-//		if r.expectingSeq == 0 {
-//			r.expectingRollover++
-//		}
-//		// Send through decoder.
-//		return r.decoder.Write(packet.Data)
-//	}
-//
-//	if r.currentRollover < r.expectingRollover {
-//
-//	} else {
-//
-//	}
-//
-//	return nil
-//}
-//
-//func (r *Receiver) receiveOutOfOrderOrDropped(packet *Packet) error {
-//	r.received++
-//
-//	var sequence uint64
-//	var next uint64
-//	if packet.Sequence == math.MaxUint32 {
-//		r.rolloverCount++
-//	}
-//	sequence := (uint64(packet.Sequence) * uint64(r.rolloverCount)) + uint64(packet.Sequence)
-//
-//	if sequence != r.expectingSeq {
-//		// Next was dropped.
-//	}
-//
-//	_ = sequence
-//
-//	return nil
-//}
-//
-//func (r *Receiver) receiveRTPFirst(packet *rtp.Packet) error {
-//	r.received++
-//
-//	if packet.SequenceNumber == 0 {
-//		// Assume this is the first
-//	} else {
-//
-//	}
-//
-//	r.Receive = r.receiveCaughtUp
-//
-//	return nil
-//}
-//
-//func (j *Receiver) receiveRTP2(packet *rtp.Packet) error {
-//	j.received++
-//
-//	sequence := (uint64(packet.SequenceNumber) * uint64(j.rolloverCount)) + uint64(packet.SequenceNumber)
-//	nextSequence := sequence + 1
-//
-//	_ = nextSequence
-//
-//	return nil
-//}
-//
-//func (j *Receiver) receiveRTP3(packet *rtp.Packet) error {
-//	return nil
-//}
-//
-//func (j *Receiver) receiveRTP4(packet *rtp.Packet) error {
-//
-//	return nil
-//}
-//
-//func (j *Receiver) receiveRTP5(packet *rtp.Packet) error {
-//
-//	return nil
-//}
-//
-//func (j *Receiver) flush(packet *rtp.Packet) {
-//
-//}
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pidato/audio/red"
+	"github.com/pidato/audio/transcode"
+	"github.com/pion/rtp"
+)
+
+var (
+	ErrLate      = errors.New("packet is late")
+	ErrDuplicate = errors.New("duplicate packet")
+	ErrFull      = errors.New("jitter buffer is full")
+)
+
+// Packets held by a JitterBuffer at most.
+const MaxPackets = 1000
+
+// Stats of the packets a JitterBuffer received.
+type Stats struct {
+	Expected   int // Highest sequence number minus the first plus one.
+	Received   int
+	Lost       int // Never decoded, including late packets.
+	Late       int // Arrived after their playout time, or played already.
+	Duplicates int
+	Recovered  int // Lost packets recovered with FEC or RED.
+	Concealed  int // RTP timestamp ticks concealed.
+	Errors     int // Payloads the decoder rejected.
+
+	// Interarrival jitter (RFC 3550) and its maximum.
+	Jitter    time.Duration
+	MaxJitter time.Duration
+}
+
+// JitterBuffer reorders the RTP packets of one SSRC and plays them out to a
+// decoder after a fixed delay. Packets that didn't arrive in time are
+// concealed with Missing, or recovered from the FEC of the next packet when
+// the decoder is a transcode.FECDecoder. With SetRED, lost packets are also
+// recovered from the redundant blocks of RED packets.
+//
+// Time is passed in rather than read from a clock, so captured streams can be
+// replayed faster than real time. Call Advance regularly, at least every
+// ptime, to play out packets that are due.
+type JitterBuffer struct {
+	decoder     transcode.FrameDecoder
+	payloadType uint8
+	clockRate   int
+	delay       time.Duration
+
+	redPayloadType uint8
+	red            bool
+
+	packets []*packet // Sorted by sequence.
+
+	started       bool
+	base          time.Time // Arrival of the first packet.
+	baseTimestamp uint32
+	first         int64 // Extended sequence number of the first packet.
+	highest       int64
+	next          int64 // Extended sequence number to play out next.
+	played        bool
+	lastTimestamp uint32
+
+	transit int64
+	jitter  float64
+
+	stats Stats
+	mu    sync.Mutex
+}
+
+type packet struct {
+	seq       int64
+	redundant bool // From a RED packet, until the packet itself arrives.
+	*rtp.Packet
+}
+
+// NewJitterBuffer creates a JitterBuffer decoding packets of payloadType.
+// Packets of other payload types, such as CN, keep their place in the
+// sequence but aren't decoded.
+func NewJitterBuffer(decoder transcode.FrameDecoder, payloadType uint8, clockRate int, delay time.Duration) *JitterBuffer {
+	return &JitterBuffer{
+		decoder:     decoder,
+		payloadType: payloadType,
+		clockRate:   clockRate,
+		delay:       delay,
+	}
+}
+
+// SetRED unpacks packets of payloadType as RED (RFC 2198). Their redundant
+// blocks take the place of packets that haven't arrived.
+func (j *JitterBuffer) SetRED(payloadType uint8) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.redPayloadType = payloadType
+	j.red = true
+}
+
+// Push adds a packet that arrived at arrival.
+func (j *JitterBuffer) Push(p *rtp.Packet, arrival time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.red || p.PayloadType != j.redPayloadType {
+		return j.push(p, arrival)
+	}
+	packets, err := red.Split(p)
+	if err != nil {
+		j.stats.Errors++
+		return err
+	}
+	last := len(packets) - 1
+	err = j.push(packets[last], arrival)
+	for _, redundant := range packets[:last] {
+		j.pushRedundant(redundant)
+	}
+	return err
+}
+
+func (j *JitterBuffer) push(p *rtp.Packet, arrival time.Time) error {
+	if !j.started {
+		j.started = true
+		j.base = arrival
+		j.baseTimestamp = p.Timestamp
+		j.first = int64(p.SequenceNumber)
+		j.highest = j.first
+		j.next = j.first
+	}
+	seq := j.highest + int64(int16(p.SequenceNumber-uint16(j.highest)))
+	if seq < j.next {
+		j.stats.Late++
+		return ErrLate
+	}
+	i := sort.Search(len(j.packets), func(i int) bool {
+		return j.packets[i].seq >= seq
+	})
+	switch {
+	case i < len(j.packets) && j.packets[i].seq == seq && j.packets[i].redundant:
+		j.packets[i] = &packet{seq: seq, Packet: p}
+	case i < len(j.packets) && j.packets[i].seq == seq:
+		j.stats.Duplicates++
+		return ErrDuplicate
+	case len(j.packets) == MaxPackets:
+		return ErrFull
+	default:
+		j.insert(i, &packet{seq: seq, Packet: p})
+	}
+
+	j.stats.Received++
+	if seq > j.highest {
+		j.highest = seq
+	}
+	j.updateJitter(p.Timestamp, arrival)
+	return nil
+}
+
+// pushRedundant adds a packet from a redundant block unless it arrived
+// already or was played out.
+func (j *JitterBuffer) pushRedundant(p *rtp.Packet) {
+	seq := j.highest + int64(int16(p.SequenceNumber-uint16(j.highest)))
+	if seq < j.next || len(j.packets) == MaxPackets {
+		return
+	}
+	i := sort.Search(len(j.packets), func(i int) bool {
+		return j.packets[i].seq >= seq
+	})
+	if i < len(j.packets) && j.packets[i].seq == seq {
+		return
+	}
+	j.insert(i, &packet{seq: seq, redundant: true, Packet: p})
+}
+
+func (j *JitterBuffer) insert(i int, p *packet) {
+	j.packets = append(j.packets, nil)
+	copy(j.packets[i+1:], j.packets[i:])
+	j.packets[i] = p
+}
+
+// updateJitter estimates the interarrival jitter as in RFC 3550 A.8.
+func (j *JitterBuffer) updateJitter(timestamp uint32, arrival time.Time) {
+	ticks := int64(arrival.Sub(j.base)) * int64(j.clockRate) / int64(time.Second)
+	transit := ticks - int64(int32(timestamp-j.baseTimestamp))
+	if j.stats.Received > 1 {
+		d := transit - j.transit
+		if d < 0 {
+			d = -d
+		}
+		j.jitter += (float64(d) - j.jitter) / 16
+	}
+	j.transit = transit
+	jitter := time.Duration(j.jitter * float64(time.Second) / float64(j.clockRate))
+	j.stats.Jitter = jitter
+	if jitter > j.stats.MaxJitter {
+		j.stats.MaxJitter = jitter
+	}
+}
+
+// Advance plays out the packets due at now.
+func (j *JitterBuffer) Advance(now time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for len(j.packets) > 0 && !now.Before(j.due(j.packets[0])) {
+		if err := j.play(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush plays out all packets, such as at the end of a stream.
+func (j *JitterBuffer) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for len(j.packets) > 0 {
+		if err := j.play(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// due returns the playout time of a packet.
+func (j *JitterBuffer) due(p *packet) time.Time {
+	offset := int64(int32(p.Timestamp-j.baseTimestamp)) * int64(time.Second) / int64(j.clockRate)
+	return j.base.Add(j.delay + time.Duration(offset))
+}
+
+// play decodes the first packet, concealing the packets lost before it.
+func (j *JitterBuffer) play() error {
+	p := j.packets[0]
+	j.packets[0] = nil
+	j.packets = j.packets[1:]
+
+	if lost := p.seq - j.next; lost > 0 && j.played {
+		j.stats.Lost += int(lost)
+		// Assume the lost packets were as long as the ones around them.
+		missing := int(int64(int32(p.Timestamp-j.lastTimestamp)) * lost / (lost + 1))
+		if err := j.conceal(p, int(lost), missing); err != nil {
+			return err
+		}
+	}
+	j.next = p.seq + 1
+	j.played = true
+	j.lastTimestamp = p.Timestamp
+	if p.redundant {
+		j.stats.Recovered++
+	}
+
+	if p.PayloadType != j.payloadType {
+		return nil
+	}
+	return j.check(j.decoder.Write(p.Payload))
+}
+
+// check counts decoder errors other than closing, so a bad payload doesn't
+// stop the stream.
+func (j *JitterBuffer) check(err error) error {
+	switch err {
+	case nil:
+	case os.ErrClosed, io.ErrClosedPipe:
+		return err
+	default:
+		j.stats.Errors++
+	}
+	return nil
+}
+
+func (j *JitterBuffer) conceal(p *packet, lost, missing int) error {
+	if missing <= 0 {
+		return nil
+	}
+	if fec, ok := j.decoder.(transcode.FECDecoder); ok && lost == 1 && p.PayloadType == j.payloadType {
+		if err := fec.WriteFEC(p.Payload, missing); err == nil {
+			j.stats.Recovered++
+			return nil
+		}
+	}
+	j.stats.Concealed += missing
+	return j.check(j.decoder.Missing(missing))
+}
+
+// Stats returns the statistics so far.
+func (j *JitterBuffer) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stats := j.stats
+	if j.started {
+		stats.Expected = int(j.highest - j.first + 1)
+	}
+	return stats
+}
+
+// Delay returns the playout delay.
+func (j *JitterBuffer) Delay() time.Duration {
+	return j.delay
+}
@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+var start = time.Unix(1600000000, 0)
+
+// decoder records the payloads written to it. Payloads are a single byte
+// numbering the packet.
+type decoder struct {
+	calls []string
+}
+
+func (d *decoder) Close() error                { return nil }
+func (d *decoder) Elapsed() time.Duration      { return 0 }
+func (d *decoder) SampleRate() int             { return 8000 }
+func (d *decoder) Channels() int               { return 1 }
+func (d *decoder) FrameSize() int              { return 160 }
+func (d *decoder) Ptime() time.Duration        { return 20 * time.Millisecond }
+func (d *decoder) Release(p []int16)           {}
+func (d *decoder) Alloc() []int16              { return make([]int16, 160) }
+func (d *decoder) ReadFrame() ([]int16, error) { return nil, nil }
+func (d *decoder) WriteFinal() error           { return nil }
+func (d *decoder) Buffered() int               { return 0 }
+
+func (d *decoder) Write(payload []byte) error {
+	d.calls = append(d.calls, fmt.Sprint("write ", payload[0]))
+	return nil
+}
+
+func (d *decoder) Missing(ticks int) error {
+	d.calls = append(d.calls, fmt.Sprint("missing ", ticks))
+	return nil
+}
+
+type fecDecoder struct {
+	decoder
+}
+
+func (d *fecDecoder) WriteFEC(payload []byte, ticks int) error {
+	d.calls = append(d.calls, fmt.Sprint("fec ", payload[0], " ", ticks))
+	return nil
+}
+
+// rtpPacket returns packet i of a stream of 20ms packets starting at seq.
+func rtpPacket(seq uint16, i int) *rtp.Packet {
+	return &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			SequenceNumber: seq + uint16(i),
+			Timestamp:      uint32(160 * i),
+		},
+		Payload: []byte{byte(i)},
+	}
+}
+
+func arrival(i int) time.Time {
+	return start.Add(time.Duration(i) * 20 * time.Millisecond)
+}
+
+func TestJitterBuffer_Reorder(t *testing.T) {
+	// Sequence numbers wrap after packet 2.
+	for _, seq := range []uint16{0, 65534} {
+		d := &decoder{}
+		j := NewJitterBuffer(d, 0, 8000, 60*time.Millisecond)
+		for _, i := range []int{0, 2, 1, 3, 5, 4} {
+			if err := j.Push(rtpPacket(seq, i), arrival(i)); err != nil {
+				t.Fatal(seq, i, err)
+			}
+		}
+		if err := j.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"write 0", "write 1", "write 2", "write 3", "write 4", "write 5"}
+		if !reflect.DeepEqual(d.calls, want) {
+			t.Fatal(seq, d.calls)
+		}
+		if stats := j.Stats(); stats.Expected != 6 || stats.Received != 6 || stats.Lost != 0 {
+			t.Fatalf("%d %+v", seq, stats)
+		}
+	}
+}
+
+func TestJitterBuffer_Advance(t *testing.T) {
+	d := &decoder{}
+	j := NewJitterBuffer(d, 0, 8000, 60*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := j.Push(rtpPacket(0, i), arrival(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Packet 1 is due at 80ms.
+	if err := j.Advance(arrival(4).Add(-time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.calls, []string{"write 0"}) {
+		t.Fatal(d.calls)
+	}
+	if err := j.Advance(arrival(4)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.calls, []string{"write 0", "write 1"}) {
+		t.Fatal(d.calls)
+	}
+}
+
+func TestJitterBuffer_LateDuplicate(t *testing.T) {
+	d := &decoder{}
+	j := NewJitterBuffer(d, 0, 8000, 40*time.Millisecond)
+	for _, i := range []int{0, 1, 3} {
+		if err := j.Push(rtpPacket(65535, i), arrival(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Push(rtpPacket(65535, 3), arrival(3)); err != ErrDuplicate {
+		t.Fatal(err)
+	}
+	// Packet 2 was concealed before it arrived.
+	if err := j.Advance(arrival(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Push(rtpPacket(65535, 2), arrival(5)); err != ErrLate {
+		t.Fatal(err)
+	}
+	if err := j.Push(rtpPacket(65535, 1), arrival(5)); err != ErrLate {
+		t.Fatal(err)
+	}
+	want := []string{"write 0", "write 1", "missing 160", "write 3"}
+	if !reflect.DeepEqual(d.calls, want) {
+		t.Fatal(d.calls)
+	}
+	stats := j.Stats()
+	if stats.Expected != 4 || stats.Received != 3 || stats.Lost != 1 || stats.Late != 2 ||
+		stats.Duplicates != 1 || stats.Concealed != 160 {
+		t.Fatalf("%+v", stats)
+	}
+}
+
+func TestJitterBuffer_FEC(t *testing.T) {
+	d := &fecDecoder{}
+	j := NewJitterBuffer(d, 0, 8000, 40*time.Millisecond)
+	// A single loss is recovered from the next packet, two aren't.
+	for _, i := range []int{0, 2, 5} {
+		if err := j.Push(rtpPacket(0, i), arrival(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"write 0", "fec 2 160", "write 2", "missing 320", "write 5"}
+	if !reflect.DeepEqual(d.calls, want) {
+		t.Fatal(d.calls)
+	}
+	if stats := j.Stats(); stats.Lost != 3 || stats.Recovered != 1 || stats.Concealed != 320 {
+		t.Fatalf("%+v", stats)
+	}
+}
+
+func TestJitterBuffer_Full(t *testing.T) {
+	j := NewJitterBuffer(&decoder{}, 0, 8000, time.Minute)
+	for i := 0; i < MaxPackets; i++ {
+		if err := j.Push(rtpPacket(0, i), arrival(i)); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	if err := j.Push(rtpPacket(0, MaxPackets), arrival(MaxPackets)); err != ErrFull {
+		t.Fatal(err)
+	}
+	if stats := j.Stats(); stats.Received != MaxPackets {
+		t.Fatalf("%+v", stats)
+	}
+}
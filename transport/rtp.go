@@ -1,4 +1,119 @@
 package transport
 
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+var (
+	ErrNoRemote = errors.New("no remote address")
+)
+
+// PacketRecorder records the packets an RTPTransport receives, such as to a
+// capture file.
+type PacketRecorder interface {
+	Record(data []byte, src, dst net.Addr, arrival time.Time) error
+}
+
+// RTPTransport sends and receives RTP packets over a UDP socket.
 type RTPTransport struct {
+	conn     net.PacketConn
+	remote   net.Addr
+	recorder PacketRecorder
+	buf      []byte
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewRTPTransport creates an RTPTransport on conn, which Close closes.
+func NewRTPTransport(conn net.PacketConn) *RTPTransport {
+	return &RTPTransport{
+		conn: conn,
+		buf:  make([]byte, 1500),
+	}
+}
+
+// SetRemote sets the address WriteRTP sends to.
+func (t *RTPTransport) SetRemote(addr net.Addr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remote = addr
+}
+
+// SetRecorder records every packet received from now on, nil to stop.
+// Errors of the recorder don't stop receiving.
+func (t *RTPTransport) SetRecorder(recorder PacketRecorder) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recorder = recorder
+}
+
+// ReadRTP returns the next RTP packet, its source and when it arrived.
+// Packets that aren't RTP, such as RTCP, are recorded and skipped.
+func (t *RTPTransport) ReadRTP() (*rtp.Packet, net.Addr, time.Time, error) {
+	for {
+		n, addr, err := t.conn.ReadFrom(t.buf)
+		if err != nil {
+			return nil, nil, time.Time{}, err
+		}
+		arrival := time.Now()
+		data := t.buf[:n]
+
+		t.mu.Lock()
+		recorder := t.recorder
+		t.mu.Unlock()
+		if recorder != nil {
+			_ = recorder.Record(data, addr, t.conn.LocalAddr(), arrival)
+		}
+
+		// Packet types 192 to 223 are RTCP (RFC 5761).
+		if n < 12 || data[0]>>6 != 2 || (data[1] >= 192 && data[1] <= 223) {
+			continue
+		}
+		packet := &rtp.Packet{}
+		if err = packet.Unmarshal(append([]byte(nil), data...)); err != nil {
+			continue
+		}
+		return packet, addr, arrival, nil
+	}
+}
+
+// WriteRTP sends a packet to the remote address.
+func (t *RTPTransport) WriteRTP(packet *rtp.Packet) error {
+	t.mu.Lock()
+	remote, closed := t.remote, t.closed
+	t.mu.Unlock()
+	if closed {
+		return os.ErrClosed
+	}
+	if remote == nil {
+		return ErrNoRemote
+	}
+	buf, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = t.conn.WriteTo(buf, remote)
+	return err
+}
+
+// LocalAddr returns the address packets are received on.
+func (t *RTPTransport) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+func (t *RTPTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return os.ErrClosed
+	}
+	t.closed = true
+	return t.conn.Close()
 }
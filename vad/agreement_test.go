@@ -0,0 +1,93 @@
+//go:build cgo
+// +build cgo
+
+package vad
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+var _ Detector = (*VAD)(nil)
+
+// agreementFiles are the test recordings and their sample rates. The raw files
+// are recording.wav resampled by the resampler tests.
+var agreementFiles = []struct {
+	name       string
+	sampleRate int
+}{
+	{"recording.wav", 16000},
+	{"8000.pcm", 8000},
+	{"16000.pcm", 16000},
+	{"32000.pcm", 32000},
+}
+
+// loadAgreement returns the 10ms frames of a test recording.
+func loadAgreement(t *testing.T, name string, sampleRate int) [][]int16 {
+	frameSize := sampleRate / 100
+	if name == "recording.wav" {
+		frames, err := load(name, FrameSize(frameSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frames
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames [][]int16
+	for ; len(data) >= 2*frameSize; data = data[2*frameSize:] {
+		frame := make([]int16, frameSize)
+		for i := range frame {
+			frame[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// TestEnergy_Agreement reports how often the Energy decisions agree with
+// libfvad's on each test recording in each mode. fvad isn't ground truth, so
+// this doesn't measure the accuracy of either detector.
+func TestEnergy_Agreement(t *testing.T) {
+	for _, file := range agreementFiles {
+		frames := loadAgreement(t, file.name, file.sampleRate)
+		if len(frames) == 0 {
+			t.Fatalf("%s: no frames", file.name)
+		}
+
+		for mode := Quality; mode <= VeryAggressive; mode++ {
+			ref := New()
+			ref.SetMode(mode)
+			ref.SetSampleRate(int32(file.sampleRate))
+			e := NewEnergy()
+			e.SetMode(mode)
+			e.SetSampleRate(int32(file.sampleRate))
+
+			var agree, onlyEnergy, onlyFvad int
+			for _, frame := range frames {
+				fvad := ref.Process(frame) == Active
+				energy := e.Process(frame) == Active
+				switch {
+				case fvad == energy:
+					agree++
+				case energy:
+					onlyEnergy++
+				default:
+					onlyFvad++
+				}
+			}
+			_ = ref.Close()
+			_ = e.Close()
+
+			agreement := float64(agree) / float64(len(frames))
+			t.Logf("%s mode %d: agreement %.1f%% over %d frames, active only for energy %d, only for fvad %d",
+				file.name, mode, agreement*100, len(frames), onlyEnergy, onlyFvad)
+			if agreement < 0.7 {
+				t.Errorf("%s mode %d: agreement with fvad %.1f%% is below 70%%", file.name, mode, agreement*100)
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package vad
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// Longest window the spectral features are computed over.
+	maxFFTSize = 1024
+	// Frames quieter than this are never speech (dBFS).
+	energyMinLevel = -60.0
+	// Band the spectral flatness is measured in.
+	flatnessLowHz  = 300
+	flatnessHighHz = 4000
+)
+
+// Per mode thresholds. Higher modes require more energy above the noise floor
+// and a more tonal spectrum, so they report less speech.
+var energyModes = [...]struct {
+	threshold float64 // dB above the noise floor.
+	flatness  float64 // Maximum spectral flatness of speech.
+	hangover  int     // Frames speech is held after the features drop.
+}{
+	Quality:        {threshold: 6, flatness: 0.6, hangover: 8},
+	LowBitrate:     {threshold: 8, flatness: 0.5, hangover: 6},
+	Aggressive:     {threshold: 10, flatness: 0.4, hangover: 4},
+	VeryAggressive: {threshold: 12, flatness: 0.3, hangover: 3},
+}
+
+// Energy is a pure Go Detector. It doesn't need cgo and accepts frames of any
+// size at any sample rate.
+//
+// Each frame is classified from its energy relative to an adaptive noise
+// floor, its zero-crossing rate and its spectral flatness. The noise floor
+// follows quiet frames down immediately and rises slowly, so it adapts to
+// changing background noise without tracking speech.
+type Energy struct {
+	sampleRate int
+	mode       Mode
+
+	floor    float64 // Noise floor in dBFS.
+	hasFloor bool
+	hangover int
+
+	re     []float64
+	im     []float64
+	window []float64
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewEnergy creates an Energy detector at 16Khz in Quality mode.
+func NewEnergy() *Energy {
+	return &Energy{
+		sampleRate: 16000,
+		mode:       Quality,
+	}
+}
+
+func (e *Energy) SetMode(mode Mode) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || mode < Quality || mode > VeryAggressive {
+		return false
+	}
+	e.mode = mode
+	return true
+}
+
+func (e *Energy) SetSampleRate(sampleRate int32) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || sampleRate < 4000 {
+		return false
+	}
+	e.sampleRate = int(sampleRate)
+	return true
+}
+
+func (e *Energy) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.floor = 0
+	e.hasFloor = false
+	e.hangover = 0
+}
+
+func (e *Energy) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+// Process returns the decision for a frame. Frames should be 10ms or longer
+// for the spectral features to be meaningful.
+func (e *Energy) Process(frame []int16) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || len(frame) == 0 {
+		return Invalid
+	}
+
+	mode := energyModes[e.mode]
+	level := frameLevel(frame)
+
+	if !e.hasFloor {
+		e.floor = level
+		e.hasFloor = true
+	}
+
+	speech := false
+	if level > energyMinLevel && level > e.floor+mode.threshold {
+		zcr := zeroCrossingRate(frame)
+		flatness := e.spectralFlatness(frame)
+		// Voiced speech is tonal. Unvoiced fricatives are noise-like but
+		// have a high zero-crossing rate and are only accepted well above
+		// the floor.
+		speech = flatness < mode.flatness ||
+			(zcr > 0.25 && level > e.floor+mode.threshold*2)
+	}
+
+	// Track the noise floor. Quiet frames pull it down immediately, anything
+	// that isn't speech raises it slowly.
+	switch {
+	case level < e.floor:
+		e.floor = level
+	case !speech:
+		e.floor += (level - e.floor) * 0.05
+	default:
+		e.floor += 0.01
+	}
+
+	if speech {
+		e.hangover = mode.hangover
+		return Active
+	}
+	if e.hangover > 0 {
+		e.hangover--
+		return Active
+	}
+	return NonActive
+}
+
+// frameLevel returns the RMS level of a frame in dBFS.
+func frameLevel(frame []int16) float64 {
+	var sum float64
+	for _, s := range frame {
+		v := float64(s)
+		sum += v * v
+	}
+	rms := math.Sqrt(sum/float64(len(frame))) / 32768
+	if rms < 1e-9 {
+		return -180
+	}
+	return 20 * math.Log10(rms)
+}
+
+// zeroCrossingRate returns the fraction of adjacent samples changing sign.
+func zeroCrossingRate(frame []int16) float64 {
+	if len(frame) < 2 {
+		return 0
+	}
+	crossings := 0
+	for i := 1; i < len(frame); i++ {
+		if (frame[i-1] >= 0) != (frame[i] >= 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(frame)-1)
+}
+
+// spectralFlatness returns the ratio of the geometric and arithmetic mean of
+// the power spectrum in the speech band. Close to 1 for noise, close to 0 for
+// tonal signals.
+func (e *Energy) spectralFlatness(frame []int16) float64 {
+	n := 1
+	for n < len(frame) && n < maxFFTSize {
+		n <<= 1
+	}
+	if n < 16 {
+		return 1
+	}
+	if len(e.re) != n {
+		e.re = make([]float64, n)
+		e.im = make([]float64, n)
+		e.window = make([]float64, n)
+		for i := range e.window {
+			e.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		}
+	}
+	// Use the middle of long frames, zero pad short ones.
+	offset := 0
+	if len(frame) > n {
+		offset = (len(frame) - n) / 2
+	}
+	for i := 0; i < n; i++ {
+		e.im[i] = 0
+		if offset+i < len(frame) {
+			e.re[i] = float64(frame[offset+i]) * e.window[i]
+		} else {
+			e.re[i] = 0
+		}
+	}
+	fft(e.re, e.im)
+
+	low := flatnessLowHz * n / e.sampleRate
+	high := flatnessHighHz * n / e.sampleRate
+	if low < 1 {
+		low = 1
+	}
+	if high > n/2 {
+		high = n / 2
+	}
+	if high <= low {
+		return 1
+	}
+
+	var logSum, sum float64
+	for k := low; k < high; k++ {
+		p := e.re[k]*e.re[k] + e.im[k]*e.im[k] + 1e-10
+		logSum += math.Log(p)
+		sum += p
+	}
+	count := float64(high - low)
+	return math.Exp(logSum/count) / (sum / count)
+}
+
+// fft is an in-place iterative radix-2 FFT. len(re) must be a power of 2.
+func fft(re, im []float64) {
+	n := len(re)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		angle := -2 * math.Pi / float64(size)
+		wRe, wIm := math.Cos(angle), math.Sin(angle)
+		for start := 0; start < n; start += size {
+			uRe, uIm := 1.0, 0.0
+			for k := 0; k < size/2; k++ {
+				a := start + k
+				b := a + size/2
+				tRe := re[b]*uRe - im[b]*uIm
+				tIm := re[b]*uIm + im[b]*uRe
+				re[b] = re[a] - tRe
+				im[b] = im[a] - tIm
+				re[a] += tRe
+				im[a] += tIm
+				uRe, uIm = uRe*wRe-uIm*wIm, uRe*wIm+uIm*wRe
+			}
+		}
+	}
+}
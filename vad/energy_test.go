@@ -0,0 +1,116 @@
+package vad
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var _ Detector = (*Energy)(nil)
+
+func TestEnergy_Synthetic(t *testing.T) {
+	e := NewEnergy()
+	defer e.Close()
+	e.SetSampleRate(16000)
+	e.SetMode(Aggressive)
+
+	rng := rand.New(rand.NewSource(1))
+	noise := func(level float64) []int16 {
+		frame := make([]int16, 160)
+		for i := range frame {
+			frame[i] = int16(rng.NormFloat64() * level)
+		}
+		return frame
+	}
+
+	// Background noise settles as non-speech.
+	var r Result
+	for i := 0; i < 100; i++ {
+		r = e.Process(noise(100))
+	}
+	if r != NonActive {
+		t.Fatalf("Background noise reported as %d", r)
+	}
+
+	// A loud harmonic tone over the noise is speech.
+	tone := noise(100)
+	for i := range tone {
+		v := 0.0
+		for h := 1; h <= 5; h++ {
+			v += math.Sin(2*math.Pi*150*float64(h)*float64(i)/16000) / float64(h)
+		}
+		tone[i] += int16(v * 6000)
+	}
+	if r = e.Process(tone); r != Active {
+		t.Fatalf("Tone reported as %d", r)
+	}
+
+	// Louder noise is absorbed by the floor once the hangover runs out.
+	for i := 0; i < 300; i++ {
+		r = e.Process(noise(400))
+	}
+	if r != NonActive {
+		t.Errorf("Raised background noise reported as %d", r)
+	}
+
+	if r = e.Process(nil); r != Invalid {
+		t.Errorf("Expected Invalid for an empty frame, got %d", r)
+	}
+	if e.SetMode(Mode(4)) {
+		t.Errorf("Expected invalid mode to be rejected")
+	}
+}
+
+func TestEnergy_Recording(t *testing.T) {
+	frames, err := load("recording.wav", 320)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEnergy()
+	defer e.Close()
+	e.SetSampleRate(16000)
+
+	active := 0
+	for _, frame := range frames {
+		if e.Process(frame) == Active {
+			active++
+		}
+	}
+	if active == 0 || active == len(frames) {
+		t.Errorf("Unexpected speech frames %d of %d", active, len(frames))
+	}
+}
+
+func TestFFT(t *testing.T) {
+	const n = 64
+	re := make([]float64, n)
+	im := make([]float64, n)
+	for i := range re {
+		re[i] = math.Cos(2 * math.Pi * 5 * float64(i) / n)
+	}
+	fft(re, im)
+	for k := 0; k < n/2; k++ {
+		mag := math.Hypot(re[k], im[k])
+		if k == 5 {
+			if math.Abs(mag-n/2) > 1e-9 {
+				t.Errorf("Bin %d: got %f, expected %d", k, mag, n/2)
+			}
+		} else if mag > 1e-9 {
+			t.Errorf("Bin %d: unexpected energy %f", k, mag)
+		}
+	}
+}
+
+func BenchmarkEnergy_Process(b *testing.B) {
+	frames, err := load("recording.wav", 160)
+	if err != nil {
+		b.Fatal(err)
+	}
+	e := NewEnergy()
+	defer e.Close()
+	e.SetSampleRate(16000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Process(frames[i%len(frames)])
+	}
+}
@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package vad
 
 /*
@@ -11,23 +14,7 @@ import (
 	"unsafe"
 )
 
-type Result C.int
-
-const (
-	Active    = Result(1)
-	NonActive = Result(0)
-	Invalid   = Result(1)
-)
-
-type Mode C.int
-
-const (
-	Quality        = Mode(0)
-	LowBitrate     = Mode(1)
-	Aggressive     = Mode(2)
-	VeryAggressive = Mode(3)
-)
-
+// VAD is a Detector backed by libfvad.
 type VAD struct {
 	ptr    *C.Fvad
 	mu     sync.Mutex
@@ -46,17 +33,14 @@ func New() *VAD {
 	}
 }
 
-//
 // Re-initializes a VAD instance, clearing all state and resetting mode and
 // sample rate to defaults.
-//
 func (v *VAD) Reset() {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	C.fvad_reset(v.ptr)
 }
 
-//
 // Changes the VAD operating ("aggressiveness") mode of a VAD instance.
 //
 // A more aggressive (higher mode) VAD is more restrictive in reporting speech.
@@ -68,7 +52,6 @@ func (v *VAD) Reset() {
 // ("very aggressive"). The default mode is 0.
 //
 // Returns 0 on success, or -1 if the specified mode is invalid.
-//
 func (v *VAD) SetMode(mode Mode) bool {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -78,7 +61,6 @@ func (v *VAD) SetMode(mode Mode) bool {
 	return C.fvad_set_mode(v.ptr, (C.int)(mode)) == 0
 }
 
-//
 // Sets the input sample rate in Hz for a VAD instance.
 //
 // Valid values are 8000, 16000, 32000 and 48000. The default is 8000. Note
@@ -86,7 +68,6 @@ func (v *VAD) SetMode(mode Mode) bool {
 // sample rates will just be downsampled first.
 //
 // Returns 0 on success, or -1 if the passed value is invalid.
-//
 func (v *VAD) SetSampleRate(sampleRate int32) bool {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -96,7 +77,6 @@ func (v *VAD) SetSampleRate(sampleRate int32) bool {
 	return C.fvad_set_sample_rate(v.ptr, (C.int)(sampleRate)) == 0
 }
 
-//
 // Calculates a VAD decision for an audio frame.
 //
 // `frame` is an array of `length` signed 16-bit samples. Only frames with a
@@ -104,9 +84,9 @@ func (v *VAD) SetSampleRate(sampleRate int32) bool {
 // must be either 80, 160 or 240.
 //
 // Returns              : 1 - (active voice),
-//                        0 - (non-active Voice),
-//                       -1 - (invalid frame length).
 //
+//	 0 - (non-active Voice),
+//	-1 - (invalid frame length).
 func (v *VAD) Process(data []int16) Result {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -1,4 +1,5 @@
-// +build darwin
+//go:build darwin && cgo
+// +build darwin,cgo
 
 package vad
 
@@ -0,0 +1,266 @@
+//go:build cgo
+// +build cgo
+
+package vad
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+var (
+	ErrSampleRate = errors.New("unsupported sample rate")
+	ErrMode       = errors.New("unsupported mode")
+	ErrInvalid    = errors.New("invalid frame")
+)
+
+// Stream runs a VAD over frames of any size at any sample rate.
+//
+// Sample rates libfvad doesn't support are brought to one it does. Rates that
+// are a power of 2 away from a supported rate (12000, 24000, 64000, 96000)
+// go through UpSampleBy2 or DownSampleBy2. Anything else is linearly
+// interpolated to 16000. Frames are then re-chunked into 10ms chunks.
+//
+// Process returns the decision of the chunks completed by the frame. A frame
+// too short to complete a chunk (2.5 or 5ms) returns the previous decision.
+type Stream struct {
+	vad        *VAD
+	sampleRate int
+	vadRate    int
+
+	up   int // Number of UpSampleBy2 stages.
+	down int // Number of DownSampleBy2 stages.
+
+	upState   [][8]int32
+	downState [][8]int32
+	linear    *linearResampler
+
+	chunk   []int16 // Partial 10ms chunk at vadRate.
+	scratch [2][]int16
+	last    Result
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewStream creates a Stream for frames of sampleRate.
+func NewStream(sampleRate int, mode Mode) (*Stream, error) {
+	if sampleRate <= 0 {
+		return nil, ErrSampleRate
+	}
+	s := &Stream{
+		sampleRate: sampleRate,
+		vadRate:    sampleRate,
+		last:       NonActive,
+	}
+	switch {
+	case isFvadRate(sampleRate):
+	case sampleRate < 48000 && stagesBy2(sampleRate, true) > 0:
+		s.up = stagesBy2(sampleRate, true)
+		s.vadRate = sampleRate << uint(s.up)
+	case sampleRate > 48000 && stagesBy2(sampleRate, false) > 0:
+		s.down = stagesBy2(sampleRate, false)
+		s.vadRate = sampleRate >> uint(s.down)
+	default:
+		s.vadRate = 16000
+		s.linear = &linearResampler{step: float64(sampleRate) / 16000}
+	}
+	s.upState = make([][8]int32, s.up)
+	s.downState = make([][8]int32, s.down)
+
+	s.vad = New()
+	if s.vad == nil {
+		return nil, ErrInvalid
+	}
+	if !s.vad.SetSampleRate(int32(s.vadRate)) {
+		_ = s.vad.Close()
+		return nil, ErrSampleRate
+	}
+	if !s.vad.SetMode(mode) {
+		_ = s.vad.Close()
+		return nil, ErrMode
+	}
+	s.chunk = make([]int16, 0, s.vadRate/100)
+	return s, nil
+}
+
+func isFvadRate(sampleRate int) bool {
+	switch sampleRate {
+	case 8000, 16000, 32000, 48000:
+		return true
+	}
+	return false
+}
+
+// stagesBy2 returns the number of times sampleRate has to be doubled (up) or
+// halved (!up) to reach a rate libfvad supports, or 0 if it never does.
+func stagesBy2(sampleRate int, up bool) int {
+	for n := 1; n <= 4; n++ {
+		if up {
+			sampleRate *= 2
+		} else {
+			if sampleRate%2 != 0 {
+				return 0
+			}
+			sampleRate /= 2
+		}
+		if isFvadRate(sampleRate) {
+			return n
+		}
+	}
+	return 0
+}
+
+func (s *Stream) SampleRate() int {
+	return s.sampleRate
+}
+
+// Reset clears the VAD, the resampler state and any partial chunk.
+func (s *Stream) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vad.Reset()
+	for i := range s.upState {
+		s.upState[i] = [8]int32{}
+	}
+	for i := range s.downState {
+		s.downState[i] = [8]int32{}
+	}
+	if s.linear != nil {
+		*s.linear = linearResampler{step: s.linear.step}
+	}
+	s.chunk = s.chunk[:0]
+	s.last = NonActive
+}
+
+// Process submits the next frame and returns whether voice was detected.
+func (s *Stream) Process(frame []int16) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return Invalid, os.ErrClosed
+	}
+	if len(frame) == 0 {
+		return Invalid, ErrInvalid
+	}
+
+	data, err := s.resample(frame)
+	if err != nil {
+		return Invalid, err
+	}
+
+	result := Invalid
+	for len(data) > 0 {
+		n := cap(s.chunk) - len(s.chunk)
+		if n > len(data) {
+			n = len(data)
+		}
+		s.chunk = append(s.chunk, data[:n]...)
+		data = data[n:]
+		if len(s.chunk) < cap(s.chunk) {
+			break
+		}
+		r := s.vad.Process(s.chunk)
+		s.chunk = s.chunk[:0]
+		if r == Invalid {
+			return Invalid, ErrInvalid
+		}
+		if result != Active {
+			result = r
+		}
+	}
+	if result == Invalid {
+		return s.last, nil
+	}
+	s.last = result
+	return result, nil
+}
+
+func (s *Stream) resample(frame []int16) ([]int16, error) {
+	switch {
+	case s.up > 0:
+		data := frame
+		for i := 0; i < s.up; i++ {
+			out := s.buffer(i%2, len(data)*2)
+			if err := UpSampleBy2(data, out, &s.upState[i]); err != nil {
+				return nil, err
+			}
+			data = out
+		}
+		return data, nil
+	case s.down > 0:
+		data := frame
+		for i := 0; i < s.down; i++ {
+			if len(data)%2 != 0 {
+				return nil, ErrInvalid
+			}
+			out := s.buffer(i%2, len(data)/2)
+			if err := DownSampleBy2(data, out, &s.downState[i]); err != nil {
+				return nil, err
+			}
+			data = out
+		}
+		return data, nil
+	case s.linear != nil:
+		out := s.buffer(0, int(float64(len(frame))/s.linear.step)+2)
+		n := s.linear.process(frame, out)
+		return out[:n], nil
+	}
+	return frame, nil
+}
+
+func (s *Stream) buffer(i, size int) []int16 {
+	if cap(s.scratch[i]) < size {
+		s.scratch[i] = make([]int16, size)
+	}
+	return s.scratch[i][:size]
+}
+
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	s.closed = true
+	return s.vad.Close()
+}
+
+// linearResampler converts between arbitrary rates by linear interpolation.
+// It is only used to feed the VAD, which doesn't need better quality.
+type linearResampler struct {
+	step float64 // Input samples per output sample.
+	pos  float64 // Position of the next output sample relative to prev.
+	prev int16
+	init bool
+}
+
+func (l *linearResampler) process(in []int16, out []int16) int {
+	n := 0
+	if !l.init && len(in) > 0 {
+		l.prev = in[0]
+		l.init = true
+	}
+	// Position 0 is prev, position i+1 is in[i].
+	for n < len(out) {
+		i := int(l.pos)
+		if i >= len(in) {
+			break
+		}
+		var a int16
+		if i == 0 {
+			a = l.prev
+		} else {
+			a = in[i-1]
+		}
+		b := in[i]
+		frac := l.pos - float64(i)
+		out[n] = int16(float64(a) + (float64(b)-float64(a))*frac)
+		n++
+		l.pos += l.step
+	}
+	l.pos -= float64(len(in))
+	l.prev = in[len(in)-1]
+	return n
+}
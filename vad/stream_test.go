@@ -0,0 +1,130 @@
+//go:build cgo
+// +build cgo
+
+package vad
+
+import (
+	"testing"
+)
+
+func TestStream_SampleRates(t *testing.T) {
+	rates := map[int]int{
+		8000:  8000,
+		11025: 16000,
+		12000: 48000,
+		22050: 16000,
+		24000: 48000,
+		44100: 16000,
+		64000: 32000,
+		96000: 48000,
+	}
+	for rate, vadRate := range rates {
+		s, err := NewStream(rate, Aggressive)
+		if err != nil {
+			t.Fatalf("%d: %v", rate, err)
+		}
+		if s.vadRate != vadRate {
+			t.Errorf("%d: got VAD rate %d, expected %d", rate, s.vadRate, vadRate)
+		}
+
+		// 2.5ms of silence at a time.
+		frame := make([]int16, rate/400)
+		for i := 0; i < 40; i++ {
+			r, err := s.Process(frame)
+			if err != nil {
+				t.Fatalf("%d: %v", rate, err)
+			}
+			if r != NonActive {
+				t.Fatalf("%d: silence reported as %d", rate, r)
+			}
+		}
+		_ = s.Close()
+	}
+
+	if _, err := NewStream(0, Aggressive); err != ErrSampleRate {
+		t.Errorf("Expected ErrSampleRate, got %v", err)
+	}
+	if _, err := NewStream(16000, Mode(10)); err != ErrMode {
+		t.Errorf("Expected ErrMode, got %v", err)
+	}
+}
+
+func TestStream_Rechunk(t *testing.T) {
+	frames, err := load("recording.wav", 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := New()
+	defer ref.Close()
+	ref.SetMode(Aggressive)
+	ref.SetSampleRate(16000)
+
+	s, err := NewStream(16000, Aggressive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	active := 0
+	for i, frame := range frames {
+		expected := ref.Process(frame)
+		if expected == Active {
+			active++
+		}
+		// Feed 2.5ms at a time, the last one completes the chunk.
+		var r Result
+		for j := 0; j < len(frame); j += 40 {
+			r, err = s.Process(frame[j : j+40])
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if r != expected {
+			t.Fatalf("Frame %d: got %d, expected %d", i, r, expected)
+		}
+	}
+	if active == 0 {
+		t.Fatal("Recording has no speech")
+	}
+}
+
+func TestStream_Resampled(t *testing.T) {
+	frames, err := load("recording.wav", 480)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStream(24000, Aggressive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	up := &linearResampler{step: 16000.0 / 24000}
+	active := 0
+	for _, frame := range frames {
+		out := make([]int16, len(frame)*3/2+2)
+		n := up.process(frame, out)
+		r, err := s.Process(out[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == Active {
+			active++
+		}
+	}
+	if active == 0 {
+		t.Errorf("No speech detected at 24Khz")
+	}
+}
+
+func TestProcess_Invalid(t *testing.T) {
+	if Invalid == Active {
+		t.Fatal("Invalid must not equal Active")
+	}
+	v := New()
+	defer v.Close()
+	if r := v.Process(make([]int16, 100)); r != Invalid {
+		t.Errorf("Expected Invalid for a 100 sample frame, got %d", r)
+	}
+}
@@ -0,0 +1,36 @@
+package vad
+
+type Result int
+
+const (
+	Active    = Result(1)
+	NonActive = Result(0)
+	// Returned for an invalid frame length or a closed detector.
+	Invalid = Result(-1)
+)
+
+type Mode int
+
+const (
+	Quality        = Mode(0)
+	LowBitrate     = Mode(1)
+	Aggressive     = Mode(2)
+	VeryAggressive = Mode(3)
+)
+
+// Detector is a voice activity detector. VAD is backed by libfvad and needs
+// cgo, Energy is pure Go.
+type Detector interface {
+	// Process returns the decision for a mono frame. The accepted frame sizes
+	// depend on the implementation.
+	Process(frame []int16) Result
+
+	SetMode(mode Mode) bool
+
+	SetSampleRate(sampleRate int32) bool
+
+	// Reset clears all state.
+	Reset()
+
+	Close() error
+}
@@ -0,0 +1,322 @@
+package vad
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-audio/audio"
+	"github.com/go-audio/riff"
+	"github.com/go-audio/wav"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type FrameSize int
+
+func (f FrameSize) Duration() time.Duration {
+	switch f {
+	case 160:
+		return time.Millisecond * 10
+	case 320:
+		return time.Millisecond * 20
+	case 480:
+		return time.Millisecond * 30
+	}
+	return time.Millisecond * 10
+}
+
+func load(filename string, frameSize FrameSize) ([][]int16, error) {
+	file, err := OpenWav("recording.wav", frameSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var data [][]int16
+
+	for {
+		frame, err := file.Next()
+		if err != nil {
+			if len(frame) > 0 {
+				data = append(data, frame)
+			}
+			break
+		}
+		data = append(data, frame)
+	}
+
+	return data, nil
+}
+
+type FrameReader struct {
+	reader PCMReader
+
+	frameSize int
+	frames    [][]int16
+	overflow  []int16
+	eof       bool
+}
+
+func OpenWav(filename string, frameSize FrameSize) (*FrameReader, error) {
+	file, err := os.Open("recording.wav")
+	if err != nil {
+		return nil, err
+	}
+
+	switch frameSize {
+	case 80:
+	case 160:
+	case 320:
+	default:
+		frameSize = 160
+	}
+
+	reader := NewWavToPCM16(file)
+	return &FrameReader{
+		reader:    reader,
+		frameSize: int(frameSize),
+		frames:    nil,
+		overflow:  nil,
+		eof:       false,
+	}, nil
+}
+
+func (f *FrameReader) FrameDuration() time.Duration {
+	return FrameSize(f.frameSize).Duration()
+}
+
+func (f *FrameReader) Next() ([]int16, error) {
+	for {
+		if len(f.frames) > 0 {
+			first := f.frames[0]
+			f.frames = f.frames[1:]
+			return first, nil
+		}
+
+		if f.eof {
+			return nil, io.EOF
+		}
+
+		var err error
+		f.overflow, err = f.reader.Read(f.overflow)
+
+		if err == io.EOF {
+			f.eof = true
+		}
+
+		if len(f.overflow) < f.frameSize {
+			continue
+		}
+
+		split := len(f.overflow) / f.frameSize
+		for i := 0; i < split; i++ {
+			f.frames = append(f.frames, f.overflow[i*f.frameSize:i*f.frameSize+f.frameSize])
+		}
+		f.overflow = f.overflow[split*f.frameSize:]
+	}
+}
+
+var (
+	ErrPCMChunkNotFound = errors.New("PCM chunk not found")
+	ErrPCMNot16Bit      = errors.New("PCM is not 16-bit")
+	ErrClosed           = errors.New("closed")
+)
+
+type SeekReader struct {
+	io.ReadSeeker
+	io.Closer
+	reader io.ReadCloser
+	at     uint64
+	closed bool
+	mu     sync.Mutex
+}
+
+func NewSeekReader(reader io.ReadCloser) *SeekReader {
+	return &SeekReader{
+		reader: reader,
+		at:     0,
+	}
+}
+
+func (s *SeekReader) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+
+	err := s.reader.Close()
+	s.closed = true
+	s.mu.Unlock()
+
+	return err
+}
+
+func (s *SeekReader) Read(b []byte) (int, error) {
+	n, err := s.reader.Read(b)
+	if err != nil {
+		return n, err
+	}
+
+	s.at += uint64(n)
+	return n, err
+}
+
+func (s *SeekReader) Seek(offset int64, whence int) (int64, error) {
+	i := int64(0)
+	var buf []byte
+	for int64(s.at) < offset {
+		if buf == nil {
+			buf = make([]byte, 8192)
+		}
+		next := offset - int64(s.at)
+		if next < int64(len(buf)) {
+			buf = buf[:next]
+		}
+
+		n, err := s.reader.Read(buf)
+		s.at += uint64(n)
+		i += int64(n)
+		if err != nil {
+			return i, err
+		}
+	}
+	return i, nil
+}
+
+type WavToPCM struct {
+	reader   io.ReadCloser
+	decoder  *wav.Decoder
+	didFirst bool
+	closed   bool
+
+	err error
+
+	bytesPerSample uint16
+	sampleBufData  []byte
+	decodeFn       func(io.Reader, []byte) (int16, error)
+
+	mu sync.Mutex
+}
+
+func NewWavToPCM16(reader io.ReadCloser) *WavToPCM {
+	r := NewSeekReader(reader)
+	return &WavToPCM{
+		reader:         r,
+		decoder:        wav.NewDecoder(r),
+		didFirst:       false,
+		bytesPerSample: 0,
+		sampleBufData:  nil,
+		decodeFn:       nil,
+	}
+}
+
+func (w *WavToPCM) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		err := w.err
+		w.mu.Unlock()
+		return err
+	}
+	w.closed = true
+	reader := w.reader
+	w.reader = nil
+	err := w.err
+	w.mu.Unlock()
+
+	_ = reader.Close()
+	return err
+}
+
+func (w *WavToPCM) IsClosed() bool {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	return closed
+}
+
+type PCMReader interface {
+	Read(buffer []int16) ([]int16, error)
+}
+
+func (w *WavToPCM) Read(buffer []int16) ([]int16, error) {
+	var chunk *riff.Chunk
+	var err error
+	if !w.didFirst {
+		w.didFirst = true
+		if !w.decoder.WasPCMAccessed() {
+			err := w.decoder.FwdToPCM()
+			if err != nil {
+				return nil, w.decoder.Err()
+			}
+		}
+		chunk = w.decoder.PCMChunk
+		if chunk == nil {
+			return nil, ErrPCMChunkNotFound
+		}
+
+		w.bytesPerSample = (w.decoder.BitDepth-1)/8 + 1
+		w.sampleBufData = make([]byte, w.bytesPerSample)
+
+		if w.decoder.BitDepth != 16 {
+
+			return nil, ErrPCMNot16Bit
+		}
+
+		w.decodeFn, err = sampleDecodeFunc(int(w.decoder.BitDepth))
+	} else {
+		chunk, err = w.decoder.NextChunk()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var next int16
+	for {
+		next, err = w.decodeFn(chunk, w.sampleBufData)
+		if err != nil {
+			break
+		}
+
+		buffer = append(buffer, next)
+	}
+
+	return buffer, err
+}
+
+// sampleDecodeFunc returns a function that can be used to convert
+// a byte range into an int value based on the amount of bits used per sample.
+// Note that 8bit samples are unsigned, all other values are signed.
+func sampleDecodeFunc(bitsPerSample int) (func(io.Reader, []byte) (int16, error), error) {
+	// NOTE: WAV PCM data is stored using little-endian
+	switch bitsPerSample {
+	case 8:
+		// 8bit values are unsigned
+		return func(r io.Reader, buf []byte) (int16, error) {
+			_, err := r.Read(buf[:1])
+			return int16(buf[0]), err
+		}, nil
+	case 16:
+		return func(r io.Reader, buf []byte) (int16, error) {
+			_, err := r.Read(buf[:2])
+			return int16(binary.LittleEndian.Uint16(buf[:2])), err
+		}, nil
+	case 24:
+		// -34,359,738,367 (0x7FFFFF) to 34,359,738,368	(0x800000)
+		return func(r io.Reader, buf []byte) (int16, error) {
+			_, err := r.Read(buf[:3])
+			if err != nil {
+				return 0, err
+			}
+			return int16(audio.Int24LETo32(buf[:3])), nil
+		}, nil
+	case 32:
+		return func(r io.Reader, buf []byte) (int16, error) {
+			_, err := r.Read(buf[:4])
+			return int16(int32(binary.LittleEndian.Uint32(buf[:4]))), err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unhandled byte depth:%d", bitsPerSample)
+	}
+}